@@ -0,0 +1,92 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"os"
+
+	"github.com/derv-dice/go-web-server/audit"
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/jobs"
+	"github.com/derv-dice/go-web-server/middleware"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/server"
+)
+
+// newAdminServer - Служебный сервер на отдельном адресе из секции admin. Его маршруты не регистрируются
+// в публичном маршрутизаторе, поэтому профилирование недоступно снаружи, пока admin.host - локальный адрес.
+// Состояние процесса в /debug/stats включает число открытых соединений основного сервера srv,
+// /debug/audit/verify проверяет цепочку записей журнала auditLog, если он открыт, /debug/jobs показывает
+// состояние очереди фоновых задач q, POST /debug/cache/purge удаляет ответы из кэша cache.responses cache.
+// Возвращает nil, если служебный адрес выключен
+func newAdminServer(cfg config.Config, srv *server.Server, auditLog *audit.Log, q *jobs.Queue, cache middleware.ResponseCache) (*server.Server, error) {
+	if !cfg.Admin.Enabled {
+		return nil, nil
+	}
+
+	mux := http.NewServeMux()
+	if cfg.Admin.Pprof {
+		// Те же обработчики, что net/http/pprof регистрирует в http.DefaultServeMux
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	if cfg.Admin.Stats {
+		mux.HandleFunc("/debug/stats", statsHandler(srv))
+	}
+	if auditLog != nil {
+		mux.HandleFunc("/debug/audit/verify", auditVerifyHandler(auditLog, cfg.Audit.Key))
+	}
+	mux.HandleFunc("/debug/jobs", jobsHandler(q))
+	if cfg.Cache.Responses.Enabled {
+		mux.HandleFunc("/debug/cache/purge", cachePurgeHandler(cache))
+	}
+
+	return server.New(cfg.Admin.Server(cfg.Server.ShutdownTimeout), mux)
+}
+
+// cachePurgeHandler - Обработчик POST /debug/cache/purge?prefix=/v1/ служебного адреса: удаление сохраненных
+// ответов на запросы к путям с префиксом prefix, без prefix - всех. Следующие запросы к ним получат X-Cache: MISS
+func cachePurgeHandler(cache middleware.ResponseCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			response.JSON(w, http.StatusMethodNotAllowed, response.Body{Error: "ожидается POST"})
+			return
+		}
+
+		prefix := r.URL.Query().Get("prefix")
+		n, err := cache.Purge(r.Context(), prefix)
+		data := map[string]any{"prefix": prefix, "purged": n}
+		if err != nil {
+			response.JSON(w, http.StatusServiceUnavailable, response.Body{Data: data, Error: err.Error()})
+			return
+		}
+		slog.Info("cache: responses purged", "prefix", prefix, "purged", n)
+		response.JSON(w, http.StatusOK, response.Body{Data: data})
+	}
+}
+
+// auditVerifyHandler - Обработчик GET /debug/audit/verify служебного адреса: проверка цепочки хешей журнала действий.
+// Если цепочка нарушена, возвращается 409 с номером строки первой измененной записи
+func auditVerifyHandler(l *audit.Log, key string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := os.Open(l.Path())
+		if err != nil {
+			response.JSON(w, http.StatusInternalServerError, response.Body{Error: err.Error()})
+			return
+		}
+		defer f.Close()
+
+		n, err := audit.Verify(f, key)
+		data := map[string]any{"path": l.Path(), "verified": n, "ok": err == nil}
+		if err != nil {
+			response.JSON(w, http.StatusConflict, response.Body{Data: data, Error: err.Error()})
+			return
+		}
+		response.JSON(w, http.StatusOK, response.Body{Data: data})
+	}
+}
@@ -0,0 +1,128 @@
+// Package apperr - Ошибки обработчиков с машиночитаемым кодом, по которому выбирается HTTP статус ответа
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Code - Машиночитаемый код ошибки. Передается клиенту в поле code вместе с текстом ошибки
+type Code string
+
+// Коды ошибок
+const (
+	BadRequest           Code = "BAD_REQUEST"
+	Validation           Code = "VALIDATION_FAILED"
+	Unauthorized         Code = "UNAUTHORIZED"
+	Forbidden            Code = "FORBIDDEN"
+	NotFound             Code = "NOT_FOUND"
+	MethodNotAllowed     Code = "METHOD_NOT_ALLOWED"
+	NotAcceptable        Code = "NOT_ACCEPTABLE"
+	Conflict             Code = "CONFLICT"
+	Gone                 Code = "GONE"
+	PreconditionFailed   Code = "PRECONDITION_FAILED"
+	PayloadTooLarge      Code = "PAYLOAD_TOO_LARGE"
+	UnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE"
+	RateLimited          Code = "RATE_LIMITED"
+	Internal             Code = "INTERNAL"
+	BadGateway           Code = "BAD_GATEWAY"
+	Unavailable          Code = "UNAVAILABLE"
+	Timeout              Code = "TIMEOUT"
+)
+
+// statuses - HTTP статус для каждого кода. У каждого статуса не больше одного кода, поэтому CodeFor однозначен
+var statuses = map[Code]int{
+	BadRequest:           http.StatusBadRequest,
+	Validation:           http.StatusUnprocessableEntity,
+	Unauthorized:         http.StatusUnauthorized,
+	Forbidden:            http.StatusForbidden,
+	NotFound:             http.StatusNotFound,
+	MethodNotAllowed:     http.StatusMethodNotAllowed,
+	NotAcceptable:        http.StatusNotAcceptable,
+	Conflict:             http.StatusConflict,
+	Gone:                 http.StatusGone,
+	PreconditionFailed:   http.StatusPreconditionFailed,
+	PayloadTooLarge:      http.StatusRequestEntityTooLarge,
+	UnsupportedMediaType: http.StatusUnsupportedMediaType,
+	RateLimited:          http.StatusTooManyRequests,
+	Internal:             http.StatusInternalServerError,
+	BadGateway:           http.StatusBadGateway,
+	Unavailable:          http.StatusServiceUnavailable,
+	Timeout:              http.StatusGatewayTimeout,
+}
+
+// Status - HTTP статус ответа для кода. Для неизвестного кода - 500
+func (c Code) Status() int {
+	if s, ok := statuses[c]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// CodeFor - Код ошибки для HTTP статуса status: для ответов, которые формируются без Error, например response.Error.
+// Для статуса без своего кода - BAD_REQUEST для 4xx и INTERNAL для 5xx
+func CodeFor(status int) Code {
+	for code, s := range statuses {
+		if s == status {
+			return code
+		}
+	}
+	if status >= 400 && status < 500 {
+		return BadRequest
+	}
+	return Internal
+}
+
+// Error - Ошибка обработчика: код, текст для клиента и, при необходимости, исходная ошибка для логов
+type Error struct {
+	Code    Code
+	Message string // Текст ошибки для клиента
+	Data    any    // Подробности для клиента, например список неверных полей
+	Err     error  // Исходная ошибка. Клиенту не передается
+}
+
+// New - Ошибка с кодом code и текстом msg для клиента
+func New(code Code, msg string) *Error {
+	return &Error{Code: code, Message: msg}
+}
+
+// Errorf - Ошибка с кодом code и текстом для клиента по формату fmt
+func Errorf(code Code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap - Ошибка с кодом code и текстом msg для клиента поверх исходной ошибки err, которая попадет только в логи
+func Wrap(err error, code Code, msg string) *Error {
+	return &Error{Code: code, Message: msg, Err: err}
+}
+
+// Error - Текст ошибки вместе с исходной ошибкой
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Unwrap - Исходная ошибка
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithData - Копия ошибки с подробностями data для клиента
+func (e *Error) WithData(data any) *Error {
+	c := *e
+	c.Data = data
+	return &c
+}
+
+// As - *Error из цепочки ошибок err. Для других ошибок - Internal без текста исходной ошибки,
+// чтобы не раскрывать клиенту внутренние детали сервера
+func As(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return Wrap(err, Internal, "внутренняя ошибка сервера"), false
+}
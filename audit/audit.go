@@ -0,0 +1,213 @@
+// Package audit - Журнал действий клиентов, отдельный от логов сервера.
+//
+// Каждая запись - строка JSON с хешем, в который входят поля записи и хеш предыдущей записи.
+// Изменение, удаление или вставка записи нарушает цепочку, что обнаруживает Verify.
+// С ключом (audit.key) хеш - HMAC-SHA256, и подделать цепочку без ключа нельзя даже целиком
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Результаты действий
+const (
+	OutcomeSuccess = "success" // Статус ответа меньше 400
+	OutcomeDenied  = "denied"  // 401 или 403
+	OutcomeFailure = "failure" // Остальные ошибки
+)
+
+// Entry - Запись журнала
+type Entry struct {
+	Seq        uint64    `json:"seq"`         // Номер записи, начиная с 1
+	Time       time.Time `json:"time"`        // Время записи, UTC
+	RequestID  string    `json:"request_id"`  // Идентификатор запроса
+	Actor      string    `json:"actor"`       // Аутентифицированный клиент или "-"
+	AuthMethod string    `json:"auth_method"` // Способ аутентификации: basic, api_key, jwt и т.д.
+	IP         string    `json:"ip"`          // IP адрес клиента
+	Action     string    `json:"action"`      // Действие: метод и шаблон маршрута, например DELETE /files/{id}
+	Resource   string    `json:"resource"`    // Путь запроса, над которым выполнялось действие
+	Outcome    string    `json:"outcome"`     // success, denied или failure
+	Status     int       `json:"status"`      // Статус код ответа
+	Prev       string    `json:"prev"`        // Хеш предыдущей записи, пусто для первой
+	Hash       string    `json:"hash"`        // Хеш записи вместе с Prev
+}
+
+// Outcome - Результат действия по статус коду ответа
+func Outcome(status int) string {
+	switch {
+	case status < 400:
+		return OutcomeSuccess
+	case status == 401 || status == 403:
+		return OutcomeDenied
+	}
+	return OutcomeFailure
+}
+
+// Log - Файл журнала. Безопасен для использования из нескольких горутин
+type Log struct {
+	key []byte
+
+	mu   sync.Mutex
+	f    *os.File
+	seq  uint64
+	prev string
+}
+
+// tailSize - Сколько байт с конца файла читается при открытии, чтобы продолжить цепочку с последней записи
+const tailSize = 64 << 10
+
+// Open - Открытие журнала path на дозапись. Цепочка продолжается с последней записи в файле.
+// key - ключ HMAC, пустой - хеши SHA-256 без ключа
+func Open(path, key string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{key: []byte(key), f: f}
+	last, err := lastEntry(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if last != nil {
+		l.seq, l.prev = last.Seq, last.Hash
+	}
+	return l, nil
+}
+
+// lastEntry - Последняя запись файла f, nil для пустого файла
+func lastEntry(f *os.File) (*Entry, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, nil
+	}
+
+	offset := max(info.Size()-tailSize, 0)
+	buf := make([]byte, info.Size()-offset)
+	if _, err = f.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	buf = bytes.TrimRight(buf, "\n")
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		buf = buf[i+1:]
+	}
+
+	var e Entry
+	if err = json.Unmarshal(buf, &e); err != nil {
+		return nil, fmt.Errorf("последняя запись журнала не разбирается: %w", err)
+	}
+	return &e, nil
+}
+
+// Record - Добавление записи e. Seq, Prev и Hash заполняются журналом, пустое время - текущим
+func (l *Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Seq, e.Prev = l.seq+1, l.prev
+	e.Hash = sum(l.key, e)
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err = l.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	l.seq, l.prev = e.Seq, e.Hash
+	return nil
+}
+
+// Path - Путь к файлу журнала
+func (l *Log) Path() string {
+	return l.f.Name()
+}
+
+// Close - Закрытие файла журнала
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// sum - Хеш записи e: JSON записи без поля hash, в который входит хеш предыдущей записи
+func sum(key []byte, e Entry) string {
+	e.Hash = ""
+	data, _ := json.Marshal(e) // Entry всегда сериализуется без ошибок
+
+	var h hash.Hash
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ErrBroken - Цепочка записей журнала нарушена
+var ErrBroken = errors.New("audit: цепочка записей нарушена")
+
+// Verify - Проверка цепочки записей журнала из r с ключом key. Возвращает количество проверенных записей
+// и ErrBroken с номером строки, если запись изменена, удалена или вставлена. Удаление последних записей
+// цепочка не выявляет: для этого количество записей сверяется с сохраненным отдельно
+func Verify(r io.Reader, key string) (int, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), tailSize)
+
+	var (
+		n    int
+		prev string
+		seq  uint64
+	)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		n++
+
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return n - 1, fmt.Errorf("%w: строка %d: %v", ErrBroken, n, err)
+		}
+		// Первая запись начинает цепочку: удаление записей из начала файла тоже нарушает ее
+		if e.Prev != prev || e.Seq != seq+1 {
+			return n - 1, fmt.Errorf("%w: строка %d: запись не следует за предыдущей", ErrBroken, n)
+		}
+		if !hmac.Equal([]byte(sum([]byte(key), e)), []byte(e.Hash)) {
+			return n - 1, fmt.Errorf("%w: строка %d: хеш не совпадает", ErrBroken, n)
+		}
+		prev, seq = e.Hash, e.Seq
+	}
+	if err := sc.Err(); err != nil {
+		return n, err
+	}
+	return n, nil
+}
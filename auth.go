@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/derv-dice/go-web-server/auth"
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/redis"
+	"github.com/derv-dice/go-web-server/router"
+	"github.com/derv-dice/go-web-server/session"
+)
+
+// basicAuth - Middleware Basic аутентификации по настройкам auth.basic. Пока она выключена, запросы проходят без проверки.
+//
+// Пользователи читаются из htpasswd_file и users при запуске и заново после перечитывания конфигурации.
+// Если новый список прочитать не удалось, продолжает действовать прежний
+func basicAuth(store *config.Store) (router.Middleware, error) {
+	var users atomic.Pointer[auth.Users]
+
+	u, err := loadUsers(store.Current().Auth.Basic)
+	if err != nil {
+		return nil, fmt.Errorf("auth.basic: %w", err)
+	}
+	users.Store(&u)
+
+	store.OnReload(func(_, cur *config.Config) {
+		u, err := loadUsers(cur.Auth.Basic)
+		if err != nil {
+			slog.Error("config: reload: auth.basic", "error", err)
+			return
+		}
+		users.Store(&u)
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := store.Current().Auth.Basic
+			if !cfg.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+			auth.Basic(cfg.Realm, *users.Load(), logins)(next).ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// logins - Неудачные попытки входа по паролю, общие для всех маршрутизаторов, см. newLoginThrottle
+var logins *auth.LoginThrottle
+
+// newLoginThrottle - Защита входа от подбора паролей по настройкам auth.login_throttle.
+// Пороги обновляются после перечитывания конфигурации, накопленные блокировки сохраняются
+func newLoginThrottle(store *config.Store) *auth.LoginThrottle {
+	policy := func(cfg config.LoginThrottle) auth.ThrottlePolicy {
+		if !cfg.Enabled {
+			return auth.ThrottlePolicy{} // Без порогов вход не блокируется
+		}
+		return auth.ThrottlePolicy{
+			AccountThreshold: cfg.AccountThreshold,
+			IPThreshold:      cfg.IPThreshold,
+			BaseDelay:        cfg.BaseDelay.D(),
+			MaxDelay:         cfg.MaxDelay.D(),
+			Window:           cfg.Window.D(),
+			MaxEntries:       cfg.MaxEntries,
+		}
+	}
+
+	t := auth.NewLoginThrottle(policy(store.Current().Auth.LoginThrottle))
+	store.OnReload(func(_, cur *config.Config) { t.Update(policy(cur.Auth.LoginThrottle)) })
+	return t
+}
+
+// loadUsers - Пользователи из файла htpasswd и из списка users конфигурации
+func loadUsers(cfg config.BasicAuth) (auth.Users, error) {
+	if !cfg.Enabled {
+		return auth.Users{}, nil
+	}
+
+	users := auth.Users{}
+	if cfg.HtpasswdFile != "" {
+		var err error
+		if users, err = auth.LoadHtpasswd(cfg.HtpasswdFile); err != nil {
+			return nil, err
+		}
+	}
+
+	extra := auth.Users(cfg.Users)
+	if err := extra.Check(); err != nil {
+		return nil, fmt.Errorf("users: %w", err)
+	}
+	maps.Copy(users, extra)
+
+	return users, nil
+}
+
+// newPasswordAuth - Регистрация и вход по паролю по настройкам auth.password.
+// Токены подписываются секретом auth.jwt, поэтому их принимают маршруты API
+func newPasswordAuth(cfg *config.Config) (*auth.PasswordAuth, error) {
+	p := cfg.Auth.Password
+
+	var accounts auth.AccountStore = auth.NewMemoryAccounts()
+	if p.Store == config.AccountStoreFile {
+		var err error
+		if accounts, err = auth.OpenFileAccounts(p.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	pc := auth.PasswordConfig{
+		Accounts:          accounts,
+		Throttle:          logins,
+		Registration:      p.Registration,
+		MinPasswordLength: p.MinPasswordLength,
+		DefaultRoles:      p.DefaultRoles,
+		Pool:              workPool,
+		SessionTTL:        p.SessionTTL.D(),
+	}
+	if p.Issue == config.PasswordIssueJWT {
+		pc.JWTSecret = []byte(cfg.Auth.JWT.Secret)
+		pc.JWTIssuer = cfg.Auth.JWT.Issuer
+		pc.JWTAudience = cfg.Auth.JWT.Audience
+		pc.TokenTTL = p.TokenTTL.D()
+	}
+	if p.Refresh.Enabled {
+		pc.Refresh = auth.NewMemoryRefreshStore()
+		pc.RefreshTTL = p.Refresh.TTL.D()
+	}
+	return auth.NewPasswordAuth(pc)
+}
+
+// clientAuth - Middleware аутентификации клиентов API по настройкам auth.api_key и auth.jwt. Способы взаимозаменяемы:
+// если включены оба, запрос проходит с API ключом или с JWT (см. auth.APIKeyOrJWT), а не с обоими сразу.
+// Пока оба выключены, запросы проходят без проверки. Список ключей и ключ проверки подписи JWT обновляются
+// после перечитывания конфигурации
+func clientAuth(store *config.Store) (router.Middleware, error) {
+	var (
+		keys     atomic.Pointer[auth.StaticKeys]
+		verifier atomic.Pointer[auth.JWTVerifier]
+	)
+
+	k, err := auth.NewStaticKeys(store.Current().Auth.APIKey.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("auth.api_key: %w", err)
+	}
+	keys.Store(&k)
+
+	v, err := newJWTVerifier(store.Current().Auth.JWT)
+	if err != nil {
+		return nil, fmt.Errorf("auth.jwt: %w", err)
+	}
+	verifier.Store(v)
+
+	store.OnReload(func(_, cur *config.Config) {
+		// Ошибка здесь невозможна: хэши ключей уже проверены при загрузке конфигурации
+		k, _ := auth.NewStaticKeys(cur.Auth.APIKey.Keys)
+		keys.Store(&k)
+
+		v, err := newJWTVerifier(cur.Auth.JWT)
+		if err != nil {
+			slog.Error("config: reload: auth.jwt", "error", err)
+			return
+		}
+		verifier.Store(v)
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := store.Current().Auth
+			v := verifier.Load()
+			byKey, byToken := cfg.APIKey.Enabled, cfg.JWT.Enabled && v != nil
+
+			switch {
+			case byKey && byToken:
+				auth.APIKeyOrJWT(*keys.Load(), v)(next).ServeHTTP(w, r)
+			case byKey:
+				auth.APIKey(*keys.Load())(next).ServeHTTP(w, r)
+			case byToken:
+				auth.JWT(v)(next).ServeHTTP(w, r)
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}, nil
+}
+
+// nonces - Использованные X-Nonce, общие для всех маршрутизаторов: повтор запроса на другой виртуальный хост
+// тоже отклоняется, см. newNonces
+var nonces *auth.MemoryNonces
+
+// newNonces - Хранилище X-Nonce размером auth.replay.max_entries. Размер обновляется после перечитывания
+// конфигурации, запомненные X-Nonce сохраняются
+func newNonces(store *config.Store) *auth.MemoryNonces {
+	n := auth.NewMemoryNonces(store.Current().Auth.Replay.MaxEntries)
+	store.OnReload(func(_, cur *config.Config) { n.SetLimit(cur.Auth.Replay.MaxEntries) })
+	return n
+}
+
+// replayGuard - Middleware защиты от повтора запросов по настройкам auth.replay. Пока она выключена,
+// запросы проходят без проверки. Использованные X-Nonce не забываются при перечитывании конфигурации
+func replayGuard(store *config.Store) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := store.Current().Auth.Replay
+			if !cfg.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			auth.Replay(nonces, cfg.MaxAge.D())(next).ServeHTTP(w, r)
+		})
+	}
+}
+
+// signatureAuth - Middleware проверки подписи HMAC по настройкам auth.signature. Пока она выключена, запросы проходят без проверки.
+// Секреты клиентов обновляются после перечитывания конфигурации, использованные подписи при этом не забываются
+func signatureAuth(store *config.Store) (router.Middleware, error) {
+	var verifier atomic.Pointer[auth.SignatureVerifier]
+
+	apply := func(cfg config.SignatureAuth) error {
+		if !cfg.Enabled {
+			return nil
+		}
+		if v := verifier.Load(); v != nil {
+			return v.Update(cfg.Clients, cfg.MaxAge.D())
+		}
+
+		v, err := auth.NewSignatureVerifier(cfg.Clients, cfg.MaxAge.D())
+		if err != nil {
+			return err
+		}
+		verifier.Store(v)
+		return nil
+	}
+
+	if err := apply(store.Current().Auth.Signature); err != nil {
+		return nil, fmt.Errorf("auth.signature: %w", err)
+	}
+
+	store.OnReload(func(_, cur *config.Config) {
+		if err := apply(cur.Auth.Signature); err != nil {
+			slog.Error("config: reload: auth.signature", "error", err)
+		}
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v := verifier.Load()
+			if !store.Current().Auth.Signature.Enabled || v == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			auth.Signature(v)(next).ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// newJWTVerifier - Проверка токенов по настройкам cfg. nil, если аутентификация по JWT выключена
+func newJWTVerifier(cfg config.JWTAuth) (*auth.JWTVerifier, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var key any = []byte(cfg.Secret)
+	if cfg.PublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = auth.ParsePublicKeyPEM(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.PublicKeyFile, err)
+		}
+	}
+
+	return auth.NewJWTVerifier(key, cfg.Issuer, cfg.Audience, cfg.Leeway.D())
+}
+
+// newSessions - Middleware сессий в хранилище store по настройкам session. Если сессии выключены, запросы проходят без изменений
+func newSessions(cfg config.Session, store session.Store) router.Middleware {
+	if !cfg.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	return session.Middleware(store, session.Options{
+		CookieName: cfg.CookieName,
+		TTL:        cfg.TTL.D(),
+		Secure:     cfg.Secure,
+	})
+}
+
+// newSessionStore - Хранилище сессий по настройке session.store. nil, если сессии выключены
+func newSessionStore(cfg config.Session, shared *redis.Client) (session.Store, error) {
+	switch {
+	case !cfg.Enabled:
+		return nil, nil
+	case cfg.Store == "cookie":
+		return session.NewCookieStore([]byte(cfg.Secret))
+	case cfg.Store == config.StateRedis:
+		return shared.Sessions, nil
+	}
+	return session.NewMemoryStore(), nil
+}
+
+// access - Проверка разрешений клиента по настройкам auth.rbac. Правила обновляются после перечитывания конфигурации
+type access struct {
+	store *config.Store
+	rbac  *auth.RBAC
+}
+
+// newAccess - Проверка разрешений по текущей конфигурации store
+func newAccess(store *config.Store) *access {
+	policy := func(cfg config.RBAC) auth.Policy {
+		return auth.Policy{Roles: cfg.Roles, Assignments: cfg.Assignments}
+	}
+
+	a := &access{store: store, rbac: auth.NewRBAC(policy(store.Current().Auth.RBAC))}
+	store.OnReload(func(_, cur *config.Config) { a.rbac.Update(policy(cur.Auth.RBAC)) })
+	return a
+}
+
+// requirePermission - Middleware маршрута, требующий разрешений perms. Пока auth.rbac выключен, запросы проходят без проверки
+func (a *access) requirePermission(perms ...string) router.Middleware {
+	check := a.rbac.RequirePermission(perms...)
+
+	return func(next http.Handler) http.Handler {
+		checked := check(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !a.store.Current().Auth.RBAC.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			checked.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireScope - Middleware маршрута, требующий scope токена. Пока auth.jwt.require_scopes выключен,
+// запросы проходят без проверки; включенная проверка пропускает только клиентов с JWT
+func (a *access) requireScope(scopes ...string) router.Middleware {
+	check := auth.RequireScope(scopes...)
+
+	return func(next http.Handler) http.Handler {
+		checked := check(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg := a.store.Current().Auth.JWT; !cfg.Enabled || !cfg.RequireScopes {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			checked.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ошибки хранилища учетных записей
+var (
+	ErrAccountExists   = errors.New("учетная запись уже существует")
+	ErrAccountNotFound = errors.New("учетная запись не найдена")
+)
+
+// Account - Учетная запись пользователя для входа по паролю
+type Account struct {
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"password_hash"` // Хэш из HashPassword
+	Roles        []string  `json:"roles,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AccountStore - Хранилище учетных записей
+type AccountStore interface {
+	// Create - Сохранение новой учетной записи. ErrAccountExists, если имя уже занято
+	Create(ctx context.Context, a Account) error
+	// Account - Учетная запись по имени. ErrAccountNotFound, если ее нет
+	Account(ctx context.Context, name string) (Account, error)
+}
+
+// MemoryAccounts - AccountStore в памяти процесса. Учетные записи теряются при перезапуске
+type MemoryAccounts struct {
+	mu       sync.RWMutex
+	accounts map[string]Account
+}
+
+// NewMemoryAccounts - Пустое хранилище учетных записей в памяти
+func NewMemoryAccounts() *MemoryAccounts {
+	return &MemoryAccounts{accounts: map[string]Account{}}
+}
+
+func (m *MemoryAccounts) Create(_ context.Context, a Account) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.accounts[a.Name]; ok {
+		return ErrAccountExists
+	}
+	m.accounts[a.Name] = a
+	return nil
+}
+
+func (m *MemoryAccounts) Account(_ context.Context, name string) (Account, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	a, ok := m.accounts[name]
+	if !ok {
+		return Account{}, ErrAccountNotFound
+	}
+	return a, nil
+}
+
+// FileAccounts - AccountStore в JSON файле. Файл перезаписывается целиком при каждом изменении
+// через временный файл, поэтому сбой во время записи не портит его
+type FileAccounts struct {
+	path string
+	mem  *MemoryAccounts
+	mu   sync.Mutex // Последовательная запись файла
+}
+
+// OpenFileAccounts - Хранилище учетных записей в файле path. Если файла нет, он создается при первой регистрации
+func OpenFileAccounts(path string) (*FileAccounts, error) {
+	f := &FileAccounts{path: path, mem: NewMemoryAccounts()}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return f, nil
+	case err != nil:
+		return nil, err
+	}
+
+	var list []Account
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, a := range list {
+		f.mem.accounts[a.Name] = a
+	}
+	return f, nil
+}
+
+func (f *FileAccounts) Create(ctx context.Context, a Account) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.mem.Create(ctx, a); err != nil {
+		return err
+	}
+	if err := f.save(); err != nil {
+		// Учетная запись, которую не удалось сохранить, не должна действовать до перезапуска
+		f.mem.mu.Lock()
+		delete(f.mem.accounts, a.Name)
+		f.mem.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (f *FileAccounts) Account(ctx context.Context, name string) (Account, error) {
+	return f.mem.Account(ctx, name)
+}
+
+// save - Запись всех учетных записей в файл
+func (f *FileAccounts) save() error {
+	f.mem.mu.RLock()
+	list := make([]Account, 0, len(f.mem.accounts))
+	for _, a := range f.mem.accounts {
+		list = append(list, a)
+	}
+	f.mem.mu.RUnlock()
+	slices.SortFunc(list, func(a, b Account) int { return strings.Compare(a.Name, b.Name) })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// CreateTemp создает файл с правами 0600: хэши паролей не должны быть доступны другим пользователям
+	return os.Rename(tmp.Name(), f.path)
+}
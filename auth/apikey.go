@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// APIKeyHeader - Заголовок запроса с API ключом
+const APIKeyHeader = "X-API-Key"
+
+// KeyStore - Хранилище API ключей
+type KeyStore interface {
+	// Lookup - Имя владельца ключа key. ok == false, если ключ неизвестен
+	Lookup(key string) (name string, ok bool)
+}
+
+// APIKey - Middleware, пропускающий только запросы с известным API ключом в заголовке X-API-Key
+// или Authorization: Bearer. Имя ключа сохраняется в контексте запроса, см. IdentityFrom
+func APIKey(keys KeyStore) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := apiKey(r)
+			if key == "" {
+				unauthorized(w, `Bearer realm="api"`)
+				return
+			}
+
+			name, ok := keys.Lookup(key)
+			if !ok {
+				unauthorized(w, `Bearer realm="api", error="invalid_token"`)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), Identity{Name: name, Method: "api_key"})))
+		})
+	}
+}
+
+// APIKeyOrJWT - Middleware для маршрутов, где допустим любой из двух способов: запрос проходит с известным
+// API ключом или с действительным JWT, второй при этом не нужен. Ключ в X-API-Key проверяется только как API ключ.
+// Значение Authorization: Bearer сначала проверяется как JWT, а если токен не прошел проверку - как API ключ.
+// Клиент сохраняется в контексте запроса так же, как в APIKey и JWT
+func APIKeyOrJWT(keys KeyStore, v *JWTVerifier) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		byKey := APIKey(keys)(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(APIKeyHeader) != "" {
+				byKey.ServeHTTP(w, r)
+				return
+			}
+
+			token := bearerToken(r)
+			if token == "" {
+				unauthorized(w, `Bearer realm="api"`)
+				return
+			}
+
+			claims, err := v.Verify(token)
+			if err == nil {
+				next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), claims)))
+				return
+			}
+			if name, ok := keys.Lookup(token); ok {
+				next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), Identity{Name: name, Method: "api_key"})))
+				return
+			}
+
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			response.Error(w, http.StatusUnauthorized, err.Error())
+		})
+	}
+}
+
+// apiKey - Ключ из заголовка X-API-Key или Authorization: Bearer
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	return bearerToken(r)
+}
+
+// bearerToken - Токен из заголовка Authorization: Bearer
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// StaticKeys - KeyStore с фиксированным списком ключей.
+// Сами ключи не хранятся: поиск идет по их хэшу SHA-256, поэтому утечка конфигурации не раскрывает ключи
+type StaticKeys map[[sha256.Size]byte]string
+
+// NewStaticKeys - Хранилище ключей из списка имя ключа - SHA-256 хэш ключа в hex (echo -n $KEY | sha256sum)
+func NewStaticKeys(hashes map[string]string) (StaticKeys, error) {
+	keys := make(StaticKeys, len(hashes))
+	for name, h := range hashes {
+		var sum [sha256.Size]byte
+		if n, err := hex.Decode(sum[:], []byte(h)); err != nil || n != sha256.Size || len(h) != hex.EncodedLen(sha256.Size) {
+			return nil, fmt.Errorf("ключ %s: ожидается SHA-256 хэш из 64 шестнадцатеричных символов", name)
+		}
+		keys[sum] = name
+	}
+	return keys, nil
+}
+
+func (k StaticKeys) Lookup(key string) (string, bool) {
+	name, ok := k[sha256.Sum256([]byte(key))]
+	return name, ok
+}
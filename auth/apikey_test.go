@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// staticKeys - Хранилище с ключом "key-1" клиента svc
+func staticKeys(t *testing.T) StaticKeys {
+	t.Helper()
+	keys, err := NewStaticKeys(map[string]string{
+		"svc": "be2974546978e3739e6d6da85c4be9f334ce32df2b9fd4b6ff1b55c0d57e9d44",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// whoami - Обработчик, отвечающий именем и способом аутентификации клиента
+var whoami = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	id, _ := IdentityFrom(r.Context())
+	w.Write([]byte(id.Name + " " + id.Method))
+})
+
+func TestAPIKeyOrJWT(t *testing.T) {
+	v, err := NewJWTVerifier(testSecret, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := signToken(t, "HS256", claimsWith(nil), hmacSigner(testSecret))
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		body    string
+	}{
+		{name: "только JWT", headers: map[string]string{"Authorization": "Bearer " + token}, status: http.StatusOK, body: "alice jwt"},
+		{name: "ключ в X-API-Key", headers: map[string]string{APIKeyHeader: "key-1"}, status: http.StatusOK, body: "svc api_key"},
+		{name: "ключ в Authorization", headers: map[string]string{"Authorization": "Bearer key-1"}, status: http.StatusOK, body: "svc api_key"},
+		{
+			// X-API-Key проверяется как ключ, даже если в Authorization действительный JWT
+			name:    "неизвестный ключ и JWT",
+			headers: map[string]string{APIKeyHeader: "key-2", "Authorization": "Bearer " + token},
+			status:  http.StatusUnauthorized,
+		},
+		{name: "неизвестный ключ", headers: map[string]string{"Authorization": "Bearer key-2"}, status: http.StatusUnauthorized},
+		{name: "недействительный JWT", headers: map[string]string{"Authorization": "Bearer " + tamper(token, 2)}, status: http.StatusUnauthorized},
+		{name: "без учетных данных", status: http.StatusUnauthorized},
+	}
+
+	h := APIKeyOrJWT(staticKeys(t), v)(whoami)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/hello", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("статус %d, ожидается %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == http.StatusOK && w.Body.String() != tt.body {
+				t.Fatalf("ответ %q, ожидается %q", w.Body.String(), tt.body)
+			}
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Fatalf("нет заголовка WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	h := APIKey(staticKeys(t))(whoami)
+
+	for header, status := range map[string]int{APIKeyHeader: http.StatusOK, "X-Other": http.StatusUnauthorized} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/hello", nil)
+		r.Header.Set(header, "key-1")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != status {
+			t.Fatalf("ключ в %s: статус %d, ожидается %d", header, w.Code, status)
+		}
+	}
+
+	if _, err := NewStaticKeys(map[string]string{"svc": "not-a-hash"}); err == nil {
+		t.Fatalf("принят хэш ключа не в hex")
+	}
+}
@@ -0,0 +1,69 @@
+// Package auth - Аутентификация клиентов в middleware маршрутизатора
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/derv-dice/go-web-server/response"
+)
+
+// Identity - Аутентифицированный клиент
+type Identity struct {
+	Name   string   // Имя пользователя или идентификатор ключа
+	Method string   // Способ аутентификации: basic, api_key и т.д.
+	Roles  []string // Роли из токена или сессии, см. RBAC
+}
+
+// identityKey - Ключ контекста для Identity
+type identityKey struct{}
+
+// slotKey - Ключ контекста для ячейки, в которую записывается Identity, см. Track
+type slotKey struct{}
+
+// WithIdentity - Контекст с аутентифицированным клиентом id
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	if slot, ok := ctx.Value(slotKey{}).(*atomic.Pointer[Identity]); ok {
+		slot.Store(&id)
+	}
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// Track - Запрос, после обработки которого можно узнать, кем аутентифицирован клиент.
+//
+// Аутентификация выполняется в middleware маршрута, то есть глубже, чем общий middleware (например, логирование):
+// тот видит только исходный контекст. Track дает ему способ получить Identity после вызова обработчика:
+//
+//	r, identity := auth.Track(r)
+//	next.ServeHTTP(w, r)
+//	id, ok := identity()
+//
+// Несколько middleware в одной цепочке получают Identity из общей ячейки
+func Track(r *http.Request) (*http.Request, func() (Identity, bool)) {
+	// Обработчик может выполняться в другой горутине (см. middleware.Timeout), поэтому запись атомарная
+	slot, ok := r.Context().Value(slotKey{}).(*atomic.Pointer[Identity])
+	if !ok {
+		slot = new(atomic.Pointer[Identity])
+		r = r.WithContext(context.WithValue(r.Context(), slotKey{}, slot))
+	}
+
+	return r, func() (Identity, bool) {
+		if id := slot.Load(); id != nil {
+			return *id, true
+		}
+		return Identity{}, false
+	}
+}
+
+// IdentityFrom - Аутентифицированный клиент из контекста ctx. ok == false, если запрос не прошел аутентификацию
+func IdentityFrom(ctx context.Context) (id Identity, ok bool) {
+	id, ok = ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// unauthorized - Ответ 401 с заголовком WWW-Authenticate challenge
+func unauthorized(w http.ResponseWriter, challenge string) {
+	w.Header().Set("WWW-Authenticate", challenge)
+	response.Error(w, http.StatusUnauthorized, "требуется авторизация")
+}
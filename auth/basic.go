@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/realip"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// Credentials - Источник учетных данных для Basic аутентификации
+type Credentials interface {
+	// Verify - Пароль password верен для пользователя user
+	Verify(user, password string) bool
+}
+
+// Basic - Middleware, пропускающий только запросы с верными учетными данными в заголовке Authorization: Basic.
+// Остальные клиенты получают 401 с предложением браузеру запросить пароль для области realm.
+// Если задан throttle, неудачные попытки учитываются в нем, а при блокировке клиент получает 429 без проверки пароля
+func Basic(realm string, creds Credentials, throttle *LoginThrottle) router.Middleware {
+	challenge := "Basic realm=" + strconv.Quote(realm) + `, charset="UTF-8"`
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, password, ok := r.BasicAuth()
+			if !ok {
+				unauthorized(w, challenge)
+				return
+			}
+
+			if throttle != nil {
+				if l, locked := throttle.Locked(user, realip.From(r)); locked {
+					TooManyAttempts(w, l)
+					return
+				}
+			}
+
+			if !creds.Verify(user, password) {
+				if throttle != nil {
+					if l, locked := throttle.Failed(user, realip.From(r)); locked {
+						logging.From(r.Context()).Warn("auth: login locked", "user", user, "scope", l.Scope, "retry_after", l.RetryAfter)
+					}
+				}
+				unauthorized(w, challenge)
+				return
+			}
+
+			if throttle != nil {
+				throttle.Succeeded(user)
+			}
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), Identity{Name: user, Method: "basic"})))
+		})
+	}
+}
@@ -0,0 +1,20 @@
+//go:build bcrypt
+
+package auth
+
+import "golang.org/x/crypto/bcrypt"
+
+func bcryptSupported() error {
+	return nil
+}
+
+// bcryptHash - bcrypt хэш пароля password
+func bcryptHash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	return string(hash), err
+}
+
+// bcryptMatch - Пароль password соответствует bcrypt хэшу hash
+func bcryptMatch(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
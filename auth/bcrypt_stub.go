@@ -0,0 +1,17 @@
+//go:build !bcrypt
+
+package auth
+
+import "errors"
+
+func bcryptSupported() error {
+	return errors.New("хэши bcrypt поддерживаются только при сборке с тегом bcrypt")
+}
+
+func bcryptHash(string) (string, error) {
+	return "", bcryptSupported()
+}
+
+func bcryptMatch(hash, password string) bool {
+	return false
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// CertInfo - Проверенный сертификат клиента (mTLS)
+type CertInfo struct {
+	Subject        string    // Distinguished Name владельца, например "CN=billing,O=Example"
+	CommonName     string    // CN владельца
+	Issuer         string    // Distinguished Name удостоверяющего центра
+	SerialNumber   string    // Серийный номер в десятичном виде
+	DNSNames       []string  // SAN: DNS имена
+	EmailAddresses []string  // SAN: адреса почты
+	URIs           []string  // SAN: URI, например SPIFFE ID
+	IPAddresses    []string  // SAN: IP адреса
+	NotAfter       time.Time // Окончание срока действия
+	Fingerprint    string    // SHA-256 сертификата в hex
+}
+
+// Names - CN и все имена из SAN сертификата
+func (c CertInfo) Names() []string {
+	names := make([]string, 0, 1+len(c.DNSNames)+len(c.EmailAddresses)+len(c.URIs)+len(c.IPAddresses))
+	if c.CommonName != "" {
+		names = append(names, c.CommonName)
+	}
+	names = append(names, c.DNSNames...)
+	names = append(names, c.EmailAddresses...)
+	names = append(names, c.URIs...)
+	return append(names, c.IPAddresses...)
+}
+
+// newCertInfo - CertInfo из сертификата cert
+func newCertInfo(cert *x509.Certificate) CertInfo {
+	sum := sha256.Sum256(cert.Raw)
+	info := CertInfo{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		Issuer:         cert.Issuer.String(),
+		SerialNumber:   cert.SerialNumber.String(),
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		NotAfter:       cert.NotAfter,
+		Fingerprint:    hex.EncodeToString(sum[:]),
+	}
+	for _, u := range cert.URIs {
+		info.URIs = append(info.URIs, u.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	return info
+}
+
+// certKey - Ключ контекста для CertInfo
+type certKey struct{}
+
+// CertFrom - Сертификат клиента из контекста ctx. ok == false, если клиент не предъявил проверенный сертификат
+// или запрос не прошел через ClientCert
+func CertFrom(ctx context.Context) (info CertInfo, ok bool) {
+	info, ok = ctx.Value(certKey{}).(CertInfo)
+	return info, ok
+}
+
+// ClientCert - Middleware, сохраняющий в контексте запроса сертификат клиента, проверенный при TLS рукопожатии
+// (server.tls.client_auth). Клиент становится аутентифицированным с именем из CN и способом mtls, см. IdentityFrom.
+// Запросы без сертификата проходят без изменений: обязательность сертификата задается в настройках адреса
+// или в RequireClientCert
+func ClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Сертификаты, которые не удалось проверить по client_ca_file, в VerifiedChains не попадают
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		info := newCertInfo(r.TLS.VerifiedChains[0][0])
+		ctx := context.WithValue(r.Context(), certKey{}, info)
+		ctx = WithIdentity(ctx, Identity{Name: info.CommonName, Method: "mtls"})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireClientCert - Middleware, пропускающий только запросы с проверенным сертификатом клиента.
+// Если задан список names, CN или одно из имен SAN сертификата должно быть в нем, иначе ответ 403.
+// Работает только после ClientCert
+func RequireClientCert(names ...string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info, ok := CertFrom(r.Context())
+			if !ok {
+				response.Error(w, http.StatusUnauthorized, "требуется сертификат клиента")
+				return
+			}
+
+			if len(names) > 0 && !slices.ContainsFunc(info.Names(), func(n string) bool { return slices.Contains(names, n) }) {
+				response.Error(w, http.StatusForbidden, "сертификат клиента не допускается")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
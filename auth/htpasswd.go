@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Users - Пользователи и хэши их паролей в формате htpasswd.
+//
+// Поддерживаются хэши {SHA} (htpasswd -s), $apr1$ (htpasswd -m, по умолчанию в Apache) и bcrypt
+// $2y$ (htpasswd -B) - последний только при сборке с тегом bcrypt
+type Users map[string]string
+
+// LoadHtpasswd - Чтение пользователей из файла в формате htpasswd
+func LoadHtpasswd(path string) (Users, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users, err := ParseHtpasswd(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return users, nil
+}
+
+// ParseHtpasswd - Разбор строк вида user:hash. Пустые строки и комментарии (#) пропускаются
+func ParseHtpasswd(r io.Reader) (Users, error) {
+	users := make(Users)
+	var errs []error
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			errs = append(errs, fmt.Errorf("строка %d: ожидается user:hash", n))
+			continue
+		}
+		if err := checkHash(hash); err != nil {
+			errs = append(errs, fmt.Errorf("строка %d: пользователь %s: %w", n, user, err))
+			continue
+		}
+		users[user] = hash
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return users, errors.Join(errs...)
+}
+
+// Check - Проверка, что хэши паролей всех пользователей в поддерживаемом формате
+func (u Users) Check() error {
+	var errs []error
+	for user, hash := range u {
+		if err := checkHash(hash); err != nil {
+			errs = append(errs, fmt.Errorf("пользователь %s: %w", user, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (u Users) Verify(user, password string) bool {
+	hash, ok := u[user]
+	if !ok {
+		// Проверка с заведомо неверным хэшем, чтобы по времени ответа нельзя было узнать, есть ли такой пользователь
+		matchHash("{SHA}", password)
+		return false
+	}
+	return matchHash(hash, password)
+}
+
+// checkHash - Хэш пароля в поддерживаемом формате
+func checkHash(hash string) error {
+	switch {
+	case strings.HasPrefix(hash, "{SHA}"), strings.HasPrefix(hash, "$apr1$"):
+		return nil
+	case strings.HasPrefix(hash, "$2y$"), strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"):
+		return bcryptSupported()
+	default:
+		return errors.New("неизвестный формат хэша пароля: ожидается {SHA}, $apr1$ или $2y$")
+	}
+}
+
+// matchHash - Пароль password соответствует хэшу hash. Сравнение выполняется за постоянное время
+func matchHash(hash, password string) bool {
+	var computed string
+
+	switch {
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		computed = "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt, _, _ := strings.Cut(strings.TrimPrefix(hash, "$apr1$"), "$")
+		computed = apr1(password, salt)
+	default:
+		return bcryptMatch(hash, password)
+	}
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1
+}
+
+// apr1 - Хэш пароля в формате Apache MD5 ($apr1$salt$hash), алгоритм md5crypt
+func apr1(password, salt string) string {
+	const magic = "$apr1$"
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+
+	alt := md5.Sum([]byte(password + salt + password))
+
+	h := md5.New()
+	h.Write([]byte(password + magic + salt))
+	for i := len(pw); i > 0; i -= 16 {
+		h.Write(alt[:min(i, 16)])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(pw[:1])
+		}
+	}
+	final := h.Sum(nil)
+
+	// 1000 раундов, чтобы замедлить перебор
+	for i := 0; i < 1000; i++ {
+		h := md5.New()
+		if i&1 == 1 {
+			h.Write(pw)
+		} else {
+			h.Write(final)
+		}
+		if i%3 != 0 {
+			h.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h.Write(pw)
+		}
+		if i&1 == 1 {
+			h.Write(final)
+		} else {
+			h.Write(pw)
+		}
+		final = h.Sum(nil)
+	}
+
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	var out strings.Builder
+	encode := func(v uint32, n int) {
+		for ; n > 0; n-- {
+			out.WriteByte(itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, g := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint32(final[g[0]])<<16|uint32(final[g[1]])<<8|uint32(final[g[2]]), 4)
+	}
+	encode(uint32(final[11]), 2)
+
+	return magic + salt + "$" + out.String()
+}
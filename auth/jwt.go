@@ -0,0 +1,352 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // Регистрация хэш-функций для crypto.Hash
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// Claims - Проверенные утверждения JWT
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time // Нулевое значение, если утверждения nbf нет
+	IssuedAt  time.Time // Нулевое значение, если утверждения iat нет
+
+	Raw map[string]any // Все утверждения токена, включая нестандартные
+}
+
+// Strings - Утверждение name как список строк: JSON массив строк или строка, разделенная пробелами.
+// nil, если утверждения нет или оно другого типа
+func (c *Claims) Strings(name string) []string {
+	switch v := c.Raw[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// claimsKey - Ключ контекста для Claims
+type claimsKey struct{}
+
+// ClaimsFrom - Утверждения JWT из контекста запроса. ok == false, если запрос не прошел через JWT middleware
+func ClaimsFrom(ctx context.Context) (c *Claims, ok bool) {
+	c, ok = ctx.Value(claimsKey{}).(*Claims)
+	return c, ok
+}
+
+// JWT - Middleware, пропускающий только запросы с действительным JWT в заголовке Authorization: Bearer.
+// Утверждения токена сохраняются в контексте запроса (см. ClaimsFrom), а sub и roles - как имя и роли клиента
+// (см. IdentityFrom)
+func JWT(v *JWTVerifier) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" {
+				unauthorized(w, `Bearer realm="api"`)
+				return
+			}
+
+			claims, err := v.Verify(token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+				response.Error(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// withClaims - Контекст с утверждениями проверенного токена и клиентом из sub и roles
+func withClaims(ctx context.Context, claims *Claims) context.Context {
+	ctx = context.WithValue(ctx, claimsKey{}, claims)
+	return WithIdentity(ctx, Identity{Name: claims.Subject, Method: "jwt", Roles: claims.Strings("roles")})
+}
+
+// JWTVerifier - Проверка подписи и стандартных утверждений JWT
+type JWTVerifier struct {
+	key        any           // []byte для HMAC, *rsa.PublicKey или *ecdsa.PublicKey
+	algorithms []string      // Алгоритмы подписи, допустимые для key
+	issuer     string        // Ожидаемое значение iss, пустая строка - не проверяется
+	audience   string        // Значение, которое должно быть в aud, пустая строка - не проверяется
+	leeway     time.Duration // Допустимое расхождение часов при проверке exp и nbf
+}
+
+// NewJWTVerifier - Проверка токенов, подписанных ключом key: секретом []byte (HS256, HS384, HS512),
+// открытым ключом *rsa.PublicKey (RS*, PS*) или *ecdsa.PublicKey (ES* для кривой ключа).
+// Токены с другими алгоритмами отклоняются, поэтому подменить алгоритм в заголовке токена нельзя
+func NewJWTVerifier(key any, issuer, audience string, leeway time.Duration) (*JWTVerifier, error) {
+	v := &JWTVerifier{key: key, issuer: issuer, audience: audience, leeway: leeway}
+
+	switch k := key.(type) {
+	case []byte:
+		if len(k) < 32 {
+			return nil, errors.New("jwt: секрет HMAC должен быть не короче 32 байт")
+		}
+		v.algorithms = []string{"HS256", "HS384", "HS512"}
+	case *rsa.PublicKey:
+		v.algorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	case *ecdsa.PublicKey:
+		alg, ok := map[elliptic.Curve]string{elliptic.P256(): "ES256", elliptic.P384(): "ES384", elliptic.P521(): "ES512"}[k.Curve]
+		if !ok {
+			return nil, errors.New("jwt: неподдерживаемая кривая ECDSA")
+		}
+		v.algorithms = []string{alg}
+	default:
+		return nil, fmt.Errorf("jwt: неподдерживаемый тип ключа %T", key)
+	}
+
+	return v, nil
+}
+
+// ParsePublicKeyPEM - Открытый ключ RSA или ECDSA из PEM: PUBLIC KEY, RSA PUBLIC KEY или CERTIFICATE
+func ParsePublicKeyPEM(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: PEM блок не найден")
+	}
+
+	var key any
+	switch block.Type {
+	case "PUBLIC KEY":
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %w", err)
+		}
+		key = k
+	case "RSA PUBLIC KEY":
+		k, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %w", err)
+		}
+		key = k
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %w", err)
+		}
+		key = cert.PublicKey
+	default:
+		return nil, fmt.Errorf("jwt: неподдерживаемый PEM блок %q", block.Type)
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("jwt: неподдерживаемый тип ключа %T", key)
+	}
+}
+
+// hashes - Хэш-функция для каждого алгоритма подписи
+var hashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// Verify - Проверка подписи токена и утверждений exp (обязательно), nbf, iss и aud
+func (v *JWTVerifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("недействительный токен: ожидается три части, разделенные точкой")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("недействительный токен: заголовок: %w", err)
+	}
+	if !slices.Contains(v.algorithms, header.Alg) {
+		return nil, fmt.Errorf("недействительный токен: алгоритм %q не допускается", header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("недействительный токен: подпись не в base64url")
+	}
+	if !v.verifySignature(header.Alg, parts[0]+"."+parts[1], sig) {
+		return nil, errors.New("недействительный токен: неверная подпись")
+	}
+
+	var raw map[string]any
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("недействительный токен: утверждения: %w", err)
+	}
+
+	claims, err := parseClaims(raw)
+	if err != nil {
+		return nil, fmt.Errorf("недействительный токен: %w", err)
+	}
+
+	return claims, v.validate(claims)
+}
+
+// verifySignature - Подпись sig данных signed ключом проверки алгоритмом alg
+func (v *JWTVerifier) verifySignature(alg, signed string, sig []byte) bool {
+	hash := hashes[alg]
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch key := v.key.(type) {
+	case []byte:
+		mac := hmac.New(hash.New, key)
+		mac.Write([]byte(signed))
+		return hmac.Equal(mac.Sum(nil), sig)
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(key, hash, digest, sig, nil) == nil
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		// Подпись ES* - конкатенация r и s фиксированной длины, а не DER
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// validate - Проверка срока действия, издателя и получателя токена
+func (v *JWTVerifier) validate(c *Claims) error {
+	now := time.Now()
+
+	if c.ExpiresAt.IsZero() {
+		return errors.New("недействительный токен: нет срока действия exp")
+	}
+	if now.After(c.ExpiresAt.Add(v.leeway)) {
+		return errors.New("недействительный токен: истек срок действия")
+	}
+	if !c.NotBefore.IsZero() && now.Add(v.leeway).Before(c.NotBefore) {
+		return errors.New("недействительный токен: срок действия еще не начался")
+	}
+	if v.issuer != "" && c.Issuer != v.issuer {
+		return fmt.Errorf("недействительный токен: неожиданный издатель %q", c.Issuer)
+	}
+	if v.audience != "" && !slices.Contains(c.Audience, v.audience) {
+		return errors.New("недействительный токен: токен выпущен не для этого сервиса")
+	}
+	return nil
+}
+
+// decodeSegment - Разбор части токена: JSON в base64url без выравнивания
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.New("не в base64url")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// parseClaims - Стандартные утверждения из разобранного JSON
+func parseClaims(raw map[string]any) (*Claims, error) {
+	c := &Claims{Raw: raw}
+	var errs []error
+
+	str := func(name string) string {
+		v, ok := raw[name]
+		if !ok {
+			return ""
+		}
+		s, ok := v.(string)
+		if !ok {
+			errs = append(errs, fmt.Errorf("утверждение %s должно быть строкой", name))
+		}
+		return s
+	}
+	date := func(name string) time.Time {
+		v, ok := raw[name]
+		if !ok {
+			return time.Time{}
+		}
+		n, ok := v.(json.Number)
+		f, err := n.Float64()
+		if !ok || err != nil {
+			errs = append(errs, fmt.Errorf("утверждение %s должно быть числом секунд", name))
+			return time.Time{}
+		}
+		return time.Unix(0, int64(f*float64(time.Second)))
+	}
+
+	c.Issuer = str("iss")
+	c.Subject = str("sub")
+	c.ExpiresAt = date("exp")
+	c.NotBefore = date("nbf")
+	c.IssuedAt = date("iat")
+
+	// aud может быть строкой или списком строк
+	switch aud := raw["aud"].(type) {
+	case nil:
+	case string:
+		c.Audience = []string{aud}
+	case []any:
+		for _, a := range aud {
+			s, ok := a.(string)
+			if !ok {
+				errs = append(errs, errors.New("утверждение aud должно содержать строки"))
+				break
+			}
+			c.Audience = append(c.Audience, s)
+		}
+	default:
+		errs = append(errs, errors.New("утверждение aud должно быть строкой или списком строк"))
+	}
+
+	return c, errors.Join(errs...)
+}
+
+// SignJWT - Токен с утверждениями claims, подписанный секретом secret алгоритмом HS256
+func SignJWT(secret []byte, claims map[string]any) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("jwt: %w", err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(crypto.SHA256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSecret - Секрет HMAC тестовых токенов
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// signToken - Токен с заголовком {"alg": alg} и утверждениями claims, подписанный функцией sign.
+// sign == nil - токен без подписи
+func signToken(t *testing.T, alg string, claims map[string]any, sign func(signed []byte) []byte) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	var sig []byte
+	if sign != nil {
+		sig = sign([]byte(signed))
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// hmacSigner - Подпись HMAC SHA-256 секретом key
+func hmacSigner(key []byte) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(crypto.SHA256.New, key)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+// rsaSigner - Подпись RS256 или PS256 ключом key
+func rsaSigner(t *testing.T, key *rsa.PrivateKey, pss bool) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		digest := crypto.SHA256.New()
+		digest.Write(signed)
+		var (
+			sig []byte
+			err error
+		)
+		if pss {
+			sig, err = rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest.Sum(nil), nil)
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest.Sum(nil))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+}
+
+// ecdsaSigner - Подпись ES256 ключом key: r и s по 32 байта
+func ecdsaSigner(t *testing.T, key *ecdsa.PrivateKey) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		digest := crypto.SHA256.New()
+		digest.Write(signed)
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig
+	}
+}
+
+// claimsWith - Действительные утверждения тестового токена с изменениями из changes (nil - удалить утверждение)
+func claimsWith(changes map[string]any) map[string]any {
+	now := time.Now()
+	claims := map[string]any{
+		"sub":   "alice",
+		"iss":   "https://issuer.example",
+		"aud":   "api",
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"roles": []string{"admin"},
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+// tamper - Токен с измененным последним символом части part
+func tamper(token string, part int) string {
+	parts := strings.Split(token, ".")
+	seg := []byte(parts[part])
+	if seg[len(seg)-2] == 'A' {
+		seg[len(seg)-2] = 'B'
+	} else {
+		seg[len(seg)-2] = 'A'
+	}
+	parts[part] = string(seg)
+	return strings.Join(parts, ".")
+}
+
+func TestJWTVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	newVerifier := func(key any) *JWTVerifier {
+		v, err := NewJWTVerifier(key, "https://issuer.example", "api", 30*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	hs := newVerifier(testSecret)
+	rs := newVerifier(&rsaKey.PublicKey)
+	es := newVerifier(&ecKey.PublicKey)
+
+	now := time.Now()
+	valid := signToken(t, "HS256", claimsWith(nil), hmacSigner(testSecret))
+
+	tests := []struct {
+		name     string
+		verifier *JWTVerifier
+		token    string
+		err      string // Часть текста ошибки, пустая строка - токен действителен
+	}{
+		{name: "HS256", verifier: hs, token: valid},
+		{name: "RS256", verifier: rs, token: signToken(t, "RS256", claimsWith(nil), rsaSigner(t, rsaKey, false))},
+		{name: "PS256", verifier: rs, token: signToken(t, "PS256", claimsWith(nil), rsaSigner(t, rsaKey, true))},
+		{name: "ES256", verifier: es, token: signToken(t, "ES256", claimsWith(nil), ecdsaSigner(t, ecKey))},
+		{name: "SignJWT", verifier: hs, token: func() string {
+			token, err := SignJWT(testSecret, claimsWith(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return token
+		}()},
+
+		{name: "alg none", verifier: hs, token: signToken(t, "none", claimsWith(nil), nil), err: `алгоритм "none" не допускается`},
+		{name: "alg None", verifier: rs, token: signToken(t, "None", claimsWith(nil), nil), err: "не допускается"},
+		{name: "без alg", verifier: hs, token: signToken(t, "", claimsWith(nil), hmacSigner(testSecret)), err: "не допускается"},
+		{
+			// Открытый ключ RSA известен всем: токен HS256 с ним в качестве секрета не должен приниматься
+			name: "HS256 с открытым ключом RSA", verifier: rs,
+			token: signToken(t, "HS256", claimsWith(nil), hmacSigner(rsaPEM)), err: `алгоритм "HS256" не допускается`,
+		},
+		{
+			name: "HS256 с DER открытого ключа RSA", verifier: rs,
+			token: signToken(t, "HS256", claimsWith(nil), hmacSigner(der)), err: "не допускается",
+		},
+		{name: "RS256 для секрета HMAC", verifier: hs, token: signToken(t, "RS256", claimsWith(nil), rsaSigner(t, rsaKey, false)), err: "не допускается"},
+		{name: "ES256 для ключа RSA", verifier: rs, token: signToken(t, "ES256", claimsWith(nil), ecdsaSigner(t, ecKey)), err: "не допускается"},
+		{name: "другой секрет", verifier: hs, token: signToken(t, "HS256", claimsWith(nil), hmacSigner([]byte(strings.Repeat("x", 32)))), err: "неверная подпись"},
+		{name: "измененная подпись", verifier: hs, token: tamper(valid, 2), err: "неверная подпись"},
+		{name: "измененные утверждения", verifier: hs, token: tamper(valid, 1), err: "неверная подпись"},
+		{name: "измененная подпись RS256", verifier: rs, token: tamper(signToken(t, "RS256", claimsWith(nil), rsaSigner(t, rsaKey, false)), 2), err: "неверная подпись"},
+		{name: "короткая подпись ES256", verifier: es, token: signToken(t, "ES256", claimsWith(nil), func([]byte) []byte { return make([]byte, 32) }), err: "неверная подпись"},
+		{name: "пустая подпись", verifier: hs, token: signToken(t, "HS256", claimsWith(nil), nil), err: "неверная подпись"},
+		{name: "две части", verifier: hs, token: valid[:strings.LastIndex(valid, ".")], err: "три части"},
+		{name: "подпись не в base64url", verifier: hs, token: valid + "*", err: "base64url"},
+
+		{name: "истек срок", verifier: hs, token: signToken(t, "HS256", claimsWith(map[string]any{"exp": now.Add(-time.Minute).Unix()}), hmacSigner(testSecret)), err: "истек срок действия"},
+		{name: "истек в пределах leeway", verifier: hs, token: signToken(t, "HS256", claimsWith(map[string]any{"exp": now.Add(-10 * time.Second).Unix()}), hmacSigner(testSecret))},
+		{name: "без exp", verifier: hs, token: signToken(t, "HS256", claimsWith(map[string]any{"exp": nil}), hmacSigner(testSecret)), err: "нет срока действия"},
+		{name: "exp строкой", verifier: hs, token: signToken(t, "HS256", claimsWith(map[string]any{"exp": "tomorrow"}), hmacSigner(testSecret)), err: "exp должно быть числом"},
+		{name: "еще не действует", verifier: hs, token: signToken(t, "HS256", claimsWith(map[string]any{"nbf": now.Add(time.Minute).Unix()}), hmacSigner(testSecret)), err: "еще не начался"},
+		{name: "nbf в пределах leeway", verifier: hs, token: signToken(t, "HS256", claimsWith(map[string]any{"nbf": now.Add(10 * time.Second).Unix()}), hmacSigner(testSecret))},
+
+		{name: "другой издатель", verifier: hs, token: signToken(t, "HS256", claimsWith(map[string]any{"iss": "https://evil.example"}), hmacSigner(testSecret)), err: "неожиданный издатель"},
+		{name: "без издателя", verifier: hs, token: signToken(t, "HS256", claimsWith(map[string]any{"iss": nil}), hmacSigner(testSecret)), err: "неожиданный издатель"},
+		{name: "другой получатель", verifier: hs, token: signToken(t, "HS256", claimsWith(map[string]any{"aud": "billing"}), hmacSigner(testSecret)), err: "не для этого сервиса"},
+		{name: "получатель в списке", verifier: hs, token: signToken(t, "HS256", claimsWith(map[string]any{"aud": []string{"billing", "api"}}), hmacSigner(testSecret))},
+		{name: "без получателя", verifier: hs, token: signToken(t, "HS256", claimsWith(map[string]any{"aud": nil}), hmacSigner(testSecret)), err: "не для этого сервиса"},
+		{name: "aud числом", verifier: hs, token: signToken(t, "HS256", claimsWith(map[string]any{"aud": 1}), hmacSigner(testSecret)), err: "aud должно быть"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.verifier.Verify(tt.token)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("токен отклонен: %v", err)
+				}
+				if claims.Subject != "alice" {
+					t.Fatalf("sub %q, ожидается alice", claims.Subject)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("ошибка %v, ожидается %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNewJWTVerifier(t *testing.T) {
+	if _, err := NewJWTVerifier([]byte("short"), "", "", 0); err == nil {
+		t.Fatalf("принят секрет HMAC короче 32 байт")
+	}
+	p224, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if _, err := NewJWTVerifier(&p224.PublicKey, "", "", 0); err == nil {
+		t.Fatalf("принят ключ ECDSA на неподдерживаемой кривой")
+	}
+	if _, err := NewJWTVerifier("secret", "", "", 0); err == nil {
+		t.Fatalf("принят ключ неподдерживаемого типа")
+	}
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	v, err := NewJWTVerifier(testSecret, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := JWT(v)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := IdentityFrom(r.Context())
+		claims, ok := ClaimsFrom(r.Context())
+		if !ok || claims.Subject != id.Name {
+			t.Errorf("утверждения токена не сохранены в контексте")
+		}
+		w.Write([]byte(id.Name + " " + id.Method + " " + strings.Join(id.Roles, ",")))
+	}))
+
+	tests := []struct {
+		name   string
+		auth   string
+		status int
+		body   string
+	}{
+		{name: "действительный токен", auth: "Bearer " + signToken(t, "HS256", claimsWith(nil), hmacSigner(testSecret)), status: http.StatusOK, body: "alice jwt admin"},
+		{name: "схема в другом регистре", auth: "bearer " + signToken(t, "HS256", claimsWith(nil), hmacSigner(testSecret)), status: http.StatusOK, body: "alice jwt admin"},
+		{name: "без токена", status: http.StatusUnauthorized},
+		{name: "Basic", auth: "Basic YWxpY2U6cGFzcw==", status: http.StatusUnauthorized},
+		{name: "alg none", auth: "Bearer " + signToken(t, "none", claimsWith(nil), nil), status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/hello", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("статус %d, ожидается %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == http.StatusOK && w.Body.String() != tt.body {
+				t.Fatalf("ответ %q, ожидается %q", w.Body.String(), tt.body)
+			}
+			if tt.status == http.StatusUnauthorized && !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+				t.Fatalf("WWW-Authenticate %q", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/derv-dice/go-web-server/apperr"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/realip"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/session"
+	"github.com/derv-dice/go-web-server/workpool"
+)
+
+// accountName - Допустимое имя учетной записи: латинские буквы, цифры и . _ @ -, от 3 до 64 символов
+var accountName = regexp.MustCompile(`^[A-Za-z0-9._@-]{3,64}$`)
+
+// PasswordConfig - Настройки регистрации и входа по паролю
+type PasswordConfig struct {
+	Accounts          AccountStore
+	Throttle          *LoginThrottle // Защита входа от подбора паролей, nil - без нее
+	Registration      bool           // Открытая регистрация через Register. Если выключена, Register отвечает 403
+	MinPasswordLength int            // Наименьшая длина пароля в символах
+	DefaultRoles      []string       // Роли новых учетных записей
+	Pool              *workpool.Pool // Исполнители для хэширования паролей, nil - без ограничения
+
+	// Выдача входа: в сессии (нужен session.Middleware, проверка - RequireSession) или, если задан JWTSecret,
+	// в JWT с подписью HS256, который принимает JWT middleware с тем же секретом
+	SessionTTL  time.Duration // Сколько действует вход в сессии
+	JWTSecret   []byte
+	JWTIssuer   string        // Утверждение iss токена, пустая строка - без него
+	JWTAudience string        // Утверждение aud токена, пустая строка - без него
+	TokenTTL    time.Duration // Срок действия токена
+
+	// Токены обновления вместе с JWT, см. Refresh. Refresh == nil - без них
+	Refresh    RefreshStore
+	RefreshTTL time.Duration // Срок действия цепочки токенов обновления от одного входа
+}
+
+// PasswordAuth - Регистрация учетных записей и вход по имени и паролю
+type PasswordAuth struct {
+	cfg PasswordConfig
+
+	// dummyHash - Хэш, который проверяется для несуществующей учетной записи, чтобы время ответа
+	// не выдавало, зарегистрировано ли имя
+	dummyHash string
+}
+
+// credentials - Тело запросов Register и Login
+type credentials struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// NewPasswordAuth - Регистрация и вход по паролю с настройками cfg
+func NewPasswordAuth(cfg PasswordConfig) (*PasswordAuth, error) {
+	if cfg.Accounts == nil {
+		return nil, errors.New("password: не задано хранилище учетных записей")
+	}
+	if cfg.JWTSecret != nil && len(cfg.JWTSecret) < 32 {
+		return nil, errors.New("password: секрет JWT должен быть не короче 32 байт")
+	}
+
+	if cfg.DefaultRoles == nil {
+		cfg.DefaultRoles = []string{} // В ответах и токенах - пустой список, а не null
+	}
+
+	dummy, err := HashPassword("dummy password")
+	if err != nil {
+		return nil, fmt.Errorf("password: %w", err)
+	}
+	return &PasswordAuth{cfg: cfg, dummyHash: dummy}, nil
+}
+
+// Register - Обработчик POST регистрации: {"name": "...", "password": "..."} в JSON.
+// Ответ 201 с именем и ролями новой учетной записи, 409 - если имя занято, 422 - если имя или пароль не подходят
+func (p *PasswordAuth) Register(w http.ResponseWriter, r *http.Request) error {
+	if !p.cfg.Registration {
+		return apperr.New(apperr.Forbidden, "регистрация закрыта")
+	}
+
+	c, err := readCredentials(r)
+	if err != nil {
+		return err
+	}
+	if err := p.validate(c); err != nil {
+		return err
+	}
+
+	var hash string
+	if err := p.work(r.Context(), w, func() { hash, err = HashPassword(c.Password) }); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	a := Account{Name: c.Name, PasswordHash: hash, Roles: p.cfg.DefaultRoles, CreatedAt: time.Now().UTC()}
+	switch err := p.cfg.Accounts.Create(r.Context(), a); {
+	case errors.Is(err, ErrAccountExists):
+		return apperr.New(apperr.Conflict, "имя уже занято")
+	case err != nil:
+		return err
+	}
+
+	logging.From(r.Context()).Info("auth: account registered", "user", a.Name)
+	response.JSON(w, http.StatusCreated, response.Body{Data: map[string]any{
+		"name":       a.Name,
+		"roles":      a.Roles,
+		"created_at": a.CreatedAt,
+	}})
+	return nil
+}
+
+// Login - Обработчик POST входа: {"name": "...", "password": "..."} в JSON.
+// С JWTSecret ответ содержит access_token (и refresh_token, если задан Refresh), иначе вход сохраняется в сессии. Неверное имя или пароль - 401,
+// при блокировке после серии неудачных попыток - 429 (см. LoginThrottle)
+func (p *PasswordAuth) Login(w http.ResponseWriter, r *http.Request) error {
+	c, err := readCredentials(r)
+	if err != nil {
+		return err
+	}
+
+	ip := realip.From(r)
+	if p.cfg.Throttle != nil {
+		if l, locked := p.cfg.Throttle.Locked(c.Name, ip); locked {
+			TooManyAttempts(w, l)
+			return nil
+		}
+	}
+
+	a, ok, err := p.check(r.Context(), w, c)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if p.cfg.Throttle != nil {
+			if l, locked := p.cfg.Throttle.Failed(c.Name, ip); locked {
+				logging.From(r.Context()).Warn("auth: login locked", "user", c.Name, "scope", l.Scope, "retry_after", l.RetryAfter)
+			}
+		}
+		return apperr.New(apperr.Unauthorized, "неверное имя или пароль")
+	}
+	if p.cfg.Throttle != nil {
+		p.cfg.Throttle.Succeeded(a.Name)
+	}
+
+	// Аутентифицированный клиент виден журналу доступа и аудиту, см. Track
+	WithIdentity(r.Context(), Identity{Name: a.Name, Method: "password", Roles: a.Roles})
+
+	if p.cfg.JWTSecret != nil {
+		return p.issueToken(r.Context(), w, a, newFamily(), time.Now().Add(p.cfg.RefreshTTL))
+	}
+	return p.issueSession(w, r, a)
+}
+
+// Refresh - Обработчик POST обмена токена обновления: {"refresh_token": "..."} в JSON. Ответ как у Login:
+// новый access_token и следующий refresh_token той же цепочки, а предъявленный токен больше не действует.
+//
+// Повторное предъявление уже обменянного токена значит, что его копия есть у кого-то еще: цепочка отзывается
+// целиком, и войти заново придется и владельцу, и тому, кто токен похитил
+func (p *PasswordAuth) Refresh(w http.ResponseWriter, r *http.Request) error {
+	token, err := readRefreshToken(r)
+	if err != nil {
+		return err
+	}
+
+	invalid := apperr.New(apperr.Unauthorized, "недействительный токен обновления")
+	t, err := p.cfg.Refresh.Use(r.Context(), refreshID(token))
+	switch {
+	case errors.Is(err, ErrRefreshNotFound):
+		return invalid
+	case err != nil:
+		return err
+	}
+
+	if t.Used {
+		logging.From(r.Context()).Warn("auth: refresh token reuse, revoking", "user", t.Subject, "family", t.Family)
+		if err := p.cfg.Refresh.Revoke(r.Context(), t.Family); err != nil {
+			return err
+		}
+		return invalid
+	}
+	if time.Now().After(t.ExpiresAt) {
+		return invalid
+	}
+
+	// Роли берутся из учетной записи заново: их изменение действует с ближайшего обмена
+	a, err := p.cfg.Accounts.Account(r.Context(), t.Subject)
+	switch {
+	case errors.Is(err, ErrAccountNotFound):
+		return invalid
+	case err != nil:
+		return err
+	}
+
+	WithIdentity(r.Context(), Identity{Name: a.Name, Method: "refresh", Roles: a.Roles})
+	return p.issueToken(r.Context(), w, a, t.Family, t.ExpiresAt)
+}
+
+// Revoke - Обработчик POST отзыва токена обновления: {"refresh_token": "..."} в JSON. Отзывается вся цепочка
+// токена, уже выданные JWT действуют до окончания срока. Как в RFC 7009, ответ 204 и для неизвестного токена
+func (p *PasswordAuth) Revoke(w http.ResponseWriter, r *http.Request) error {
+	token, err := readRefreshToken(r)
+	if err != nil {
+		return err
+	}
+
+	t, err := p.cfg.Refresh.Use(r.Context(), refreshID(token))
+	switch {
+	case errors.Is(err, ErrRefreshNotFound):
+	case err != nil:
+		return err
+	default:
+		if err := p.cfg.Refresh.Revoke(r.Context(), t.Family); err != nil {
+			return err
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Logout - Обработчик выхода из сессии. Ответ 204. JWT выход не отменяет: токен действует до окончания срока
+func (p *PasswordAuth) Logout(w http.ResponseWriter, r *http.Request) error {
+	if sess := session.From(r.Context()); sess != nil {
+		sess.Destroy()
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// check - Учетная запись c.Name, если пароль верен. ok == false для неизвестного имени и неверного пароля
+func (p *PasswordAuth) check(ctx context.Context, w http.ResponseWriter, c credentials) (Account, bool, error) {
+	a, err := p.cfg.Accounts.Account(ctx, c.Name)
+	found, hash := true, a.PasswordHash
+	switch {
+	case errors.Is(err, ErrAccountNotFound):
+		found, hash = false, p.dummyHash
+	case err != nil:
+		return Account{}, false, err
+	}
+
+	var ok bool
+	if err := p.work(ctx, w, func() { ok = CheckPassword(hash, c.Password) }); err != nil {
+		return Account{}, false, err
+	}
+	if !found {
+		return Account{}, false, nil
+	}
+	return a, ok, nil
+}
+
+// work - Выполнение fn в пуле исполнителей Pool. Если свободного исполнителя не дождаться - ошибка 503
+// с заголовком Retry-After
+func (p *PasswordAuth) work(ctx context.Context, w http.ResponseWriter, fn func()) error {
+	err := p.cfg.Pool.Do(ctx, fn)
+	if errors.Is(err, workpool.ErrBusy) {
+		w.Header().Set("Retry-After", "1")
+		return apperr.Wrap(err, apperr.Unavailable, "сервер занят, повторите позже")
+	}
+	return err
+}
+
+// issueToken - Ответ с JWT для учетной записи a и, если включены токены обновления, со следующим токеном
+// цепочки family, которая действует до expires
+func (p *PasswordAuth) issueToken(ctx context.Context, w http.ResponseWriter, a Account, family string, expires time.Time) error {
+	now := time.Now()
+	claims := map[string]any{
+		"sub":   a.Name,
+		"iat":   now.Unix(),
+		"exp":   now.Add(p.cfg.TokenTTL).Unix(),
+		"roles": a.Roles,
+	}
+	if p.cfg.JWTIssuer != "" {
+		claims["iss"] = p.cfg.JWTIssuer
+	}
+	if p.cfg.JWTAudience != "" {
+		claims["aud"] = p.cfg.JWTAudience
+	}
+
+	token, err := SignJWT(p.cfg.JWTSecret, claims)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(p.cfg.TokenTTL.Seconds()),
+	}
+	if p.cfg.Refresh != nil {
+		refresh, id := newRefreshToken()
+		if err := p.cfg.Refresh.Create(ctx, RefreshToken{ID: id, Family: family, Subject: a.Name, ExpiresAt: expires}); err != nil {
+			return err
+		}
+		data["refresh_token"] = refresh
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	response.JSON(w, http.StatusOK, response.Body{Data: data})
+	return nil
+}
+
+// issueSession - Сохранение входа учетной записи a в сессии
+func (p *PasswordAuth) issueSession(w http.ResponseWriter, r *http.Request, a Account) error {
+	sess := session.From(r.Context())
+	if sess == nil {
+		return errors.New("password: сессии не настроены")
+	}
+
+	expires := time.Now().Add(p.cfg.SessionTTL)
+	sess.Renew()
+	sess.Set(sessionSubject, a.Name)
+	sess.Set(sessionName, a.Name)
+	sess.Set(sessionRoles, a.Roles)
+	sess.Set(sessionExpires, expires.Unix())
+	sess.Set(sessionMethod, "password")
+
+	response.JSON(w, http.StatusOK, response.Body{Data: map[string]any{
+		"name":       a.Name,
+		"roles":      a.Roles,
+		"expires_at": expires.UTC().Truncate(time.Second),
+	}})
+	return nil
+}
+
+// validate - Проверка имени и пароля новой учетной записи
+func (p *PasswordAuth) validate(c credentials) error {
+	fields := map[string]string{}
+	if !accountName.MatchString(c.Name) {
+		fields["name"] = "от 3 до 64 символов: латинские буквы, цифры и . _ @ -"
+	}
+	switch n := len([]rune(c.Password)); {
+	case n < p.cfg.MinPasswordLength:
+		fields["password"] = fmt.Sprintf("не короче %d символов", p.cfg.MinPasswordLength)
+	case MaxPasswordBytes() > 0 && len(c.Password) > MaxPasswordBytes():
+		fields["password"] = fmt.Sprintf("не длиннее %d байт", MaxPasswordBytes())
+	}
+
+	if len(fields) > 0 {
+		return apperr.New(apperr.Validation, "некорректные данные учетной записи").WithData(fields)
+	}
+	return nil
+}
+
+// readCredentials - Имя и пароль из JSON тела запроса
+func readCredentials(r *http.Request) (credentials, error) {
+	var c credentials
+	if err := decodeBody(r, &c, "ожидается JSON с полями name и password"); err != nil {
+		return c, err
+	}
+	if c.Name == "" || c.Password == "" {
+		return c, apperr.New(apperr.BadRequest, "нужно указать name и password")
+	}
+	return c, nil
+}
+
+// readRefreshToken - Токен обновления из JSON тела запроса
+func readRefreshToken(r *http.Request) (string, error) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := decodeBody(r, &body, "ожидается JSON с полем refresh_token"); err != nil {
+		return "", err
+	}
+	if body.RefreshToken == "" {
+		return "", apperr.New(apperr.BadRequest, "нужно указать refresh_token")
+	}
+	return body.RefreshToken, nil
+}
+
+// decodeBody - Разбор JSON тела запроса в v. msg - сообщение об ошибке для некорректного тела
+func decodeBody(r *http.Request, v any, msg string) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			return err // Ответ 413 по ошибке лимита отправляет response.Handle
+		}
+		return apperr.Wrap(err, apperr.BadRequest, msg)
+	}
+	return nil
+}
@@ -0,0 +1,498 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/derv-dice/go-web-server/cookies"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+	"github.com/derv-dice/go-web-server/session"
+)
+
+// oidcStateCookie - Cookie с параметрами незавершенного входа: state, nonce и code_verifier
+const oidcStateCookie = "oidc_state"
+
+// Ключи значений сессии пользователя после входа
+const (
+	sessionSubject = "auth.subject"
+	sessionName    = "auth.name"
+	sessionEmail   = "auth.email"
+	sessionRoles   = "auth.roles"
+	sessionExpires = "auth.expires" // Unix время окончания входа
+	sessionMethod  = "auth.method"  // Способ входа: oidc или password
+)
+
+// oidcStateTTL - Сколько времени у пользователя есть на вход у провайдера
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCConfig - Настройки клиента OpenID Connect
+type OIDCConfig struct {
+	Issuer       string   // URL провайдера, например https://accounts.google.com или https://keycloak/realms/main
+	ClientID     string   // Идентификатор клиента, зарегистрированного у провайдера
+	ClientSecret string   // Секрет клиента
+	RedirectURL  string   // Полный URL обработчика Callback, зарегистрированный у провайдера
+	Scopes       []string // Запрашиваемые scope, openid добавляется автоматически
+
+	CookieSecret []byte        // Ключ подписи cookie с параметрами входа, не короче 32 байт
+	SessionTTL   time.Duration // Сколько действует вход, сохраненный в сессии
+
+	Client *http.Client // Клиент для запросов к провайдеру, nil - клиент с таймаутом 10 секунд
+}
+
+// OIDC - Вход пользователей через провайдера OpenID Connect по схеме authorization code с PKCE.
+//
+// Login перенаправляет пользователя к провайдеру, Callback обменивает полученный код на ID токен, проверяет его
+// и сохраняет пользователя в сессии (см. пакет session), поэтому маршруты входа должны проходить через
+// session.Middleware. Адреса провайдера получаются из его discovery документа при первом входе
+type OIDC struct {
+	cfg     OIDCConfig
+	codec   *cookies.Codec
+	cookies cookies.Options // Cookie только для HTTPS, если RedirectURL - HTTPS адрес
+
+	mu       sync.Mutex
+	provider *oidcProvider
+	keys     map[string]any // Ключи подписи провайдера по kid
+}
+
+// oidcProvider - Адреса провайдера из discovery документа
+type oidcProvider struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcState - Параметры незавершенного входа, хранятся в подписанной cookie до возврата пользователя от провайдера
+type oidcState struct {
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	Verifier string    `json:"verifier"`
+	ReturnTo string    `json:"return_to"`
+	Expires  time.Time `json:"expires"`
+}
+
+// NewOIDC - Клиент OpenID Connect с настройками cfg
+func NewOIDC(cfg OIDCConfig) (*OIDC, error) {
+	codec, err := cookies.NewCodec(cfg.CookieSecret)
+	if err != nil {
+		return nil, errors.New("oidc: ключ подписи cookie должен быть не короче 32 байт")
+	}
+
+	redirect, err := url.Parse(cfg.RedirectURL)
+	if err != nil || !redirect.IsAbs() {
+		return nil, fmt.Errorf("oidc: некорректный redirect_url %q: ожидается полный URL", cfg.RedirectURL)
+	}
+
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	// SameSite=Lax (по умолчанию), чтобы cookie приходила при возврате пользователя от провайдера
+	opts := cookies.Options{MaxAge: oidcStateTTL, Insecure: redirect.Scheme != "https"}
+	return &OIDC{cfg: cfg, codec: codec, cookies: opts}, nil
+}
+
+// Login - Обработчик начала входа: перенаправление к провайдеру.
+// Параметр return_to задает локальный путь, куда пользователь вернется после входа
+func (o *OIDC) Login(w http.ResponseWriter, r *http.Request) {
+	p, err := o.discover(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	st := oidcState{
+		State:    randomString(),
+		Nonce:    randomString(),
+		Verifier: randomString(),
+		ReturnTo: localPath(r.URL.Query().Get("return_to")),
+		Expires:  time.Now().Add(oidcStateTTL),
+	}
+
+	data, _ := json.Marshal(st)
+	if err := o.codec.SetSigned(w, oidcStateCookie, string(data), o.cookies); err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// PKCE: провайдер выдаст токен только тому, кто знает Verifier, даже если код перехвачен
+	challenge := sha256.Sum256([]byte(st.Verifier))
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {o.cfg.ClientID},
+		"redirect_uri":          {o.cfg.RedirectURL},
+		"scope":                 {strings.Join(o.scopes(), " ")},
+		"state":                 {st.State},
+		"nonce":                 {st.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	target := p.AuthorizationEndpoint
+	if strings.Contains(target, "?") {
+		target += "&" + q.Encode()
+	} else {
+		target += "?" + q.Encode()
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// Callback - Обработчик возврата от провайдера: обмен кода на ID токен, его проверка и сохранение пользователя в сессии
+func (o *OIDC) Callback(w http.ResponseWriter, r *http.Request) {
+	sess := session.From(r.Context())
+	if sess == nil {
+		response.Error(w, http.StatusInternalServerError, "сессии не настроены")
+		return
+	}
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		response.Error(w, http.StatusUnauthorized, fmt.Sprintf("вход отклонен провайдером: %s %s", e, q.Get("error_description")))
+		return
+	}
+
+	st, err := o.readState(r)
+	if err != nil || q.Get("state") == "" || q.Get("state") != st.State {
+		response.Error(w, http.StatusBadRequest, "недействительный или устаревший запрос входа, начните вход заново")
+		return
+	}
+	cookies.Delete(w, oidcStateCookie, o.cookies)
+
+	claims, err := o.exchange(r.Context(), q.Get("code"), st)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	name, _ := claims.Raw["name"].(string)
+	email, _ := claims.Raw["email"].(string)
+
+	sess.Renew()
+	sess.Set(sessionSubject, claims.Subject)
+	sess.Set(sessionName, name)
+	sess.Set(sessionEmail, email)
+	sess.Set(sessionRoles, claims.Strings("roles"))
+	sess.Set(sessionExpires, time.Now().Add(o.cfg.SessionTTL).Unix())
+	sess.Set(sessionMethod, "oidc")
+
+	http.Redirect(w, r, st.ReturnTo, http.StatusFound)
+}
+
+// Logout - Обработчик выхода: удаление сессии
+func (o *OIDC) Logout(w http.ResponseWriter, r *http.Request) {
+	if sess := session.From(r.Context()); sess != nil {
+		sess.Destroy()
+	}
+	http.Redirect(w, r, localPath(r.URL.Query().Get("return_to")), http.StatusFound)
+}
+
+// Session - Middleware, пропускающий только запросы из сессии, в которой пользователь вошел через OIDC.
+// Субъект из ID токена сохраняется как имя клиента, см. IdentityFrom
+func (o *OIDC) Session() router.Middleware {
+	return RequireSession()
+}
+
+// RequireSession - Middleware, пропускающий только запросы из сессии, в которой пользователь вошел
+// через OIDC или по паролю (см. PasswordAuth). Имя и роли пользователя сохраняются в контексте, см. IdentityFrom
+func RequireSession() router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sess := session.From(r.Context())
+			if sess == nil || sess.String(sessionSubject) == "" {
+				unauthorized(w, `Bearer realm="session"`)
+				return
+			}
+
+			// Значения сессии хранятся в JSON, поэтому число возвращается как float64
+			if exp, _ := sess.Get(sessionExpires).(float64); time.Now().Unix() > int64(exp) {
+				unauthorized(w, `Bearer realm="session"`)
+				return
+			}
+
+			method := sess.String(sessionMethod)
+			if method == "" {
+				method = "oidc"
+			}
+			id := Identity{Name: sess.String(sessionSubject), Method: method, Roles: sess.Strings(sessionRoles)}
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+		})
+	}
+}
+
+// exchange - Обмен кода авторизации на токены и проверка ID токена
+func (o *OIDC) exchange(ctx context.Context, code string, st oidcState) (*Claims, error) {
+	p, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {o.cfg.RedirectURL},
+		"code_verifier": {st.Verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.cfg.ClientID), url.QueryEscape(o.cfg.ClientSecret))
+
+	var tokens struct {
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	status, err := o.doJSON(req, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || tokens.IDToken == "" {
+		return nil, fmt.Errorf("oidc: провайдер не выдал ID токен: %d %s %s", status, tokens.Error, tokens.Description)
+	}
+
+	claims, err := o.verifyIDToken(ctx, tokens.IDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// nonce защищает от повторного использования перехваченного ID токена
+	if nonce, _ := claims.Raw["nonce"].(string); nonce != st.Nonce {
+		return nil, errors.New("oidc: nonce ID токена не совпадает с запросом входа")
+	}
+	return claims, nil
+}
+
+// verifyIDToken - Проверка подписи ID токена ключом провайдера и его утверждений iss, aud и exp
+func (o *OIDC) verifyIDToken(ctx context.Context, token string) (*Claims, error) {
+	var header struct {
+		Kid string `json:"kid"`
+	}
+	head, _, _ := strings.Cut(token, ".")
+	if err := decodeSegment(head, &header); err != nil {
+		return nil, fmt.Errorf("oidc: недействительный ID токен: %w", err)
+	}
+
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := NewJWTVerifier(key, o.cfg.Issuer, o.cfg.ClientID, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := v.Verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: ID токен: %w", err)
+	}
+	return claims, nil
+}
+
+// discover - Адреса провайдера из discovery документа. Документ запрашивается один раз
+func (o *OIDC) discover(ctx context.Context) (*oidcProvider, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.provider != nil {
+		return o.provider, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(o.cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+
+	var p oidcProvider
+	status, err := o.doJSON(req, &p)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc: некорректный discovery документ провайдера (статус %d)", status)
+	}
+
+	o.provider = &p
+	return o.provider, nil
+}
+
+// key - Ключ подписи провайдера kid. Если ключ неизвестен, набор ключей запрашивается заново: провайдер мог их сменить
+func (o *OIDC) key(ctx context.Context, kid string) (any, error) {
+	o.mu.Lock()
+	key, ok := o.keys[kid]
+	o.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	p, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.JWKSURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if status, err := o.doJSON(req, &set); err != nil {
+		return nil, err
+	} else if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: набор ключей провайдера недоступен (статус %d)", status)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+
+	o.mu.Lock()
+	o.keys = keys
+	o.mu.Unlock()
+
+	if key, ok = keys[kid]; !ok {
+		return nil, fmt.Errorf("oidc: неизвестный ключ подписи %q", kid)
+	}
+	return key, nil
+}
+
+// doJSON - Выполнение запроса к провайдеру и разбор JSON ответа в v
+func (o *OIDC) doJSON(req *http.Request, v any) (int, error) {
+	resp, err := o.cfg.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("oidc: провайдер недоступен: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, 1<<20)).Decode(v); err != nil {
+		return resp.StatusCode, fmt.Errorf("oidc: некорректный ответ провайдера (статус %d): %w", resp.StatusCode, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// readState - Параметры входа из cookie, если подпись верна и срок не истек
+func (o *OIDC) readState(r *http.Request) (oidcState, error) {
+	var st oidcState
+	if err := o.readCookie(r, oidcStateCookie, &st); err != nil {
+		return st, err
+	}
+	if time.Now().After(st.Expires) {
+		return st, errors.New("срок запроса входа истек")
+	}
+	return st, nil
+}
+
+func (o *OIDC) readCookie(r *http.Request, name string, v any) error {
+	value, err := o.codec.GetSigned(r, name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(value), v)
+}
+
+// scopes - Запрашиваемые scope, openid всегда первый
+func (o *OIDC) scopes() []string {
+	scopes := []string{"openid"}
+	for _, s := range o.cfg.Scopes {
+		if s != "openid" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// jwk - Ключ из набора ключей провайдера (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey - Открытый ключ RSA или ECDSA
+func (k jwk) publicKey() (any, error) {
+	num := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, errors.New("некорректное число в ключе")
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := num(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := num(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("некорректная экспонента RSA")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		curve, ok := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("неподдерживаемая кривая %q", k.Crv)
+		}
+		x, err := num(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := num(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("неподдерживаемый тип ключа %q", k.Kty)
+	}
+}
+
+// randomString - Случайная строка из 32 байт в base64url
+func randomString() string {
+	var b [32]byte
+	_, _ = rand.Read(b[:])
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+// localPath - Путь для возврата после входа или выхода. Адреса других сайтов не принимаются,
+// чтобы ссылку входа нельзя было использовать для перенаправления на произвольный сайт
+func localPath(p string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.HasPrefix(p, "/\\") {
+		return "/"
+	}
+	return p
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pbkdf2Iterations - Число итераций PBKDF2-HMAC-SHA256 для новых хэшей, по рекомендации OWASP
+const pbkdf2Iterations = 600_000
+
+// HashPassword - Хэш пароля для хранения: bcrypt при сборке с тегом bcrypt, иначе PBKDF2-HMAC-SHA256
+// в формате $pbkdf2-sha256$<итерации>$<соль>$<хэш>. Проверка пароля - CheckPassword
+func HashPassword(password string) (string, error) {
+	if bcryptSupported() == nil {
+		return bcryptHash(password)
+	}
+
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	key, err := pbkdf2.Key(sha256.New, password, salt, pbkdf2Iterations, sha256.Size)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("$pbkdf2-sha256$%d$%s$%s", pbkdf2Iterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword - Пароль password соответствует хэшу hash из HashPassword
+func CheckPassword(hash, password string) bool {
+	if !strings.HasPrefix(hash, "$pbkdf2-sha256$") {
+		return bcryptMatch(hash, password)
+	}
+
+	parts := strings.Split(hash, "$") // "", "pbkdf2-sha256", итерации, соль, хэш
+	if len(parts) != 5 {
+		return false
+	}
+	iter, err := strconv.Atoi(parts[2])
+	if err != nil || iter < 1 {
+		return false
+	}
+	salt, err1 := base64.RawStdEncoding.DecodeString(parts[3])
+	want, err2 := base64.RawStdEncoding.DecodeString(parts[4])
+	if err1 != nil || err2 != nil || len(want) == 0 {
+		return false
+	}
+
+	got, err := pbkdf2.Key(sha256.New, password, salt, iter, len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// MaxPasswordBytes - Наибольшая длина пароля в байтах, которую учитывает HashPassword: bcrypt отбрасывает
+// все, что длиннее 72 байт. 0 - без ограничения
+func MaxPasswordBytes() int {
+	if bcryptSupported() == nil {
+		return 72
+	}
+	return 0
+}
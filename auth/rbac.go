@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// Policy - Правила ролевого доступа
+type Policy struct {
+	// Разрешения каждой роли. Разрешение * дает все разрешения, debug:* - все разрешения вида debug:...
+	Roles map[string][]string
+	// Роли, назначенные клиентам по имени (Identity.Name), в дополнение к ролям из токена или сессии
+	Assignments map[string][]string
+}
+
+// RBAC - Проверка ролей и разрешений аутентифицированного клиента. Правила можно заменить во время работы, см. Update
+type RBAC struct {
+	policy atomic.Pointer[Policy]
+}
+
+// NewRBAC - Проверка по правилам p
+func NewRBAC(p Policy) *RBAC {
+	r := &RBAC{}
+	r.Update(p)
+	return r
+}
+
+// Update - Замена правил. Применяется к следующим запросам
+func (a *RBAC) Update(p Policy) {
+	a.policy.Store(&p)
+}
+
+// forbidden - Подробности ответа 403
+type forbidden struct {
+	Required []string `json:"required"` // Чего требует маршрут
+	Missing  []string `json:"missing"`  // Чего не хватает клиенту
+}
+
+// RequireRole - Middleware, пропускающий только клиентов хотя бы с одной из ролей roles.
+// Запрос без аутентификации получает 401, клиент без нужной роли - 403
+func (a *RBAC) RequireRole(roles ...string) router.Middleware {
+	return a.require(roles, func(id Identity) []string {
+		if slices.ContainsFunc(roles, a.roles(id).Contains) {
+			return nil
+		}
+		return roles
+	})
+}
+
+// RequirePermission - Middleware, пропускающий только клиентов со всеми разрешениями perms.
+// Запрос без аутентификации получает 401, клиент без какого-либо разрешения - 403
+func (a *RBAC) RequirePermission(perms ...string) router.Middleware {
+	return a.require(perms, func(id Identity) []string {
+		granted := a.permissions(id)
+
+		var missing []string
+		for _, p := range perms {
+			if !permitted(granted, p) {
+				missing = append(missing, p)
+			}
+		}
+		return missing
+	})
+}
+
+// require - Middleware, отклоняющий запрос, если check вернул непустой список недостающих ролей или разрешений
+func (a *RBAC) require(required []string, check func(Identity) []string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := IdentityFrom(r.Context())
+			if !ok {
+				unauthorized(w, `Bearer realm="api"`)
+				return
+			}
+
+			if missing := check(id); len(missing) > 0 {
+				response.JSON(w, http.StatusForbidden, response.Body{
+					Error: "недостаточно прав",
+					Data:  forbidden{Required: required, Missing: missing},
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// roles - Роли клиента: из токена или сессии и назначенные в правилах
+func (a *RBAC) roles(id Identity) roleSet {
+	set := make(roleSet)
+	for _, r := range id.Roles {
+		set[r] = struct{}{}
+	}
+	for _, r := range a.policy.Load().Assignments[id.Name] {
+		set[r] = struct{}{}
+	}
+	return set
+}
+
+// permissions - Разрешения всех ролей клиента
+func (a *RBAC) permissions(id Identity) []string {
+	p := a.policy.Load()
+
+	var perms []string
+	for r := range a.roles(id) {
+		perms = append(perms, p.Roles[r]...)
+	}
+	return perms
+}
+
+// permitted - Разрешение perm есть среди granted напрямую или через шаблон
+func permitted(granted []string, perm string) bool {
+	for _, g := range granted {
+		if g == "*" || g == perm {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(g, "*"); ok && strings.HasPrefix(perm, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// roleSet - Множество ролей
+type roleSet map[string]struct{}
+
+func (s roleSet) Contains(role string) bool {
+	_, ok := s[role]
+	return ok
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRefreshNotFound - Токен обновления не выдавался или уже удален из хранилища
+var ErrRefreshNotFound = errors.New("токен обновления не найден")
+
+// RefreshToken - Запись о выданном токене обновления. Сам токен не хранится, только его хэш
+type RefreshToken struct {
+	ID        string    // SHA-256 токена в hex, см. refreshID
+	Family    string    // Цепочка токенов от одного входа: каждый обмен выдает следующий токен той же цепочки
+	Subject   string    // Имя учетной записи
+	ExpiresAt time.Time // Срок действия всей цепочки, обмен его не продлевает
+	Used      bool      // Токен уже обменян на новый
+}
+
+// RefreshStore - Хранилище выданных токенов обновления. Обменянные токены хранятся до окончания срока,
+// чтобы их повторное предъявление распознавалось как кража токена
+type RefreshStore interface {
+	// Create - Сохранение нового токена
+	Create(ctx context.Context, t RefreshToken) error
+	// Use - Отметка токена id как обменянного. Возвращает запись токена в состоянии до отметки, чтобы из двух
+	// одновременных обменов одного токена только один увидел Used == false. ErrRefreshNotFound, если токена нет
+	Use(ctx context.Context, id string) (RefreshToken, error)
+	// Revoke - Отзыв цепочки family: все ее токены удаляются и больше не обмениваются
+	Revoke(ctx context.Context, family string) error
+}
+
+// MemoryRefreshStore - RefreshStore в памяти процесса. После перезапуска все токены обновления недействительны
+type MemoryRefreshStore struct {
+	mu        sync.Mutex
+	tokens    map[string]RefreshToken
+	lastSweep time.Time
+}
+
+// NewMemoryRefreshStore - Пустое хранилище токенов обновления в памяти
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{tokens: map[string]RefreshToken{}}
+}
+
+func (m *MemoryRefreshStore) Create(_ context.Context, t RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(time.Now())
+	m.tokens[t.ID] = t
+	return nil
+}
+
+func (m *MemoryRefreshStore) Use(_ context.Context, id string) (RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[id]
+	if !ok {
+		return RefreshToken{}, ErrRefreshNotFound
+	}
+	used := t
+	used.Used = true
+	m.tokens[id] = used
+	return t, nil
+}
+
+func (m *MemoryRefreshStore) Revoke(_ context.Context, family string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, t := range m.tokens {
+		if t.Family == family {
+			delete(m.tokens, id)
+		}
+	}
+	return nil
+}
+
+// sweep - Удаление истекших токенов, не чаще раза в минуту
+func (m *MemoryRefreshStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	for id, t := range m.tokens {
+		if now.After(t.ExpiresAt) {
+			delete(m.tokens, id)
+		}
+	}
+	m.lastSweep = now
+}
+
+// newRefreshToken - Случайный токен обновления и его идентификатор для хранилища
+func newRefreshToken() (token, id string) {
+	var b [32]byte
+	_, _ = rand.Read(b[:])
+	token = base64.RawURLEncoding.EncodeToString(b[:])
+	return token, refreshID(token)
+}
+
+// refreshID - Идентификатор токена в хранилище: утечка хранилища не дает действующих токенов
+func refreshID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newFamily - Идентификатор новой цепочки токенов обновления
+func newFamily() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
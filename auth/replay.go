@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// Заголовки запроса для защиты от повтора, см. Replay
+const (
+	NonceHeader     = "X-Nonce"     // Уникальное значение каждого запроса клиента
+	TimestampHeader = "X-Timestamp" // Время отправки запроса: unix время в секундах
+)
+
+// nonceFormat - Допустимое значение X-Nonce: от 16 до 128 символов base64url или hex, например UUID
+var nonceFormat = regexp.MustCompile(`^[A-Za-z0-9_-]{16,128}$`)
+
+// NonceStore - Хранилище использованных значений с ограниченным сроком хранения
+type NonceStore interface {
+	// Remember - Запоминание ключа key до момента until. false, если ключ уже запомнен и срок еще не истек
+	Remember(ctx context.Context, key string, until time.Time) (bool, error)
+}
+
+// DefaultNonceEntries - Наибольшее число ключей MemoryNonces, если не задано другое
+const DefaultNonceEntries = 500000
+
+// ErrNoncesFull - В MemoryNonces нет места: все запомненные ключи еще действуют
+var ErrNoncesFull = errors.New("nonces: хранилище заполнено")
+
+// MemoryNonces - NonceStore в памяти процесса. Несколько экземпляров сервера не видят значения друг друга
+type MemoryNonces struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time // Запомненные ключи и момент, после которого их можно забыть
+	max       int
+	lastSweep time.Time
+}
+
+// NewMemoryNonces - Пустое хранилище в памяти не больше чем на max ключей, 0 - DefaultNonceEntries.
+// Заполненное хранилище не вытесняет действующие ключи, иначе повтор запроса снова был бы принят:
+// Remember возвращает ErrNoncesFull, пока сроки ключей не истекут
+func NewMemoryNonces(max int) *MemoryNonces {
+	m := &MemoryNonces{seen: map[string]time.Time{}}
+	m.SetLimit(max)
+	return m
+}
+
+// SetLimit - Замена наибольшего числа ключей, 0 - DefaultNonceEntries. Уже запомненные ключи сохраняются
+func (m *MemoryNonces) SetLimit(max int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if max <= 0 {
+		max = DefaultNonceEntries
+	}
+	m.max = max
+}
+
+func (m *MemoryNonces) Remember(_ context.Context, key string, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now, time.Minute)
+
+	prev, ok := m.seen[key]
+	if ok && now.Before(prev) {
+		return false, nil
+	}
+	if !ok && len(m.seen) >= m.max {
+		// Место могут освободить истекшие ключи, но перебирать их при каждом запросе слишком дорого
+		m.sweep(now, time.Second)
+		if len(m.seen) >= m.max {
+			return false, ErrNoncesFull
+		}
+	}
+	m.seen[key] = until
+	return true, nil
+}
+
+// sweep - Удаление ключей с истекшим сроком, не чаще раза в interval
+func (m *MemoryNonces) sweep(now time.Time, interval time.Duration) {
+	if now.Sub(m.lastSweep) < interval {
+		return
+	}
+	for key, until := range m.seen {
+		if now.After(until) {
+			delete(m.seen, key)
+		}
+	}
+	m.lastSweep = now
+}
+
+// Replay - Middleware защиты от повтора запросов, например для платежных API: клиент передает в каждом запросе
+// уникальный X-Nonce и время отправки X-Timestamp. Запрос отклоняется с ответом 400, если заголовков нет
+// или время отличается от времени сервера больше чем на maxAge, с ответом 409, если такой X-Nonce уже был
+// за это время, и с ответом 503, если store не может его запомнить, например когда хранилище MemoryNonces заполнено.
+// Значения запоминаются отдельно для каждого клиента, поэтому middleware ставится после аутентификации
+func Replay(store NonceStore, maxAge time.Duration) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nonce := r.Header.Get(NonceHeader)
+			if !nonceFormat.MatchString(nonce) {
+				response.Error(w, http.StatusBadRequest, "нужен заголовок "+NonceHeader+": от 16 до 128 символов A-Z, a-z, 0-9, _ и -")
+				return
+			}
+
+			t, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+			if err != nil {
+				response.Error(w, http.StatusBadRequest, "нужен заголовок "+TimestampHeader+": unix время в секундах")
+				return
+			}
+			sent := time.Unix(t, 0)
+			if d := time.Since(sent); d > maxAge || d < -maxAge {
+				response.Error(w, http.StatusBadRequest, TimestampHeader+" отличается от времени сервера больше допустимого")
+				return
+			}
+
+			// Запрос с тем же X-Nonce после until отклонит проверка времени, поэтому дольше его хранить не нужно
+			id, _ := IdentityFrom(r.Context())
+			fresh, err := store.Remember(r.Context(), id.Method+" "+id.Name+" "+nonce, sent.Add(maxAge))
+			if err != nil {
+				logging.From(r.Context()).Error("auth: replay: remember nonce", "error", err)
+				response.Error(w, http.StatusServiceUnavailable, "не удалось проверить повтор запроса")
+				return
+			}
+			if !fresh {
+				logging.From(r.Context()).Warn("auth: replayed request", "client", id.Name, "nonce", nonce)
+				response.Error(w, http.StatusConflict, "запрос с таким "+NonceHeader+" уже выполнен")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// replayRequest - Запрос клиента client с заголовками X-Nonce nonce и X-Timestamp sent, пустые значения не передаются
+func replayRequest(client, nonce, sent string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/v1/payments", nil)
+	if nonce != "" {
+		r.Header.Set(NonceHeader, nonce)
+	}
+	if sent != "" {
+		r.Header.Set(TimestampHeader, sent)
+	}
+	return r.WithContext(WithIdentity(r.Context(), Identity{Name: client, Method: "api_key"}))
+}
+
+// unix - Время now + d в формате X-Timestamp
+func unix(d time.Duration) string {
+	return strconv.FormatInt(time.Now().Add(d).Unix(), 10)
+}
+
+func TestReplay(t *testing.T) {
+	const nonce = "0123456789abcdef"
+
+	tests := []struct {
+		name   string
+		before []*http.Request // Запросы, выполненные раньше проверяемого
+		req    *http.Request
+		status int
+	}{
+		{name: "новый запрос", req: replayRequest("billing", nonce, unix(0)), status: http.StatusOK},
+		{name: "время в пределах окна", req: replayRequest("billing", nonce, unix(-4*time.Minute)), status: http.StatusOK},
+
+		{name: "нет X-Nonce", req: replayRequest("billing", "", unix(0)), status: http.StatusBadRequest},
+		{name: "короткий X-Nonce", req: replayRequest("billing", "0123456789", unix(0)), status: http.StatusBadRequest},
+		{name: "X-Nonce с недопустимыми символами", req: replayRequest("billing", "0123456789abcdef/", unix(0)), status: http.StatusBadRequest},
+		{name: "нет X-Timestamp", req: replayRequest("billing", nonce, ""), status: http.StatusBadRequest},
+		{name: "X-Timestamp не число", req: replayRequest("billing", nonce, "yesterday"), status: http.StatusBadRequest},
+		{name: "устаревший X-Timestamp", req: replayRequest("billing", nonce, unix(-6*time.Minute)), status: http.StatusBadRequest},
+		{name: "X-Timestamp из будущего", req: replayRequest("billing", nonce, unix(6*time.Minute)), status: http.StatusBadRequest},
+
+		{
+			name:   "повтор X-Nonce",
+			before: []*http.Request{replayRequest("billing", nonce, unix(0))},
+			req:    replayRequest("billing", nonce, unix(0)),
+			status: http.StatusConflict,
+		},
+		{
+			name:   "повтор X-Nonce с другим временем",
+			before: []*http.Request{replayRequest("billing", nonce, unix(-time.Minute))},
+			req:    replayRequest("billing", nonce, unix(0)),
+			status: http.StatusConflict,
+		},
+		{
+			name:   "тот же X-Nonce другого клиента",
+			before: []*http.Request{replayRequest("billing", nonce, unix(0))},
+			req:    replayRequest("crm", nonce, unix(0)),
+			status: http.StatusOK,
+		},
+		{
+			name:   "отклоненный запрос не запоминает X-Nonce",
+			before: []*http.Request{replayRequest("billing", nonce, unix(-time.Hour))},
+			req:    replayRequest("billing", nonce, unix(0)),
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Replay(NewMemoryNonces(0), 5*time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			for _, r := range tt.before {
+				h.ServeHTTP(httptest.NewRecorder(), r)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, tt.req)
+			if w.Code != tt.status {
+				t.Fatalf("статус %d, ожидается %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestMemoryNoncesFull(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryNonces(3)
+	until := time.Now().Add(time.Minute)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if fresh, err := m.Remember(ctx, key, until); !fresh || err != nil {
+			t.Fatalf("ключ %s: %v %v", key, fresh, err)
+		}
+	}
+
+	// Заполненное хранилище не принимает новые ключи, но продолжает узнавать запомненные
+	if fresh, err := m.Remember(ctx, "d", until); fresh || !errors.Is(err, ErrNoncesFull) {
+		t.Fatalf("новый ключ в заполненном хранилище: %v %v, ожидается ErrNoncesFull", fresh, err)
+	}
+	if fresh, err := m.Remember(ctx, "a", until); fresh || err != nil {
+		t.Fatalf("повтор ключа в заполненном хранилище: %v %v", fresh, err)
+	}
+
+	// Истекший ключ освобождает место
+	m.seen["b"] = time.Now().Add(-time.Second)
+	m.lastSweep = time.Time{}
+	if fresh, err := m.Remember(ctx, "d", until); !fresh || err != nil {
+		t.Fatalf("ключ после истечения другого: %v %v", fresh, err)
+	}
+	if len(m.seen) != 3 {
+		t.Fatalf("%d ключей, ожидается 3", len(m.seen))
+	}
+
+	// SetLimit увеличивает хранилище без потери ключей
+	m.SetLimit(4)
+	if fresh, err := m.Remember(ctx, "e", until); !fresh || err != nil {
+		t.Fatalf("ключ после SetLimit: %v %v", fresh, err)
+	}
+	if fresh, _ := m.Remember(ctx, "c", until); fresh {
+		t.Fatalf("SetLimit забыл запомненный ключ")
+	}
+}
+
+func TestReplayStoreFull(t *testing.T) {
+	h := Replay(NewMemoryNonces(1), 5*time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, replayRequest("billing", "0123456789abcdef", unix(0)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("первый запрос: статус %d", w.Code)
+	}
+
+	// Новый X-Nonce не запомнить - запрос отклоняется, а не пропускается без проверки
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, replayRequest("billing", "fedcba9876543210", unix(0)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("заполненное хранилище: статус %d, ожидается 503", w.Code)
+	}
+}
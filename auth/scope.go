@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// ScopesFrom - Scope OAuth из токена запроса: утверждение scope (строка через пробел, RFC 9068)
+// или scp (массив строк, так их выдают Azure AD и Okta). nil, если запрос не прошел через JWT middleware
+func ScopesFrom(ctx context.Context) []string {
+	c, ok := ClaimsFrom(ctx)
+	if !ok {
+		return nil
+	}
+	if scopes := c.Strings("scope"); scopes != nil {
+		return scopes
+	}
+	return c.Strings("scp")
+}
+
+// RequireScope - Middleware, пропускающий только запросы с JWT, в котором есть все scope из scopes.
+// Запрос без токена получает 401, токен без какого-либо scope - 403 с заголовком
+// WWW-Authenticate: Bearer error="insufficient_scope" (RFC 6750) и списками требуемых и недостающих scope в data.
+// Ставится после JWT middleware
+func RequireScope(scopes ...string) router.Middleware {
+	challenge := fmt.Sprintf(`Bearer realm="api", error="insufficient_scope", scope=%q`, strings.Join(scopes, " "))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := ClaimsFrom(r.Context()); !ok {
+				unauthorized(w, `Bearer realm="api"`)
+				return
+			}
+
+			granted := ScopesFrom(r.Context())
+			var missing []string
+			for _, s := range scopes {
+				if !slices.Contains(granted, s) {
+					missing = append(missing, s)
+				}
+			}
+
+			if len(missing) > 0 {
+				w.Header().Set("WWW-Authenticate", challenge)
+				response.JSON(w, http.StatusForbidden, response.Body{
+					Error: "недостаточно scope в токене",
+					Data:  forbidden{Required: scopes, Missing: missing},
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// SignatureHeader - Заголовок запроса с подписью HMAC: client=<имя клиента>, t=<unix время>, sig=<HMAC-SHA256 в hex>
+const SignatureHeader = "X-Signature"
+
+// SignaturePayload - Подписываемые данные запроса: время, метод, путь с query и SHA-256 тела в hex через перевод строки
+func SignaturePayload(t int64, method, uri string, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	return []byte(strconv.FormatInt(t, 10) + "\n" + method + "\n" + uri + "\n" + hex.EncodeToString(sum[:]))
+}
+
+// Sign - Значение заголовка X-Signature для запроса клиента client, подписанного секретом secret в момент t
+func Sign(client string, secret []byte, t time.Time, method, uri string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(SignaturePayload(t.Unix(), method, uri, body))
+	return fmt.Sprintf("client=%s, t=%d, sig=%s", client, t.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+// SignatureVerifier - Проверка подписи HMAC запросов по общим секретам клиентов.
+//
+// Подпись действительна в течение maxAge от времени t в обе стороны. Использованные подписи запоминаются
+// на это время, поэтому повторить перехваченный запрос нельзя
+type SignatureVerifier struct {
+	mu      sync.Mutex
+	secrets map[string][]byte // Секреты по имени клиента
+	maxAge  time.Duration     // Допустимое отклонение t от текущего времени
+	seen    *MemoryNonces     // Использованные подписи
+}
+
+// NewSignatureVerifier - Проверка подписей клиентов из списка имя клиента - секрет не короче 32 байт
+func NewSignatureVerifier(secrets map[string]string, maxAge time.Duration) (*SignatureVerifier, error) {
+	v := &SignatureVerifier{seen: NewMemoryNonces(0)}
+	if err := v.Update(secrets, maxAge); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Update - Замена секретов клиентов и допустимого возраста подписи. Использованные подписи не забываются
+func (v *SignatureVerifier) Update(secrets map[string]string, maxAge time.Duration) error {
+	list := make(map[string][]byte, len(secrets))
+	for client, secret := range secrets {
+		if len(secret) < 32 {
+			return fmt.Errorf("signature: клиент %s: секрет должен быть не короче 32 байт", client)
+		}
+		list[client] = []byte(secret)
+	}
+	if maxAge <= 0 {
+		return errors.New("signature: ожидается положительный допустимый возраст подписи")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secrets, v.maxAge = list, maxAge
+	return nil
+}
+
+// Verify - Проверка заголовка X-Signature запроса r с телом body. Возвращает имя клиента
+func (v *SignatureVerifier) Verify(r *http.Request, body []byte) (string, error) {
+	client, t, sig, err := parseSignature(r.Header.Get(SignatureHeader))
+	if err != nil {
+		return "", err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	secret, ok := v.secrets[client]
+	if !ok {
+		return "", fmt.Errorf("неизвестный клиент %q", client)
+	}
+
+	now := time.Now()
+	if d := now.Sub(time.Unix(t, 0)); d > v.maxAge || d < -v.maxAge {
+		return "", errors.New("подпись устарела: время запроса отличается от времени сервера больше допустимого")
+	}
+
+	// Путь берется из строки запроса: маршрутизатор может изменить r.URL (например, версия API по умолчанию)
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(SignaturePayload(t, r.Method, uri, body))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", errors.New("подпись не совпадает")
+	}
+
+	// Подпись достаточно помнить, пока она проходит проверку времени
+	fresh, err := v.seen.Remember(r.Context(), client+" "+hex.EncodeToString(sig), time.Unix(t, 0).Add(v.maxAge))
+	if err != nil {
+		return "", fmt.Errorf("не удалось проверить повтор подписи: %w", err)
+	}
+	if !fresh {
+		return "", errors.New("подпись уже использована")
+	}
+
+	return client, nil
+}
+
+// parseSignature - Имя клиента, время и подпись из значения заголовка X-Signature
+func parseSignature(header string) (client string, t int64, sig []byte, err error) {
+	if header == "" {
+		return "", 0, nil, errors.New("нет заголовка " + SignatureHeader)
+	}
+
+	var ts string
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "client":
+			client = value
+		case "t":
+			ts = value
+		case "sig":
+			if sig, err = hex.DecodeString(value); err != nil {
+				return "", 0, nil, errors.New("подпись должна быть в hex")
+			}
+		}
+	}
+
+	if client == "" || ts == "" || len(sig) == 0 {
+		return "", 0, nil, fmt.Errorf("в заголовке %s нужны client, t и sig", SignatureHeader)
+	}
+	if t, err = strconv.ParseInt(ts, 10, 64); err != nil {
+		return "", 0, nil, errors.New("t должно быть unix временем в секундах")
+	}
+	return client, t, sig, nil
+}
+
+// Signature - Middleware, пропускающий только запросы с действительной подписью HMAC в заголовке X-Signature.
+// Тело запроса читается целиком для проверки подписи и передается обработчику без изменений.
+// Имя клиента сохраняется в контексте запроса, см. IdentityFrom
+func Signature(v *SignatureVerifier) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(SignatureHeader) == "" {
+				unauthorized(w, `Signature realm="api", headers="`+SignatureHeader+`"`)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var tooBig *http.MaxBytesError
+				if errors.As(err, &tooBig) {
+					response.Error(w, http.StatusRequestEntityTooLarge, "тело запроса больше допустимого размера")
+					return
+				}
+				response.Error(w, http.StatusBadRequest, "не удалось прочитать тело запроса")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			client, err := v.Verify(r, body)
+			if err != nil {
+				response.Error(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), Identity{Name: client, Method: "signature"})))
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signatureClients - Клиенты тестового SignatureVerifier
+var signatureClients = map[string]string{
+	"billing": "billing-secret-0123456789abcdef0123",
+	"crm":     "crm-secret-0123456789abcdef01234567",
+}
+
+// signedRequest - Запрос method uri с телом body и заголовком X-Signature signature
+func signedRequest(method, uri, body, signature string) *http.Request {
+	r := httptest.NewRequest(method, uri, strings.NewReader(body))
+	if signature != "" {
+		r.Header.Set(SignatureHeader, signature)
+	}
+	return r
+}
+
+// replaceSig - Значение X-Signature с подписью, измененной функцией change
+func replaceSig(t *testing.T, header string, change func(sig []byte) []byte) string {
+	t.Helper()
+	i := strings.Index(header, "sig=")
+	sig, err := hex.DecodeString(header[i+len("sig="):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header[:i+len("sig=")] + hex.EncodeToString(change(sig))
+}
+
+func TestSignatureVerify(t *testing.T) {
+	const maxAge = 5 * time.Minute
+	now := time.Now()
+	secret := []byte(signatureClients["billing"])
+	body := `{"amount":100}`
+	valid := Sign("billing", secret, now, http.MethodPost, "/v1/payments?id=7", []byte(body))
+
+	tests := []struct {
+		name      string
+		method    string
+		uri       string
+		body      string // Тело запроса, по умолчанию {"amount":100}
+		empty     bool   // Запрос без тела
+		signature string
+		err       string // Часть текста ошибки, пустая строка - подпись действительна
+	}{
+		{name: "действительная подпись", signature: valid},
+		{name: "пустое тело", empty: true, signature: Sign("billing", secret, now, http.MethodPost, "/v1/payments?id=7", nil)},
+
+		{name: "измененное тело", body: `{"amount":1000}`, signature: valid, err: "подпись не совпадает"},
+		{name: "тело с лишним пробелом", body: body + " ", signature: valid, err: "подпись не совпадает"},
+		{name: "измененный метод", method: http.MethodPut, signature: valid, err: "подпись не совпадает"},
+		{name: "измененный путь", uri: "/v1/refunds?id=7", signature: valid, err: "подпись не совпадает"},
+		{name: "измененный query", uri: "/v1/payments?id=8", signature: valid, err: "подпись не совпадает"},
+
+		// Поля заголовка X-Signature входят в подпись: t - напрямую, client - через секрет
+		{name: "измененное время в заголовке", signature: strings.Replace(valid, "t="+itoa(now.Unix()), "t="+itoa(now.Unix()+1), 1), err: "подпись не совпадает"},
+		{name: "чужое имя клиента", signature: strings.Replace(valid, "client=billing", "client=crm", 1), err: "подпись не совпадает"},
+		{name: "неизвестный клиент", signature: strings.Replace(valid, "client=billing", "client=shop", 1), err: `неизвестный клиент "shop"`},
+		{name: "чужой секрет", signature: Sign("billing", []byte(signatureClients["crm"]), now, http.MethodPost, "/v1/payments?id=7", []byte(body)), err: "подпись не совпадает"},
+
+		// Подпись сравнивается целиком: совпадение начала или длины ничего не дает
+		{name: "другой первый байт", signature: replaceSig(t, valid, func(s []byte) []byte { s[0] ^= 1; return s }), err: "подпись не совпадает"},
+		{name: "другой последний байт", signature: replaceSig(t, valid, func(s []byte) []byte { s[len(s)-1] ^= 1; return s }), err: "подпись не совпадает"},
+		{name: "начало подписи", signature: replaceSig(t, valid, func(s []byte) []byte { return s[:16] }), err: "подпись не совпадает"},
+		{name: "подпись с лишним байтом", signature: replaceSig(t, valid, func(s []byte) []byte { return append(s, 0) }), err: "подпись не совпадает"},
+
+		{name: "время в пределах окна в прошлом", signature: Sign("billing", secret, now.Add(-maxAge+time.Minute), http.MethodPost, "/v1/payments?id=7", []byte(body))},
+		{name: "время в пределах окна в будущем", signature: Sign("billing", secret, now.Add(maxAge-time.Minute), http.MethodPost, "/v1/payments?id=7", []byte(body))},
+		{name: "время до окна", signature: Sign("billing", secret, now.Add(-maxAge-time.Minute), http.MethodPost, "/v1/payments?id=7", []byte(body)), err: "подпись устарела"},
+		{name: "время после окна", signature: Sign("billing", secret, now.Add(maxAge+time.Minute), http.MethodPost, "/v1/payments?id=7", []byte(body)), err: "подпись устарела"},
+
+		{name: "нет заголовка", err: "нет заголовка X-Signature"},
+		{name: "нет sig", signature: "client=billing, t=" + itoa(now.Unix()), err: "нужны client, t и sig"},
+		{name: "sig не в hex", signature: "client=billing, t=" + itoa(now.Unix()) + ", sig=zz", err: "в hex"},
+		{name: "t не число", signature: "client=billing, t=now, sig=00", err: "unix временем"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewSignatureVerifier(signatureClients, maxAge)
+			if err != nil {
+				t.Fatal(err)
+			}
+			method, uri := tt.method, tt.uri
+			if method == "" {
+				method = http.MethodPost
+			}
+			if uri == "" {
+				uri = "/v1/payments?id=7"
+			}
+			reqBody := body
+			if tt.body != "" || tt.empty {
+				reqBody = tt.body
+			}
+
+			client, err := v.Verify(signedRequest(method, uri, reqBody, tt.signature), []byte(reqBody))
+			if tt.err == "" {
+				if err != nil || client != "billing" {
+					t.Fatalf("подпись отклонена: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("ошибка %v, ожидается %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSignatureReplay(t *testing.T) {
+	v, err := NewSignatureVerifier(signatureClients, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := Sign("billing", []byte(signatureClients["billing"]), time.Now(), http.MethodPost, "/v1/payments", []byte("{}"))
+
+	if _, err := v.Verify(signedRequest(http.MethodPost, "/v1/payments", "{}", signature), []byte("{}")); err != nil {
+		t.Fatalf("первый запрос: %v", err)
+	}
+	if _, err := v.Verify(signedRequest(http.MethodPost, "/v1/payments", "{}", signature), []byte("{}")); err == nil ||
+		!strings.Contains(err.Error(), "уже использована") {
+		t.Fatalf("повтор запроса: %v, ожидается отказ", err)
+	}
+
+	// Обновление секретов не сбрасывает использованные подписи
+	if err := v.Update(signatureClients, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(signedRequest(http.MethodPost, "/v1/payments", "{}", signature), []byte("{}")); err == nil {
+		t.Fatalf("повтор запроса после Update принят")
+	}
+}
+
+func TestSignatureVerifierConfig(t *testing.T) {
+	if _, err := NewSignatureVerifier(map[string]string{"short": "secret"}, time.Minute); err == nil {
+		t.Fatalf("принят секрет короче 32 байт")
+	}
+	if _, err := NewSignatureVerifier(signatureClients, 0); err == nil {
+		t.Fatalf("принят нулевой допустимый возраст подписи")
+	}
+}
+
+func TestSignatureMiddleware(t *testing.T) {
+	v, err := NewSignatureVerifier(signatureClients, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Signature(v)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := IdentityFrom(r.Context())
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(id.Name + " " + string(body)))
+	}))
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	signature := Sign("crm", []byte(signatureClients["crm"]), time.Now(), http.MethodPost, "/v1/hook", []byte("payload"))
+	if w := serve(signedRequest(http.MethodPost, "/v1/hook", "payload", signature)); w.Code != http.StatusOK || w.Body.String() != "crm payload" {
+		t.Fatalf("подписанный запрос: статус %d, ответ %q", w.Code, w.Body.String())
+	}
+
+	w := serve(signedRequest(http.MethodPost, "/v1/hook", "payload", ""))
+	if w.Code != http.StatusUnauthorized || !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Signature") {
+		t.Fatalf("запрос без подписи: статус %d, WWW-Authenticate %q", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+
+	if w := serve(signedRequest(http.MethodPost, "/v1/hook", "changed", signature)); w.Code != http.StatusUnauthorized {
+		t.Fatalf("измененное тело: статус %d, ожидается 401", w.Code)
+	}
+
+	big := signedRequest(http.MethodPost, "/v1/hook", strings.Repeat("x", 100), signature)
+	big.Body = http.MaxBytesReader(httptest.NewRecorder(), big.Body, 10)
+	if w := serve(big); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("тело больше лимита: статус %d, ожидается 413", w.Code)
+	}
+}
+
+// itoa - Число в десятичной записи
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
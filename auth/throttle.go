@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/derv-dice/go-web-server/response"
+)
+
+// ThrottlePolicy - Пороги защиты входа по паролю от подбора
+type ThrottlePolicy struct {
+	AccountThreshold int           // Неудачных попыток для одной учетной записи до первой блокировки
+	IPThreshold      int           // Неудачных попыток с одного IP адреса до первой блокировки
+	BaseDelay        time.Duration // Длительность первой блокировки, каждая следующая вдвое дольше
+	MaxDelay         time.Duration // Наибольшая длительность блокировки
+	Window           time.Duration // Через сколько без неудачных попыток счетчик сбрасывается
+	MaxEntries       int           // Наибольшее число счетчиков в памяти, 0 - DefaultThrottleEntries
+}
+
+// DefaultThrottleEntries - Наибольшее число счетчиков LoginThrottle, если в ThrottlePolicy не задано другое
+const DefaultThrottleEntries = 100000
+
+// delay - Длительность блокировки после failures неудачных попыток при пороге threshold
+func (p ThrottlePolicy) delay(failures, threshold int) time.Duration {
+	n := failures - threshold
+	if n > 30 {
+		return p.MaxDelay
+	}
+	return min(p.BaseDelay<<n, p.MaxDelay)
+}
+
+// Lockout - Блокировка входа
+type Lockout struct {
+	Scope      string        // За что заблокирован вход: account - учетная запись, ip - адрес клиента
+	RetryAfter time.Duration // Через сколько можно повторить попытку
+}
+
+// attempts - Неудачные попытки входа для одной учетной записи или одного адреса
+type attempts struct {
+	key         string
+	failures    int
+	last        time.Time // Время последней неудачной попытки
+	lockedUntil time.Time
+}
+
+// LoginThrottle - Защита входа по паролю от подбора: после AccountThreshold неудачных попыток для учетной записи
+// или IPThreshold с одного адреса вход блокируется, и каждая следующая неудачная попытка удваивает блокировку.
+// Счетчики хранятся в памяти процесса, не больше MaxEntries: при переполнении вытесняются счетчики
+// с самой давней неудачной попыткой, чтобы перебор имен пользователей не занимал память без предела
+type LoginThrottle struct {
+	mu        sync.Mutex
+	policy    ThrottlePolicy
+	entries   map[string]*list.Element // Счетчики по ключу "account " + имя или "ip " + адрес, значения - *attempts
+	lru       *list.List               // Счетчики с недавней неудачной попыткой в начале
+	lastSweep time.Time
+}
+
+// NewLoginThrottle - Защита входа с порогами policy
+func NewLoginThrottle(policy ThrottlePolicy) *LoginThrottle {
+	return &LoginThrottle{policy: policy, entries: map[string]*list.Element{}, lru: list.New()}
+}
+
+// Update - Замена порогов. Накопленные счетчики и действующие блокировки сохраняются,
+// если только в новых порогах блокировка не выключена совсем
+func (t *LoginThrottle) Update(policy ThrottlePolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.policy = policy
+	if policy.AccountThreshold == 0 && policy.IPThreshold == 0 {
+		clear(t.entries)
+		t.lru.Init()
+		return
+	}
+	for t.lru.Len() > t.maxEntries() {
+		t.remove(t.lru.Back())
+	}
+}
+
+// Locked - Блокировка входа пользователя user с адреса ip. ok == false, если вход разрешен.
+// Если заблокированы и учетная запись, и адрес, возвращается более долгая блокировка
+func (t *LoginThrottle) Locked(user, ip string) (l Lockout, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for _, k := range [...]struct{ scope, key string }{{"account", "account " + user}, {"ip", "ip " + ip}} {
+		el, found := t.entries[k.key]
+		if !found {
+			continue
+		}
+		if e := el.Value.(*attempts); e.lockedUntil.After(now) {
+			if wait := e.lockedUntil.Sub(now); wait > l.RetryAfter {
+				l, ok = Lockout{Scope: k.scope, RetryAfter: wait}, true
+			}
+		}
+	}
+	return l, ok
+}
+
+// Failed - Учет неудачной попытки входа пользователя user с адреса ip. ok == true, если после нее вход заблокирован
+func (t *LoginThrottle) Failed(user, ip string) (l Lockout, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.sweep(now)
+
+	for _, k := range [...]struct {
+		scope, key string
+		threshold  int
+	}{{"account", "account " + user, t.policy.AccountThreshold}, {"ip", "ip " + ip, t.policy.IPThreshold}} {
+		if k.threshold <= 0 {
+			continue // Без порога счетчик ни на что не влияет
+		}
+
+		var e *attempts
+		if el, found := t.entries[k.key]; found {
+			e = el.Value.(*attempts)
+			if t.expired(e, now) {
+				*e = attempts{key: k.key}
+			}
+			t.lru.MoveToFront(el)
+		} else {
+			for t.lru.Len() >= t.maxEntries() {
+				t.remove(t.lru.Back())
+			}
+			e = &attempts{key: k.key}
+			t.entries[k.key] = t.lru.PushFront(e)
+		}
+
+		e.failures++
+		e.last = now
+		if e.failures >= k.threshold {
+			e.lockedUntil = now.Add(t.policy.delay(e.failures, k.threshold))
+			if wait := e.lockedUntil.Sub(now); wait > l.RetryAfter {
+				l, ok = Lockout{Scope: k.scope, RetryAfter: wait}, true
+			}
+		}
+	}
+	return l, ok
+}
+
+// Succeeded - Успешный вход пользователя user: его счетчик сбрасывается. Счетчик адреса не сбрасывается,
+// иначе вход в свою учетную запись позволял бы продолжать подбор паролей к чужим
+func (t *LoginThrottle) Succeeded(user string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if el, found := t.entries["account "+user]; found {
+		t.remove(el)
+	}
+}
+
+// expired - Счетчик e больше не действует: блокировка закончилась и неудачных попыток не было дольше Window
+func (t *LoginThrottle) expired(e *attempts, now time.Time) bool {
+	return !e.lockedUntil.After(now) && now.Sub(e.last) > t.policy.Window
+}
+
+// sweep - Удаление недействующих счетчиков, не чаще раза в минуту
+func (t *LoginThrottle) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < time.Minute {
+		return
+	}
+	for el := t.lru.Front(); el != nil; {
+		next := el.Next()
+		if t.expired(el.Value.(*attempts), now) {
+			t.remove(el)
+		}
+		el = next
+	}
+	t.lastSweep = now
+}
+
+// maxEntries - Наибольшее число счетчиков по текущим порогам
+func (t *LoginThrottle) maxEntries() int {
+	if t.policy.MaxEntries > 0 {
+		return t.policy.MaxEntries
+	}
+	return DefaultThrottleEntries
+}
+
+// remove - Удаление счетчика el
+func (t *LoginThrottle) remove(el *list.Element) {
+	delete(t.entries, t.lru.Remove(el).(*attempts).key)
+}
+
+// TooManyAttempts - Ответ 429 на попытку входа при блокировке l: Retry-After и в data - scope и retry_after в секундах
+func TooManyAttempts(w http.ResponseWriter, l Lockout) {
+	// Округление вверх, чтобы повторная попытка не пришла раньше окончания блокировки
+	seconds := int(math.Ceil(l.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	response.JSON(w, http.StatusTooManyRequests, response.Body{
+		Error: "слишком много неудачных попыток входа, повторите позже",
+		Data:  map[string]any{"scope": l.Scope, "retry_after": seconds},
+	})
+}
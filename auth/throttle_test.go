@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// throttlePolicy - Пороги тестовой защиты входа
+var throttlePolicy = ThrottlePolicy{
+	AccountThreshold: 3,
+	IPThreshold:      5,
+	BaseDelay:        time.Minute,
+	MaxDelay:         10 * time.Minute,
+	Window:           time.Hour,
+}
+
+// failN - n неудачных попыток входа user с адреса ip, возвращается результат последней
+func failN(th *LoginThrottle, n int, user, ip string) (Lockout, bool) {
+	var l Lockout
+	var ok bool
+	for range n {
+		l, ok = th.Failed(user, ip)
+	}
+	return l, ok
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    func(th *LoginThrottle) (Lockout, bool)
+		locked  bool
+		scope   string
+		atLeast time.Duration // Наименьшая ожидаемая длительность блокировки
+	}{
+		{
+			name: "до порога учетной записи",
+			fail: func(th *LoginThrottle) (Lockout, bool) { return failN(th, 2, "alice", "10.0.0.1") },
+		},
+		{
+			name: "порог учетной записи",
+			fail: func(th *LoginThrottle) (Lockout, bool) { return failN(th, 3, "alice", "10.0.0.1") }, locked: true, scope: "account", atLeast: time.Minute,
+		},
+		{
+			name: "каждая попытка после порога удваивает блокировку",
+			fail: func(th *LoginThrottle) (Lockout, bool) { return failN(th, 5, "alice", "10.0.0.1") }, locked: true, scope: "account", atLeast: 4 * time.Minute,
+		},
+		{
+			name: "блокировка не дольше max_delay",
+			fail: func(th *LoginThrottle) (Lockout, bool) { return failN(th, 50, "alice", "10.0.0.1") }, locked: true, atLeast: 10 * time.Minute,
+		},
+		{
+			name: "порог адреса для разных учетных записей",
+			fail: func(th *LoginThrottle) (Lockout, bool) {
+				for i := range 4 {
+					th.Failed(fmt.Sprintf("user%d", i), "10.0.0.1")
+				}
+				return th.Failed("bob", "10.0.0.1")
+			},
+			locked: true, scope: "ip", atLeast: time.Minute,
+		},
+		{
+			name: "учетная запись с разных адресов",
+			fail: func(th *LoginThrottle) (Lockout, bool) {
+				th.Failed("alice", "10.0.0.1")
+				th.Failed("alice", "10.0.0.2")
+				return th.Failed("alice", "10.0.0.3")
+			},
+			locked: true, scope: "account", atLeast: time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := NewLoginThrottle(throttlePolicy)
+			l, ok := tt.fail(th)
+			if ok != tt.locked {
+				t.Fatalf("блокировка %v, ожидается %v", ok, tt.locked)
+			}
+			if !ok {
+				return
+			}
+			if tt.scope != "" && l.Scope != tt.scope {
+				t.Fatalf("блокировка по %s, ожидается по %s", l.Scope, tt.scope)
+			}
+			if l.RetryAfter < tt.atLeast-time.Second || l.RetryAfter > throttlePolicy.MaxDelay {
+				t.Fatalf("блокировка на %v, ожидается от %v до %v", l.RetryAfter, tt.atLeast, throttlePolicy.MaxDelay)
+			}
+			if _, locked := th.Locked("alice", "10.0.0.1"); !locked && tt.scope != "ip" {
+				t.Fatalf("Locked не видит блокировку")
+			}
+		})
+	}
+}
+
+func TestLoginThrottleSucceeded(t *testing.T) {
+	th := NewLoginThrottle(throttlePolicy)
+	failN(th, 2, "alice", "10.0.0.1")
+	th.Succeeded("alice")
+
+	// Счетчик учетной записи сброшен: до блокировки снова три попытки
+	if _, ok := failN(th, 2, "alice", "10.0.0.1"); ok {
+		t.Fatalf("вход заблокирован после сброса счетчика")
+	}
+	// Счетчик адреса не сбрасывается: 2 + 2 + 1 попытка с одного адреса
+	if l, ok := th.Failed("bob", "10.0.0.1"); !ok || l.Scope != "ip" {
+		t.Fatalf("блокировка %v %+v, ожидается блокировка адреса", ok, l)
+	}
+}
+
+func TestLoginThrottleWindow(t *testing.T) {
+	th := NewLoginThrottle(throttlePolicy)
+	failN(th, 2, "alice", "10.0.0.1")
+
+	// Последняя неудачная попытка была дольше Window назад
+	for _, el := range th.entries {
+		el.Value.(*attempts).last = time.Now().Add(-2 * throttlePolicy.Window)
+	}
+	if _, ok := failN(th, 2, "alice", "10.0.0.1"); ok {
+		t.Fatalf("старые неудачные попытки учтены после окна")
+	}
+
+	// Действующая блокировка не сбрасывается окном
+	failN(th, 1, "alice", "10.0.0.1")
+	for _, el := range th.entries {
+		el.Value.(*attempts).last = time.Now().Add(-2 * throttlePolicy.Window)
+	}
+	if _, ok := th.Locked("alice", "10.0.0.1"); !ok {
+		t.Fatalf("блокировка снята окном до своего окончания")
+	}
+}
+
+func TestLoginThrottleMaxEntries(t *testing.T) {
+	policy := throttlePolicy
+	policy.IPThreshold = 0
+	policy.MaxEntries = 10
+	th := NewLoginThrottle(policy)
+
+	// Перебор имен пользователей не увеличивает память сверх MaxEntries
+	for i := range 1000 {
+		th.Failed(fmt.Sprintf("user%d", i), fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+	if n := len(th.entries); n != policy.MaxEntries || th.lru.Len() != n {
+		t.Fatalf("%d счетчиков (в списке %d), ожидается %d", n, th.lru.Len(), policy.MaxEntries)
+	}
+
+	// Вытесняются счетчики с самой давней неудачной попыткой
+	failN(th, 2, "alice", "10.0.0.1")
+	for i := range policy.MaxEntries - 1 {
+		th.Failed(fmt.Sprintf("other%d", i), "10.0.0.1")
+	}
+	if _, ok := th.Failed("alice", "10.0.0.1"); !ok {
+		t.Fatalf("счетчик недавней попытки вытеснен")
+	}
+	if _, found := th.entries["account user999"]; found {
+		t.Fatalf("давний счетчик не вытеснен")
+	}
+
+	// Уменьшение MaxEntries сразу освобождает память
+	policy.MaxEntries = 3
+	th.Update(policy)
+	if n := len(th.entries); n != 3 || th.lru.Len() != 3 {
+		t.Fatalf("после Update %d счетчиков, ожидается 3", n)
+	}
+	if _, ok := th.Locked("alice", "10.0.0.1"); !ok {
+		t.Fatalf("Update вытеснил недавний счетчик")
+	}
+}
+
+func TestLoginThrottleDisabled(t *testing.T) {
+	th := NewLoginThrottle(throttlePolicy)
+	failN(th, 3, "alice", "10.0.0.1")
+
+	// Без порогов блокировки снимаются, и счетчики не накапливаются
+	th.Update(ThrottlePolicy{})
+	if _, ok := th.Locked("alice", "10.0.0.1"); ok {
+		t.Fatalf("блокировка осталась после выключения")
+	}
+	if _, ok := failN(th, 10, "alice", "10.0.0.1"); ok || len(th.entries) != 0 {
+		t.Fatalf("выключенная защита блокирует вход или хранит %d счетчиков", len(th.entries))
+	}
+}
+
+func TestTooManyAttempts(t *testing.T) {
+	w := httptest.NewRecorder()
+	TooManyAttempts(w, Lockout{Scope: "account", RetryAfter: 1500 * time.Millisecond})
+
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("статус %d, Retry-After %q, ожидается 429 и 2", w.Code, w.Header().Get("Retry-After"))
+	}
+}
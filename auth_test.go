@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/derv-dice/go-web-server/auth"
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// Аутентификация по API ключу и по JWT взаимозаменяемы: при обеих включенных запросу к /v1 достаточно одного из двух
+func TestAPIAuthEitherKeyOrJWT(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+	cfg := config.Default()
+	cfg.Auth.APIKey.Enabled = true
+	cfg.Auth.APIKey.Keys = map[string]string{"svc": "be2974546978e3739e6d6da85c4be9f334ce32df2b9fd4b6ff1b55c0d57e9d44"} // key-1
+	cfg.Auth.JWT.Enabled = true
+	cfg.Auth.JWT.Secret = secret
+
+	mux := router.New()
+	if err := registerAPI(mux, config.NewStore(cfg, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.SignJWT([]byte(secret), map[string]any{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{name: "только JWT", header: "Authorization", value: "Bearer " + token, status: http.StatusOK},
+		{name: "только API ключ", header: auth.APIKeyHeader, value: "key-1", status: http.StatusOK},
+		{name: "API ключ в Authorization", header: "Authorization", value: "Bearer key-1", status: http.StatusOK},
+		{name: "неизвестный токен", header: "Authorization", value: "Bearer key-2", status: http.StatusUnauthorized},
+		{name: "без учетных данных", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/time", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("статус %d, ожидается %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" // База часовых поясов встраивается в бинарный файл: в минимальных образах ее может не быть
+
+	"github.com/derv-dice/go-web-server/apperr"
+	"github.com/derv-dice/go-web-server/response"
+)
+
+// timeFormats - Форматы времени для параметра format: имя - шаблон time.Format
+var timeFormats = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"rfc1123":     time.RFC1123,
+	"rfc1123z":    time.RFC1123Z,
+	"rfc822":      time.RFC822,
+	"rfc822z":     time.RFC822Z,
+	"ansic":       time.ANSIC,
+	"kitchen":     time.Kitchen,
+	"datetime":    time.DateTime,
+	"date":        time.DateOnly,
+	"time":        time.TimeOnly,
+}
+
+// clockTime - Ответ GET /v1/time
+type clockTime struct {
+	Time     string `json:"time"`     // Время в запрошенном формате
+	Timezone string `json:"timezone"` // Часовой пояс из базы IANA
+	Format   string `json:"format"`
+	Unix     int64  `json:"unix"` // Секунды с начала эпохи Unix, не зависят от часового пояса
+}
+
+// formatTime - Время t в часовом поясе tz и формате format. Пустые значения - UTC и rfc3339
+func formatTime(t time.Time, tz, format string) (clockTime, error) {
+	if tz == "" {
+		tz = "UTC"
+	}
+	if format == "" {
+		format = "rfc3339"
+	}
+
+	// Local - часовой пояс сервера: его значение зависит от окружения и не должно быть видно клиентам
+	if tz == "Local" {
+		return clockTime{}, apperr.Errorf(apperr.BadRequest, "неизвестный часовой пояс %q", tz)
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return clockTime{}, apperr.Errorf(apperr.BadRequest, "неизвестный часовой пояс %q: ожидается имя из базы IANA, например Europe/Moscow", tz)
+	}
+
+	layout, ok := timeFormats[strings.ToLower(format)]
+	if !ok {
+		return clockTime{}, apperr.Errorf(apperr.BadRequest, "неизвестный формат %q: ожидается один из %s",
+			format, strings.Join(slices.Sorted(maps.Keys(timeFormats)), ", "))
+	}
+
+	return clockTime{
+		Time:     t.In(loc).Format(layout),
+		Timezone: loc.String(),
+		Format:   strings.ToLower(format),
+		Unix:     t.Unix(),
+	}, nil
+}
+
+// timeHandler - Обработчик метода GET /v1/time?tz=Europe/Moscow&format=rfc3339: текущее время
+// в часовом поясе tz и формате format
+func timeHandler(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+
+	t, err := formatTime(time.Now(), q.Get("tz"), q.Get("format"))
+	if err != nil {
+		return err
+	}
+
+	response.Respond(w, r, http.StatusOK, response.Body{Data: t})
+	return nil
+}
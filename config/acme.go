@@ -0,0 +1,55 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ACME - Автоматическое получение и продление сертификатов HTTPS по протоколу ACME (Let's Encrypt).
+// Требует сборки с -tags acme. Применяется только при запуске
+type ACME struct {
+	Enabled bool     `json:"enabled"`
+	Domains []string `json:"domains"` // Домены, для которых выпускаются сертификаты. Запросы к другим именам получают ошибку TLS
+	Email   string   `json:"email"`   // Адрес для уведомлений удостоверяющего центра об истечении сертификатов
+
+	// Каталог, в котором хранятся ключ учетной записи и выпущенные сертификаты. Без него сертификаты
+	// запрашивались бы заново при каждом запуске и быстро уперлись бы в ограничения Let's Encrypt
+	CacheDir string `json:"cache_dir"`
+
+	// Способ подтверждения владения доменом: tls-alpn-01 - на порту HTTPS, http-01 - на обычном HTTP,
+	// который должен быть доступен удостоверяющему центру на порту 80
+	Challenge string `json:"challenge"`
+
+	// Адрес каталога ACME, по умолчанию Let's Encrypt. Для проверки настроек -
+	// https://acme-staging-v02.api.letsencrypt.org/directory
+	DirectoryURL string `json:"directory_url"`
+}
+
+func (a ACME) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if len(a.Domains) == 0 {
+		errs = append(errs, errors.New("server.tls.acme.domains: нужен хотя бы один домен"))
+	}
+	for i, d := range a.Domains {
+		if err := validateHost(d); err != nil || d == "" {
+			errs = append(errs, fmt.Errorf("server.tls.acme.domains[%d]: некорректный домен %q", i, d))
+		}
+	}
+
+	if a.CacheDir == "" {
+		errs = append(errs, errors.New("server.tls.acme.cache_dir: значение не может быть пустым"))
+	}
+
+	switch a.Challenge {
+	case "tls-alpn-01", "http-01":
+	default:
+		errs = append(errs, fmt.Errorf("server.tls.acme.challenge: неизвестный способ %q: ожидается tls-alpn-01 или http-01", a.Challenge))
+	}
+
+	return errors.Join(errs...)
+}
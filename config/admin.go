@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Admin - Отдельный служебный адрес сервера, недоступный через публичные маршруты. Применяется только при запуске.
+// По умолчанию слушает только локальный интерфейс
+type Admin struct {
+	Enabled bool   `json:"enabled"`
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	Pprof   bool   `json:"pprof"` // Профилирование net/http/pprof по адресу /debug/pprof/
+	Stats   bool   `json:"stats"` // Состояние процесса в JSON по адресу /debug/stats
+}
+
+// Server - Настройки HTTP сервера для служебного адреса. Таймаут записи не задан: снятие профиля CPU
+// длится столько секунд, сколько запрошено в параметре seconds
+func (a Admin) Server(shutdownTimeout Duration) Server {
+	return Server{
+		Listeners: []Listener{{
+			Name: "admin",
+			Addr: net.JoinHostPort(a.Host, strconv.Itoa(a.Port)),
+		}},
+		ReadHeaderTimeout: Duration(5 * time.Second),
+		IdleTimeout:       Duration(2 * time.Minute),
+		ShutdownTimeout:   shutdownTimeout,
+	}
+}
+
+func (a Admin) validate(public Server) error {
+	if !a.Enabled {
+		return nil
+	}
+
+	if a.Port < 1 || a.Port > 65535 {
+		return fmt.Errorf("admin.port: некорректный порт %d: ожидается число от 1 до 65535", a.Port)
+	}
+
+	if err := validateHost(a.Host); err != nil {
+		return fmt.Errorf("admin.host: %w", err)
+	}
+
+	for _, l := range public.EffectiveListeners() {
+		if l.Socket == "" && l.Port() == strconv.Itoa(a.Port) {
+			return fmt.Errorf("admin.port: порт %d уже используется адресом %s", a.Port, l.Name)
+		}
+	}
+
+	return nil
+}
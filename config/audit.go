@@ -0,0 +1,37 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Audit - Журнал действий клиентов с изменяющими запросами: кто, что, над каким ресурсом и с каким результатом.
+// Записи связаны в цепочку хешей, поэтому изменение или удаление записи обнаруживается при проверке.
+// Журнал открывается при запуске, если audit включен, поэтому Path и Key применяются только при запуске
+type Audit struct {
+	Enabled bool     `json:"enabled"`
+	Path    string   `json:"path"`    // Файл журнала, отдельный от логов сервера
+	Methods []string `json:"methods"` // Методы запросов, которые записываются в журнал
+	Key     string   `json:"key"`     // Ключ HMAC-SHA256 для хешей записей. Без ключа - SHA-256, который может пересчитать любой
+}
+
+func (a Audit) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if a.Path == "" {
+		errs = append(errs, errors.New("audit.path: значение не может быть пустым"))
+	}
+	if len(a.Methods) == 0 {
+		errs = append(errs, errors.New("audit.methods: список не может быть пустым"))
+	}
+	for i, m := range a.Methods {
+		if m == "" || m != strings.ToUpper(m) || strings.ContainsAny(m, " \t") {
+			errs = append(errs, fmt.Errorf("audit.methods[%d]: ожидается HTTP метод в верхнем регистре, получено %q", i, m))
+		}
+	}
+	return errors.Join(errs...)
+}
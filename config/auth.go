@@ -0,0 +1,343 @@
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Auth - Настройки аутентификации клиентов
+type Auth struct {
+	Basic  BasicAuth  `json:"basic"`
+	APIKey APIKeyAuth `json:"api_key"`
+	JWT    JWTAuth    `json:"jwt"`
+
+	Signature SignatureAuth `json:"signature"`
+	Replay    Replay        `json:"replay"`
+
+	LoginThrottle LoginThrottle `json:"login_throttle"`
+	Password      Password      `json:"password"`
+	OIDC          OIDC          `json:"oidc"`
+	RBAC          RBAC          `json:"rbac"`
+}
+
+// BasicAuth - Настройки Basic аутентификации для отладочных маршрутов
+type BasicAuth struct {
+	Enabled      bool              `json:"enabled"`
+	Realm        string            `json:"realm"`         // Название области, которое браузер показывает при запросе пароля
+	HtpasswdFile string            `json:"htpasswd_file"` // Файл пользователей в формате htpasswd
+	Users        map[string]string `json:"users"`         // Дополнительные пользователи: имя и хэш пароля в формате htpasswd
+}
+
+func (b BasicAuth) validate() error {
+	if !b.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if b.HtpasswdFile == "" && len(b.Users) == 0 {
+		errs = append(errs, errors.New("auth.basic: нужно указать htpasswd_file или users"))
+	}
+
+	if b.Realm == "" {
+		errs = append(errs, errors.New("auth.basic.realm: значение не может быть пустым"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// APIKeyAuth - Настройки аутентификации по API ключу для маршрутов API
+type APIKeyAuth struct {
+	Enabled bool              `json:"enabled"`
+	Keys    map[string]string `json:"keys"` // Имя ключа и SHA-256 хэш ключа в hex: echo -n $KEY | sha256sum
+}
+
+func (a APIKeyAuth) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+
+	if len(a.Keys) == 0 {
+		return errors.New("auth.api_key.keys: нужен хотя бы один ключ")
+	}
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(a.Keys)) {
+		if b, err := hex.DecodeString(a.Keys[name]); err != nil || len(b) != 32 {
+			errs = append(errs, fmt.Errorf("auth.api_key.keys[%s]: ожидается SHA-256 хэш из 64 шестнадцатеричных символов", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// JWTAuth - Настройки аутентификации по JWT для маршрутов API. Если включена и аутентификация по API ключу,
+// клиенту достаточно одного из двух
+type JWTAuth struct {
+	Enabled       bool     `json:"enabled"`
+	Secret        string   `json:"secret"`          // Секрет для токенов с подписью HMAC (HS256, HS384, HS512), не короче 32 байт
+	PublicKeyFile string   `json:"public_key_file"` // PEM файл с открытым ключом RSA или ECDSA для токенов RS*, PS*, ES*
+	Issuer        string   `json:"issuer"`          // Ожидаемый издатель токена (iss), пустое значение - не проверяется
+	Audience      string   `json:"audience"`        // Значение, которое должно быть в aud, пустое значение - не проверяется
+	Leeway        Duration `json:"leeway"`          // Допустимое расхождение часов при проверке exp и nbf
+	RequireScopes bool     `json:"require_scopes"`  // Маршруты, объявившие scope, доступны только по JWT с этими scope
+}
+
+func (j JWTAuth) validate() error {
+	if !j.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	switch {
+	case j.Secret == "" && j.PublicKeyFile == "":
+		errs = append(errs, errors.New("auth.jwt: нужно указать secret или public_key_file"))
+	case j.Secret != "" && j.PublicKeyFile != "":
+		errs = append(errs, errors.New("auth.jwt: secret и public_key_file нельзя указывать одновременно"))
+	case j.PublicKeyFile == "" && len(j.Secret) < 32:
+		errs = append(errs, errors.New("auth.jwt.secret: секрет должен быть не короче 32 байт"))
+	}
+
+	if j.Leeway < 0 {
+		errs = append(errs, errors.New("auth.jwt.leeway: значение не может быть отрицательным"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// LoginThrottle - Настройки защиты входа по паролю (auth.basic и auth.password) от подбора: временная блокировка учетной записи
+// и адреса клиента после серии неудачных попыток. Каждая следующая неудачная попытка удваивает блокировку
+type LoginThrottle struct {
+	Enabled          bool     `json:"enabled"`
+	AccountThreshold int      `json:"account_threshold"` // Неудачных попыток для учетной записи до блокировки, 0 - не блокировать
+	IPThreshold      int      `json:"ip_threshold"`      // Неудачных попыток с одного адреса до блокировки, 0 - не блокировать
+	BaseDelay        Duration `json:"base_delay"`        // Длительность первой блокировки
+	MaxDelay         Duration `json:"max_delay"`         // Наибольшая длительность блокировки
+	Window           Duration `json:"window"`            // Через сколько без неудачных попыток счетчик сбрасывается
+	MaxEntries       int      `json:"max_entries"`       // Сколько счетчиков попыток хранится в памяти, давно не обновлявшиеся вытесняются
+}
+
+func (l LoginThrottle) validate() error {
+	if !l.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if l.AccountThreshold < 0 || l.IPThreshold < 0 {
+		errs = append(errs, errors.New("auth.login_throttle: account_threshold и ip_threshold не могут быть отрицательными"))
+	}
+	if l.AccountThreshold == 0 && l.IPThreshold == 0 {
+		errs = append(errs, errors.New("auth.login_throttle: нужен хотя бы один из порогов account_threshold, ip_threshold"))
+	}
+
+	if l.BaseDelay <= 0 {
+		errs = append(errs, errors.New("auth.login_throttle.base_delay: ожидается положительная длительность"))
+	}
+	if l.MaxDelay < l.BaseDelay {
+		errs = append(errs, errors.New("auth.login_throttle.max_delay: значение не может быть меньше base_delay"))
+	}
+	if l.Window <= 0 {
+		errs = append(errs, errors.New("auth.login_throttle.window: ожидается положительная длительность"))
+	}
+	if l.MaxEntries <= 0 {
+		errs = append(errs, fmt.Errorf("auth.login_throttle.max_entries: ожидается положительное число, получено %d", l.MaxEntries))
+	}
+
+	return errors.Join(errs...)
+}
+
+// SignatureAuth - Настройки проверки подписи HMAC запросов (интеграции в стиле webhook) для маршрутов API
+type SignatureAuth struct {
+	Enabled bool              `json:"enabled"`
+	Clients map[string]string `json:"clients"` // Имя клиента и общий секрет подписи, не короче 32 байт
+	MaxAge  Duration          `json:"max_age"` // Допустимое отклонение времени подписи от времени сервера
+}
+
+func (s SignatureAuth) validate() error {
+	if !s.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if len(s.Clients) == 0 {
+		errs = append(errs, errors.New("auth.signature.clients: нужен хотя бы один клиент"))
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.Clients)) {
+		if len(s.Clients[name]) < 32 {
+			errs = append(errs, fmt.Errorf("auth.signature.clients[%s]: секрет должен быть не короче 32 байт", name))
+		}
+	}
+
+	if s.MaxAge <= 0 {
+		errs = append(errs, errors.New("auth.signature.max_age: ожидается положительная длительность"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Способы выдачи входа по паролю
+const (
+	PasswordIssueSession = "session" // Вход сохраняется в сессии (см. секцию session)
+	PasswordIssueJWT     = "jwt"     // Клиент получает JWT, который принимают маршруты API (см. auth.jwt)
+)
+
+// Хранилища учетных записей
+const (
+	AccountStoreMemory = "memory" // В памяти процесса, учетные записи теряются при перезапуске
+	AccountStoreFile   = "file"   // В JSON файле
+)
+
+// Password - Настройки регистрации и входа пользователей по паролю: POST /auth/register и POST /auth/login.
+// Применяются только при запуске: маршруты /auth/* регистрируются один раз
+type Password struct {
+	Enabled           bool     `json:"enabled"`
+	Store             string   `json:"store"`               // Хранилище учетных записей: memory или file
+	Path              string   `json:"path"`                // Файл учетных записей для store: file
+	Registration      bool     `json:"registration"`        // Открытая регистрация, без нее учетные записи добавляются в файл вручную
+	MinPasswordLength int      `json:"min_password_length"` // Наименьшая длина пароля при регистрации
+	DefaultRoles      []string `json:"default_roles"`       // Роли новых учетных записей, см. auth.rbac
+	Issue             string   `json:"issue"`               // Что получает клиент после входа: session или jwt
+	SessionTTL        Duration `json:"session_ttl"`         // Сколько действует вход в сессии
+	TokenTTL          Duration `json:"token_ttl"`           // Срок действия JWT
+
+	Refresh RefreshTokens `json:"refresh"`
+}
+
+// RefreshTokens - Настройки токенов обновления для issue: jwt: POST /auth/refresh меняет токен обновления
+// на новую пару токенов, POST /auth/revoke отзывает его. Токены хранятся в памяти процесса
+type RefreshTokens struct {
+	Enabled bool     `json:"enabled"`
+	TTL     Duration `json:"ttl"` // Сколько после входа можно обменивать токены без повторного ввода пароля
+}
+
+func (p Password) validate() error {
+	if !p.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	switch p.Store {
+	case AccountStoreMemory:
+	case AccountStoreFile:
+		if p.Path == "" {
+			errs = append(errs, errors.New("auth.password.path: для store: file нужно указать файл учетных записей"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth.password.store: неизвестное хранилище %q, ожидается memory или file", p.Store))
+	}
+
+	if p.MinPasswordLength < 1 {
+		errs = append(errs, errors.New("auth.password.min_password_length: ожидается положительное число"))
+	}
+
+	switch p.Issue {
+	case PasswordIssueSession:
+		if p.SessionTTL <= 0 {
+			errs = append(errs, errors.New("auth.password.session_ttl: ожидается положительная длительность"))
+		}
+	case PasswordIssueJWT:
+		if p.TokenTTL <= 0 {
+			errs = append(errs, errors.New("auth.password.token_ttl: ожидается положительная длительность"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth.password.issue: неизвестное значение %q, ожидается session или jwt", p.Issue))
+	}
+
+	if p.Refresh.Enabled {
+		if p.Issue != PasswordIssueJWT {
+			errs = append(errs, errors.New("auth.password.refresh: токены обновления выдаются только вместе с JWT (issue: jwt)"))
+		}
+		if p.Refresh.TTL <= 0 {
+			errs = append(errs, errors.New("auth.password.refresh.ttl: ожидается положительная длительность"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Replay - Настройки защиты маршрутов API от повтора запросов: каждый запрос должен содержать уникальный
+// X-Nonce и время отправки X-Timestamp
+type Replay struct {
+	Enabled    bool     `json:"enabled"`
+	MaxAge     Duration `json:"max_age"`     // Допустимое отклонение X-Timestamp от времени сервера и срок хранения X-Nonce
+	MaxEntries int      `json:"max_entries"` // Сколько X-Nonce хранится в памяти, при заполнении новые запросы получают 503
+}
+
+func (r Replay) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if r.MaxAge <= 0 {
+		errs = append(errs, errors.New("auth.replay.max_age: ожидается положительная длительность"))
+	}
+	if r.MaxEntries <= 0 {
+		errs = append(errs, fmt.Errorf("auth.replay.max_entries: ожидается положительное число, получено %d", r.MaxEntries))
+	}
+	return errors.Join(errs...)
+}
+
+// OIDC - Настройки входа пользователей через провайдера OpenID Connect (Keycloak, Google и т.п.).
+// Применяются только при запуске: маршруты /auth/* регистрируются один раз
+type OIDC struct {
+	Enabled      bool     `json:"enabled"`
+	Issuer       string   `json:"issuer"`        // URL провайдера, например https://accounts.google.com
+	ClientID     string   `json:"client_id"`     // Идентификатор клиента у провайдера
+	ClientSecret string   `json:"client_secret"` // Секрет клиента
+	RedirectURL  string   `json:"redirect_url"`  // Полный URL /auth/callback этого сервера, зарегистрированный у провайдера
+	Scopes       []string `json:"scopes"`        // Запрашиваемые scope, openid добавляется автоматически
+	CookieSecret string   `json:"cookie_secret"` // Ключ подписи cookie с параметрами входа, не короче 32 байт
+	SessionTTL   Duration `json:"session_ttl"`   // Сколько действует вход, сохраненный в сессии (см. секцию session)
+}
+
+func (o OIDC) validate() error {
+	if !o.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if o.Issuer == "" || o.ClientID == "" || o.RedirectURL == "" {
+		errs = append(errs, errors.New("auth.oidc: нужно указать issuer, client_id и redirect_url"))
+	}
+
+	if len(o.CookieSecret) < 32 {
+		errs = append(errs, errors.New("auth.oidc.cookie_secret: ключ должен быть не короче 32 байт"))
+	}
+
+	if o.SessionTTL <= 0 {
+		errs = append(errs, errors.New("auth.oidc.session_ttl: ожидается положительная длительность"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// RBAC - Настройки ролевого доступа к маршрутам, которые требуют разрешений
+type RBAC struct {
+	Enabled     bool                `json:"enabled"`
+	Roles       map[string][]string `json:"roles"`       // Разрешения ролей: * - все, debug:* - все вида debug:...
+	Assignments map[string][]string `json:"assignments"` // Роли клиентов по имени (пользователь Basic, имя API ключа, sub токена)
+}
+
+func (r RBAC) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(r.Assignments)) {
+		for _, role := range r.Assignments[name] {
+			if _, ok := r.Roles[role]; !ok {
+				errs = append(errs, fmt.Errorf("auth.rbac.assignments[%s]: неизвестная роль %q", name, role))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,896 @@
+// Package config - Типизированная конфигурация сервера.
+//
+// Конфигурация собирается из нескольких источников. Приоритет (от большего к меньшему):
+// флаги командной строки, переменные окружения, файл конфигурации (JSON или YAML), значения по умолчанию.
+// Во время работы конфигурацию можно перечитать без перезапуска процесса, см. Store.
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Имена переменных окружения
+const (
+	EnvConfig   = "SERVER_CONFIG"    // Путь к файлу конфигурации
+	EnvAddr     = "SERVER_ADDR"      // Адрес (хост), на котором запускается сервер
+	EnvPort     = "SERVER_PORT"      // Порт, на котором запускается сервер
+	EnvSocket   = "SERVER_SOCKET"    // Путь к Unix сокету, на котором запускается сервер вместо TCP порта
+	EnvLogLevel = "SERVER_LOG_LEVEL" // Уровень логирования: debug, info, warn или error
+)
+
+// Config - Конфигурация сервера целиком
+type Config struct {
+	Server       Server       `json:"server"`
+	Router       Router       `json:"router"`
+	CORS         CORS         `json:"cors"`
+	Compression  Compression  `json:"compression"`
+	ETag         ETag         `json:"etag"`
+	Cache        Cache        `json:"cache"`
+	RateLimit    RateLimit    `json:"rate_limit"`
+	LoadShedding LoadShedding `json:"load_shedding"`
+	Request      Request      `json:"request"`
+	Response     Response     `json:"response"`
+	IPFilter     IPFilter     `json:"ip_filter"`
+	Auth         Auth         `json:"auth"`
+	Session      Session      `json:"session"`
+	Files        Files        `json:"files"`
+	KV           KV           `json:"kv"`
+	Redis        Redis        `json:"redis"`
+	Migrations   Migrations   `json:"migrations"`
+	Jobs         Jobs         `json:"jobs"`
+	WorkPool     WorkPool     `json:"work_pool"`
+	Scheduler    Scheduler    `json:"scheduler"`
+	Outbox       Outbox       `json:"outbox"`
+	Admin        Admin        `json:"admin"`
+	Tracing      Tracing      `json:"tracing"`
+	Health       Health       `json:"health"`
+	ErrorReport  ErrorReport  `json:"error_reporting"`
+	Audit        Audit        `json:"audit"`
+	RealIP       RealIP       `json:"real_ip"`
+	Secrets      Secrets      `json:"secrets"`
+	Log          Log          `json:"log"`
+	Features     Features     `json:"features"`
+
+	// Режим отладки: ответ на панику в обработчике содержит ее текст и стек вызовов, JSON отправляется с отступами.
+	// Не включать на боевом сервере
+	Debug bool `json:"debug"`
+}
+
+// Server - Настройки HTTP сервера
+type Server struct {
+	Host string `json:"host"` // Пустой хост означает все сетевые интерфейсы
+	Port int    `json:"port"`
+
+	// Путь к Unix сокету. Если задан, обычный HTTP слушает сокет вместо TCP порта (например, за nginx)
+	Socket     string `json:"socket"`
+	SocketMode string `json:"socket_mode"` // Права на файл сокета в восьмеричном виде, например "0660"
+
+	// Ограничения на время соединения и размер запроса. Без них медленный клиент (slowloris)
+	// может бесконечно удерживать соединение. Значение 0 - без ограничения
+	ReadTimeout       Duration `json:"read_timeout"`        // Максимальное время чтения запроса целиком, включая тело
+	ReadHeaderTimeout Duration `json:"read_header_timeout"` // Максимальное время чтения заголовков запроса
+	WriteTimeout      Duration `json:"write_timeout"`       // Максимальное время от конца чтения заголовков до конца записи ответа
+	IdleTimeout       Duration `json:"idle_timeout"`        // Максимальное время ожидания следующего запроса в keep-alive соединении
+	MaxHeaderBytes    int      `json:"max_header_bytes"`    // Максимальный размер заголовков запроса в байтах
+
+	ShutdownTimeout Duration `json:"shutdown_timeout"` // Сколько ждать завершения активных запросов при остановке сервера
+
+	TLS TLS `json:"tls"`
+
+	// PROXY protocol на адресах из host, port, socket и tls (см. Listener.ProxyProtocol)
+	ProxyProtocol bool `json:"proxy_protocol"`
+
+	// Явный список адресов. Если задан, host, port, socket, tls.port, tls.redirect_http, tls.http3
+	// и proxy_protocol не используются
+	Listeners []Listener `json:"listeners"`
+}
+
+// Addr - Адрес в формате host:port, пригодный для передачи в http.Server
+func (s Server) Addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// Router - Настройки маршрутизации запросов
+type Router struct {
+	// Поведение, когда путь запроса отличается от маршрута только завершающим "/":
+	// redirect - перенаправление на путь маршрута, match - обработка маршрутом, strict - 404
+	TrailingSlash string `json:"trailing_slash"`
+
+	// Виртуальные хосты: для каждого набора имен хостов свой набор маршрутов
+	Hosts []VirtualHost `json:"hosts"`
+
+	// Наборы маршрутов для хостов, не перечисленных в hosts. Если не задано - все наборы,
+	// пустой список - 404 для любого запроса к незарегистрированному хосту
+	DefaultRoutes []string `json:"default_routes"`
+
+	// Маршруты обратного прокси (набор маршрутов proxy)
+	Proxies []Proxy `json:"proxies"`
+
+	// Каталоги со статическими файлами (набор маршрутов static)
+	Static []Static `json:"static"`
+}
+
+// Static - Маршрут, отдающий файлы из каталога по префиксу пути
+type Static struct {
+	Prefix  string       `json:"prefix"`  // Префикс пути, например /static
+	Dir     string       `json:"dir"`     // Каталог с файлами: /static/css/app.css отдается из <dir>/css/app.css
+	Listing bool         `json:"listing"` // Список файлов для каталога без index.html. Иначе для такого каталога 404
+	Cache   *CachePolicy `json:"cache"`   // Политика кэширования файлов. Не задано - политика секции cache
+
+	// Одностраничное приложение: на путь, для которого нет файла, отдается <dir>/index.html, а маршрутизацию
+	// выполняет само приложение в браузере
+	SPA bool `json:"spa"`
+	// Префиксы путей, для которых при spa отдается обычный 404, например /api
+	SPAExclude []string `json:"spa_exclude"`
+}
+
+// Proxy - Маршрут, перенаправляющий все запросы с префиксом пути на другой сервер
+type Proxy struct {
+	Prefix       string            `json:"prefix"`        // Префикс пути, например /backend
+	Upstream     string            `json:"upstream"`      // Адрес сервера, например http://127.0.0.1:9000
+	StripPrefix  bool              `json:"strip_prefix"`  // Удалять префикс из пути перед отправкой на upstream
+	PreserveHost bool              `json:"preserve_host"` // Передавать upstream исходный заголовок Host вместо адреса upstream
+	Headers      map[string]string `json:"headers"`       // Дополнительные заголовки запроса к upstream
+}
+
+// VirtualHost - Виртуальный хост: имена хостов и обслуживаемые ими наборы маршрутов
+type VirtualHost struct {
+	Names  []string `json:"names"`  // Имена хостов: api.example.com или *.example.com для всех поддоменов
+	Routes []string `json:"routes"` // Названия наборов маршрутов, например api, debug
+}
+
+// Log - Настройки логирования
+type Log struct {
+	Output string  `json:"output"` // Куда пишутся логи: stderr, stdout, file, syslog или journald
+	File   LogFile `json:"file"`   // Файл для output: file
+	Syslog Syslog  `json:"syslog"` // Сервер syslog для output: syslog, имя программы и для journald
+	Level  string  `json:"level"`  // Минимальный уровень записей: debug, info, warn или error
+	Format string  `json:"format"` // Формат записей: text - строка key=value, json - объект JSON в строке
+	Access bool    `json:"access"` // Включает логирование всех входящих запросов
+
+	// Формат access лога: text - запись лога сервера в формате Format, common - Common Log Format,
+	// combined - Combined Log Format (common с Referer и User-Agent), json - объект JSON в строке,
+	// template - шаблон text/template из AccessTemplate
+	AccessFormat   string `json:"access_format"`
+	AccessTemplate string `json:"access_template"` // Шаблон строки для access_format: template, поля logging.Access
+
+	Bodies BodyLog  `json:"bodies"` // Тела запросов и ответов в логе для отладки
+	Async  AsyncLog `json:"async"`  // Запись логов через очередь в отдельной горутине
+
+	// Запросы, обработка которых заняла больше SlowThreshold, записываются в лог с уровнем warn
+	// независимо от access лога. 0 - не записываются
+	SlowThreshold Duration `json:"slow_threshold"`
+}
+
+// Features - Переключатели отдельных возможностей сервера
+type Features struct {
+	Hello       bool `json:"hello"`        // Обработчик GET /v1/hello
+	Time        bool `json:"time"`         // Обработчик GET /v1/time
+	DebugRoutes bool `json:"debug_routes"` // Обработчик GET /debug/routes со списком маршрутов, только с локального адреса
+	DebugEcho   bool `json:"debug_echo"`   // Обработчик /debug/echo, возвращающий запрос клиенту, только с локального адреса
+}
+
+// Default - Конфигурация по умолчанию
+func Default() Config {
+	return Config{
+		Server: Server{
+			Port:              8080,
+			SocketMode:        "0660",
+			ReadTimeout:       Duration(30 * time.Second),
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			WriteTimeout:      Duration(30 * time.Second),
+			IdleTimeout:       Duration(2 * time.Minute),
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   Duration(15 * time.Second),
+			TLS: TLS{
+				Port:       8443,
+				MinVersion: "1.2",
+				ACME: ACME{
+					CacheDir:  "acme-cache",
+					Challenge: "tls-alpn-01",
+				},
+			},
+		},
+		Router: Router{
+			TrailingSlash: "redirect",
+		},
+		CORS: CORS{
+			AllowedMethods: []string{http.MethodGet, http.MethodHead, http.MethodPost},
+			AllowedHeaders: []string{"Content-Type"},
+			MaxAge:         Duration(10 * time.Minute),
+		},
+		Compression: Compression{
+			MinSize: 1024,
+			Types:   []string{"text/*", "application/json", "application/javascript", "application/xml", "image/svg+xml"},
+		},
+		ETag: ETag{
+			Enabled: true,
+		},
+		Cache: Cache{
+			Enabled: true,
+			Default: CachePolicy{NoStore: true},
+			Responses: ResponseCache{
+				Store:          StateMemory,
+				MaxBodyBytes:   1 << 20, // 1 MiB
+				MaxEntries:     10000,
+				MaxMemoryBytes: 64 << 20, // 64 MiB
+			},
+		},
+		RateLimit: RateLimit{
+			Rate:  10,
+			Burst: 20,
+			Store: StateMemory,
+		},
+		LoadShedding: LoadShedding{
+			MaxInFlight: 1000,
+			RetryAfter:  Duration(time.Second),
+			Exclude:     []string{"/healthz", "/readyz", "/metrics"},
+		},
+		Request: Request{
+			Timeout:      Duration(20 * time.Second),
+			MaxBodyBytes: 1 << 20, // 1 MiB
+		},
+		Response: Response{
+			ErrorFormat: "envelope",
+			CSV: CSV{
+				Delimiter: ",",
+				Header:    true,
+			},
+		},
+		Auth: Auth{
+			Basic: BasicAuth{
+				Realm: "go-web-server",
+			},
+			JWT: JWTAuth{
+				Leeway: Duration(30 * time.Second),
+			},
+			Signature: SignatureAuth{
+				MaxAge: Duration(5 * time.Minute),
+			},
+			Replay: Replay{
+				MaxAge:     Duration(5 * time.Minute),
+				MaxEntries: 500000,
+			},
+			LoginThrottle: LoginThrottle{
+				AccountThreshold: 5,
+				IPThreshold:      20,
+				BaseDelay:        Duration(30 * time.Second),
+				MaxDelay:         Duration(15 * time.Minute),
+				Window:           Duration(15 * time.Minute),
+				MaxEntries:       100000,
+			},
+			Password: Password{
+				Store:             AccountStoreMemory,
+				Registration:      true,
+				MinPasswordLength: 8,
+				Issue:             PasswordIssueSession,
+				SessionTTL:        Duration(12 * time.Hour),
+				TokenTTL:          Duration(time.Hour),
+				Refresh: RefreshTokens{
+					TTL: Duration(30 * 24 * time.Hour),
+				},
+			},
+			OIDC: OIDC{
+				Scopes:     []string{"openid", "profile", "email"},
+				SessionTTL: Duration(12 * time.Hour),
+			},
+		},
+		Session: Session{
+			Store:      "cookie",
+			CookieName: "session",
+			TTL:        Duration(24 * time.Hour),
+		},
+		Files: Files{
+			Dir:             "uploads",
+			MaxFileBytes:    32 << 20,  // 32 MiB
+			MaxRequestBytes: 100 << 20, // 100 MiB
+			MaxFiles:        10,
+		},
+		KV: KV{
+			Store:         KVStoreMemory,
+			Path:          "kv.db",
+			SweepInterval: Duration(time.Minute),
+			Postgres: Postgres{
+				MaxConns:        10,
+				MaxConnLifetime: Duration(time.Hour),
+				MaxConnIdleTime: Duration(30 * time.Minute),
+				ConnectTimeout:  Duration(5 * time.Second),
+			},
+			MaxValueBytes: 1 << 20, // 1 MiB
+		},
+		Migrations: Migrations{
+			Auto: true,
+		},
+		Outbox: Outbox{
+			Interval:    Duration(time.Second),
+			MaxAttempts: 10,
+			BaseBackoff: Duration(time.Second),
+			MaxBackoff:  Duration(10 * time.Minute),
+			Timeout:     Duration(30 * time.Second),
+		},
+		WorkPool: WorkPool{
+			QueueSize: 64,
+			MaxWait:   Duration(5 * time.Second),
+		},
+		Scheduler: Scheduler{
+			Timezone: "UTC",
+		},
+		Jobs: Jobs{
+			Workers:     4,
+			QueueSize:   10000,
+			MaxAttempts: 5,
+			BaseBackoff: Duration(time.Second),
+			MaxBackoff:  Duration(5 * time.Minute),
+			Timeout:     Duration(30 * time.Second),
+		},
+		Redis: Redis{
+			Addr:        "127.0.0.1:6379",
+			KeyPrefix:   "go-web-server:",
+			PoolSize:    10,
+			DialTimeout: Duration(5 * time.Second),
+			Timeout:     Duration(time.Second),
+		},
+		Admin: Admin{
+			Host:  "127.0.0.1",
+			Port:  6060,
+			Pprof: true,
+			Stats: true,
+		},
+		Tracing: Tracing{
+			Protocol:    "http",
+			Endpoint:    "localhost:4318",
+			ServiceName: "go-web-server",
+			SampleRatio: 1,
+		},
+		Health: Health{
+			Timeout: Duration(2 * time.Second),
+		},
+		ErrorReport: ErrorReport{
+			Provider:   "none",
+			SampleRate: 1,
+		},
+		RealIP: RealIP{
+			Headers: []string{"X-Forwarded-For", "X-Real-IP"},
+		},
+		Audit: Audit{
+			Methods: []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		},
+		Secrets: Secrets{
+			Vault: Vault{Timeout: Duration(5 * time.Second)},
+		},
+		Log: Log{
+			Output: "stderr",
+			File: LogFile{
+				MaxBytes:   100 << 20, // 100 MiB
+				MaxBackups: 7,
+			},
+			Syslog: Syslog{
+				Facility: "daemon",
+				Tag:      "go-web-server",
+			},
+			Level:  "info",
+			Format: "text",
+			Access: true,
+
+			AccessFormat: "text",
+			Async: AsyncLog{
+				QueueSize: 4096,
+				Policy:    "block",
+			},
+			Bodies: BodyLog{
+				MaxBytes:      4096,
+				RedactHeaders: []string{"Authorization", "Cookie", "Set-Cookie", "X-API-Key", "Proxy-Authorization"},
+				RedactFields:  []string{"password", "secret", "token", "access_token", "refresh_token", "client_secret", "api_key"},
+			},
+		},
+		Features: Features{
+			Hello: true,
+			Time:  true,
+		},
+	}
+}
+
+// Load - Собирает конфигурацию из аргументов командной строки, переменных окружения и файла конфигурации
+func Load(args []string, getenv func(string) string) (Config, error) {
+	var (
+		path    string
+		host    string
+		port    int
+		socket  string
+		migrate string
+		dryRun  bool
+	)
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(&path, "config", "", "путь к файлу конфигурации (.json, .yaml, .yml), env "+EnvConfig)
+	fs.StringVar(&host, "addr", "", "адрес (хост), на котором запускается сервер, env "+EnvAddr)
+	fs.IntVar(&port, "port", 0, "порт, на котором запускается сервер, env "+EnvPort)
+	fs.StringVar(&socket, "socket", "", "путь к Unix сокету, на котором запускается сервер вместо TCP порта, env "+EnvSocket)
+	fs.StringVar(&migrate, "migrate", "", "выполнить миграции схемы хранилищ и завершиться: up, down (откат последней) или status")
+	fs.BoolVar(&dryRun, "migrate-dry-run", false, "с -migrate up или down: только записать в лог SQL миграций, не выполняя его")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("неожиданные аргументы командной строки: %q", fs.Args())
+	}
+
+	// Флаги, явно указанные в командной строке
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if !set["config"] {
+		path, _ = lookupEnv(getenv, EnvConfig)
+	}
+
+	cfg := Default()
+
+	// Файл конфигурации перекрывает значения по умолчанию
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	// Адрес из переменных окружения или флагов. Он не может быть применен вместе с явным списком server.listeners
+	var overridden []string
+
+	// Переменные окружения перекрывают значения из файла
+	if v, ok := lookupEnv(getenv, EnvAddr); ok {
+		cfg.Server.Host = v
+		overridden = append(overridden, EnvAddr)
+	}
+	if v, ok := lookupEnv(getenv, EnvPort); ok {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: некорректный порт %q", EnvPort, v)
+		}
+		cfg.Server.Port = p
+		overridden = append(overridden, EnvPort)
+	}
+	if v, ok := lookupEnv(getenv, EnvSocket); ok {
+		cfg.Server.Socket = v
+		overridden = append(overridden, EnvSocket)
+	}
+	if v, ok := lookupEnv(getenv, EnvLogLevel); ok {
+		cfg.Log.Level = v
+	}
+
+	// Явно указанные флаги перекрывают все остальные источники
+	if set["addr"] {
+		cfg.Server.Host = host
+		overridden = append(overridden, "-addr")
+	}
+	if set["port"] {
+		cfg.Server.Port = port
+		overridden = append(overridden, "-port")
+	}
+	if set["socket"] {
+		cfg.Server.Socket = socket
+		overridden = append(overridden, "-socket")
+	}
+
+	cfg.Migrations.Command, cfg.Migrations.DryRun = migrate, dryRun
+
+	if len(overridden) > 0 && len(cfg.Server.Listeners) > 0 {
+		return Config{}, fmt.Errorf("%v: адрес нельзя переопределить, когда в конфигурации задан server.listeners", overridden)
+	}
+
+	// Ссылки на секреты заменяются их значениями до проверки: проверяются уже сами секреты
+	if err := cfg.resolveSecrets(getenv); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// FromEnvironment - Конфигурация для текущего процесса
+func FromEnvironment() (Config, error) {
+	return Load(os.Args[1:], os.Getenv)
+}
+
+// Validate - Проверка корректности конфигурации
+func (c Config) Validate() error {
+	var errs []error
+
+	if len(c.Server.Listeners) > 0 {
+		errs = append(errs, validateListeners(c.Server.Listeners, c.Server.TLS))
+	} else {
+		if c.Server.Port < 1 || c.Server.Port > 65535 {
+			errs = append(errs, fmt.Errorf("server.port: некорректный порт %d: ожидается число от 1 до 65535", c.Server.Port))
+		}
+
+		if err := validateHost(c.Server.Host); err != nil {
+			errs = append(errs, fmt.Errorf("server.host: %w", err))
+		}
+
+		if err := validateSocketMode(c.Server.SocketMode); err != nil {
+			errs = append(errs, fmt.Errorf("server.socket_mode: %w", err))
+		}
+
+		if c.Server.Socket != "" && c.Server.TLS.Enabled() {
+			errs = append(errs, errors.New("server.socket: Unix сокет не используется вместе с HTTPS, TLS завершается на стороне прокси"))
+		}
+
+		errs = append(errs, c.Server.TLS.validateListener(c.Server.Port))
+	}
+
+	for _, t := range []struct {
+		name string
+		d    Duration
+	}{
+		{"read_timeout", c.Server.ReadTimeout},
+		{"read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"write_timeout", c.Server.WriteTimeout},
+		{"idle_timeout", c.Server.IdleTimeout},
+	} {
+		if t.d < 0 {
+			errs = append(errs, fmt.Errorf("server.%s: значение не может быть отрицательным", t.name))
+		}
+	}
+
+	if c.Server.MaxHeaderBytes < 0 {
+		errs = append(errs, errors.New("server.max_header_bytes: значение не может быть отрицательным"))
+	}
+
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout: значение должно быть положительным"))
+	}
+
+	errs = append(errs, c.Server.TLS.validate())
+
+	switch c.Router.TrailingSlash {
+	case "redirect", "match", "strict":
+	default:
+		errs = append(errs, fmt.Errorf("router.trailing_slash: неизвестный режим %q: ожидается redirect, match или strict", c.Router.TrailingSlash))
+	}
+
+	errs = append(errs, validateHosts(c.Router.Hosts))
+	errs = append(errs, validateProxies(c.Router.Proxies))
+	errs = append(errs, validateStatic(c.Router.Static))
+
+	errs = append(errs, c.CORS.validate())
+	errs = append(errs, c.Compression.validate())
+	errs = append(errs, c.Cache.validate())
+	errs = append(errs, c.RateLimit.validate())
+	errs = append(errs, c.LoadShedding.validate())
+	errs = append(errs, c.Request.validate())
+	errs = append(errs, c.Response.validate())
+	if _, _, err := c.IPFilter.Prefixes(); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, c.Auth.Basic.validate())
+	errs = append(errs, c.Auth.APIKey.validate())
+	errs = append(errs, c.Auth.JWT.validate())
+	errs = append(errs, c.Auth.Signature.validate())
+	errs = append(errs, c.Auth.Replay.validate())
+	errs = append(errs, c.Auth.LoginThrottle.validate())
+	errs = append(errs, c.Auth.Password.validate())
+	errs = append(errs, c.Auth.OIDC.validate())
+	errs = append(errs, c.Auth.RBAC.validate())
+	errs = append(errs, c.Session.validate())
+	if c.Auth.OIDC.Enabled && !c.Session.Enabled {
+		errs = append(errs, errors.New("auth.oidc: для входа через OIDC нужно включить сессии (session.enabled)"))
+	}
+	if p := c.Auth.Password; p.Enabled {
+		switch {
+		case p.Issue == PasswordIssueSession && !c.Session.Enabled:
+			errs = append(errs, errors.New("auth.password: для входа в сессии нужно включить сессии (session.enabled)"))
+		case p.Issue == PasswordIssueJWT && (!c.Auth.JWT.Enabled || c.Auth.JWT.Secret == ""):
+			// Токены подписываются тем же секретом, которым их проверяет auth.jwt
+			errs = append(errs, errors.New("auth.password: для выдачи JWT нужно включить auth.jwt с секретом secret"))
+		}
+	}
+	errs = append(errs, c.Files.validate())
+	errs = append(errs, c.KV.validate())
+	errs = append(errs, c.Redis.validate())
+	errs = append(errs, c.Migrations.validate())
+	errs = append(errs, c.Jobs.validate())
+	errs = append(errs, c.Scheduler.validate())
+	errs = append(errs, c.Outbox.validate())
+	errs = append(errs, c.WorkPool.validate())
+	if c.Outbox.Enabled && (!c.KV.Enabled || c.KV.Store == KVStoreMemory) {
+		errs = append(errs, errors.New("outbox.enabled: события хранятся в базе kv.store: sqlite или postgres, а kv не включен или хранится в памяти"))
+	}
+	for i, w := range c.Jobs.Webhooks {
+		if (w.Event == EventKVPut || w.Event == EventKVDelete) && !c.Outbox.Enabled {
+			errs = append(errs, fmt.Errorf("jobs.webhooks[%d].event: события %s доставляются через outbox, а outbox.enabled выключен", i, w.Event))
+		}
+	}
+	if c.Scheduler.Enabled {
+		if _, ok := c.Scheduler.Tasks[TaskSessionCleanup]; ok && (!c.Session.Enabled || c.Session.Store != StateMemory) {
+			errs = append(errs, errors.New("scheduler.tasks.session_cleanup: задача удаляет сессии из session.store: memory, а сессии не включены или хранятся не в памяти"))
+		}
+		if _, ok := c.Scheduler.Tasks[TaskCacheWarmup]; ok && !c.Cache.Responses.Enabled {
+			errs = append(errs, errors.New("scheduler.tasks.cache_warmup: задача заполняет кэш ответов, а cache.responses.enabled выключен"))
+		}
+	}
+	if c.Migrations.Command != "" && (!c.KV.Enabled || c.KV.Store == KVStoreMemory) {
+		errs = append(errs, errors.New("-migrate: миграции есть только у хранилищ kv.store: sqlite и postgres, а kv не включен или хранится в памяти"))
+	}
+	if !c.Redis.Enabled {
+		if c.RateLimit.Enabled && c.RateLimit.Store == StateRedis {
+			errs = append(errs, errors.New("rate_limit.store: для хранилища redis нужно включить redis.enabled"))
+		}
+		if c.Session.Enabled && c.Session.Store == StateRedis {
+			errs = append(errs, errors.New("session.store: для хранилища redis нужно включить redis.enabled"))
+		}
+		if c.Cache.Responses.Enabled && c.Cache.Responses.Store == StateRedis {
+			errs = append(errs, errors.New("cache.responses.store: для хранилища redis нужно включить redis.enabled"))
+		}
+	}
+	errs = append(errs, c.Admin.validate(c.Server))
+	errs = append(errs, c.Tracing.validate())
+	errs = append(errs, c.Health.validate())
+	errs = append(errs, c.ErrorReport.validate())
+	errs = append(errs, c.Audit.validate())
+	if _, err := c.RealIP.Prefixes(); err != nil {
+		errs = append(errs, err)
+	}
+
+	errs = append(errs, c.Log.validateOutput())
+	switch c.Log.Format {
+	case "", "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("log.format: неизвестный формат %q: ожидается text или json", c.Log.Format))
+	}
+	if _, err := c.Log.SlogLevel(); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	errs = append(errs, c.Log.validateAccess())
+	errs = append(errs, c.Log.Bodies.validate())
+	errs = append(errs, c.Log.Async.validate())
+	if c.Log.SlowThreshold < 0 {
+		errs = append(errs, errors.New("log.slow_threshold: ожидается неотрицательная длительность"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateAccess - Проверка формата access лога и шаблона для access_format: template
+func (l Log) validateAccess() error {
+	switch l.AccessFormat {
+	case "", "text", "common", "combined", "json":
+		return nil
+	case "template":
+		if l.AccessTemplate == "" {
+			return errors.New("log.access_template: шаблон не задан, а access_format: template")
+		}
+		if _, err := template.New("access").Parse(l.AccessTemplate); err != nil {
+			return fmt.Errorf("log.access_template: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("log.access_format: неизвестный формат %q: ожидается text, common, combined, json или template", l.AccessFormat)
+}
+
+// SlogLevel - Минимальный уровень записей лога, указанный в настройках
+func (l Log) SlogLevel() (slog.Level, error) {
+	switch strings.ToLower(l.Level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("неизвестный уровень %q: ожидается debug, info, warn или error", l.Level)
+	}
+}
+
+// validateHost - Проверка хоста: пустая строка, IP адрес или имя хоста без порта
+func validateHost(host string) error {
+	// Пустой хост означает все сетевые интерфейсы
+	if host == "" {
+		return nil
+	}
+
+	// IP адрес (в том числе IPv6 без квадратных скобок)
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+
+	// Иначе хост должен быть доменным именем без порта и пробелов
+	if strings.ContainsAny(host, ":/ \t") {
+		return fmt.Errorf("некорректный адрес %q: ожидается IP адрес или имя хоста без порта", host)
+	}
+
+	return nil
+}
+
+// validateHosts - Проверка виртуальных хостов: имена не пустые и не повторяются
+func validateHosts(hosts []VirtualHost) error {
+	var errs []error
+	seen := map[string]bool{}
+
+	for i, h := range hosts {
+		if len(h.Names) == 0 {
+			errs = append(errs, fmt.Errorf("router.hosts[%d].names: нужно указать хотя бы одно имя хоста", i))
+		}
+
+		for _, name := range h.Names {
+			name = strings.ToLower(name)
+			if err := validateHost(strings.TrimPrefix(name, "*.")); err != nil || name == "" || name == "*." {
+				errs = append(errs, fmt.Errorf("router.hosts[%d].names: некорректное имя хоста %q", i, name))
+				continue
+			}
+			if seen[name] {
+				errs = append(errs, fmt.Errorf("router.hosts[%d].names: хост %q уже указан", i, name))
+			}
+			seen[name] = true
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateProxies - Проверка маршрутов обратного прокси
+func validateProxies(proxies []Proxy) error {
+	var errs []error
+	seen := map[string]bool{}
+
+	for i, p := range proxies {
+		if !strings.HasPrefix(p.Prefix, "/") {
+			errs = append(errs, fmt.Errorf("router.proxies[%d].prefix: префикс %q должен начинаться с /", i, p.Prefix))
+		} else if prefix := strings.TrimSuffix(p.Prefix, "/"); seen[prefix] {
+			errs = append(errs, fmt.Errorf("router.proxies[%d].prefix: префикс %q уже используется", i, p.Prefix))
+		} else {
+			seen[prefix] = true
+		}
+
+		u, err := url.Parse(p.Upstream)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("router.proxies[%d].upstream: некорректный адрес %q: ожидается http(s)://host[:port][/path]", i, p.Upstream))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateStatic(static []Static) error {
+	var errs []error
+	seen := map[string]bool{}
+
+	for i, s := range static {
+		if !strings.HasPrefix(s.Prefix, "/") {
+			errs = append(errs, fmt.Errorf("router.static[%d].prefix: префикс %q должен начинаться с /", i, s.Prefix))
+		} else if prefix := strings.TrimSuffix(s.Prefix, "/"); seen[prefix] {
+			errs = append(errs, fmt.Errorf("router.static[%d].prefix: префикс %q уже используется", i, s.Prefix))
+		} else {
+			seen[prefix] = true
+		}
+
+		for j, p := range s.SPAExclude {
+			if !strings.HasPrefix(p, "/") {
+				errs = append(errs, fmt.Errorf("router.static[%d].spa_exclude[%d]: префикс %q должен начинаться с /", i, j, p))
+			}
+		}
+
+		if s.Dir == "" {
+			errs = append(errs, fmt.Errorf("router.static[%d].dir: значение не может быть пустым", i))
+		} else if fi, err := os.Stat(s.Dir); err != nil {
+			errs = append(errs, fmt.Errorf("router.static[%d].dir: %w", i, err))
+		} else if !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("router.static[%d].dir: %q не является каталогом", i, s.Dir))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// lookupEnv - Возвращает значение переменной окружения, если оно задано и не пустое
+func lookupEnv(getenv func(string) string, key string) (string, bool) {
+	v := strings.TrimSpace(getenv(key))
+	return v, v != ""
+}
+
+// Duration - time.Duration, который в файле конфигурации записывается строкой вида "5s" или "1m30s"
+type Duration time.Duration
+
+// D - Значение в виде time.Duration
+func (d Duration) D() time.Duration {
+	return time.Duration(d)
+}
+
+// String - Строковое представление длительности
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON - Сериализация длительности в строку
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, d.String()), nil
+}
+
+// UnmarshalJSON - Разбор длительности из строки вида "5s"
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	s, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("длительность должна быть строкой вида \"5s\", получено %s", data)
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// Checksum - Контрольная сумма SHA-256 конфигурации в JSON без секретов (см. redacted). По ней видно,
+// одинаково ли настроены экземпляры сервера и применилось ли перечитывание, без раскрытия самих значений.
+// Секреты не учитываются: иначе по сумме можно было бы подбирать короткие секреты и замечать их смену
+func (c *Config) Checksum() string {
+	data, _ := json.Marshal(c.redacted()) // Config состоит из сериализуемых полей
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// redactedValue - Значение секрета в redacted
+const redactedValue = "[REDACTED]"
+
+// redacted - Копия конфигурации, в которой непустые секреты заменены на redactedValue. Новое поле
+// с секретом нужно добавить и сюда
+func (c *Config) redacted() *Config {
+	r := *c
+	redact := func(s *string) {
+		if *s != "" {
+			*s = redactedValue
+		}
+	}
+	redactMap := func(m map[string]string) map[string]string {
+		out := make(map[string]string, len(m))
+		for k, v := range m {
+			out[k] = v
+			if v != "" {
+				out[k] = redactedValue
+			}
+		}
+		return out
+	}
+
+	redact(&r.Server.TLS.Key)
+	redact(&r.Auth.JWT.Secret)
+	redact(&r.Auth.OIDC.ClientSecret)
+	redact(&r.Auth.OIDC.CookieSecret)
+	r.Auth.Basic.Users = redactMap(r.Auth.Basic.Users)
+	r.Auth.APIKey.Keys = redactMap(r.Auth.APIKey.Keys)
+	r.Auth.Signature.Clients = redactMap(r.Auth.Signature.Clients)
+	redact(&r.Session.Secret)
+	redact(&r.KV.Postgres.DSN)
+	redact(&r.Redis.Password)
+	redact(&r.Audit.Key)
+	redact(&r.ErrorReport.DSN)
+	r.Jobs.Webhooks = slices.Clone(r.Jobs.Webhooks)
+	for i := range r.Jobs.Webhooks {
+		redact(&r.Jobs.Webhooks[i].Secret)
+	}
+	return &r
+}
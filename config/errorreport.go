@@ -0,0 +1,34 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorReport - Отправка паник и внутренних ошибок во внешний сервис. Применяется только при запуске,
+// провайдер sentry требует сборки с -tags sentry
+type ErrorReport struct {
+	Provider    string  `json:"provider"`    // none - не отправлять, sentry - в Sentry
+	DSN         string  `json:"dsn"`         // Адрес проекта Sentry
+	Environment string  `json:"environment"` // Окружение в отчетах, например production
+	SampleRate  float64 `json:"sample_rate"` // Доля отправляемых ошибок от 0 до 1
+}
+
+func (e ErrorReport) validate() error {
+	var errs []error
+
+	switch e.Provider {
+	case "none":
+	case "sentry":
+		if e.DSN == "" {
+			errs = append(errs, errors.New("error_reporting.dsn: для провайдера sentry значение не может быть пустым"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("error_reporting.provider: неизвестное значение %q: ожидается none или sentry", e.Provider))
+	}
+	if e.SampleRate < 0 || e.SampleRate > 1 {
+		errs = append(errs, fmt.Errorf("error_reporting.sample_rate: ожидается число от 0 до 1, получено %v", e.SampleRate))
+	}
+
+	return errors.Join(errs...)
+}
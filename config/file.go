@@ -0,0 +1,44 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadFile - Читает файл конфигурации поверх уже заполненных значений.
+// Формат файла определяется по расширению: .json, .yaml или .yml
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("чтение файла конфигурации: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+	case ".yaml", ".yml":
+		// YAML приводится к JSON, чтобы разбор в структуру был одинаковым для обоих форматов
+		data, err = yamlToJSON(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("%s: неподдерживаемый формат файла конфигурации %q", path, ext)
+	}
+
+	if err = decodeJSON(data, c); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// decodeJSON - Строгий разбор JSON: неизвестные поля считаются ошибкой, чтобы опечатки в конфиге не терялись молча
+func decodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
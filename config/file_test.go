@@ -0,0 +1,11 @@
+package config
+
+import "testing"
+
+// Пример конфигурации из репозитория должен загружаться без ошибок: неизвестный ключ или сбитый отступ
+// секции в нем - ошибка разбора
+func TestLoadExampleConfig(t *testing.T) {
+	if _, err := Load([]string{"-config", "../config.example.yaml"}, func(string) string { return "" }); err != nil {
+		t.Fatalf("config.example.yaml: %v", err)
+	}
+}
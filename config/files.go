@@ -0,0 +1,41 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Files - Загрузка и скачивание файлов (набор маршрутов files). Применяется только при запуске
+type Files struct {
+	Enabled         bool   `json:"enabled"`
+	Dir             string `json:"dir"`               // Каталог, в котором сохраняются загруженные файлы
+	MaxFileBytes    int64  `json:"max_file_bytes"`    // Максимальный размер одного файла
+	MaxRequestBytes int64  `json:"max_request_bytes"` // Максимальный размер запроса на загрузку целиком, вместо request.max_body_bytes
+	MaxFiles        int    `json:"max_files"`         // Максимальное количество файлов в одном запросе
+}
+
+func (f Files) validate() error {
+	if !f.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if f.Dir == "" {
+		errs = append(errs, errors.New("files.dir: значение не может быть пустым"))
+	}
+
+	if f.MaxFileBytes <= 0 {
+		errs = append(errs, fmt.Errorf("files.max_file_bytes: ожидается положительное число, получено %d", f.MaxFileBytes))
+	}
+
+	if f.MaxRequestBytes < f.MaxFileBytes {
+		errs = append(errs, fmt.Errorf("files.max_request_bytes: значение %d меньше files.max_file_bytes", f.MaxRequestBytes))
+	}
+
+	if f.MaxFiles <= 0 {
+		errs = append(errs, fmt.Errorf("files.max_files: ожидается положительное число, получено %d", f.MaxFiles))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Health - Проверки зависимостей в /readyz. Список disks применяется только при запуске
+type Health struct {
+	Timeout Duration    `json:"timeout"` // Сколько ждать каждую проверку, проверки выполняются параллельно
+	Disks   []DiskCheck `json:"disks"`   // Проверки свободного места на диске
+}
+
+// DiskCheck - Проверка, что на файловой системе с каталогом Path свободно не меньше MinFreeBytes байт
+type DiskCheck struct {
+	Path         string `json:"path"`
+	MinFreeBytes uint64 `json:"min_free_bytes"`
+}
+
+func (h Health) validate() error {
+	var errs []error
+
+	if h.Timeout <= 0 {
+		errs = append(errs, errors.New("health.timeout: ожидается положительная длительность"))
+	}
+	for i, d := range h.Disks {
+		if d.Path == "" {
+			errs = append(errs, fmt.Errorf("health.disks[%d].path: значение не может быть пустым", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
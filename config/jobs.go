@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// События webhook
+const (
+	EventFileUploaded = "file.uploaded" // Файлы загружены через POST /upload, тело - их описания
+	EventKVPut        = "kv.put"        // Записано значение ключа kv, доставляется через outbox
+	EventKVDelete     = "kv.delete"     // Удален ключ kv, доставляется через outbox
+)
+
+// events - Все события webhook
+var events = []string{EventFileUploaded, EventKVPut, EventKVDelete}
+
+// Jobs - Фоновые задачи: пул воркеров с повторами при ошибках. Состояние очереди - GET /debug/jobs
+// на admin адресе. Применяется только при запуске
+type Jobs struct {
+	Workers     int       `json:"workers"`      // Сколько задач выполняется одновременно
+	QueueSize   int       `json:"queue_size"`   // Сколько задач может ждать выполнения, новые сверх этого отклоняются
+	MaxAttempts int       `json:"max_attempts"` // Попыток выполнить задачу до признания ее неудачной
+	BaseBackoff Duration  `json:"base_backoff"` // Пауза перед первым повтором, каждая следующая вдвое дольше
+	MaxBackoff  Duration  `json:"max_backoff"`  // Наибольшая пауза перед повтором
+	Timeout     Duration  `json:"timeout"`      // Ограничение времени одной попытки
+	Webhooks    []Webhook `json:"webhooks"`     // Уведомления о событиях сервера
+}
+
+// Webhook - Уведомление о событии: POST на url описания события в JSON
+type Webhook struct {
+	Event  string `json:"event"`  // file.uploaded, kv.put или kv.delete
+	URL    string `json:"url"`    // Адрес http или https получателя
+	Secret string `json:"secret"` // Ключ подписи тела HMAC-SHA256 в X-Webhook-Signature, пустой - без подписи
+}
+
+func (j Jobs) validate() error {
+	var errs []error
+
+	if j.Workers < 1 {
+		errs = append(errs, fmt.Errorf("jobs.workers: ожидается положительное число, получено %d", j.Workers))
+	}
+	if j.QueueSize < 1 {
+		errs = append(errs, fmt.Errorf("jobs.queue_size: ожидается положительное число, получено %d", j.QueueSize))
+	}
+	if j.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("jobs.max_attempts: ожидается положительное число, получено %d", j.MaxAttempts))
+	}
+	if j.BaseBackoff <= 0 || j.MaxBackoff < j.BaseBackoff {
+		errs = append(errs, errors.New("jobs.base_backoff, jobs.max_backoff: ожидаются положительные длительности, max_backoff не меньше base_backoff"))
+	}
+	if j.Timeout <= 0 {
+		errs = append(errs, errors.New("jobs.timeout: ожидается положительная длительность"))
+	}
+
+	for i, w := range j.Webhooks {
+		if !slices.Contains(events, w.Event) {
+			errs = append(errs, fmt.Errorf("jobs.webhooks[%d].event: неизвестное событие %q, ожидается одно из %s", i, w.Event, strings.Join(events, ", ")))
+		}
+		if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("jobs.webhooks[%d].url: ожидается адрес http или https, получено %q", i, w.URL))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// Listener - Отдельный адрес, на котором сервер принимает запросы.
+// Все адреса обслуживаются одним и тем же обработчиком и останавливаются вместе
+type Listener struct {
+	Name       string `json:"name"`        // Имя для логов, по умолчанию http, https или unix
+	Addr       string `json:"addr"`        // TCP адрес в формате host:port
+	Socket     string `json:"socket"`      // Путь к Unix сокету вместо TCP адреса
+	SocketMode string `json:"socket_mode"` // Права на файл сокета в восьмеричном виде, по умолчанию "0660"
+
+	TLS   bool `json:"tls"`   // HTTPS с сертификатом из server.tls
+	HTTP3 bool `json:"http3"` // Дополнительно HTTP/3 (QUIC) на том же UDP порту, только вместе с tls
+
+	// Вместо обработки запросов перенаправлять их на первый адрес с tls
+	RedirectHTTPS bool `json:"redirect_https"`
+
+	// Проверка сертификатов клиентов для адреса с tls: none, request или require (см. TLS.ClientAuth).
+	// Пустое значение - server.tls.client_auth
+	ClientAuth string `json:"client_auth"`
+
+	// Соединения начинаются с заголовка PROXY protocol (v1 или v2) от балансировщика нагрузки,
+	// адрес клиента берется из него. Соединения без заголовка закрываются. HTTP/3 не затрагивает
+	ProxyProtocol bool `json:"proxy_protocol"`
+}
+
+// Network - Сеть для net.Listen: tcp или unix
+func (l Listener) Network() string {
+	if l.Socket != "" {
+		return "unix"
+	}
+	return "tcp"
+}
+
+// Address - Адрес для net.Listen: host:port или путь к сокету
+func (l Listener) Address() string {
+	if l.Socket != "" {
+		return l.Socket
+	}
+	return l.Addr
+}
+
+// TLSClientAuth - Проверка сертификатов клиентов в виде константы пакета crypto/tls
+func (l Listener) TLSClientAuth() tls.ClientAuthType {
+	return clientAuthTypes[l.ClientAuth]
+}
+
+// SocketFileMode - Права на файл Unix сокета
+func (l Listener) SocketFileMode() os.FileMode {
+	mode, err := strconv.ParseUint(l.SocketMode, 8, 32)
+	if err != nil {
+		return 0o660
+	}
+	return os.FileMode(mode)
+}
+
+// Port - Порт TCP адреса, пустая строка для Unix сокета
+func (l Listener) Port() string {
+	_, port, _ := net.SplitHostPort(l.Addr)
+	return port
+}
+
+// EffectiveListeners - Адреса, на которых запускается сервер.
+//
+// Если список server.listeners не задан, он строится из server.host, server.port, server.socket и server.tls:
+// Unix сокет, либо обычный HTTP, либо HTTPS и, при server.tls.redirect_http, перенаправляющий на него HTTP
+func (s Server) EffectiveListeners() []Listener {
+	if len(s.Listeners) > 0 {
+		list := make([]Listener, len(s.Listeners))
+		for i, l := range s.Listeners {
+			if l.Name == "" {
+				l.Name = defaultListenerName(l)
+			}
+			if l.SocketMode == "" {
+				l.SocketMode = "0660"
+			}
+			if l.TLS && l.ClientAuth == "" {
+				l.ClientAuth = s.TLS.ClientAuth
+			}
+			list[i] = l
+		}
+		return list
+	}
+
+	switch {
+	case s.Socket != "":
+		return []Listener{{Name: "unix", Socket: s.Socket, SocketMode: s.SocketMode, ProxyProtocol: s.ProxyProtocol}}
+	case !s.TLS.Enabled():
+		return []Listener{{Name: "http", Addr: s.Addr(), ProxyProtocol: s.ProxyProtocol}}
+	}
+
+	list := []Listener{{
+		Name:          "https",
+		Addr:          s.TLS.Addr(s.Host),
+		TLS:           true,
+		HTTP3:         s.TLS.HTTP3,
+		ClientAuth:    s.TLS.ClientAuth,
+		ProxyProtocol: s.ProxyProtocol,
+	}}
+	if s.TLS.RedirectHTTP {
+		list = append(list, Listener{Name: "http", Addr: s.Addr(), RedirectHTTPS: true, ProxyProtocol: s.ProxyProtocol})
+	}
+	return list
+}
+
+// defaultListenerName - Имя адреса для логов, если оно не задано в конфигурации
+func defaultListenerName(l Listener) string {
+	switch {
+	case l.Socket != "":
+		return "unix"
+	case l.TLS:
+		return "https"
+	default:
+		return "http"
+	}
+}
+
+// validateListeners - Проверка явно заданного списка server.listeners
+func validateListeners(list []Listener, t TLS) error {
+	var (
+		errs    []error
+		names   = map[string]bool{}
+		addrs   = map[string]bool{}
+		haveTLS bool
+	)
+
+	for i, l := range list {
+		prefix := fmt.Sprintf("server.listeners[%d]", i)
+
+		if l.Name != "" {
+			if names[l.Name] {
+				errs = append(errs, fmt.Errorf("%s.name: повторяющееся имя %q", prefix, l.Name))
+			}
+			names[l.Name] = true
+		}
+
+		switch {
+		case l.Addr == "" && l.Socket == "":
+			errs = append(errs, fmt.Errorf("%s: нужно указать addr или socket", prefix))
+			continue
+		case l.Addr != "" && l.Socket != "":
+			errs = append(errs, fmt.Errorf("%s: addr и socket нельзя указывать одновременно", prefix))
+			continue
+		case l.Addr != "":
+			if err := validateAddr(l.Addr); err != nil {
+				errs = append(errs, fmt.Errorf("%s.addr: %w", prefix, err))
+			}
+		case l.Socket != "":
+			if l.TLS {
+				errs = append(errs, fmt.Errorf("%s.tls: Unix сокет не используется вместе с HTTPS, TLS завершается на стороне прокси", prefix))
+			}
+			if l.SocketMode != "" {
+				if err := validateSocketMode(l.SocketMode); err != nil {
+					errs = append(errs, fmt.Errorf("%s.socket_mode: %w", prefix, err))
+				}
+			}
+		}
+
+		key := l.Network() + " " + l.Address()
+		if addrs[key] {
+			errs = append(errs, fmt.Errorf("%s: адрес %s уже используется другим listener", prefix, l.Address()))
+		}
+		addrs[key] = true
+
+		if l.TLS {
+			haveTLS = true
+			if !t.Enabled() {
+				errs = append(errs, fmt.Errorf("%s.tls: не заданы server.tls.cert_file и server.tls.key_file или server.tls.acme", prefix))
+			}
+		}
+
+		switch {
+		case l.ClientAuth != "" && !l.TLS:
+			errs = append(errs, fmt.Errorf("%s.client_auth: сертификаты клиентов проверяются только на адресе с tls", prefix))
+		case l.ClientAuth != "":
+			if err := t.validateClientAuth(prefix+".client_auth", l.ClientAuth); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if l.HTTP3 && !l.TLS {
+			errs = append(errs, fmt.Errorf("%s.http3: HTTP/3 работает только вместе с tls", prefix))
+		}
+
+		if l.RedirectHTTPS && l.TLS {
+			errs = append(errs, fmt.Errorf("%s.redirect_https: адрес с tls не может перенаправлять сам на себя", prefix))
+		}
+	}
+
+	if t.ACME.Enabled && t.ACME.Challenge == "http-01" && !slices.ContainsFunc(list, func(l Listener) bool { return !l.TLS && l.Socket == "" }) {
+		errs = append(errs, errors.New("server.tls.acme.challenge: для http-01 нужен адрес с обычным HTTP на TCP"))
+	}
+
+	for i, l := range list {
+		if l.RedirectHTTPS && !haveTLS {
+			errs = append(errs, fmt.Errorf("server.listeners[%d].redirect_https: в списке нет адреса с tls", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateAddr - Проверка TCP адреса в формате host:port
+func validateAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("некорректный адрес %q: ожидается host:port", addr)
+	}
+
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("некорректный порт %q: ожидается число от 1 до 65535", port)
+	}
+
+	return validateHost(host)
+}
+
+// validateSocketMode - Проверка прав на файл сокета
+func validateSocketMode(mode string) error {
+	if m, err := strconv.ParseUint(mode, 8, 32); err != nil || m > 0o777 {
+		return fmt.Errorf("некорректные права %q: ожидается восьмеричное число, например \"0660\"", mode)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+)
+
+// LogFile - Файл логов с ротацией: текущий файл переименовывается в архивный по размеру или по времени,
+// старые архивные файлы удаляются
+type LogFile struct {
+	Path        string   `json:"path"`         // Путь к текущему файлу логов, например /var/log/go-web-server/server.log
+	MaxBytes    int64    `json:"max_bytes"`    // Ротация, когда размер файла превысит это значение, 0 - без ротации по размеру
+	RotateEvery Duration `json:"rotate_every"` // Ротация через этот интервал после открытия файла, 0 - без ротации по времени
+	MaxBackups  int      `json:"max_backups"`  // Сколько архивных файлов хранить, 0 - без ограничения
+	MaxAge      Duration `json:"max_age"`      // Удалять архивные файлы старше этого возраста, 0 - без ограничения
+}
+
+// Syslog - Отправка логов в syslog, для output: syslog. Tag используется и как SYSLOG_IDENTIFIER для output: journald
+type Syslog struct {
+	Network  string `json:"network"`  // Пусто - локальный syslog через Unix сокет, иначе udp или tcp
+	Address  string `json:"address"`  // Адрес сервера для udp и tcp, например logs.example.com:514
+	Facility string `json:"facility"` // Источник записей: daemon, user, local0 ... local7
+	Tag      string `json:"tag"`      // Имя программы в записях
+}
+
+// SyslogFacilities - Допустимые значения Syslog.Facility
+var SyslogFacilities = []string{"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron",
+	"authpriv", "ftp", "local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7"}
+
+func (s Syslog) validate() error {
+	var errs []error
+
+	switch s.Network {
+	case "":
+		if s.Address != "" {
+			errs = append(errs, errors.New("log.syslog.address: адрес задается только вместе с network: udp или tcp"))
+		}
+	case "udp", "tcp":
+		if _, _, err := net.SplitHostPort(s.Address); err != nil {
+			errs = append(errs, fmt.Errorf("log.syslog.address: ожидается хост:порт, получено %q", s.Address))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("log.syslog.network: неизвестное значение %q: ожидается пустое значение, udp или tcp", s.Network))
+	}
+
+	if !slices.Contains(SyslogFacilities, s.Facility) {
+		errs = append(errs, fmt.Errorf("log.syslog.facility: неизвестное значение %q: ожидается одно из %v", s.Facility, SyslogFacilities))
+	}
+	if s.Tag == "" {
+		errs = append(errs, errors.New("log.syslog.tag: значение не может быть пустым"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// AsyncLog - Асинхронная запись логов: записи попадают в очередь, а в log.output их пишет отдельная горутина,
+// поэтому медленный диск или syslog не задерживают обработку запросов
+type AsyncLog struct {
+	Enabled   bool   `json:"enabled"`
+	QueueSize int    `json:"queue_size"` // Размер очереди записей, только при запуске
+	Policy    string `json:"policy"`     // Когда очередь заполнена: block - ждать места, drop - отбросить запись
+}
+
+func (a AsyncLog) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if a.QueueSize <= 0 {
+		errs = append(errs, fmt.Errorf("log.async.queue_size: ожидается положительное число, получено %d", a.QueueSize))
+	}
+	switch a.Policy {
+	case "block", "drop":
+	default:
+		errs = append(errs, fmt.Errorf("log.async.policy: неизвестное значение %q: ожидается block или drop", a.Policy))
+	}
+	return errors.Join(errs...)
+}
+
+// BodyLog - Логирование тел запросов и ответов для отладки. Записи идут с уровнем debug,
+// поэтому нужен и log.level: debug. Не включать на боевом сервере: тела могут содержать персональные данные
+type BodyLog struct {
+	Enabled       bool     `json:"enabled"`
+	MaxBytes      int      `json:"max_bytes"`      // Сколько байт тела записывать в лог, остальное отбрасывается
+	RedactHeaders []string `json:"redact_headers"` // Заголовки, значения которых заменяются на [REDACTED]
+	RedactFields  []string `json:"redact_fields"`  // Поля JSON и форм и параметры query, значения которых заменяются на [REDACTED], без учета регистра
+}
+
+func (b BodyLog) validate() error {
+	if b.Enabled && b.MaxBytes <= 0 {
+		return fmt.Errorf("log.bodies.max_bytes: ожидается положительное число, получено %d", b.MaxBytes)
+	}
+	return nil
+}
+
+// validateOutput - Проверка назначения логов и настроек файла для output: file
+func (l Log) validateOutput() error {
+	switch l.Output {
+	case "", "stderr", "stdout":
+		return nil
+	case "file":
+		return l.File.validate()
+	case "syslog", "journald":
+		return l.Syslog.validate()
+	}
+	return fmt.Errorf("log.output: неизвестное значение %q: ожидается stderr, stdout, file, syslog или journald", l.Output)
+}
+
+func (f LogFile) validate() error {
+	var errs []error
+
+	if f.Path == "" {
+		errs = append(errs, errors.New("log.file.path: значение не может быть пустым при log.output: file"))
+	}
+	if f.MaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("log.file.max_bytes: ожидается неотрицательное число, получено %d", f.MaxBytes))
+	}
+	if f.RotateEvery < 0 {
+		errs = append(errs, fmt.Errorf("log.file.rotate_every: ожидается неотрицательная длительность, получено %s", f.RotateEvery.D()))
+	}
+	if f.MaxBackups < 0 {
+		errs = append(errs, fmt.Errorf("log.file.max_backups: ожидается неотрицательное число, получено %d", f.MaxBackups))
+	}
+	if f.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("log.file.max_age: ожидается неотрицательная длительность, получено %s", f.MaxAge.D()))
+	}
+
+	return errors.Join(errs...)
+}
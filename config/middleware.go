@@ -0,0 +1,306 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// CORS - Настройки кросс-доменных запросов из браузера
+type CORS struct {
+	Enabled          bool     `json:"enabled"`
+	AllowedOrigins   []string `json:"allowed_origins"`   // Разрешенные источники: https://app.example.com, https://*.example.com или *
+	AllowedMethods   []string `json:"allowed_methods"`   // Методы, разрешенные в предварительном запросе
+	AllowedHeaders   []string `json:"allowed_headers"`   // Заголовки запроса, разрешенные в предварительном запросе, * - любые
+	ExposedHeaders   []string `json:"exposed_headers"`   // Заголовки ответа, доступные скрипту в браузере
+	AllowCredentials bool     `json:"allow_credentials"` // Разрешить запросы с cookie и заголовком Authorization
+	MaxAge           Duration `json:"max_age"`           // Сколько браузер может кэшировать результат предварительного запроса
+}
+
+func (c CORS) validate() error {
+	var errs []error
+
+	if c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*") {
+		errs = append(errs, errors.New("cors.allow_credentials: запросы с cookie нельзя разрешать для любого источника (allowed_origins: *)"))
+	}
+
+	if c.MaxAge < 0 {
+		errs = append(errs, errors.New("cors.max_age: значение не может быть отрицательным"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Compression - Настройки сжатия ответов
+type Compression struct {
+	Enabled bool     `json:"enabled"`
+	MinSize int      `json:"min_size"` // Ответы меньше этого размера в байтах не сжимаются
+	Level   int      `json:"level"`    // Степень сжатия от 1 (быстрее) до 9 (сильнее), 0 - значение по умолчанию алгоритма
+	Types   []string `json:"types"`    // Сжимаемые типы содержимого: application/json или text/* для всей группы
+}
+
+func (c Compression) validate() error {
+	var errs []error
+
+	if c.MinSize < 0 {
+		errs = append(errs, errors.New("compression.min_size: значение не может быть отрицательным"))
+	}
+
+	if c.Level < 0 || c.Level > 9 {
+		errs = append(errs, fmt.Errorf("compression.level: некорректная степень сжатия %d: ожидается число от 0 до 9", c.Level))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ETag - Настройки условных GET запросов
+type ETag struct {
+	Enabled bool `json:"enabled"` // ETag для JSON ответов и 304 Not Modified по If-None-Match
+}
+
+// Cache - Политики кэширования ответов клиентами и промежуточными прокси
+type Cache struct {
+	Enabled   bool                   `json:"enabled"`
+	Default   CachePolicy            `json:"default"`   // Политика для путей, не подходящих ни под один префикс из paths
+	Paths     map[string]CachePolicy `json:"paths"`     // Политики по префиксу пути, из подходящих выбирается самый длинный
+	Responses ResponseCache          `json:"responses"` // Кэш ответов на стороне сервера
+}
+
+// ResponseCache - Кэш ответов на стороне сервера: ответ 200 на GET без учетных данных и аутентификации клиента
+// сохраняется на max_age политики своего пути, если она разрешает общий кэш (не no_store и не private)
+type ResponseCache struct {
+	Enabled        bool   `json:"enabled"`
+	Store          string `json:"store"`            // Где хранятся ответы: memory или redis, только при запуске
+	MaxBodyBytes   int64  `json:"max_body_bytes"`   // Ответы с телом больше этого не кэшируются
+	MaxEntries     int    `json:"max_entries"`      // Сколько ответов хранит store: memory, давно не запрошенные вытесняются, только при запуске
+	MaxMemoryBytes int64  `json:"max_memory_bytes"` // Сколько байт занимают ответы в store: memory, только при запуске
+}
+
+// CachePolicy - Политика кэширования, из которой строятся заголовки Cache-Control и Expires
+type CachePolicy struct {
+	NoStore   bool     `json:"no_store"`  // Ответ нельзя сохранять в кэше, остальные поля не учитываются
+	Private   bool     `json:"private"`   // Ответ можно сохранять только в кэше браузера, но не в общих прокси
+	MaxAge    Duration `json:"max_age"`   // Сколько ответ считается свежим. 0 - кэшировать, но проверять актуальность при каждом запросе
+	Immutable bool     `json:"immutable"` // Ответ не меняется, пока свежий: браузер не проверяет его актуальность
+}
+
+// Policy - Политика для пути path
+func (c Cache) Policy(path string) CachePolicy {
+	policy, longest := c.Default, -1
+	for prefix, p := range c.Paths {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			policy, longest = p, len(prefix)
+		}
+	}
+	return policy
+}
+
+func (c Cache) validate() error {
+	var errs []error
+
+	if c.Default.MaxAge < 0 {
+		errs = append(errs, errors.New("cache.default.max_age: значение не может быть отрицательным"))
+	}
+
+	for _, prefix := range slices.Sorted(maps.Keys(c.Paths)) {
+		if !strings.HasPrefix(prefix, "/") {
+			errs = append(errs, fmt.Errorf("cache.paths: префикс %q должен начинаться с /", prefix))
+		}
+		if c.Paths[prefix].MaxAge < 0 {
+			errs = append(errs, fmt.Errorf("cache.paths[%s].max_age: значение не может быть отрицательным", prefix))
+		}
+	}
+
+	if c.Responses.Enabled {
+		errs = append(errs, validateState("cache.responses.store", c.Responses.Store))
+		if c.Responses.MaxBodyBytes <= 0 {
+			errs = append(errs, fmt.Errorf("cache.responses.max_body_bytes: ожидается положительное число, получено %d", c.Responses.MaxBodyBytes))
+		}
+		if c.Responses.MaxEntries <= 0 {
+			errs = append(errs, fmt.Errorf("cache.responses.max_entries: ожидается положительное число, получено %d", c.Responses.MaxEntries))
+		}
+		if c.Responses.MaxMemoryBytes < c.Responses.MaxBodyBytes {
+			errs = append(errs, errors.New("cache.responses.max_memory_bytes: ожидается не меньше max_body_bytes"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RateLimit - Настройки ограничения частоты запросов с одного IP адреса
+type RateLimit struct {
+	Enabled bool    `json:"enabled"`
+	Rate    float64 `json:"rate"`  // Сколько запросов в секунду в среднем разрешено клиенту
+	Burst   int     `json:"burst"` // Сколько запросов подряд клиент может отправить сверх среднего
+	// Store - Где хранятся корзины клиентов: memory - у каждого экземпляра свои, redis - лимит общий
+	// для всех экземпляров. Только при запуске
+	Store string `json:"store"`
+}
+
+func (r RateLimit) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if r.Rate <= 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.rate: некорректное значение %v: ожидается положительное число", r.Rate))
+	}
+
+	if r.Burst < 1 {
+		errs = append(errs, fmt.Errorf("rate_limit.burst: некорректное значение %d: ожидается число не меньше 1", r.Burst))
+	}
+
+	errs = append(errs, validateState("rate_limit.store", r.Store))
+
+	return errors.Join(errs...)
+}
+
+// LoadShedding - Ограничение количества запросов, обрабатываемых одновременно. Запросы сверх лимита
+// не ждут в очереди, а сразу получают 503 с заголовком Retry-After
+type LoadShedding struct {
+	Enabled     bool     `json:"enabled"`
+	MaxInFlight int      `json:"max_in_flight"` // Сколько запросов сервер обрабатывает одновременно
+	RetryAfter  Duration `json:"retry_after"`   // Через сколько клиенту повторить отклоненный запрос, округляется вверх до секунд
+	// Exclude - Префиксы путей, запросы к которым не ограничиваются и не учитываются в лимите: проверки
+	// Kubernetes, метрики и долгие соединения (WebSocket, SSE), которые иначе занимали бы место в лимите
+	Exclude []string `json:"exclude"`
+}
+
+// Excluded - Запросы к path не ограничиваются
+func (l LoadShedding) Excluded(path string) bool {
+	for _, prefix := range l.Exclude {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l LoadShedding) validate() error {
+	if !l.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if l.MaxInFlight < 1 {
+		errs = append(errs, fmt.Errorf("load_shedding.max_in_flight: некорректное значение %d: ожидается число не меньше 1", l.MaxInFlight))
+	}
+
+	if l.RetryAfter < 0 {
+		errs = append(errs, errors.New("load_shedding.retry_after: значение не может быть отрицательным"))
+	}
+
+	for _, prefix := range l.Exclude {
+		if !strings.HasPrefix(prefix, "/") {
+			errs = append(errs, fmt.Errorf("load_shedding.exclude: префикс %q должен начинаться с /", prefix))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Request - Ограничения на обработку одного запроса
+type Request struct {
+	Timeout      Duration `json:"timeout"`        // Максимальное время обработки запроса, при превышении - 504. 0 - без ограничения
+	MaxBodyBytes int64    `json:"max_body_bytes"` // Максимальный размер тела запроса в байтах, при превышении - 413. 0 - без ограничения
+}
+
+func (r Request) validate() error {
+	var errs []error
+
+	if r.Timeout < 0 {
+		errs = append(errs, errors.New("request.timeout: значение не может быть отрицательным"))
+	}
+
+	if r.MaxBodyBytes < 0 {
+		errs = append(errs, errors.New("request.max_body_bytes: значение не может быть отрицательным"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// IPFilter - Списки сетей, которым разрешен или запрещен доступ к серверу.
+// Элемент списка - сеть в нотации CIDR (10.0.0.0/8, fd00::/8) или отдельный адрес (192.168.1.10)
+type IPFilter struct {
+	Allow []string `json:"allow"` // Если список не пуст, доступ есть только у адресов из этих сетей
+	Deny  []string `json:"deny"`  // Адреса из этих сетей не допускаются, даже если входят в allow
+}
+
+// Prefixes - Разобранные списки allow и deny
+func (f IPFilter) Prefixes() (allow, deny []netip.Prefix, err error) {
+	var errs []error
+
+	parse := func(field string, list []string) []netip.Prefix {
+		prefixes := make([]netip.Prefix, 0, len(list))
+		for _, s := range list {
+			p, err := parsePrefix(s)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("ip_filter.%s: %w", field, err))
+				continue
+			}
+			prefixes = append(prefixes, p)
+		}
+		return prefixes
+	}
+
+	allow = parse("allow", f.Allow)
+	deny = parse("deny", f.Deny)
+
+	return allow, deny, errors.Join(errs...)
+}
+
+// parsePrefix - Сеть в нотации CIDR или отдельный адрес как сеть из одного адреса
+func parsePrefix(s string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("некорректная сеть %q: ожидается CIDR (10.0.0.0/8) или IP адрес", s)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Response - Формат ответов сервера
+type Response struct {
+	// Формат ответов с ошибкой: envelope - {"error": "..."}, problem - application/problem+json (RFC 7807)
+	ErrorFormat string `json:"error_format"`
+	CSV         CSV    `json:"csv"`
+}
+
+// CSV - Настройки ответов в формате text/csv
+type CSV struct {
+	Delimiter string `json:"delimiter"` // Разделитель полей, один символ, \t - табуляция
+	Header    bool   `json:"header"`    // Первая строка - имена столбцов
+}
+
+// Comma - Разделитель полей. Значение уже проверено при загрузке конфигурации
+func (c CSV) Comma() rune {
+	if c.Delimiter == `\t` {
+		return '\t'
+	}
+	r, _ := utf8.DecodeRuneInString(c.Delimiter)
+	return r
+}
+
+func (r Response) validate() error {
+	switch r.ErrorFormat {
+	case "envelope", "problem":
+	default:
+		return fmt.Errorf("response.error_format: неизвестный формат %q: ожидается envelope или problem", r.ErrorFormat)
+	}
+
+	d := r.CSV.Delimiter
+	if d != `\t` && (utf8.RuneCountInString(d) != 1 || d == `"` || d == "\r" || d == "\n" || d == string(utf8.RuneError)) {
+		return fmt.Errorf("response.csv.delimiter: некорректный разделитель %q: ожидается один символ, кроме кавычки и перевода строки", d)
+	}
+	return nil
+}
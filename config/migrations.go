@@ -0,0 +1,34 @@
+package config
+
+import "fmt"
+
+// Команды флага -migrate
+const (
+	MigrateUp     = "up"     // Применить все недостающие миграции
+	MigrateDown   = "down"   // Откатить последнюю примененную миграцию
+	MigrateStatus = "status" // Записать в лог примененные и недостающие миграции
+)
+
+// Migrations - Миграции схемы баз данных хранилищ, встроенные в сервер (kv.store: sqlite или postgres).
+// Применяются только при запуске
+type Migrations struct {
+	// Auto - Применять недостающие миграции при запуске. Без него сервер не запускается со старой схемой,
+	// и миграции применяются отдельно: go-web-server -migrate up
+	Auto bool `json:"auto"`
+	// Command - Команда флага -migrate: up, down или status. Сервер выполняет ее и завершается, не принимая запросов
+	Command string `json:"-"`
+	// DryRun - Флаг -migrate-dry-run: up и down только записывают в лог SQL миграций, не выполняя его
+	DryRun bool `json:"-"`
+}
+
+func (m Migrations) validate() error {
+	switch m.Command {
+	case "", MigrateUp, MigrateDown, MigrateStatus:
+	default:
+		return fmt.Errorf("-migrate: неизвестная команда %q: ожидается up, down или status", m.Command)
+	}
+	if m.DryRun && m.Command != MigrateUp && m.Command != MigrateDown {
+		return fmt.Errorf("-migrate-dry-run: применяется только вместе с -migrate up или -migrate down")
+	}
+	return nil
+}
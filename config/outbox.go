@@ -0,0 +1,41 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Outbox - События изменений ключей kv в таблице kv_outbox базы kv.store: sqlite или postgres.
+// Событие фиксируется в той же транзакции, что и изменение, и доставляется webhook из jobs.webhooks
+// даже после падения процесса. Применяется только при запуске
+type Outbox struct {
+	Enabled     bool     `json:"enabled"`
+	Interval    Duration `json:"interval"`     // Как часто проверяется таблица, когда событий нет
+	MaxAttempts int      `json:"max_attempts"` // Попыток доставки, после чего событие остается в таблице с dead = 1
+	BaseBackoff Duration `json:"base_backoff"` // Пауза перед первым повтором, каждая следующая вдвое дольше
+	MaxBackoff  Duration `json:"max_backoff"`  // Наибольшая пауза перед повтором
+	Timeout     Duration `json:"timeout"`      // Ограничение времени доставки одного события всем получателям
+}
+
+func (o Outbox) validate() error {
+	if !o.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if o.Interval <= 0 {
+		errs = append(errs, errors.New("outbox.interval: ожидается положительная длительность"))
+	}
+	if o.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("outbox.max_attempts: ожидается положительное число, получено %d", o.MaxAttempts))
+	}
+	if o.BaseBackoff <= 0 || o.MaxBackoff < o.BaseBackoff {
+		errs = append(errs, errors.New("outbox.base_backoff, outbox.max_backoff: ожидаются положительные длительности, max_backoff не меньше base_backoff"))
+	}
+	if o.Timeout <= 0 {
+		errs = append(errs, errors.New("outbox.timeout: ожидается положительная длительность"))
+	}
+
+	return errors.Join(errs...)
+}
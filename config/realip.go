@@ -0,0 +1,43 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// RealIP - Определение IP адреса клиента за обратным прокси и балансировщиком. Адрес из заголовков
+// принимается, только если запрос пришел с адреса из TrustedProxies, иначе используется адрес соединения
+type RealIP struct {
+	TrustedProxies []string `json:"trusted_proxies"` // Сети и адреса прокси, которым можно верить
+	TrustUnix      bool     `json:"trust_unix"`      // Считать доверенным прокси любого клиента через Unix сокет
+	// Заголовки с адресом клиента в порядке проверки: X-Forwarded-For (список через запятую, справа налево
+	// до первого недоверенного адреса), X-Real-IP или другие заголовки с одним адресом, например CF-Connecting-IP
+	Headers []string `json:"headers"`
+}
+
+// Prefixes - Разобранный список trusted_proxies
+func (ri RealIP) Prefixes() ([]netip.Prefix, error) {
+	var errs []error
+
+	prefixes := make([]netip.Prefix, 0, len(ri.TrustedProxies))
+	for _, s := range ri.TrustedProxies {
+		p, err := parsePrefix(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("real_ip.trusted_proxies: %w", err))
+			continue
+		}
+		prefixes = append(prefixes, p)
+	}
+
+	for i, h := range ri.Headers {
+		if h == "" {
+			errs = append(errs, fmt.Errorf("real_ip.headers[%d]: значение не может быть пустым", i))
+		}
+	}
+	if len(ri.Headers) == 0 && (len(ri.TrustedProxies) > 0 || ri.TrustUnix) {
+		errs = append(errs, errors.New("real_ip.headers: список не может быть пустым, если заданы доверенные прокси"))
+	}
+
+	return prefixes, errors.Join(errs...)
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Хранилища состояния, которое может быть общим для нескольких экземпляров сервера:
+// корзин rate_limit, сессий и кэша ответов
+const (
+	StateMemory = "memory" // В памяти процесса, у каждого экземпляра свое
+	StateRedis  = "redis"  // В Redis из секции redis, общее для всех экземпляров
+)
+
+// Redis - Подключение к Redis для общего состояния экземпляров сервера. Применяется только при запуске
+type Redis struct {
+	Enabled     bool     `json:"enabled"`
+	Addr        string   `json:"addr"`         // Адрес host:port
+	Username    string   `json:"username"`     // Пользователь ACL Redis 6+, пустой - default
+	Password    string   `json:"password"`     // Пароль, лучше ссылкой на секрет, например ${env:REDIS_PASSWORD}
+	DB          int      `json:"db"`           // Номер базы
+	TLS         bool     `json:"tls"`          // Соединение по TLS
+	KeyPrefix   string   `json:"key_prefix"`   // Префикс всех ключей, чтобы несколько приложений могли делить один Redis
+	PoolSize    int      `json:"pool_size"`    // Наибольшее число соединений
+	DialTimeout Duration `json:"dial_timeout"` // Ожидание установки соединения
+	Timeout     Duration `json:"timeout"`      // Ограничение времени одной команды
+}
+
+func (r Redis) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if r.Addr == "" {
+		errs = append(errs, errors.New("redis.addr: нужно указать адрес host:port"))
+	}
+	if r.DB < 0 {
+		errs = append(errs, fmt.Errorf("redis.db: ожидается неотрицательное число, получено %d", r.DB))
+	}
+	if r.PoolSize < 1 {
+		errs = append(errs, fmt.Errorf("redis.pool_size: ожидается положительное число, получено %d", r.PoolSize))
+	}
+	if r.DialTimeout <= 0 {
+		errs = append(errs, errors.New("redis.dial_timeout: ожидается положительная длительность"))
+	}
+	if r.Timeout <= 0 {
+		errs = append(errs, errors.New("redis.timeout: ожидается положительная длительность"))
+	}
+	return errors.Join(errs...)
+}
+
+// validateState - Проверка хранилища состояния store в поле field
+func validateState(field, store string) error {
+	switch store {
+	case StateMemory, StateRedis:
+		return nil
+	}
+	return fmt.Errorf("%s: неизвестное хранилище %q: ожидается memory или redis", field, store)
+}
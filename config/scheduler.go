@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/derv-dice/go-web-server/schedule"
+)
+
+// Задачи по расписанию
+const (
+	TaskSessionCleanup = "session_cleanup" // Удаление истекших сессий из session.store: memory
+	TaskCacheWarmup    = "cache_warmup"    // GET путей scheduler.warmup.paths, чтобы их ответы попали в кэш
+	TaskKVCleanup      = "kv_cleanup"      // Удаление ключей kv с истекшим сроком, выполняется всегда раз в kv.sweep_interval
+)
+
+// tasks - Все задачи по расписанию
+var tasks = []string{TaskSessionCleanup, TaskCacheWarmup}
+
+// Scheduler - Задачи по расписанию cron. Применяется только при запуске
+type Scheduler struct {
+	Enabled  bool              `json:"enabled"`
+	Timezone string            `json:"timezone"` // Часовой пояс расписаний из базы IANA
+	Tasks    map[string]string `json:"tasks"`    // Имя задачи - расписание: "*/5 * * * *", @hourly или @every 10m; задачи без расписания не выполняются
+	Warmup   Warmup            `json:"warmup"`
+}
+
+// Warmup - Прогрев кэша ответов задачей cache_warmup
+type Warmup struct {
+	Host  string   `json:"host"`  // Host запросов как у клиентов, например example.com: ответы кэшируются отдельно для каждого Host
+	Paths []string `json:"paths"` // Пути с query, например /v1/time?tz=UTC
+}
+
+// Location - Часовой пояс расписаний. Ошибка невозможна после проверки конфигурации
+func (s Scheduler) Location() *time.Location {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func (s Scheduler) validate() error {
+	if !s.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	if _, err := time.LoadLocation(s.Timezone); err != nil || s.Timezone == "" || s.Timezone == "Local" {
+		errs = append(errs, fmt.Errorf("scheduler.timezone: ожидается часовой пояс из базы IANA, например Europe/Moscow, получено %q", s.Timezone))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(s.Tasks)) {
+		if !slices.Contains(tasks, name) {
+			errs = append(errs, fmt.Errorf("scheduler.tasks: неизвестная задача %q: ожидается одна из %s", name, strings.Join(tasks, ", ")))
+			continue
+		}
+		if _, err := schedule.Parse(s.Tasks[name]); err != nil {
+			errs = append(errs, fmt.Errorf("scheduler.tasks.%s: %w", name, err))
+		}
+	}
+
+	if _, ok := s.Tasks[TaskCacheWarmup]; ok && len(s.Warmup.Paths) == 0 {
+		errs = append(errs, errors.New("scheduler.warmup.paths: для задачи cache_warmup нужен хотя бы один путь"))
+	}
+	for i, p := range s.Warmup.Paths {
+		if !strings.HasPrefix(p, "/") {
+			errs = append(errs, fmt.Errorf("scheduler.warmup.paths[%d]: путь должен начинаться с /, получено %q", i, p))
+		}
+	}
+
+	return errors.Join(errs...)
+}
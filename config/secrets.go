@@ -0,0 +1,131 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/derv-dice/go-web-server/secrets"
+)
+
+// Имена переменных окружения для подключения к Vault, как у утилиты vault
+const (
+	EnvVaultAddr      = "VAULT_ADDR"
+	EnvVaultToken     = "VAULT_TOKEN"
+	EnvVaultNamespace = "VAULT_NAMESPACE"
+)
+
+// Secrets - Настройки источников секретов.
+//
+// Любое строковое значение конфигурации может ссылаться на секрет: ${env:ИМЯ} - переменная окружения,
+// ${file:путь} - содержимое файла, ${vault:путь#поле} - поле секрета HashiCorp Vault. Ссылки заменяются
+// значениями при загрузке и при каждом перечитывании конфигурации
+type Secrets struct {
+	Vault Vault `json:"vault"`
+}
+
+// Vault - Подключение к HashiCorp Vault для ссылок ${vault:...}
+type Vault struct {
+	Address   string   `json:"address"`    // Адрес сервера, по умолчанию из VAULT_ADDR
+	TokenFile string   `json:"token_file"` // Файл с токеном доступа, без него токен берется из VAULT_TOKEN
+	Namespace string   `json:"namespace"`  // Пространство имен Vault Enterprise, по умолчанию из VAULT_NAMESPACE
+	Timeout   Duration `json:"timeout"`    // Ограничение времени одного запроса к Vault
+}
+
+// options - Настройки подключения с учетом переменных окружения
+func (v Vault) options(getenv func(string) string) (secrets.VaultOptions, error) {
+	opts := secrets.VaultOptions{Address: v.Address, Namespace: v.Namespace, Timeout: v.Timeout.D()}
+	if opts.Address == "" {
+		opts.Address, _ = lookupEnv(getenv, EnvVaultAddr)
+	}
+	if opts.Namespace == "" {
+		opts.Namespace, _ = lookupEnv(getenv, EnvVaultNamespace)
+	}
+
+	if v.TokenFile == "" {
+		opts.Token, _ = lookupEnv(getenv, EnvVaultToken)
+		return opts, nil
+	}
+
+	data, err := os.ReadFile(v.TokenFile)
+	if err != nil {
+		return opts, fmt.Errorf("secrets.vault.token_file: %w", err)
+	}
+	opts.Token = strings.TrimSpace(string(data))
+	return opts, nil
+}
+
+// resolveSecrets - Замена ссылок на секреты во всех строковых значениях конфигурации, кроме секции secrets
+func (c *Config) resolveSecrets(getenv func(string) string) error {
+	resolver := secrets.NewResolver()
+	resolver.Register("env", secrets.Env(getenv))
+	resolver.Register("file", secrets.File())
+
+	vault, err := c.Secrets.Vault.options(getenv)
+	if err != nil {
+		// Ошибка настроек Vault важна, только если конфигурация действительно на него ссылается
+		resolver.Register("vault", secrets.ProviderFunc(func(context.Context, string) (string, error) { return "", err }))
+	} else {
+		// Один клиент на всю загрузку: каждый путь Vault читается один раз
+		resolver.Register("vault", secrets.NewVault(vault))
+	}
+
+	var errs []error
+	expandSecrets(reflect.ValueOf(c).Elem(), "", resolver, &errs)
+	return errors.Join(errs...)
+}
+
+// expandSecrets - Замена ссылок на секреты в строках значения v и вложенных в него структур, списков и словарей.
+// path - путь к значению в конфигурации для сообщений об ошибках, например auth.jwt.secret
+func expandSecrets(v reflect.Value, path string, r *secrets.Resolver, errs *[]error) {
+	switch v.Kind() {
+	case reflect.String:
+		s, err := r.Expand(context.Background(), v.String())
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", path, err))
+			return
+		}
+		v.SetString(s)
+
+	case reflect.Struct:
+		for i := range v.NumField() {
+			f := v.Type().Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" || (path == "" && name == "secrets") {
+				continue
+			}
+			expandSecrets(v.Field(i), joinPath(path, name), r, errs)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			expandSecrets(v.Index(i), fmt.Sprintf("%s[%d]", path, i), r, errs)
+		}
+
+	case reflect.Map:
+		// Значения словаря нельзя изменить на месте, поэтому каждое обрабатывается в копии и записывается обратно
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+			expandSecrets(value, fmt.Sprintf("%s[%v]", path, iter.Key()), r, errs)
+			v.SetMapIndex(iter.Key(), value)
+		}
+
+	case reflect.Pointer:
+		if !v.IsNil() {
+			expandSecrets(v.Elem(), path, r, errs)
+		}
+	}
+}
+
+// joinPath - Путь к полю name внутри значения по пути path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
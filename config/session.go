@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Session - Настройки сессий пользователей. Применяются только при запуске
+type Session struct {
+	Enabled    bool     `json:"enabled"`
+	Store      string   `json:"store"`       // Где хранятся сессии: cookie (зашифрованы в cookie клиента), memory (в памяти сервера) или redis
+	CookieName string   `json:"cookie_name"` // Имя cookie сессии
+	Secret     string   `json:"secret"`      // Ключ шифрования для хранилища cookie, не короче 32 байт
+	TTL        Duration `json:"ttl"`         // Время жизни сессии с момента последнего изменения
+	Secure     bool     `json:"secure"`      // Cookie передается только по HTTPS
+}
+
+func (s Session) validate() error {
+	if !s.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	switch s.Store {
+	case "cookie":
+		if len(s.Secret) < 32 {
+			errs = append(errs, errors.New("session.secret: для хранилища cookie нужен ключ не короче 32 байт"))
+		}
+	case StateMemory, StateRedis:
+	default:
+		errs = append(errs, fmt.Errorf("session.store: неизвестное хранилище %q: ожидается cookie, memory или redis", s.Store))
+	}
+
+	if s.CookieName == "" {
+		errs = append(errs, errors.New("session.cookie_name: значение не может быть пустым"))
+	}
+
+	if s.TTL <= 0 {
+		errs = append(errs, errors.New("session.ttl: ожидается положительная длительность"))
+	}
+
+	return errors.Join(errs...)
+}
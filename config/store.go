@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store - Текущая конфигурация, которую можно атомарно заменить без перезапуска процесса.
+//
+// Обработчики и middleware читают конфигурацию через Current на каждый запрос, поэтому
+// после Reload новые значения применяются к следующим запросам
+type Store struct {
+	current  atomic.Pointer[Config]
+	loadedAt atomic.Pointer[time.Time] // Когда загружен текущий снимок
+	load     func() (Config, error)    // Повторное чтение конфигурации из тех же источников, что и при запуске
+
+	mu        sync.Mutex // Последовательный Reload и доступ к подписчикам
+	listeners []func(old, cur *Config)
+}
+
+// NewStore - Хранилище с начальной конфигурацией cfg. Функция load используется для перечитывания конфигурации в Reload
+func NewStore(cfg Config, load func() (Config, error)) *Store {
+	s := &Store{load: load}
+	s.current.Store(&cfg)
+	now := time.Now()
+	s.loadedAt.Store(&now)
+	return s
+}
+
+// LoadedAt - Время запуска или последнего успешного Reload
+func (s *Store) LoadedAt() time.Time {
+	return *s.loadedAt.Load()
+}
+
+// Current - Текущий снимок конфигурации. Снимок нельзя изменять: он общий для всех горутин
+func (s *Store) Current() *Config {
+	return s.current.Load()
+}
+
+// OnReload - Регистрация функции, которая вызывается после каждой успешной замены конфигурации
+func (s *Store) OnReload(fn func(old, cur *Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Reload - Перечитывание конфигурации и атомарная замена текущего снимка.
+//
+// Если новая конфигурация некорректна, текущая остается без изменений. Настройки секций server (адреса,
+// сертификаты, таймауты) и router (маршруты) применяются только при запуске, поэтому их изменения
+// игнорируются до перезапуска
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg, err := s.load()
+	if err != nil {
+		return fmt.Errorf("reload: %w", err)
+	}
+
+	old := s.current.Load()
+	if !reflect.DeepEqual(old.Server, cfg.Server) {
+		slog.Warn("config: reload: server settings changed, restart is required to apply them")
+		cfg.Server = old.Server
+	}
+	if !reflect.DeepEqual(old.Router, cfg.Router) {
+		slog.Warn("config: reload: router settings changed, restart is required to apply them")
+		cfg.Router = old.Router
+	}
+
+	s.current.Store(&cfg)
+	now := time.Now()
+	s.loadedAt.Store(&now)
+
+	for _, fn := range s.listeners {
+		fn(old, &cfg)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// tlsVersions - Допустимые значения server.tls.min_version
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLS - Настройки HTTPS. HTTPS включается, если указаны сертификат и ключ или включен acme
+type TLS struct {
+	CertFile   string `json:"cert_file"`   // Путь к сертификату в формате PEM (может содержать цепочку)
+	KeyFile    string `json:"key_file"`    // Путь к закрытому ключу в формате PEM
+	Cert       string `json:"cert"`        // Сертификат в формате PEM вместо cert_file, обычно ссылка на секрет
+	Key        string `json:"key"`         // Закрытый ключ в формате PEM вместо key_file, обычно ссылка на секрет
+	Port       int    `json:"port"`        // Порт HTTPS
+	MinVersion string `json:"min_version"` // Минимальная версия TLS: 1.0, 1.1, 1.2 или 1.3
+
+	// Запуск на server.port обычного HTTP, который перенаправляет все запросы на HTTPS.
+	// Если выключено, при включенном HTTPS обычный HTTP не запускается
+	RedirectHTTP bool `json:"redirect_http"`
+
+	// Экспериментальный HTTP/3 (QUIC) на UDP порту server.tls.port. Требует сборки с -tags http3
+	HTTP3 bool `json:"http3"`
+
+	// Проверка сертификатов клиентов (mTLS): none - не запрашивать, request - проверять, если клиент его прислал,
+	// require - соединение без действительного сертификата отклоняется. Для адресов из server.listeners
+	// это значение по умолчанию, его можно заменить в client_auth адреса
+	ClientAuth   string `json:"client_auth"`
+	ClientCAFile string `json:"client_ca_file"` // Сертификаты удостоверяющих центров клиентов в формате PEM
+
+	ACME ACME `json:"acme"` // Сертификаты от Let's Encrypt вместо cert_file и key_file
+}
+
+// clientAuthTypes - Допустимые значения client_auth
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":        tls.NoClientCert,
+	"none":    tls.NoClientCert,
+	"request": tls.VerifyClientCertIfGiven,
+	"require": tls.RequireAndVerifyClientCert,
+}
+
+// validateClientAuth - Проверка значения client_auth в поле field
+func (t TLS) validateClientAuth(field, value string) error {
+	mode, ok := clientAuthTypes[value]
+	if !ok {
+		return fmt.Errorf("%s: неизвестное значение %q: ожидается none, request или require", field, value)
+	}
+	if mode != tls.NoClientCert && t.ClientCAFile == "" {
+		return fmt.Errorf("%s: для проверки сертификатов клиентов нужен server.tls.client_ca_file", field)
+	}
+	return nil
+}
+
+// Enabled - HTTPS включен
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || t.Cert != "" || t.Key != "" || t.ACME.Enabled
+}
+
+// Certificate - Сертификат и ключ сервера из файлов cert_file, key_file или из значений cert, key
+func (t TLS) Certificate() (tls.Certificate, error) {
+	cert, key := []byte(t.Cert), []byte(t.Key)
+
+	var err error
+	if t.CertFile != "" {
+		if cert, err = os.ReadFile(t.CertFile); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	if t.KeyFile != "" {
+		if key, err = os.ReadFile(t.KeyFile); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+
+	return tls.X509KeyPair(cert, key)
+}
+
+// Addr - Адрес HTTPS сервера в формате host:port
+func (t TLS) Addr(host string) string {
+	return net.JoinHostPort(host, strconv.Itoa(t.Port))
+}
+
+// MinTLSVersion - Минимальная версия TLS в виде константы пакета crypto/tls
+func (t TLS) MinTLSVersion() uint16 {
+	if v, ok := tlsVersions[t.MinVersion]; ok {
+		return v
+	}
+	return tls.VersionTLS12
+}
+
+// validate - Проверка сертификата и версии TLS
+func (t TLS) validate() error {
+	if !t.Enabled() {
+		return nil
+	}
+
+	var errs []error
+
+	switch {
+	case t.ACME.Enabled:
+		if t.CertFile != "" || t.KeyFile != "" || t.Cert != "" || t.Key != "" {
+			errs = append(errs, errors.New("server.tls.acme: сертификат и ключ нельзя указывать вместе с acme"))
+		}
+		errs = append(errs, t.ACME.validate())
+	case (t.CertFile == "" && t.Cert == "") || (t.KeyFile == "" && t.Key == ""):
+		errs = append(errs, errors.New("server.tls.cert_file, key_file: для HTTPS нужно указать и сертификат, и ключ"))
+	case t.CertFile != "" && t.Cert != "":
+		errs = append(errs, errors.New("server.tls.cert: cert_file и cert нельзя указывать одновременно"))
+	case t.KeyFile != "" && t.Key != "":
+		errs = append(errs, errors.New("server.tls.key: key_file и key нельзя указывать одновременно"))
+	}
+
+	if _, ok := tlsVersions[t.MinVersion]; !ok {
+		errs = append(errs, fmt.Errorf("server.tls.min_version: неизвестная версия %q: ожидается 1.0, 1.1, 1.2 или 1.3", t.MinVersion))
+	}
+
+	errs = append(errs, t.validateClientAuth("server.tls.client_auth", t.ClientAuth))
+
+	return errors.Join(errs...)
+}
+
+// validateListener - Проверка настроек адреса HTTPS, когда список server.listeners не задан.
+// httpPort - порт обычного HTTP для проверки конфликта портов
+func (t TLS) validateListener(httpPort int) error {
+	if !t.Enabled() {
+		if t.HTTP3 {
+			return errors.New("server.tls.http3: HTTP/3 работает только вместе с HTTPS")
+		}
+		if clientAuthTypes[t.ClientAuth] != tls.NoClientCert {
+			return errors.New("server.tls.client_auth: сертификаты клиентов проверяются только вместе с HTTPS")
+		}
+		return nil
+	}
+
+	var errs []error
+
+	if t.Port < 1 || t.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.tls.port: некорректный порт %d: ожидается число от 1 до 65535", t.Port))
+	}
+
+	if t.RedirectHTTP && t.Port == httpPort {
+		errs = append(errs, fmt.Errorf("server.tls.port: порт HTTPS %d совпадает с портом HTTP", t.Port))
+	}
+
+	if t.ACME.Enabled && t.ACME.Challenge == "http-01" && !t.RedirectHTTP {
+		errs = append(errs, errors.New("server.tls.acme.challenge: для http-01 нужен обычный HTTP, включите server.tls.redirect_http"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Tracing - Трассировка запросов OpenTelemetry с отправкой по OTLP. Применяется только при запуске,
+// требует сборки с -tags otel
+type Tracing struct {
+	Enabled     bool              `json:"enabled"`
+	Protocol    string            `json:"protocol"`     // Протокол OTLP: http или grpc
+	Endpoint    string            `json:"endpoint"`     // Адрес коллектора: localhost:4318 для http, localhost:4317 для grpc
+	Insecure    bool              `json:"insecure"`     // Без TLS, например для коллектора на том же хосте
+	Headers     map[string]string `json:"headers"`      // Дополнительные заголовки запросов к коллектору, например токен доступа
+	ServiceName string            `json:"service_name"` // Имя сервиса в трассировках
+	SampleRatio float64           `json:"sample_ratio"` // Доля записываемых трассировок от 0 до 1, если вызывающий не решил за нас
+}
+
+func (t Tracing) validate() error {
+	if !t.Enabled {
+		return nil
+	}
+
+	var errs []error
+
+	switch t.Protocol {
+	case "http", "grpc":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.protocol: неизвестное значение %q: ожидается http или grpc", t.Protocol))
+	}
+	if t.Endpoint == "" {
+		errs = append(errs, errors.New("tracing.endpoint: значение не может быть пустым"))
+	}
+	if t.ServiceName == "" {
+		errs = append(errs, errors.New("tracing.service_name: значение не может быть пустым"))
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio: ожидается число от 0 до 1, получено %v", t.SampleRatio))
+	}
+
+	return errors.Join(errs...)
+}
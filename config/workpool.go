@@ -0,0 +1,29 @@
+package config
+
+import (
+	"errors"
+)
+
+// WorkPool - Пул исполнителей для ресурсоемких по CPU обработчиков: хэширования паролей при регистрации
+// и входе auth.password. Состояние - в GET /debug/stats на admin адресе. Применяется только при запуске
+type WorkPool struct {
+	Size      int      `json:"size"`       // Сколько задач выполняется одновременно, 0 - по числу процессоров
+	QueueSize int      `json:"queue_size"` // Сколько задач может ждать исполнителя, запросы сверх этого получают 503
+	MaxWait   Duration `json:"max_wait"`   // Сколько задача ждет исполнителя, после этого запрос получает 503
+}
+
+func (p WorkPool) validate() error {
+	var errs []error
+
+	if p.Size < 0 {
+		errs = append(errs, errors.New("work_pool.size: значение не может быть отрицательным"))
+	}
+	if p.QueueSize < 0 {
+		errs = append(errs, errors.New("work_pool.queue_size: значение не может быть отрицательным"))
+	}
+	if p.MaxWait <= 0 {
+		errs = append(errs, errors.New("work_pool.max_wait: ожидается положительная длительность"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,362 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlToJSON - Преобразует YAML документ в JSON.
+//
+// Поддерживается подмножество YAML, достаточное для файла конфигурации: вложенные словари и списки
+// блочного вида, однострочные списки [a, b], строки в кавычках и без, числа, true/false, null и комментарии.
+// Якоря, многострочные строки (| и >) и несколько документов в одном файле не поддерживаются.
+func yamlToJSON(data []byte) ([]byte, error) {
+	lines, err := yamlLines(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &yamlParser{lines: lines}
+
+	var v any = map[string]any{}
+	if len(lines) > 0 {
+		v, err = p.block(lines[0].indent)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.pos < len(p.lines) {
+			return nil, p.errorf("неожиданный отступ")
+		}
+	}
+
+	return json.Marshal(v)
+}
+
+// yamlLine - Значимая строка YAML документа
+type yamlLine struct {
+	num    int    // Номер строки в файле, для сообщений об ошибках
+	indent int    // Количество пробелов в начале строки
+	text   string // Содержимое строки без отступа и комментария
+}
+
+// yamlLines - Разбивает документ на значимые строки, отбрасывая пустые строки и комментарии
+func yamlLines(doc string) ([]yamlLine, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(doc, "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		text := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(text)
+
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("yaml: строка %d: табуляция в отступе недопустима", i+1)
+		}
+
+		text = strings.TrimSpace(stripYAMLComment(text))
+		// Маркер "---" допустим только в начале файла, перед первым значением
+		if text == "" || text == "---" && len(lines) == 0 {
+			continue
+		}
+
+		if text == "---" || text == "..." || strings.HasPrefix(text, "--- ") {
+			return nil, fmt.Errorf("yaml: строка %d: несколько документов в одном файле не поддерживаются", i+1)
+		}
+
+		lines = append(lines, yamlLine{num: i + 1, indent: indent, text: text})
+	}
+	return lines, nil
+}
+
+// stripYAMLComment - Удаляет комментарий (# после пробела или в начале строки) вне кавычек
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && quoteStart(s, i):
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// quoteStart - Кавычка в позиции i открывает строку, только если она первый символ скаляра:
+// в начале строки, после "- " или ": ", а в однострочном списке после "[" или ",".
+// Апостроф внутри значения без кавычек (name: it's) строку не открывает
+func quoteStart(s string, i int) bool {
+	prev := strings.TrimRight(s[:i], " ")
+	if prev == "" {
+		return true
+	}
+	switch prev[len(prev)-1] {
+	case '[', ',':
+		return true
+	case ':', '-':
+		// Двоеточие и дефис разделяют ключ и значение только с пробелом после них
+		return len(prev) < i
+	}
+	return false
+}
+
+// yamlParser - Рекурсивный разбор строк документа по отступам
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	num := 0
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	} else if len(p.lines) > 0 {
+		num = p.lines[len(p.lines)-1].num
+	}
+	return fmt.Errorf("yaml: строка %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+// block - Разбор словаря или списка, все элементы которого начинаются с отступом indent
+func (p *yamlParser) block(indent int) (any, error) {
+	if isSeqItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+// mapping - Разбор словаря вида "key: value"
+func (p *yamlParser) mapping(indent int) (any, error) {
+	m := map[string]any{}
+
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf("неожиданный отступ")
+		}
+		if isSeqItem(line.text) {
+			return nil, p.errorf("элемент списка внутри словаря")
+		}
+
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, p.errorf("ожидается \"ключ: значение\", получено %q", line.text)
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("повторяющийся ключ %q", key)
+		}
+
+		if rest != "" {
+			v, err := p.scalarOrFlow(rest)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			p.pos++
+			continue
+		}
+		p.pos++
+
+		// Значение записано на следующих строках: вложенный блок с большим отступом
+		// или список на том же уровне отступа, что и ключ
+		switch {
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			v, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSeqItem(p.lines[p.pos].text):
+			v, err := p.sequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		default:
+			m[key] = nil
+		}
+	}
+
+	return m, nil
+}
+
+// sequence - Разбор списка вида "- value"
+func (p *yamlParser) sequence(indent int) (any, error) {
+	list := []any{}
+
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isSeqItem(line.text) {
+			if line.indent > indent {
+				return nil, p.errorf("неожиданный отступ")
+			}
+			break
+		}
+
+		item := strings.TrimLeft(line.text[1:], " ")
+		if item == "" {
+			// Значение элемента записано на следующих строках
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				v, err := p.block(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			} else {
+				list = append(list, nil)
+			}
+			continue
+		}
+
+		if _, _, isKey := splitYAMLKey(item); isKey || isSeqItem(item) {
+			// Элемент "- key: value" начинает вложенный блок, отступ которого совпадает с позицией после "- "
+			p.lines[p.pos] = yamlLine{
+				num:    line.num,
+				indent: indent + len(line.text) - len(item),
+				text:   item,
+			}
+			v, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+
+		v, err := p.scalarOrFlow(item)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		p.pos++
+	}
+
+	return list, nil
+}
+
+// scalarOrFlow - Разбор значения, записанного в одну строку
+func (p *yamlParser) scalarOrFlow(s string) (any, error) {
+	switch {
+	case s == "|" || s == ">" || strings.HasPrefix(s, "|") || strings.HasPrefix(s, ">"):
+		return nil, p.errorf("многострочные строки не поддерживаются")
+	case strings.HasPrefix(s, "&") || strings.HasPrefix(s, "*"):
+		return nil, p.errorf("якоря и ссылки не поддерживаются")
+	case strings.HasPrefix(s, "{"):
+		if s == "{}" {
+			return map[string]any{}, nil
+		}
+		return nil, p.errorf("однострочные словари не поддерживаются")
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, p.errorf("незакрытый список %q", s)
+		}
+		list := []any{}
+		inner := strings.TrimSpace(s[1 : len(s)-1])
+		if inner == "" {
+			return list, nil
+		}
+		for _, part := range splitFlow(inner) {
+			v, err := p.scalar(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	}
+	return p.scalar(s)
+}
+
+// scalar - Разбор скалярного значения: строки, числа, булева значения или null
+func (p *yamlParser) scalar(s string) (any, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, p.errorf("некорректная строка %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, p.errorf("некорректная строка %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && strings.ContainsAny(s[:1], "+-.0123456789") && !strings.ContainsAny(s, "xXpP_") {
+		return f, nil
+	}
+
+	return s, nil
+}
+
+// isSeqItem - Строка является элементом списка
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey - Разделяет строку "key: value" на ключ и значение. Двоеточие внутри кавычек не учитывается
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			key = strings.TrimSpace(text[:i])
+			if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+				key = key[1 : len(key)-1]
+			}
+			return key, strings.TrimSpace(text[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+// splitFlow - Разделяет содержимое однострочного списка по запятым вне кавычек
+func splitFlow(s string) []string {
+	var (
+		parts []string
+		quote byte
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && quoteStart(s, i):
+			quote = c
+		case c == ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestYAMLToJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{
+			name: "комментарии",
+			yaml: "# заголовок\nname: app # имя\n\n  # с отступом\nport: 8080#не комментарий\n",
+			want: `{"name":"app","port":"8080#не комментарий"}`,
+		},
+		{
+			name: "решетка без пробела перед ней",
+			yaml: "url: http://example.com/#frag\n",
+			want: `{"url":"http://example.com/#frag"}`,
+		},
+		{
+			name: "решетка в кавычках",
+			yaml: "a: \"x # y\" # комментарий\nb: 'x # y' # комментарий\n",
+			want: `{"a":"x # y","b":"x # y"}`,
+		},
+		{
+			name: "апостроф в значении без кавычек",
+			yaml: "name: it's # комментарий\nlist:\n  - don't # комментарий\n",
+			want: `{"list":["don't"],"name":"it's"}`,
+		},
+		{
+			name: "кавычка не в начале значения",
+			yaml: "a: say \"hi # there\n",
+			want: `{"a":"say \"hi"}`,
+		},
+		{
+			name: "экранирование в кавычках",
+			yaml: "a: \"tab\\tquote\\\"\"\nb: 'it''s'\nc: \"\"\n",
+			want: `{"a":"tab\tquote\"","b":"it's","c":""}`,
+		},
+		{
+			name: "ключи в кавычках и двоеточие в значении",
+			yaml: "\"a: b\": 1\n'c': time: 10:30\n",
+			want: `{"a: b":1,"c":"time: 10:30"}`,
+		},
+		{
+			name: "скаляры",
+			yaml: "i: -42\nf: 1.5\nt: true\nn: null\ne:\ntilde: ~\nhex: 0x10\nver: 1.2.3\n",
+			want: `{"e":null,"f":1.5,"hex":"0x10","i":-42,"n":null,"t":true,"tilde":null,"ver":"1.2.3"}`,
+		},
+		{
+			name: "вложенные словари",
+			yaml: "server:\n  tls:\n    enabled: true\n    min: \"1.2\"\n  port: 443\nlog:\n  level: info\n",
+			want: `{"log":{"level":"info"},"server":{"port":443,"tls":{"enabled":true,"min":"1.2"}}}`,
+		},
+		{
+			name: "список на уровне ключа и с отступом",
+			yaml: "a:\n- 1\n- 2\nb:\n  - x\n  -\n",
+			want: `{"a":[1,2],"b":["x",null]}`,
+		},
+		{
+			name: "список словарей",
+			yaml: "hosts:\n  - name: a\n    port: 1\n  - name: b # второй\n    tags:\n      - x\n",
+			want: `{"hosts":[{"name":"a","port":1},{"name":"b","tags":["x"]}]}`,
+		},
+		{
+			name: "вложенный список",
+			yaml: "- - 1\n  - 2\n- 3\n",
+			want: `[[1,2],3]`,
+		},
+		{
+			name: "однострочный список",
+			yaml: "a: [1, 'x, y', \"z\", it's] # комментарий\nb: []\nc: {}\n",
+			want: `{"a":[1,"x, y","z","it's"],"b":[],"c":{}}`,
+		},
+		{
+			name: "пустой документ",
+			yaml: "---\n# только комментарий\n",
+			want: `{}`,
+		},
+		{
+			name: "CRLF",
+			yaml: "a: 1\r\nb: x\r\n",
+			want: `{"a":1,"b":"x"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := yamlToJSON([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("ошибка: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("получено %s, ожидается %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLToJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string // Часть текста ошибки
+	}{
+		{name: "табуляция", yaml: "a:\n\tb: 1\n", want: "строка 2: табуляция"},
+		{name: "повторяющийся ключ", yaml: "a: 1\nb: 2\na: 3\n", want: "строка 3: повторяющийся ключ"},
+		{name: "неожиданный отступ", yaml: "a: 1\n  b: 2\n", want: "строка 2: неожиданный отступ"},
+		{name: "элемент списка в словаре", yaml: "a:\n  b: 1\n  - 2\n", want: "элемент списка внутри словаря"},
+		{name: "строка без ключа", yaml: "a: 1\njust text\n", want: "ожидается"},
+		{name: "однострочный словарь", yaml: "a: {b: 1}\n", want: "однострочные словари"},
+		{name: "незакрытый список", yaml: "a: [1, 2\n", want: "незакрытый список"},
+		{name: "незакрытая строка", yaml: "a: 'x\n", want: "некорректная строка"},
+		{name: "многострочная строка", yaml: "a: |\n  text\n", want: "многострочные строки"},
+		{name: "якорь", yaml: "a: &x 1\n", want: "якоря"},
+		{name: "несколько документов", yaml: "a: 1\n--- \nb: 2\n", want: "несколько документов"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := yamlToJSON([]byte(tt.yaml))
+			if err == nil {
+				t.Fatalf("ошибки нет, результат %s", got)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ошибка %q не содержит %q", err, tt.want)
+			}
+		})
+	}
+}
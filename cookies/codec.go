@@ -0,0 +1,131 @@
+package cookies
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalid - Cookie подделана, повреждена или подписана другим ключом
+var ErrInvalid = errors.New("cookie подделана или подписана другим ключом")
+
+// Codec - Подпись и шифрование значений cookie секретом сервера.
+//
+// Подпись и шифр зависят от имени cookie, поэтому значение одной cookie нельзя подставить в другую
+type Codec struct {
+	signKey []byte
+	aead    cipher.AEAD
+}
+
+// NewCodec - Codec с ключами подписи и шифрования, полученными из secret. Секрет должен быть не короче 32 байт
+func NewCodec(secret []byte) (*Codec, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("cookies: секрет должен быть не короче 32 байт")
+	}
+
+	// Отдельные ключи для подписи и шифрования, чтобы один не раскрывал другой
+	block, err := aes.NewCipher(derive(secret, "encrypt"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Codec{signKey: derive(secret, "sign"), aead: aead}, nil
+}
+
+// derive - Ключ для назначения purpose из секрета secret
+func derive(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("cookies " + purpose))
+	return mac.Sum(nil)
+}
+
+// Sign - Значение value cookie name с подписью HMAC-SHA256: клиент может прочитать value, но не изменить его
+func (c *Codec) Sign(name, value string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(c.mac(name, payload))
+}
+
+// Verify - Исходное значение подписанной cookie name. ErrInvalid, если подпись не сходится
+func (c *Codec) Verify(name, signed string) (string, error) {
+	payload, sig, ok := strings.Cut(signed, ".")
+	if !ok {
+		return "", ErrInvalid
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, c.mac(name, payload)) {
+		return "", ErrInvalid
+	}
+
+	value, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	return string(value), nil
+}
+
+// mac - Подпись значения payload cookie name
+func (c *Codec) mac(name, payload string) []byte {
+	mac := hmac.New(sha256.New, c.signKey)
+	mac.Write([]byte(name + "=" + payload))
+	return mac.Sum(nil)
+}
+
+// Encrypt - Значение value cookie name, зашифрованное AES-256-GCM: клиент не может ни прочитать, ни изменить его
+func (c *Codec) Encrypt(name, value string) string {
+	nonce := make([]byte, c.aead.NonceSize())
+	_, _ = rand.Read(nonce)
+	return base64.RawURLEncoding.EncodeToString(c.aead.Seal(nonce, nonce, []byte(value), []byte(name)))
+}
+
+// Decrypt - Исходное значение зашифрованной cookie name. ErrInvalid, если cookie подделана или зашифрована другим ключом
+func (c *Codec) Decrypt(name, sealed string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return "", ErrInvalid
+	}
+
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", ErrInvalid
+	}
+	return string(plain), nil
+}
+
+// SetSigned - Установка подписанной cookie, см. Sign и Set
+func (c *Codec) SetSigned(w http.ResponseWriter, name, value string, o Options) error {
+	return Set(w, name, c.Sign(name, value), o)
+}
+
+// GetSigned - Значение подписанной cookie из запроса. http.ErrNoCookie, если ее нет, ErrInvalid, если подпись не сходится
+func (c *Codec) GetSigned(r *http.Request, name string) (string, error) {
+	signed, err := Get(r, name)
+	if err != nil {
+		return "", err
+	}
+	return c.Verify(name, signed)
+}
+
+// SetEncrypted - Установка зашифрованной cookie, см. Encrypt и Set
+func (c *Codec) SetEncrypted(w http.ResponseWriter, name, value string, o Options) error {
+	return Set(w, name, c.Encrypt(name, value), o)
+}
+
+// GetEncrypted - Значение зашифрованной cookie из запроса. http.ErrNoCookie, если ее нет, ErrInvalid, если она подделана
+func (c *Codec) GetEncrypted(r *http.Request, name string) (string, error) {
+	sealed, err := Get(r, name)
+	if err != nil {
+		return "", err
+	}
+	return c.Decrypt(name, sealed)
+}
@@ -0,0 +1,90 @@
+// Package cookies - Установка и чтение cookie с безопасными настройками по умолчанию.
+//
+// Нулевое значение Options дает cookie, которая передается только по HTTPS, недоступна JavaScript
+// и не отправляется в запросах с других сайтов, кроме переходов по ссылке (SameSite=Lax): ослабить защиту можно
+// только явно. Codec добавляет подпись (клиент видит значение, но не может его изменить) и шифрование
+package cookies
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MaxSize - Наибольший размер cookie вместе с именем и атрибутами, который принимают все браузеры
+const MaxSize = 4096
+
+// ErrTooLarge - Cookie больше MaxSize: браузер ее отбросит
+var ErrTooLarge = errors.New("cookie больше 4096 байт")
+
+// Options - Атрибуты cookie. Нулевое значение - безопасные настройки, см. описание пакета
+type Options struct {
+	Path     string        // Путь, по умолчанию /
+	Domain   string        // Домен, пустая строка - только текущий хост, без поддоменов
+	MaxAge   time.Duration // Срок хранения, 0 - до закрытия браузера
+	SameSite http.SameSite // По умолчанию SameSite=Lax
+
+	Insecure     bool // Отправлять и по HTTP (без атрибута Secure), например при разработке без TLS
+	ScriptAccess bool // Доступна JavaScript (без атрибута HttpOnly)
+}
+
+// cookie - Cookie name со значением value и атрибутами o
+func (o Options) cookie(name, value string) *http.Cookie {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     o.Path,
+		Domain:   o.Domain,
+		Secure:   !o.Insecure,
+		HttpOnly: !o.ScriptAccess,
+		SameSite: o.SameSite,
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if c.SameSite == 0 || c.SameSite == http.SameSiteDefaultMode {
+		c.SameSite = http.SameSiteLaxMode
+	}
+	if o.MaxAge > 0 {
+		c.MaxAge = int(o.MaxAge.Seconds())
+		c.Expires = time.Now().Add(o.MaxAge)
+	}
+	return c
+}
+
+// Set - Установка cookie name со значением value. Ошибка, если имя или значение недопустимы или cookie больше MaxSize:
+// http.SetCookie в таком случае молча не отправляет cookie
+func Set(w http.ResponseWriter, name, value string, o Options) error {
+	c := o.cookie(name, value)
+	if err := c.Valid(); err != nil {
+		return fmt.Errorf("cookie %s: %w", name, err)
+	}
+	if len(c.String()) > MaxSize {
+		return fmt.Errorf("cookie %s: %w", name, ErrTooLarge)
+	}
+	if c.SameSite == http.SameSiteNoneMode && !c.Secure {
+		// Браузеры отбрасывают SameSite=None без Secure
+		return fmt.Errorf("cookie %s: SameSite=None требует Secure", name)
+	}
+
+	http.SetCookie(w, c)
+	return nil
+}
+
+// Get - Значение cookie name из запроса. http.ErrNoCookie, если ее нет
+func Get(r *http.Request, name string) (string, error) {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return c.Value, nil
+}
+
+// Delete - Удаление cookie name у клиента. Path и Domain в o должны совпадать с теми, с которыми cookie установлена
+func Delete(w http.ResponseWriter, name string, o Options) {
+	c := o.cookie(name, "")
+	c.MaxAge = -1
+	c.Expires = time.Unix(0, 0)
+	http.SetCookie(w, c)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/derv-dice/go-web-server/apperr"
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/middleware"
+	"github.com/derv-dice/go-web-server/realip"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// adminOnly - Middleware, пропускающий только запросы с локального адреса (127.0.0.1, ::1).
+// Остальные клиенты получают 403. Ответы не сохраняются в кэше ответов: иначе кэш отдал бы их и с других адресов
+func adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Адрес клиента, а не прокси: запрос снаружи через nginx на этом же хосте пришел бы с 127.0.0.1
+		if ip := net.ParseIP(realip.From(r)); ip == nil || !ip.IsLoopback() {
+			response.Error(w, http.StatusForbidden, "доступ разрешен только с локального адреса")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routesHandler - Обработчик метода GET /debug/routes: список всех зарегистрированных маршрутов mux
+func routesHandler(mux *router.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.Respond(w, r, http.StatusOK, response.Body{Data: mux.Routes()})
+	}
+}
+
+// echo - Запрос клиента в ответе /debug/echo
+type echo struct {
+	Method     string              `json:"method"`
+	URL        string              `json:"url"`
+	Proto      string              `json:"proto"`
+	Host       string              `json:"host"`
+	RemoteAddr string              `json:"remote_addr"`
+	Headers    map[string][]string `json:"headers"`
+	Query      map[string][]string `json:"query"`
+	Body       string              `json:"body"`
+	BodyBase64 bool                `json:"body_base64,omitempty"` // Тело не в UTF-8 и передано в base64
+}
+
+// echoHandler - Обработчик /debug/echo для любого метода: метод, заголовки, параметры и тело запроса
+// возвращаются клиенту. Помогает проверить, что доходит до сервера через прокси и балансировщики.
+// Значения заголовков и параметров query с учетными данными скрываются по настройкам log.bodies
+// (redact_headers и redact_fields), как в логе тел запросов. Размер тела ограничен request.max_body_bytes
+func echoHandler(store *config.Store) response.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return apperr.Wrap(err, apperr.BadRequest, "не удалось прочитать тело запроса")
+		}
+
+		shown := middleware.RedactRequest(store.Current().Log.Bodies, r)
+		e := echo{
+			Method:     r.Method,
+			URL:        shown.URL.String(),
+			Proto:      r.Proto,
+			Host:       r.Host,
+			RemoteAddr: r.RemoteAddr,
+			Headers:    shown.Header,
+			Query:      shown.URL.Query(),
+			Body:       string(body),
+		}
+		if !utf8.Valid(body) {
+			e.Body, e.BodyBase64 = base64.StdEncoding.EncodeToString(body), true
+		}
+
+		response.Respond(w, r, http.StatusOK, response.Body{Data: e})
+		return nil
+	}
+}
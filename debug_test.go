@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/response"
+)
+
+func TestEchoRedactsCredentials(t *testing.T) {
+	h := response.Handle(echoHandler(config.NewStore(config.Default(), nil)))
+
+	r := httptest.NewRequest(http.MethodPost, "/debug/echo?api_key=k1&page=2", strings.NewReader("hello"))
+	r.Header.Set("Authorization", "Bearer secret-token")
+	r.Header.Set("Cookie", "session=abc")
+	r.Header.Set("X-Api-Key", "k2")
+	r.Header.Set("X-Trace", "t1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if strings.Contains(w.Body.String(), "secret-token") || strings.Contains(w.Body.String(), "abc") ||
+		strings.Contains(w.Body.String(), "k1") || strings.Contains(w.Body.String(), "k2") {
+		t.Fatalf("в ответе учетные данные: %s", w.Body.String())
+	}
+
+	var resp struct {
+		Data echo `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, w.Body.String())
+	}
+	e := resp.Data
+	for _, k := range []string{"Authorization", "Cookie", "X-Api-Key"} {
+		if got := strings.Join(e.Headers[k], ","); got != "[REDACTED]" {
+			t.Fatalf("заголовок %s: %q, ожидается [REDACTED]", k, got)
+		}
+	}
+	if got := e.Headers["X-Trace"]; len(got) != 1 || got[0] != "t1" {
+		t.Fatalf("заголовок X-Trace: %q", got)
+	}
+	if e.URL != "/debug/echo?api_key=[REDACTED]&page=2" || url.Values(e.Query).Get("page") != "2" || url.Values(e.Query).Get("api_key") != "[REDACTED]" {
+		t.Fatalf("url %q, query %v", e.URL, e.Query)
+	}
+	if e.Body != "hello" {
+		t.Fatalf("тело %q", e.Body)
+	}
+
+	// Исходный запрос не изменяется: его заголовки дальше видят middleware после обработчика
+	if r.Header.Get("Authorization") != "Bearer secret-token" || r.URL.RawQuery != "api_key=k1&page=2" {
+		t.Fatalf("исходный запрос изменен: %v %q", r.Header, r.URL.RawQuery)
+	}
+}
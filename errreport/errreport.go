@@ -0,0 +1,60 @@
+// Package errreport - Отправка паник и внутренних ошибок обработчиков во внешний сервис (например, Sentry).
+//
+// Recovery и response.Handle передают ошибки в Reporter по умолчанию, который задается через SetDefault.
+// Пока он не задан, ошибки никуда не отправляются. Поддержка Sentry собирается только с -tags sentry
+package errreport
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Event - Ошибка для отправки вместе с данными запроса, при обработке которого она произошла
+type Event struct {
+	Err       error         // Ошибка или значение паники, приведенное к error
+	Panic     bool          // Ошибка - перехваченная паника
+	Stack     []byte        // Стек вызовов в момент паники, для ошибок обработчика пуст
+	RequestID string        // Идентификатор запроса
+	Request   *http.Request // Запрос, при обработке которого произошла ошибка
+	Time      time.Time
+}
+
+// Reporter - Получатель ошибок. Report не должен задерживать ответ клиенту: отправка выполняется в фоне
+type Reporter interface {
+	Report(ctx context.Context, e Event)
+}
+
+// Nop - Reporter, который ничего не отправляет. Используется по умолчанию
+type Nop struct{}
+
+// Report - Ничего не делает
+func (Nop) Report(context.Context, Event) {}
+
+// current - Reporter по умолчанию
+var current atomic.Pointer[Reporter]
+
+// SetDefault - Замена Reporter по умолчанию, nil - возврат к Nop
+func SetDefault(r Reporter) {
+	if r == nil {
+		r = Nop{}
+	}
+	current.Store(&r)
+}
+
+// Default - Reporter по умолчанию
+func Default() Reporter {
+	if r := current.Load(); r != nil {
+		return *r
+	}
+	return Nop{}
+}
+
+// Report - Отправка e через Reporter по умолчанию. Пустое время заменяется текущим
+func Report(ctx context.Context, e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	Default().Report(ctx, e)
+}
@@ -0,0 +1,64 @@
+//go:build sentry
+
+package errreport
+
+import (
+	"context"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// Setup - Reporter по настройкам cfg и функция, ожидающая отправки накопленных ошибок не дольше заданного времени.
+// release - версия сервера в отчетах
+func Setup(cfg config.ErrorReport, release string) (Reporter, func(time.Duration), error) {
+	if cfg.Provider != "sentry" {
+		return Nop{}, func(time.Duration) {}, nil
+	}
+
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: cfg.Environment,
+		Release:     release,
+		SampleRate:  cfg.SampleRate,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hub := sentry.NewHub(client, sentry.NewScope())
+	return sentryReporter{hub: hub}, func(timeout time.Duration) { client.Flush(timeout) }, nil
+}
+
+// sentryReporter - Отправка ошибок в Sentry. Заголовки Authorization и Cookie в отчеты не попадают
+type sentryReporter struct {
+	hub *sentry.Hub
+}
+
+// Report - Отправка e с данными запроса, идентификатором запроса в теге request_id и стеком паники в контексте panic
+func (s sentryReporter) Report(ctx context.Context, e Event) {
+	hub := s.hub.Clone()
+	hub.WithScope(func(scope *sentry.Scope) {
+		if e.Request != nil {
+			scope.SetRequest(e.Request)
+			scope.SetTag("method", e.Request.Method)
+			if e.Request.Pattern != "" {
+				scope.SetTag("route", e.Request.Pattern)
+			}
+		}
+		if e.RequestID != "" {
+			scope.SetTag("request_id", e.RequestID)
+		}
+
+		event := hub.Client().EventFromException(e.Err, sentry.LevelError)
+		event.Timestamp = e.Time
+		if e.Panic {
+			event.Level = sentry.LevelFatal
+			scope.SetTag("panic", "true")
+			scope.SetContext("panic", sentry.Context{"stack": string(e.Stack)})
+		}
+		hub.CaptureEvent(event)
+	})
+}
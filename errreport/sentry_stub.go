@@ -0,0 +1,18 @@
+//go:build !sentry
+
+package errreport
+
+import (
+	"errors"
+	"time"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// Setup - Reporter по настройкам cfg. В сборке без Sentry провайдер sentry приводит к ошибке запуска
+func Setup(cfg config.ErrorReport, _ string) (Reporter, func(time.Duration), error) {
+	if cfg.Provider == "sentry" {
+		return nil, nil, errors.New("error_reporting: сервер собран без поддержки Sentry, пересоберите с -tags sentry")
+	}
+	return Nop{}, func(time.Duration) {}, nil
+}
@@ -0,0 +1,58 @@
+package files
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// Download - Обработчик скачивания файла по идентификатору из параметра маршрута {id}.
+//
+// Файл отдается с типом содержимого, определенным при загрузке, и заголовком Content-Disposition с исходным
+// именем файла. Запросы диапазонов (Range, If-Range) и условные запросы обрабатывает http.ServeContent,
+// поэтому прерванное скачивание можно продолжить с того же места. ETag - хэш SHA-256 содержимого
+func Download(storage Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, info, err := storage.Open(r.Context(), router.Param(r, "id"))
+		if errors.Is(err, ErrNotFound) {
+			response.Error(w, http.StatusNotFound, "файл не найден")
+			return
+		}
+		if err != nil {
+			logging.From(r.Context()).Error("files: open", "file", router.Param(r, "id"), "error", err)
+			response.Error(w, http.StatusInternalServerError, "не удалось открыть файл")
+			return
+		}
+		defer f.Close()
+
+		h := w.Header()
+		h.Set("Content-Type", info.ContentType)
+		h.Set("Content-Disposition", disposition(r, info.Name))
+		h.Set("ETag", `"`+info.SHA256+`"`)
+		// Браузер не должен угадывать тип содержимого: загруженный пользователем HTML не откроется как страница
+		h.Set("X-Content-Type-Options", "nosniff")
+
+		http.ServeContent(w, r, info.Name, info.CreatedAt, f)
+	}
+}
+
+// disposition - Заголовок Content-Disposition. По умолчанию файл сохраняется браузером (attachment),
+// с параметром ?inline=1 - открывается в браузере, если тип это позволяет.
+// Имя с не-ASCII символами кодируется по RFC 2231, в параметре filename*
+func disposition(r *http.Request, name string) string {
+	kind := "attachment"
+	if r.URL.Query().Get("inline") == "1" {
+		kind = "inline"
+	}
+
+	v := mime.FormatMediaType(kind, map[string]string{"filename": name})
+	if v == "" {
+		// Имя не удалось закодировать, файл отдается без него
+		return kind
+	}
+	return v
+}
@@ -0,0 +1,181 @@
+// Package files - Загрузка файлов на сервер и их хранение
+package files
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNotFound - Файла с таким идентификатором нет в хранилище
+var ErrNotFound = errors.New("файл не найден")
+
+// Info - Описание сохраненного файла
+type Info struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`         // Имя файла, переданное клиентом
+	ContentType string    `json:"content_type"` // Тип содержимого, определенный по началу файла или расширению
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Storage - Хранилище загруженных файлов.
+//
+// Save читает содержимое из r до конца и возвращает описание сохраненного файла. Если чтение r завершилось
+// ошибкой, файл не сохраняется, а ошибка возвращается как есть. Open и Delete возвращают ErrNotFound,
+// если файла с идентификатором id нет
+type Storage interface {
+	Save(ctx context.Context, name, contentType string, r io.Reader) (Info, error)
+	Open(ctx context.Context, id string) (io.ReadSeekCloser, Info, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// DirStorage - Хранилище файлов в каталоге на диске. Содержимое файла хранится в <dir>/<id>,
+// описание - в <dir>/<id>.json
+type DirStorage struct {
+	dir string
+}
+
+// NewDirStorage - Хранилище в каталоге dir. Каталог создается, если его нет
+func NewDirStorage(dir string) (*DirStorage, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &DirStorage{dir: dir}, nil
+}
+
+// Save - Сохранение файла. Содержимое пишется во временный файл и переименовывается только после
+// успешной записи, поэтому прерванная загрузка не оставляет в хранилище неполных файлов
+func (s *DirStorage) Save(ctx context.Context, name, contentType string, r io.Reader) (Info, error) {
+	id, err := newID()
+	if err != nil {
+		return Info{}, err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.Remove(tmp.Name()) // После переименования ничего не удаляет
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{
+		ID:          id,
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	meta, err := json.Marshal(info)
+	if err != nil {
+		return Info{}, err
+	}
+	if err = os.WriteFile(s.path(id)+".json", meta, 0o640); err != nil {
+		return Info{}, err
+	}
+	if err = os.Rename(tmp.Name(), s.path(id)); err != nil {
+		os.Remove(s.path(id) + ".json")
+		return Info{}, err
+	}
+
+	return info, nil
+}
+
+// Open - Открытие файла id для чтения
+func (s *DirStorage) Open(ctx context.Context, id string) (io.ReadSeekCloser, Info, error) {
+	if !validID(id) {
+		return nil, Info{}, ErrNotFound
+	}
+
+	info, err := s.info(id)
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	f, err := os.Open(s.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Info{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+	return f, info, nil
+}
+
+// Delete - Удаление файла id вместе с описанием
+func (s *DirStorage) Delete(ctx context.Context, id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+
+	err := os.Remove(s.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(s.path(id) + ".json")
+}
+
+// info - Чтение описания файла id
+func (s *DirStorage) info(id string) (Info, error) {
+	data, err := os.ReadFile(s.path(id) + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return Info{}, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, err
+	}
+
+	var info Info
+	if err = json.Unmarshal(data, &info); err != nil {
+		return Info{}, fmt.Errorf("описание файла %s: %w", id, err)
+	}
+	return info, nil
+}
+
+func (s *DirStorage) path(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+// newID - Случайный идентификатор файла: 16 байт в шестнадцатеричном виде
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validID - Идентификатор имеет формат newID. Проверка не дает выйти за пределы каталога через id вида ../x
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,157 @@
+package files
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/middleware"
+	"github.com/derv-dice/go-web-server/response"
+)
+
+// errFileTooLarge - Файл больше допустимого размера
+var errFileTooLarge = errors.New("файл слишком большой")
+
+// Upload - Обработчик загрузки файлов в формате multipart/form-data.
+//
+// Файлы из всех полей формы сохраняются в storage по мере чтения запроса, не накапливаясь в памяти.
+// Обычные поля формы без имени файла пропускаются. Файл больше maxFileBytes или больше maxFiles файлов
+// в запросе - 413, при этом уже сохраненные файлы этого запроса удаляются. В ответ отправляется 201
+// со списком описаний сохраненных файлов. uploaded, если не nil, вызывается с этим списком перед ответом,
+// например чтобы поставить задачи обработки файлов
+func Upload(storage Storage, maxFileBytes int64, maxFiles int, uploaded func(ctx context.Context, saved []Info)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "ожидается тело запроса в формате multipart/form-data")
+			return
+		}
+
+		var saved []Info
+		ok := false
+		defer func() {
+			if ok {
+				return
+			}
+			// Запрос не обработан целиком - файлы, сохраненные до ошибки, не нужны клиенту
+			for _, info := range saved {
+				if err := storage.Delete(r.Context(), info.ID); err != nil {
+					logging.From(r.Context()).Error("files: delete", "file", info.ID, "error", err)
+				}
+			}
+		}()
+
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if !middleware.BodyError(w, err) {
+					response.Error(w, http.StatusBadRequest, "некорректное тело запроса multipart/form-data")
+				}
+				return
+			}
+
+			name := cleanName(part.FileName())
+			if name == "" {
+				part.Close()
+				continue
+			}
+
+			if len(saved) == maxFiles {
+				response.Error(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("в запросе больше %d файлов", maxFiles))
+				return
+			}
+
+			body := bufio.NewReader(&limitReader{r: part, n: maxFileBytes})
+			info, err := storage.Save(r.Context(), name, contentType(name, body), body)
+			part.Close()
+			switch {
+			case errors.Is(err, errFileTooLarge):
+				response.Error(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("файл %q больше допустимых %d байт", name, maxFileBytes))
+				return
+			case middleware.BodyError(w, err):
+				return
+			case err != nil:
+				logging.From(r.Context()).Error("files: save", "name", name, "error", err)
+				response.Error(w, http.StatusInternalServerError, "не удалось сохранить файл")
+				return
+			}
+
+			saved = append(saved, info)
+		}
+
+		if len(saved) == 0 {
+			response.Error(w, http.StatusBadRequest, "в запросе нет файлов")
+			return
+		}
+
+		ok = true
+		if uploaded != nil {
+			uploaded(r.Context(), saved)
+		}
+		response.JSON(w, http.StatusCreated, response.Body{Data: saved})
+	}
+}
+
+// contentType - Тип содержимого файла по его началу. Тип, переданный клиентом, не используется: ему нельзя доверять.
+// Если по содержимому тип не определяется, он берется по расширению имени файла
+func contentType(name string, body *bufio.Reader) string {
+	head, _ := body.Peek(512) // Ошибка чтения вернется при сохранении файла
+	ct := http.DetectContentType(head)
+	if ct == "application/octet-stream" || strings.HasPrefix(ct, "text/plain") {
+		if byExt := mime.TypeByExtension(path.Ext(name)); byExt != "" {
+			return byExt
+		}
+	}
+	return ct
+}
+
+// cleanName - Имя файла без пути и управляющих символов. Пустая строка, если имени нет
+func cleanName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+
+	// Клиенты на Windows передают полный путь с обратными слешами
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// limitReader - io.Reader, возвращающий errFileTooLarge, если содержимое больше n байт
+type limitReader struct {
+	r io.Reader
+	n int64 // Сколько байт еще можно прочитать
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errFileTooLarge
+	}
+
+	// Читается на байт больше лимита, чтобы отличить файл ровно в n байт от файла больше n
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errFileTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/health"
+	"github.com/derv-dice/go-web-server/response"
+)
+
+// probes - Состояние сервера для проверок Kubernetes (liveness и readiness)
+type probes struct {
+	ready  atomic.Bool      // Запуск завершен и сервер еще не останавливается
+	checks *health.Registry // Проверки зависимостей по имени
+}
+
+// readiness - Состояние сервера, общее для всех маршрутизаторов
+var readiness = &probes{checks: health.NewRegistry()}
+
+// livenessHandler - Обработчик метода GET /healthz: процесс жив и обрабатывает запросы
+func livenessHandler(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, response.Body{Data: map[string]string{"status": "ok"}})
+}
+
+// readinessHandler - Обработчик метода GET /readyz: сервер готов принимать трафик.
+// Пока запуск не завершен, после начала остановки или при недоступной зависимости возвращается 503.
+// В ответе - статус и время выполнения каждой проверки, ожидание каждой ограничено health.timeout
+func (p *probes) readinessHandler(store *config.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !p.ready.Load() {
+			response.JSON(w, http.StatusServiceUnavailable, response.Body{
+				Data:  map[string]string{"status": "not ready"},
+				Error: "сервер запускается или останавливается",
+			})
+			return
+		}
+
+		report := p.checks.Check(r.Context(), store.Current().Health.Timeout.D())
+
+		data := map[string]any{"status": "ready", "checks": report.Checks}
+		if !report.OK() {
+			data["status"] = "not ready"
+			response.JSON(w, http.StatusServiceUnavailable, response.Body{Data: data, Error: "зависимость недоступна"})
+			return
+		}
+		response.JSON(w, http.StatusOK, response.Body{Data: data})
+	}
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Pinger - Зависимость с проверкой доступности, например *sql.DB
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Ping - Проверка базы данных или другой зависимости с методом PingContext
+func Ping(p Pinger) Checker {
+	return CheckerFunc(p.PingContext)
+}
+
+// TCP - Проверка, что по адресу addr (host:port) принимаются TCP соединения
+func TCP(addr string) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// HTTP - Проверка, что GET url отвечает статус кодом меньше 500. Перенаправления не выполняются
+func HTTP(url string) Checker {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	return CheckerFunc(func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("статус ответа %d", resp.StatusCode)
+		}
+		return nil
+	})
+}
+
+// DiskSpace - Проверка, что на файловой системе с каталогом path свободно не меньше minFree байт
+func DiskSpace(path string, minFree uint64) Checker {
+	return CheckerFunc(func(context.Context) error {
+		free, err := freeSpace(path)
+		if err != nil {
+			return err
+		}
+		if free < minFree {
+			return fmt.Errorf("%s: свободно %d байт, нужно не меньше %d", path, free, minFree)
+		}
+		return nil
+	})
+}
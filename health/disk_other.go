@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+
+package health
+
+import "errors"
+
+// freeSpace - Заглушка для систем без statfs: проверка места на диске всегда не проходит
+func freeSpace(string) (uint64, error) {
+	return 0, errors.New("health: проверка места на диске не поддерживается на этой системе")
+}
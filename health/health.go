@@ -0,0 +1,128 @@
+// Package health - Проверки зависимостей сервера (база данных, upstream, место на диске) для /readyz.
+//
+// Проверки регистрируются в Registry по имени и выполняются параллельно, результат каждой - статус,
+// текст ошибки и время выполнения
+package health
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Статусы проверок и сервера в целом
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker - Проверка зависимости. Check возвращает ошибку, если зависимость недоступна, и должен завершаться
+// по отмене ctx
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc - Функция, реализующая Checker
+type CheckerFunc func(ctx context.Context) error
+
+// Check - Вызов f(ctx)
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result - Результат одной проверки
+type Result struct {
+	Status    string  `json:"status"`          // ok или fail
+	Error     string  `json:"error,omitempty"` // Причина, если зависимость недоступна
+	LatencyMS float64 `json:"latency_ms"`      // Время выполнения проверки в миллисекундах
+}
+
+// Report - Результаты всех проверок по имени. Status - fail, если не прошла хотя бы одна проверка
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// OK - Все проверки прошли
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Registry - Набор проверок зависимостей. Безопасен для использования из нескольких горутин
+type Registry struct {
+	mu     sync.Mutex
+	checks map[string]Checker
+}
+
+// NewRegistry - Создание пустого набора проверок
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]Checker)}
+}
+
+// Register - Регистрация проверки name. Повторная регистрация заменяет проверку: маршрутизаторы виртуальных хостов
+// могут регистрировать одну и ту же зависимость
+func (reg *Registry) Register(name string, c Checker) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.checks[name] = c
+}
+
+// Check - Параллельное выполнение всех проверок, каждой дается не больше timeout (0 - без ограничения, кроме ctx).
+// Время ответа не складывается из таймаутов проверок
+func (reg *Registry) Check(ctx context.Context, timeout time.Duration) Report {
+	reg.mu.Lock()
+	checks := maps.Clone(reg.checks)
+	reg.mu.Unlock()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		report = Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	)
+	for name, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			res := run(ctx, c, timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = res
+			if res.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+// run - Выполнение проверки c с ограничением timeout. Проверка, не завершившаяся по отмене контекста,
+// считается непрошедшей, ее горутина завершится сама
+func run(ctx context.Context, c Checker, timeout time.Duration) Result {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{Status: StatusOK, LatencyMS: float64(time.Since(start)) / float64(time.Millisecond)}
+	if err != nil {
+		res.Status, res.Error = StatusFail, err.Error()
+	}
+	return res
+}
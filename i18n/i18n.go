@@ -0,0 +1,155 @@
+// Package i18n - Переводы сообщений сервера и выбор языка клиента по Accept-Language
+package i18n
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLang - Язык, на котором отвечает сервер, если клиент не указал поддерживаемый язык
+const DefaultLang = "en"
+
+// catalog - Зарегистрированные языки: код языка - ключ сообщения - шаблон fmt
+var catalog = struct {
+	sync.RWMutex
+	langs map[string]map[string]string
+}{langs: make(map[string]map[string]string)}
+
+// Register - Регистрация сообщений языка lang (код BCP 47, например ru или pt-BR).
+// Повторная регистрация того же языка дополняет и перекрывает сообщения
+func Register(lang string, messages map[string]string) {
+	lang = normalize(lang)
+
+	catalog.Lock()
+	defer catalog.Unlock()
+
+	m, ok := catalog.langs[lang]
+	if !ok {
+		m = make(map[string]string, len(messages))
+		catalog.langs[lang] = m
+	}
+	for k, v := range messages {
+		m[k] = v
+	}
+}
+
+// Languages - Коды зарегистрированных языков по алфавиту
+func Languages() []string {
+	catalog.RLock()
+	defer catalog.RUnlock()
+
+	langs := make([]string, 0, len(catalog.langs))
+	for lang := range catalog.langs {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// T - Сообщение key на языке lang с подстановкой args. Если в языке нет сообщения, используется DefaultLang,
+// если нет и там - сам key
+func T(lang, key string, args ...any) string {
+	catalog.RLock()
+	tmpl, ok := catalog.langs[normalize(lang)][key]
+	if !ok {
+		tmpl, ok = catalog.langs[DefaultLang][key]
+	}
+	catalog.RUnlock()
+
+	if !ok {
+		tmpl = key
+	}
+	if len(args) == 0 {
+		return tmpl
+	}
+	return fmt.Sprintf(tmpl, args...)
+}
+
+// FromRequest - Язык ответа на запрос r: параметр ?lang=, если такой язык зарегистрирован,
+// иначе наиболее предпочтительный из заголовка Accept-Language, иначе DefaultLang
+func FromRequest(r *http.Request) string {
+	if lang, ok := Match(r.URL.Query().Get("lang")); ok {
+		return lang
+	}
+	if lang, ok := Negotiate(r.Header.Get("Accept-Language")); ok {
+		return lang
+	}
+	return DefaultLang
+}
+
+// Negotiate - Наиболее предпочтительный для клиента зарегистрированный язык из значения заголовка
+// Accept-Language, например "ru-RU,ru;q=0.9,en;q=0.8"
+func Negotiate(header string) (string, bool) {
+	type pref struct {
+		tag string
+		q   float64
+	}
+
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				continue
+			}
+			q = parsed
+		}
+		if q == 0 {
+			continue // q=0 - клиент явно не принимает этот язык
+		}
+		prefs = append(prefs, pref{tag, q})
+	}
+
+	// Языки с одинаковым весом сохраняют порядок из заголовка
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	for _, p := range prefs {
+		if lang, ok := Match(p.tag); ok {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+// Match - Зарегистрированный язык для кода tag: сам код или его основной язык (ru для ru-RU)
+func Match(tag string) (string, bool) {
+	tag = normalize(tag)
+	if tag == "" {
+		return "", false
+	}
+
+	catalog.RLock()
+	defer catalog.RUnlock()
+
+	if _, ok := catalog.langs[tag]; ok {
+		return tag, true
+	}
+	if base, _, ok := strings.Cut(tag, "-"); ok {
+		if _, ok := catalog.langs[base]; ok {
+			return base, true
+		}
+	}
+	return "", false
+}
+
+// normalize - Код языка в единой записи: ru, pt-BR
+func normalize(tag string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	base, region, ok := strings.Cut(tag, "-")
+	if !ok {
+		return strings.ToLower(base)
+	}
+	return strings.ToLower(base) + "-" + strings.ToUpper(region)
+}
@@ -0,0 +1,14 @@
+package i18n
+
+// Сообщения встроенных языков. Другие языки можно добавить через Register при запуске сервера
+func init() {
+	Register("en", map[string]string{
+		"hello":      "Hello, from service. Today is %s",
+		"hello.name": "Hello, %s! Today is %s",
+	})
+
+	Register("ru", map[string]string{
+		"hello":      "Привет от сервиса. Сегодня %s",
+		"hello.name": "Привет, %s! Сегодня %s",
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/jobs"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/response"
+)
+
+// queue - Очередь фоновых задач, общая для маршрутизаторов всех виртуальных хостов, см. newQueue
+var queue *jobs.Queue
+
+// newQueue - Очередь задач по настройкам jobs с обработчиком webhook
+func newQueue(cfg config.Jobs) *jobs.Queue {
+	q := jobs.New(jobs.Options{
+		Workers:     cfg.Workers,
+		QueueSize:   cfg.QueueSize,
+		MaxAttempts: cfg.MaxAttempts,
+		BaseBackoff: cfg.BaseBackoff.D(),
+		MaxBackoff:  cfg.MaxBackoff.D(),
+		Timeout:     cfg.Timeout.D(),
+	})
+
+	hooks := cfg.Webhooks
+	q.Register(jobs.WebhookType, jobs.Webhook(&http.Client{}, func(p jobs.WebhookPayload) string {
+		for _, w := range hooks {
+			if w.Event == p.Event && w.URL == p.URL {
+				return w.Secret
+			}
+		}
+		return ""
+	}))
+	return q
+}
+
+// event - Тело webhook
+type event struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Data  any       `json:"data"`
+}
+
+// notify - Постановка задач webhook из jobs.webhooks для события name с данными data. Ошибка постановки
+// только записывается в лог: уведомление не отменяет уже выполненное действие
+func notify(ctx context.Context, hooks []config.Webhook, name string, data any) {
+	var body []byte
+	for _, w := range hooks {
+		if w.Event != name {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(event{Event: name, Time: time.Now().UTC(), Data: data}); err != nil {
+				logging.From(ctx).Error("jobs: webhook body", "event", name, "error", err)
+				return
+			}
+		}
+		if _, err := queue.Enqueue(jobs.WebhookType, jobs.WebhookPayload{URL: w.URL, Event: name, Body: body}); err != nil {
+			logging.From(ctx).Error("jobs: enqueue webhook", "event", name, "url", w.URL, "error", err)
+		}
+	}
+}
+
+// jobsHandler - Обработчик GET /debug/jobs служебного адреса: число задач в очереди и выполняемых,
+// счетчики с момента запуска и последние неудачные задачи с ошибками
+func jobsHandler(q *jobs.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.JSON(w, http.StatusOK, response.Body{Data: q.Stats()})
+	}
+}
@@ -0,0 +1,309 @@
+// Package jobs - Фоновые задачи: обработчики запросов ставят задачу в очередь и сразу отвечают клиенту,
+// а пул воркеров выполняет ее с повторами при ошибках
+package jobs
+
+import (
+	"container/heap"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	mrand "math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Ошибки постановки задачи в очередь
+var (
+	ErrUnknownType = errors.New("jobs: неизвестный тип задачи")
+	ErrQueueFull   = errors.New("jobs: очередь задач заполнена")
+	ErrStopped     = errors.New("jobs: очередь остановлена")
+)
+
+// Job - Задача в очереди
+type Job struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"` // Сколько раз задача уже выполнялась
+	CreatedAt time.Time       `json:"created_at"`
+	RunAt     time.Time       `json:"run_at"`               // Не раньше этого момента задача выполняется снова
+	LastError string          `json:"last_error,omitempty"` // Ошибка последней попытки
+}
+
+// Handler - Выполнение задачи. Ошибка - задача выполняется снова после паузы, если попытки не исчерпаны,
+// Permanent(err) - задача завершается неудачей без повторов. ctx отменяется по Options.Timeout
+type Handler func(ctx context.Context, job Job) error
+
+// permanentError - Ошибка, после которой задачу не нужно повторять, см. Permanent
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent - Ошибка задачи, которая не исчезнет при повторе, например некорректные данные задачи
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// Options - Параметры очереди
+type Options struct {
+	Workers     int           // Сколько задач выполняется одновременно
+	QueueSize   int           // Сколько задач может ждать выполнения, включая повторы
+	MaxAttempts int           // Сколько раз выполняется задача, пока не будет признана неудачной
+	BaseBackoff time.Duration // Пауза перед первым повтором, каждая следующая вдвое дольше
+	MaxBackoff  time.Duration // Наибольшая пауза перед повтором
+	Timeout     time.Duration // Ограничение времени одной попытки
+}
+
+// maxFailures - Сколько последних неудачных задач хранится для Stats
+const maxFailures = 50
+
+// Queue - Очередь задач в памяти процесса с пулом воркеров. Задачи, не выполненные до остановки сервера,
+// теряются, поэтому в очередь ставится работа, которую можно потерять или повторить другим способом
+type Queue struct {
+	opts Options
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	pending  jobHeap
+	running  int
+	stopped  bool
+	counts   Counts
+	failures []Job // Последние неудачные задачи, новые в конце
+
+	wake chan struct{} // Будит ждущих воркеров после постановки задачи
+}
+
+// New - Пустая очередь. Задачи начинают выполняться после вызова Run
+func New(opts Options) *Queue {
+	return &Queue{
+		opts:     opts,
+		handlers: map[string]Handler{},
+		wake:     make(chan struct{}, opts.Workers),
+	}
+}
+
+// Register - Обработчик задач типа typ. Регистрируется до Run
+func (q *Queue) Register(typ string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[typ] = h
+}
+
+// Enqueue - Постановка задачи типа typ с данными payload, которые сериализуются в JSON
+func (q *Queue) Enqueue(typ string, payload any) (Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, fmt.Errorf("jobs: %s: %w", typ, err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch {
+	case q.stopped:
+		return Job{}, ErrStopped
+	case q.handlers[typ] == nil:
+		return Job{}, fmt.Errorf("%w: %s", ErrUnknownType, typ)
+	case len(q.pending) >= q.opts.QueueSize:
+		return Job{}, ErrQueueFull
+	}
+
+	now := time.Now()
+	job := Job{ID: newID(), Type: typ, Payload: data, CreatedAt: now, RunAt: now}
+	heap.Push(&q.pending, job)
+	q.counts.Enqueued++
+
+	select {
+	case q.wake <- struct{}{}:
+	default: // Все воркеры уже разбужены
+	}
+	return job, nil
+}
+
+// Run - Выполнение задач до отмены ctx. После отмены новые задачи не начинаются, а Run ждет завершения
+// начатых, каждая из которых ограничена Options.Timeout
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range q.opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	q.mu.Lock()
+	q.stopped = true
+	dropped := len(q.pending)
+	q.mu.Unlock()
+	if dropped > 0 {
+		slog.Warn("jobs: stopped with unfinished jobs", "dropped", dropped)
+	}
+}
+
+// work - Цикл одного воркера
+func (q *Queue) work(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		job, wait, ok := q.next()
+		if ok {
+			q.run(ctx, job)
+			continue
+		}
+
+		timer.Reset(wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// idleWait - Пауза воркера, когда очередь пуста: задачи будят его через wake раньше
+const idleWait = time.Minute
+
+// next - Задача, время которой наступило, или пауза до ближайшей
+func (q *Queue) next() (Job, time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return Job{}, idleWait, false
+	}
+	if wait := time.Until(q.pending[0].RunAt); wait > 0 {
+		return Job{}, wait, false
+	}
+	q.running++
+	return heap.Pop(&q.pending).(Job), 0, true
+}
+
+// run - Одна попытка выполнить задачу. Задача, которая завершилась ошибкой, возвращается в очередь
+// с паузой или переходит в неудачные
+func (q *Queue) run(ctx context.Context, job Job) {
+	q.mu.Lock()
+	h := q.handlers[job.Type]
+	q.mu.Unlock()
+
+	job.Attempts++
+	// Отмена ctx при остановке сервера не прерывает начатую попытку: ее ограничивает только Timeout
+	jctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), q.opts.Timeout)
+	err := safeRun(jctx, h, job)
+	cancel()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.running--
+
+	if err == nil {
+		q.counts.Succeeded++
+		return
+	}
+
+	job.LastError = err.Error()
+	var permanent permanentError
+	if errors.As(err, &permanent) || job.Attempts >= q.opts.MaxAttempts {
+		q.counts.Failed++
+		q.failures = append(q.failures, job)
+		if len(q.failures) > maxFailures {
+			q.failures = q.failures[1:]
+		}
+		slog.Error("jobs: job failed", "id", job.ID, "type", job.Type, "attempts", job.Attempts, "error", err)
+		return
+	}
+
+	q.counts.Retried++
+	job.RunAt = time.Now().Add(q.backoff(job.Attempts))
+	heap.Push(&q.pending, job)
+	slog.Warn("jobs: job will be retried", "id", job.ID, "type", job.Type, "attempts", job.Attempts, "run_at", job.RunAt, "error", err)
+}
+
+// safeRun - Вызов h с превращением паники в ошибку: паника одной задачи не должна останавливать воркер
+func safeRun(ctx context.Context, h Handler, job Job) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// backoff - Пауза перед повтором после attempt неудачных попыток: BaseBackoff, удвоенная за каждую попытку,
+// но не больше MaxBackoff. Случайная половина паузы разводит повторы задач, которые упали одновременно
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := float64(q.opts.BaseBackoff) * math.Pow(2, float64(attempt-1))
+	d = math.Min(d, float64(q.opts.MaxBackoff))
+	return time.Duration(d/2 + mrand.Float64()*d/2)
+}
+
+// Counts - Счетчики задач с момента запуска
+type Counts struct {
+	Enqueued  uint64 `json:"enqueued"`
+	Succeeded uint64 `json:"succeeded"`
+	Retried   uint64 `json:"retried"` // Неудачные попытки, после которых задача повторяется
+	Failed    uint64 `json:"failed"`  // Задачи, которые не удалось выполнить за все попытки
+}
+
+// Stats - Состояние очереди
+type Stats struct {
+	Pending  int    `json:"pending"` // Ждут выполнения, включая повторы
+	Running  int    `json:"running"`
+	Workers  int    `json:"workers"`
+	Counts   Counts `json:"counts"`
+	Failures []Job  `json:"failures"` // Последние неудачные задачи, новые первыми
+}
+
+// Stats - Текущее состояние очереди
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	failures := make([]Job, 0, len(q.failures))
+	for i := len(q.failures) - 1; i >= 0; i-- {
+		failures = append(failures, q.failures[i])
+	}
+	return Stats{
+		Pending:  len(q.pending),
+		Running:  q.running,
+		Workers:  q.opts.Workers,
+		Counts:   q.counts,
+		Failures: failures,
+	}
+}
+
+// newID - Случайный идентификатор задачи
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// jobHeap - Задачи по возрастанию RunAt, см. container/heap
+type jobHeap []Job
+
+func (h jobHeap) Len() int           { return len(h) }
+func (h jobHeap) Less(i, j int) bool { return h[i].RunAt.Before(h[j].RunAt) }
+func (h jobHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *jobHeap) Push(x any)        { *h = append(*h, x.(Job)) }
+
+func (h *jobHeap) Pop() any {
+	old := *h
+	job := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return job
+}
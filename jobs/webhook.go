@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WebhookType - Тип задачи отправки webhook, см. Webhook
+const WebhookType = "webhook"
+
+// Заголовки запроса webhook
+const (
+	WebhookEventHeader     = "X-Webhook-Event"     // Событие, например file.uploaded
+	WebhookIDHeader        = "X-Webhook-Id"        // Идентификатор задачи или события: одинаковый во всех повторах, получатель может отбрасывать дубли
+	WebhookSignatureHeader = "X-Webhook-Signature" // sha256=<hex HMAC-SHA256 тела запроса>, если задан секрет
+)
+
+// WebhookPayload - Данные задачи webhook
+type WebhookPayload struct {
+	URL   string          `json:"url"`
+	Event string          `json:"event"`
+	Body  json.RawMessage `json:"body"` // Тело запроса JSON
+}
+
+// Webhook - Обработчик задач WebhookType, см. SendWebhook.
+//
+// secret - ключ подписи тела webhook p, пустой - без подписи. Ключ не хранится в данных задачи,
+// чтобы не попасть в Stats
+func Webhook(client *http.Client, secret func(p WebhookPayload) string) Handler {
+	return func(ctx context.Context, job Job) error {
+		var p WebhookPayload
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return Permanent(err)
+		}
+		return SendWebhook(ctx, client, job.ID, p, secret(p))
+	}
+}
+
+// SendWebhook - POST тела p.Body на p.URL с идентификатором id в X-Webhook-Id и подписью ключом secret,
+// если он не пустой. Успех - ответ 2xx, ответы 4xx, кроме 408 и 429, - ошибка Permanent:
+// повтор того же запроса их не исправит
+func SendWebhook(ctx context.Context, client *http.Client, id string, p WebhookPayload, secret string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(p.Body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, p.Event)
+	req.Header.Set(WebhookIDHeader, id)
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(p.Body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Тело ответа дочитывается, чтобы соединение вернулось в пул
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return Permanent(fmt.Errorf("webhook %s: ответ %s", p.URL, resp.Status))
+	}
+	return fmt.Errorf("webhook %s: ответ %s", p.URL, resp.Status)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/health"
+	"github.com/derv-dice/go-web-server/kv"
+	"github.com/derv-dice/go-web-server/schedule"
+)
+
+// keyValues - Хранилище набора маршрутов kv, общее для маршрутизаторов всех виртуальных хостов, см. openKV
+var keyValues kv.Store
+
+// openKV - Хранилище значений по настройкам kv и функция его закрытия при остановке сервера.
+// Схема базы данных обновляется миграциями при migrations.auto, иначе должна быть уже актуальной
+func openKV(cfg config.KV, migrations config.Migrations) (kv.Store, func() error, error) {
+	if !cfg.Enabled || cfg.Store == config.KVStoreMemory {
+		return kv.NewMemory(), func() error { return nil }, nil
+	}
+
+	ctx := context.Background()
+	db, err := openSQL(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := db.Migrator(false)
+	if err == nil {
+		if migrations.Auto {
+			_, err = m.Up(ctx)
+		} else {
+			err = m.Check(ctx)
+		}
+	}
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	// Без соединения с базой запросы к /kv завершаются ответом 503, поэтому экземпляр не готов принимать трафик
+	readiness.checks.Register("kv "+cfg.Store, health.Ping(db))
+	return db, db.Close, nil
+}
+
+// openSQL - Хранилище kv в базе данных SQLite или PostgreSQL
+func openSQL(ctx context.Context, cfg config.KV) (*kv.SQL, error) {
+	if cfg.Store == config.KVStorePostgres {
+		p := cfg.Postgres
+		return kv.OpenPostgres(ctx, kv.PostgresOptions{
+			DSN:             p.DSN,
+			MaxConns:        p.MaxConns,
+			MinConns:        p.MinConns,
+			MaxConnLifetime: p.MaxConnLifetime.D(),
+			MaxConnIdleTime: p.MaxConnIdleTime.D(),
+			ConnectTimeout:  p.ConnectTimeout.D(),
+		})
+	}
+	return kv.OpenSQLite(ctx, cfg.Path)
+}
+
+// kvCleanup - Задача удаления ключей с истекшим сроком из store. Без нее такие ключи не видны,
+// но занимают место в хранилище
+func kvCleanup(store kv.Store) schedule.Func {
+	return func(ctx context.Context) error {
+		n, err := store.DeleteExpired(ctx)
+		if n > 0 {
+			slog.Info("kv: expired keys deleted", "count", n)
+		}
+		return err
+	}
+}
+
+// runMigrations - Выполнение команды флага -migrate для хранилища kv
+func runMigrations(cfg config.Config) error {
+	ctx := context.Background()
+	db, err := openSQL(ctx, cfg.KV)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := db.Migrator(cfg.Migrations.DryRun)
+	if err != nil {
+		return err
+	}
+
+	switch cfg.Migrations.Command {
+	case config.MigrateUp:
+		done, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		slog.Info("migrate: up finished", "applied", len(done), "dry_run", cfg.Migrations.DryRun)
+	case config.MigrateDown:
+		mig, ok, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			slog.Info("migrate: down: no applied migrations")
+		} else {
+			slog.Info("migrate: down finished", "version", mig.Version, "name", mig.Name, "dry_run", cfg.Migrations.DryRun)
+		}
+	case config.MigrateStatus:
+		applied, pending, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mig := range pending {
+			slog.Info("migrate: pending", "version", mig.Version, "name", mig.Name)
+		}
+		slog.Info("migrate: status", "applied", applied, "pending", len(pending))
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/derv-dice/go-web-server/apperr"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// Ограничения запросов
+const (
+	MaxKeyBytes  = 512                // Наибольшая длина ключа
+	DefaultLimit = 100                // Значений в ответе List, если limit не задан
+	MaxLimit     = 1000               // Наибольший limit в List
+	MaxBatchOps  = 100                // Наибольшее число операций в Batch
+	MaxTTL       = 365 * 24 * 60 * 60 // Наибольший срок значения, секунд
+)
+
+// Заголовки сроков значений
+const (
+	TTLHeader       = "TTL"        // Срок значения в секундах в запросе PUT, без заголовка - без срока
+	ExpiresAtHeader = "Expires-At" // Момент истечения срока в ответе с одним значением, HTTP-дата
+)
+
+// Get - Обработчик GET значения ключа из параметра маршрута {key}. 404, если ключа нет или срок его значения истек
+func Get(store Store) http.HandlerFunc {
+	return response.Handle(func(w http.ResponseWriter, r *http.Request) error {
+		key, err := keyParam(r)
+		if err != nil {
+			return err
+		}
+
+		e, err := store.Get(r.Context(), key)
+		if err != nil {
+			return storeError(err)
+		}
+
+		setExpiresAt(w, e)
+		response.Respond(w, r, http.StatusOK, response.Body{Data: e})
+		return nil
+	})
+}
+
+// Put - Обработчик PUT значения ключа из параметра маршрута {key}. Тело запроса - любое значение JSON
+// не больше maxValueBytes байт, заголовок TTL - срок значения в секундах: после него ключа нет,
+// как если бы он был удален. Ответ 201 для нового ключа и 200 для замены значения
+func Put(store Store, maxValueBytes int64) http.HandlerFunc {
+	return response.Handle(func(w http.ResponseWriter, r *http.Request) error {
+		key, err := keyParam(r)
+		if err != nil {
+			return err
+		}
+
+		var ttl int
+		if h := r.Header.Get(TTLHeader); h != "" {
+			if ttl, err = strconv.Atoi(h); err != nil {
+				return ttlError(TTLHeader)
+			}
+		}
+		if ttl < 0 || ttl > MaxTTL {
+			return ttlError(TTLHeader)
+		}
+
+		value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValueBytes))
+		if err != nil {
+			return err // Превышение лимита - 413, см. response.Handle
+		}
+		if !json.Valid(value) {
+			return apperr.New(apperr.BadRequest, "тело запроса должно быть значением JSON")
+		}
+
+		e, created, err := store.Put(r.Context(), key, value, time.Duration(ttl)*time.Second)
+		if err != nil {
+			return storeError(err)
+		}
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		setExpiresAt(w, e)
+		response.Respond(w, r, status, response.Body{Data: e})
+		return nil
+	})
+}
+
+// Delete - Обработчик DELETE ключа из параметра маршрута {key}. Ответ 204, 404 - если ключа нет
+func Delete(store Store) http.HandlerFunc {
+	return response.Handle(func(w http.ResponseWriter, r *http.Request) error {
+		key, err := keyParam(r)
+		if err != nil {
+			return err
+		}
+
+		if err := store.Delete(r.Context(), key); err != nil {
+			return storeError(err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+// page - Ответ List
+type page struct {
+	Items []Entry `json:"items"`
+	Next  string  `json:"next,omitempty"` // Значение after для следующей страницы, пустое - страница последняя
+}
+
+// List - Обработчик GET списка значений по возрастанию ключа. Параметры запроса: prefix - только ключи
+// с этим префиксом, limit - размер страницы (по умолчанию 100, не больше 1000), after - ключ, после которого
+// начинается страница: значение next из предыдущей страницы
+func List(store Store) http.HandlerFunc {
+	return response.Handle(func(w http.ResponseWriter, r *http.Request) error {
+		q := r.URL.Query()
+
+		limit := DefaultLimit
+		if s := q.Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > MaxLimit {
+				return apperr.Errorf(apperr.Validation, "limit: ожидается число от 1 до %d", MaxLimit)
+			}
+			limit = n
+		}
+
+		// Одно лишнее значение показывает, есть ли следующая страница
+		items, err := store.List(r.Context(), q.Get("prefix"), q.Get("after"), limit+1)
+		if err != nil {
+			return storeError(err)
+		}
+
+		p := page{Items: items}
+		if len(items) > limit {
+			p.Items = items[:limit]
+			p.Next = p.Items[limit-1].Key
+		}
+		if p.Items == nil {
+			p.Items = []Entry{}
+		}
+
+		response.Respond(w, r, http.StatusOK, response.Body{Data: p})
+		return nil
+	})
+}
+
+// Операции Batch
+const (
+	OpPut    = "put"
+	OpDelete = "delete"
+)
+
+// batchOp - Операция в теле запроса Batch
+type batchOp struct {
+	Op    string          `json:"op"` // put или delete
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"` // Значение для put
+	TTL   int             `json:"ttl"`   // Срок значения put в секундах, 0 - без срока
+}
+
+// batchResult - Результат операции Batch
+type batchResult struct {
+	Op      string `json:"op"`
+	Key     string `json:"key"`
+	Entry   *Entry `json:"entry,omitempty"`   // Записанное значение put
+	Created bool   `json:"created,omitempty"` // Ключа до put не было
+}
+
+// Batch - Обработчик POST нескольких изменений в одной транзакции: {"ops": [{"op": "put", "key": "a", "value": 1,
+// "ttl": 60}, {"op": "delete", "key": "b"}]}, ttl - срок значения в секундах, как заголовок TTL в Put. Операции выполняются по порядку, и либо применяются все, либо ни одна:
+// если ключа из delete нет, ответ 404 с номером операции в data.op, а изменения отменяются.
+// Тело запроса целиком не больше maxValueBytes байт, операций - не больше MaxBatchOps
+func Batch(store Store, maxValueBytes int64) http.HandlerFunc {
+	return response.Handle(func(w http.ResponseWriter, r *http.Request) error {
+		var body struct {
+			Ops []batchOp `json:"ops"`
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValueBytes))
+		if err != nil {
+			return err // Превышение лимита - 413, см. response.Handle
+		}
+		if err := json.Unmarshal(data, &body); err != nil {
+			return apperr.New(apperr.BadRequest, "ожидается JSON с полем ops")
+		}
+		if len(body.Ops) == 0 || len(body.Ops) > MaxBatchOps {
+			return apperr.Errorf(apperr.Validation, "ops: ожидается от 1 до %d операций", MaxBatchOps)
+		}
+		for i, op := range body.Ops {
+			if err := validKey(op.Key); err != nil {
+				return err.WithData(map[string]int{"op": i})
+			}
+			switch {
+			case op.Op == OpPut && (len(op.Value) == 0 || !json.Valid(op.Value)):
+				return apperr.New(apperr.Validation, "value: для put нужно значение JSON").WithData(map[string]int{"op": i})
+			case op.TTL < 0 || op.TTL > MaxTTL:
+				return ttlError("ttl").WithData(map[string]int{"op": i})
+			case op.Op != OpPut && op.Op != OpDelete:
+				return apperr.Errorf(apperr.Validation, "op: неизвестная операция %q, ожидается put или delete", op.Op).
+					WithData(map[string]int{"op": i})
+			}
+		}
+
+		var results []batchResult
+		err = store.Tx(r.Context(), func(tx Ops) error {
+			// Tx может вызвать fn повторно, результаты прошлой попытки не нужны
+			results = make([]batchResult, 0, len(body.Ops))
+			for i, op := range body.Ops {
+				res := batchResult{Op: op.Op, Key: op.Key}
+				switch op.Op {
+				case OpPut:
+					e, created, err := tx.Put(r.Context(), op.Key, op.Value, time.Duration(op.TTL)*time.Second)
+					if err != nil {
+						return err
+					}
+					res.Entry, res.Created = &e, created
+				case OpDelete:
+					if err := tx.Delete(r.Context(), op.Key); errors.Is(err, ErrNotFound) {
+						return apperr.Errorf(apperr.NotFound, "ключ %q не найден, изменения отменены", op.Key).
+							WithData(map[string]int{"op": i})
+					} else if err != nil {
+						return err
+					}
+				}
+				results = append(results, res)
+			}
+			return nil
+		})
+		if err != nil {
+			if _, ok := apperr.As(err); ok {
+				return err
+			}
+			return storeError(err)
+		}
+
+		response.Respond(w, r, http.StatusOK, response.Body{Data: map[string][]batchResult{"results": results}})
+		return nil
+	})
+}
+
+// keyParam - Ключ из параметра маршрута {key}, см. validKey
+func keyParam(r *http.Request) (string, error) {
+	key := router.Param(r, "key")
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// validKey - Ошибка, если key не от 1 до MaxKeyBytes байт UTF-8 или содержит управляющие символы
+func validKey(key string) *apperr.Error {
+	if key == "" || len(key) > MaxKeyBytes || !utf8.ValidString(key) || strings.IndexFunc(key, unicode.IsControl) >= 0 {
+		return apperr.Errorf(apperr.Validation, "ключ: от 1 до %d байт UTF-8 без управляющих символов", MaxKeyBytes)
+	}
+	return nil
+}
+
+// ttlError - Ошибка значения срока в поле или заголовке name
+func ttlError(name string) *apperr.Error {
+	return apperr.Errorf(apperr.Validation, "%s: ожидается срок в секундах от 0 до %d", name, MaxTTL)
+}
+
+// setExpiresAt - Заголовок Expires-At ответа со значением e, если у него есть срок
+func setExpiresAt(w http.ResponseWriter, e Entry) {
+	if !e.ExpiresAt.IsZero() {
+		w.Header().Set(ExpiresAtHeader, e.ExpiresAt.Format(http.TimeFormat))
+	}
+}
+
+// storeError - Ответ на ошибку хранилища: 404 для ErrNotFound, 409 для ErrConflict, 503 для ErrUnavailable,
+// остальные - 500
+func storeError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return apperr.New(apperr.NotFound, "ключ не найден")
+	case errors.Is(err, ErrConflict):
+		return apperr.Wrap(err, apperr.Conflict, "ключ одновременно изменяется другим запросом, повторите запрос")
+	case errors.Is(err, ErrUnavailable):
+		return apperr.Wrap(err, apperr.Unavailable, "хранилище недоступно, повторите запрос позже")
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// serve - Ответ h на запрос method target с телом body
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+// testBodyBytes - Наибольший размер тела запросов в проверках обработчиков
+const testBodyBytes = 8 << 10
+
+func TestBatchValidation(t *testing.T) {
+	tooMany := `{"ops": [` + strings.TrimSuffix(strings.Repeat(`{"op": "delete", "key": "a"},`, MaxBatchOps+1), ",") + `]}`
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		op     int // Номер операции в data.op, -1 - ответ без него
+	}{
+		{name: "не JSON", body: `ops`, status: http.StatusBadRequest, op: -1},
+		{name: "ops не список", body: `{"ops": {}}`, status: http.StatusBadRequest, op: -1},
+		{name: "нет операций", body: `{"ops": []}`, status: http.StatusUnprocessableEntity, op: -1},
+		{name: "больше MaxBatchOps операций", body: tooMany, status: http.StatusUnprocessableEntity, op: -1},
+		{name: "пустой ключ", body: `{"ops": [{"op": "put", "key": "a", "value": 1}, {"op": "put", "key": "", "value": 1}]}`, status: http.StatusUnprocessableEntity, op: 1},
+		{name: "ключ с управляющим символом", body: `{"ops": [{"op": "delete", "key": "a\nb"}]}`, status: http.StatusUnprocessableEntity, op: 0},
+		{name: "длинный ключ", body: `{"ops": [{"op": "delete", "key": "` + strings.Repeat("k", MaxKeyBytes+1) + `"}]}`, status: http.StatusUnprocessableEntity, op: 0},
+		{name: "put без значения", body: `{"ops": [{"op": "put", "key": "a"}]}`, status: http.StatusUnprocessableEntity, op: 0},
+		{name: "отрицательный ttl", body: `{"ops": [{"op": "put", "key": "a", "value": 1, "ttl": -1}]}`, status: http.StatusUnprocessableEntity, op: 0},
+		{name: "ttl больше MaxTTL", body: `{"ops": [{"op": "put", "key": "a", "value": 1, "ttl": 31536001}]}`, status: http.StatusUnprocessableEntity, op: 0},
+		{name: "неизвестная операция", body: `{"ops": [{"op": "put", "key": "a", "value": 1}, {"op": "get", "key": "a"}]}`, status: http.StatusUnprocessableEntity, op: 1},
+		{name: "delete отсутствующего ключа", body: `{"ops": [{"op": "put", "key": "a", "value": 1}, {"op": "delete", "key": "missing"}]}`, status: http.StatusNotFound, op: 1},
+		{name: "тело больше лимита", body: `{"ops": [{"op": "put", "key": "a", "value": "` + strings.Repeat("v", testBodyBytes) + `"}]}`, status: http.StatusRequestEntityTooLarge, op: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemory()
+			w := serve(Batch(s, testBodyBytes), http.MethodPost, "/kv", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("статус %d, ожидается %d: %s", w.Code, tt.status, w.Body.String())
+			}
+
+			var body struct {
+				Data struct {
+					Op *int `json:"op"`
+				} `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if op := body.Data.Op; (tt.op < 0) != (op == nil) || op != nil && *op != tt.op {
+				t.Fatalf("data.op %v, ожидается %d: %s", op, tt.op, w.Body.String())
+			}
+
+			// Ни одна операция отклоненного запроса не применена
+			if list, _ := s.List(context.Background(), "", "", 10); len(list) != 0 {
+				t.Fatalf("ключи после отклоненного запроса: %v", keys(list))
+			}
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	s := NewMemory()
+	put(t, s, "old", `1`)
+
+	w := serve(Batch(s, testBodyBytes), http.MethodPost, "/kv",
+		`{"ops": [{"op": "put", "key": "new", "value": {"a": 1}, "ttl": 60}, {"op": "put", "key": "old", "value": 2}, {"op": "delete", "key": "new"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("статус %d: %s", w.Code, w.Body.String())
+	}
+
+	var body struct {
+		Data struct {
+			Results []batchResult `json:"results"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	res := body.Data.Results
+	if len(res) != 3 || !res[0].Created || res[0].Entry.ExpiresAt.IsZero() || res[1].Created || res[2].Entry != nil {
+		t.Fatalf("результаты %+v", res)
+	}
+	if list, _ := s.List(context.Background(), "", "", 10); !slices.Equal(keys(list), []string{"old"}) || string(list[0].Value) != `2` {
+		t.Fatalf("ключи после запроса: %v", list)
+	}
+}
+
+func TestListHandler(t *testing.T) {
+	s := NewMemory()
+	for _, key := range []string{"a/1", "a/2", "a/3", "a/4", "a/5", "b/1"} {
+		put(t, s, key, `0`)
+	}
+	h := List(s)
+
+	// Обход страниц по next
+	var got []string
+	target := "/kv?prefix=a/&limit=2"
+	for pages := 0; target != ""; pages++ {
+		if pages > 3 {
+			t.Fatalf("больше 3 страниц: %v", got)
+		}
+		w := serve(h, http.MethodGet, target, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("статус %d: %s", w.Code, w.Body.String())
+		}
+
+		var body struct {
+			Data page `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Data.Items) > 2 {
+			t.Fatalf("%d значений на странице при limit=2", len(body.Data.Items))
+		}
+		got = append(got, keys(body.Data.Items)...)
+
+		target = ""
+		if body.Data.Next != "" {
+			target = "/kv?prefix=a/&limit=2&after=" + body.Data.Next
+		}
+	}
+	if want := []string{"a/1", "a/2", "a/3", "a/4", "a/5"}; !slices.Equal(got, want) {
+		t.Fatalf("ключи %v, ожидается %v", got, want)
+	}
+
+	// Страница, которая заканчивается последним ключом, не содержит next
+	w := serve(h, http.MethodGet, "/kv?prefix=b/", "")
+	if !strings.Contains(w.Body.String(), `"items":[{"key":"b/1"`) || strings.Contains(w.Body.String(), `"next"`) {
+		t.Fatalf("последняя страница: %s", w.Body.String())
+	}
+	if w := serve(h, http.MethodGet, "/kv?prefix=c/", ""); !strings.Contains(w.Body.String(), `"items":[]`) {
+		t.Fatalf("пустой список: %s", w.Body.String())
+	}
+
+	for _, limit := range []string{"0", "-1", "1001", "many"} {
+		if w := serve(h, http.MethodGet, "/kv?limit="+limit, ""); w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("limit=%s: статус %d, ожидается 422", limit, w.Code)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+-- Значения хранятся как текст JSON, время изменения - в наносекундах Unix, поэтому схема одинакова
+-- для SQLite и PostgreSQL. IF NOT EXISTS - таблица могла быть создана до появления миграций
+CREATE TABLE IF NOT EXISTS kv (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at BIGINT NOT NULL
+);
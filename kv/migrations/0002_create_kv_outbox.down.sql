@@ -0,0 +1 @@
+DROP TABLE kv_outbox;
@@ -0,0 +1,14 @@
+-- События изменений ключей для outbox: строка пишется в той же транзакции, что и изменение, и удаляется
+-- после доставки. Время - в наносекундах Unix, dead - 1, если попытки доставки исчерпаны
+CREATE TABLE kv_outbox (
+	id TEXT PRIMARY KEY,
+	event TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	created_at BIGINT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at BIGINT NOT NULL,
+	last_error TEXT NOT NULL DEFAULT '',
+	dead INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX kv_outbox_next_attempt ON kv_outbox (dead, next_attempt_at);
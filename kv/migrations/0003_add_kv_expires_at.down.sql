@@ -0,0 +1,3 @@
+DROP INDEX kv_expires_at;
+
+ALTER TABLE kv DROP COLUMN expires_at;
@@ -0,0 +1,4 @@
+-- Срок значения в наносекундах Unix, 0 - без срока. Индекс нужен для удаления истекших ключей
+ALTER TABLE kv ADD COLUMN expires_at BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX kv_expires_at ON kv (expires_at) WHERE expires_at <> 0;
@@ -0,0 +1,120 @@
+package kv
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/derv-dice/go-web-server/outbox"
+)
+
+// События изменений ключей, см. EnableOutbox
+const (
+	EventPut    = "kv.put"    // Запись значения, данные - PutEvent
+	EventDelete = "kv.delete" // Удаление ключа, данные - DeleteEvent
+)
+
+// PutEvent - Данные события EventPut
+type PutEvent struct {
+	Entry
+	Created bool `json:"created"` // Ключа раньше не было
+}
+
+// DeleteEvent - Данные события EventDelete
+type DeleteEvent struct {
+	Key string `json:"key"`
+}
+
+// Outbox - Таблица kv_outbox событий изменений ключей, outbox.Store
+type Outbox struct {
+	s *SQL
+}
+
+// EnableOutbox - Запись события в kv_outbox в той же транзакции, что и каждое изменение ключа, в том числе
+// в Tx. Событие фиксируется вместе с изменением или не записывается вовсе. Вызывается до первого запроса
+func (s *SQL) EnableOutbox() *Outbox {
+	s.outbox = true
+	return &Outbox{s: s}
+}
+
+// emit - Запись события typ с данными data в транзакции o, если outbox включен
+func (o sqlOps) emit(ctx context.Context, typ string, data any) error {
+	if !o.s.outbox {
+		return nil
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	now := time.Now().UnixNano()
+	_, err = o.q.ExecContext(ctx, o.s.query(`INSERT INTO kv_outbox (id, event, payload, created_at, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?)`), hex.EncodeToString(id[:]), typ, string(payload), now, now)
+	return err
+}
+
+// claimAttempts - Сколько событий Claim пробует занять, если их одновременно занимают другие экземпляры
+const claimAttempts = 3
+
+// Claim - Из событий, время доставки которых наступило, выбирается самое раннее. Оно занимается условным
+// UPDATE по прежнему next_attempt_at: из экземпляров, выбравших одно событие, его получает только первый
+func (o *Outbox) Claim(ctx context.Context, now, until time.Time) (outbox.Event, bool, error) {
+	s := o.s
+	for range claimAttempts {
+		var (
+			ev      outbox.Event
+			payload string
+			created int64
+			next    int64
+		)
+		err := s.db.QueryRowContext(ctx, s.query(`SELECT id, event, payload, created_at, attempts, next_attempt_at
+			FROM kv_outbox WHERE dead = 0 AND next_attempt_at <= ?
+			ORDER BY created_at LIMIT 1`), now.UnixNano()).
+			Scan(&ev.ID, &ev.Type, &payload, &created, &ev.Attempts, &next)
+		if errors.Is(err, sql.ErrNoRows) {
+			return outbox.Event{}, false, nil
+		}
+		if err != nil {
+			return outbox.Event{}, false, s.fail(err)
+		}
+
+		res, err := s.db.ExecContext(ctx, s.query(`UPDATE kv_outbox SET attempts = attempts + 1, next_attempt_at = ?
+			WHERE id = ? AND next_attempt_at = ?`), until.UnixNano(), ev.ID, next)
+		if err != nil {
+			return outbox.Event{}, false, s.fail(err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return outbox.Event{}, false, err
+		} else if n == 0 {
+			continue // Событие занял другой экземпляр
+		}
+
+		ev.Payload = json.RawMessage(payload)
+		ev.CreatedAt = time.Unix(0, created).UTC()
+		ev.Attempts++
+		return ev, true, nil
+	}
+	return outbox.Event{}, false, nil
+}
+
+func (o *Outbox) Delivered(ctx context.Context, id string) error {
+	_, err := o.s.db.ExecContext(ctx, o.s.query(`DELETE FROM kv_outbox WHERE id = ?`), id)
+	return o.s.fail(err)
+}
+
+func (o *Outbox) Retry(ctx context.Context, id string, at time.Time, lastErr string) error {
+	_, err := o.s.db.ExecContext(ctx, o.s.query(`UPDATE kv_outbox SET next_attempt_at = ?, last_error = ? WHERE id = ?`),
+		at.UnixNano(), lastErr, id)
+	return o.s.fail(err)
+}
+
+func (o *Outbox) Dead(ctx context.Context, id string, lastErr string) error {
+	_, err := o.s.db.ExecContext(ctx, o.s.query(`UPDATE kv_outbox SET dead = 1, last_error = ? WHERE id = ?`), lastErr, id)
+	return o.s.fail(err)
+}
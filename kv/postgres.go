@@ -0,0 +1,76 @@
+//go:build postgres
+
+package kv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// OpenPostgres - Store в базе PostgreSQL с пулом соединений pgx. База должна быть доступна при открытии.
+// Потерянные потом соединения пул открывает заново, а запросы без соединения
+// завершаются ошибкой ErrUnavailable
+func OpenPostgres(ctx context.Context, opts PostgresOptions) (*SQL, error) {
+	cfg, err := pgxpool.ParseConfig(opts.DSN)
+	if err != nil {
+		// Текст ошибки разбора может содержать пароль из строки подключения
+		return nil, fmt.Errorf("postgres: некорректная строка подключения")
+	}
+	if opts.MaxConns > 0 {
+		cfg.MaxConns = opts.MaxConns
+	}
+	cfg.MinConns = opts.MinConns
+	if opts.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = opts.MaxConnLifetime
+	}
+	if opts.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = opts.MaxConnIdleTime
+	}
+	if opts.ConnectTimeout > 0 {
+		cfg.ConnConfig.ConnectTimeout = opts.ConnectTimeout
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: %w", err)
+	}
+	db := stdlib.OpenDBFromPool(pool)
+
+	// Ключи сравниваются по байтам, как в Memory и SQLite, а не по правилам локали базы.
+	// Транзакции Tx изолированы так же, как в Memory: будто выполняются по одной
+	s, err := newSQL(ctx, db, dialect{
+		numbered:  true,
+		collate:   ` COLLATE "C"`,
+		isolation: sql.LevelSerializable,
+		conflict:  conflict,
+		lock:      `SELECT pg_advisory_lock(hashtext('kv_schema_migrations'))`,
+		unlock:    `SELECT pg_advisory_unlock(hashtext('kv_schema_migrations'))`,
+	})
+	if err != nil {
+		db.Close()
+		pool.Close()
+		return nil, fmt.Errorf("postgres: %w", err)
+	}
+	// Закрытие *sql.DB из OpenDBFromPool не закрывает сам пул
+	s.close = pool.Close
+	return s, nil
+}
+
+// conflict - Ошибка PostgreSQL при параллельном изменении тех же ключей: транзакцию можно повторить
+func conflict(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "40001", "40P01", "23505": // serialization_failure, deadlock_detected, unique_violation
+		return true
+	}
+	return false
+}
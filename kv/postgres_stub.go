@@ -0,0 +1,13 @@
+//go:build !postgres
+
+package kv
+
+import (
+	"context"
+	"errors"
+)
+
+// OpenPostgres - Хранилище PostgreSQL доступно только при сборке с тегом postgres
+func OpenPostgres(context.Context, PostgresOptions) (*SQL, error) {
+	return nil, errors.New("kv: сервер собран без поддержки PostgreSQL, пересоберите с -tags postgres")
+}
@@ -0,0 +1,29 @@
+//go:build sqlite
+
+package kv
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "modernc.org/sqlite" // Драйвер sqlite на чистом Go, без cgo
+)
+
+// OpenSQLite - Store в файле базы SQLite path. Файл создается, если его нет
+func OpenSQLite(ctx context.Context, path string) (*SQL, error) {
+	// WAL позволяет читать во время записи, а busy_timeout - ждать освобождения базы вместо ошибки SQLITE_BUSY
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: %w", err)
+	}
+	// SQLite выполняет записи по одной: одно соединение избавляет от ошибок блокировки между транзакциями
+	db.SetMaxOpenConns(1)
+
+	s, err := newSQL(ctx, db, dialect{})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite: %s: %w", path, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,13 @@
+//go:build !sqlite
+
+package kv
+
+import (
+	"context"
+	"errors"
+)
+
+// OpenSQLite - Хранилище SQLite доступно только при сборке с тегом sqlite
+func OpenSQLite(context.Context, string) (*SQL, error) {
+	return nil, errors.New("kv: сервер собран без поддержки SQLite, пересоберите с -tags sqlite")
+}
@@ -0,0 +1,73 @@
+//go:build sqlite
+
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openSQLite - Новое хранилище SQLite во временном каталоге теста с примененными миграциями
+func openSQLite(t *testing.T) *SQL {
+	t.Helper()
+	ctx := context.Background()
+
+	s, err := OpenSQLite(ctx, filepath.Join(t.TempDir(), "kv.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	m, err := s.Migrator(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("миграции: %v", err)
+	}
+	return s
+}
+
+func TestSQLite(t *testing.T) {
+	testStore(t, func(t *testing.T) Store { return openSQLite(t) })
+}
+
+func TestSQLitePutExpired(t *testing.T) {
+	ctx := context.Background()
+	s := openSQLite(t)
+
+	// Строка с истекшим сроком, которую DeleteExpired еще не удалил
+	expired := time.Now().Add(-time.Minute).UnixNano()
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO kv (key, value, updated_at, expires_at) VALUES (?, ?, ?, ?)`,
+		"session", `"old"`, expired, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	// UPDATE не находит действующего значения, строка удаляется и вставляется заново
+	e, created, err := s.Put(ctx, "session", json.RawMessage(`"new"`), time.Hour)
+	if err != nil || !created {
+		t.Fatalf("Put поверх истекшего значения: created %v, %v", created, err)
+	}
+
+	var n int
+	var value string
+	var expires int64
+	if err := s.db.QueryRowContext(ctx, `SELECT count(*), max(value), max(expires_at) FROM kv WHERE key = ?`, "session").
+		Scan(&n, &value, &expires); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || value != `"new"` || expires != e.ExpiresAt.UnixNano() {
+		t.Fatalf("%d строк, значение %s, срок %d, ожидается одна строка %q со сроком %d", n, value, expires, `"new"`, e.ExpiresAt.UnixNano())
+	}
+
+	// Следующая запись обновляет действующее значение
+	if _, created, err := s.Put(ctx, "session", json.RawMessage(`"newer"`), 0); err != nil || created {
+		t.Fatalf("Put поверх действующего значения: created %v, %v", created, err)
+	}
+	if got, err := s.Get(ctx, "session"); err != nil || string(got.Value) != `"newer"` || !got.ExpiresAt.IsZero() {
+		t.Fatalf("Get: %+v, %v", got, err)
+	}
+}
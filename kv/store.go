@@ -0,0 +1,221 @@
+// Package kv - Хранилище пар ключ - значение и его REST API
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ошибки хранилища
+var (
+	ErrNotFound    = errors.New("ключ не найден")
+	ErrUnavailable = errors.New("хранилище недоступно")                  // Нет соединения с базой данных, запрос можно повторить позже
+	ErrConflict    = errors.New("транзакция конфликтует с параллельной") // Повторы транзакции не помогли, см. Store.Tx
+)
+
+// Entry - Значение ключа
+type Entry struct {
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"` // Любое значение JSON
+	UpdatedAt time.Time       `json:"updated_at"`
+	ExpiresAt time.Time       `json:"expires_at,omitzero"` // После этого момента ключа нет, нулевое - без срока
+}
+
+// expired - Срок значения истек к now
+func (e Entry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// Ops - Операции с ключами хранилища и транзакции. Get и Delete возвращают ErrNotFound, если ключа нет
+// или срок его значения истек: такие ключи не видны сразу, хотя удаляются позже, см. Store.DeleteExpired.
+// Ошибки из-за потери соединения с базой данных оборачивают ErrUnavailable
+type Ops interface {
+	Get(ctx context.Context, key string) (Entry, error)
+	// Put - Запись значения ключа со сроком ttl, 0 - без срока. created == true, если ключа раньше не было
+	Put(ctx context.Context, key string, value json.RawMessage, ttl time.Duration) (e Entry, created bool, err error)
+	Delete(ctx context.Context, key string) error
+	// List - До limit значений ключей с префиксом prefix, которые идут после after, по возрастанию ключа.
+	// Пустой after - с первого ключа
+	List(ctx context.Context, prefix, after string, limit int) ([]Entry, error)
+}
+
+// Store - Хранилище пар ключ - значение: в памяти (Memory), SQLite или PostgreSQL (SQL).
+// Обработчики пишутся для Store и не зависят от того, какое хранилище выбрано при запуске
+type Store interface {
+	Ops
+	// Tx - Выполнение fn в транзакции: изменения через tx применяются все вместе, если fn вернула nil,
+	// и отменяются, если ошибку. Внутри fn нужно обращаться только к tx: вызов методов самого Store
+	// может ждать завершения транзакции бесконечно. fn может быть вызвана повторно, если транзакция
+	// конфликтует с параллельной, после нескольких неудачных попыток Tx возвращает ErrConflict
+	Tx(ctx context.Context, fn func(tx Ops) error) error
+	// DeleteExpired - Удаление ключей с истекшим сроком, возвращает их число
+	DeleteExpired(ctx context.Context) (int, error)
+}
+
+// Memory - Store в памяти процесса. Значения теряются при перезапуске
+type Memory struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+}
+
+// NewMemory - Пустое хранилище в памяти
+func NewMemory() *Memory {
+	return &Memory{entries: map[string]Entry{}}
+}
+
+func (m *Memory) Get(_ context.Context, key string) (Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, ok := m.entries[key]
+	if !ok || e.expired(time.Now()) {
+		return Entry{}, ErrNotFound
+	}
+	return e, nil
+}
+
+func (m *Memory) Put(_ context.Context, key string, value json.RawMessage, ttl time.Duration) (Entry, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, exists := m.entries[key]
+	e := newEntry(key, value, ttl)
+	m.entries[key] = e
+	return e, !exists || old.expired(e.UpdatedAt), nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.entries, key)
+	if e.expired(time.Now()) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// List - Ключи сортируются при каждом вызове, поэтому время ответа растет с числом ключей в хранилище
+func (m *Memory) List(_ context.Context, prefix, after string, limit int) ([]Entry, error) {
+	m.mu.RLock()
+	var list []Entry
+	now := time.Now()
+	for key, e := range m.entries {
+		if strings.HasPrefix(key, prefix) && key > after && !e.expired(now) {
+			list = append(list, e)
+		}
+	}
+	m.mu.RUnlock()
+
+	return sortEntries(list, limit), nil
+}
+
+// Tx - Транзакции выполняются по одной: на время fn хранилище заблокировано и для чтения,
+// поэтому конфликтов между ними не бывает
+func (m *Memory) Tx(_ context.Context, fn func(tx Ops) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &memoryTx{entries: m.entries, changes: map[string]*Entry{}, now: time.Now()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for key, e := range tx.changes {
+		if e == nil {
+			delete(m.entries, key)
+		} else {
+			m.entries[key] = *e
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteExpired(context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := len(m.entries)
+	now := time.Now()
+	maps.DeleteFunc(m.entries, func(_ string, e Entry) bool { return e.expired(now) })
+	return n - len(m.entries), nil
+}
+
+// memoryTx - Транзакция Memory: изменения копятся отдельно от значений хранилища до завершения fn
+type memoryTx struct {
+	entries map[string]Entry
+	changes map[string]*Entry // Новые значения ключей, nil - ключ удален
+	now     time.Time         // Начало транзакции: сроки значений проверяются на этот момент
+}
+
+func (tx *memoryTx) Get(_ context.Context, key string) (Entry, error) {
+	if e, ok := tx.changes[key]; ok {
+		if e == nil {
+			return Entry{}, ErrNotFound
+		}
+		return *e, nil
+	}
+	e, ok := tx.entries[key]
+	if !ok || e.expired(tx.now) {
+		return Entry{}, ErrNotFound
+	}
+	return e, nil
+}
+
+func (tx *memoryTx) Put(ctx context.Context, key string, value json.RawMessage, ttl time.Duration) (Entry, bool, error) {
+	_, err := tx.Get(ctx, key)
+	e := newEntry(key, value, ttl)
+	tx.changes[key] = &e
+	return e, err != nil, nil
+}
+
+func (tx *memoryTx) Delete(ctx context.Context, key string) error {
+	if _, err := tx.Get(ctx, key); err != nil {
+		return err
+	}
+	tx.changes[key] = nil
+	return nil
+}
+
+func (tx *memoryTx) List(_ context.Context, prefix, after string, limit int) ([]Entry, error) {
+	var list []Entry
+	for key, e := range tx.entries {
+		if _, changed := tx.changes[key]; !changed && strings.HasPrefix(key, prefix) && key > after && !e.expired(tx.now) {
+			list = append(list, e)
+		}
+	}
+	for key, e := range tx.changes {
+		if e != nil && strings.HasPrefix(key, prefix) && key > after {
+			list = append(list, *e)
+		}
+	}
+	return sortEntries(list, limit), nil
+}
+
+// newEntry - Значение ключа с текущим временем изменения и сроком ttl, 0 - без срока.
+// Копия value: вызывающий может переиспользовать буфер после записи
+func newEntry(key string, value json.RawMessage, ttl time.Duration) Entry {
+	e := Entry{Key: key, Value: slices.Clone(value), UpdatedAt: time.Now().UTC()}
+	if ttl > 0 {
+		e.ExpiresAt = e.UpdatedAt.Add(ttl)
+	}
+	return e
+}
+
+// sortEntries - Первые limit значений list по возрастанию ключа
+func sortEntries(list []Entry, limit int) []Entry {
+	slices.SortFunc(list, func(a, b Entry) int { return strings.Compare(a.Key, b.Key) })
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
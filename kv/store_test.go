@@ -0,0 +1,256 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testTTL - Срок значений в проверках истечения срока
+const testTTL = 30 * time.Millisecond
+
+// testStore - Проверки, общие для всех реализаций Store. open - новое пустое хранилище
+func testStore(t *testing.T, open func(t *testing.T) Store) {
+	t.Run("запись и чтение", func(t *testing.T) { testPutGet(t, open(t)) })
+	t.Run("срок значения", func(t *testing.T) { testExpiry(t, open(t)) })
+	t.Run("список", func(t *testing.T) { testList(t, open(t)) })
+	t.Run("транзакция", func(t *testing.T) { testTx(t, open(t)) })
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, func(*testing.T) Store { return NewMemory() })
+}
+
+// put - Запись value в key без срока с остановкой теста при ошибке
+func put(t *testing.T, s Ops, key, value string) {
+	t.Helper()
+	if _, _, err := s.Put(context.Background(), key, json.RawMessage(value), 0); err != nil {
+		t.Fatalf("Put %s: %v", key, err)
+	}
+}
+
+// keys - Ключи значений list
+func keys(list []Entry) []string {
+	k := make([]string, 0, len(list))
+	for _, e := range list {
+		k = append(k, e.Key)
+	}
+	return k
+}
+
+func testPutGet(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	e, created, err := s.Put(ctx, "user/1", json.RawMessage(`{"name":"alice"}`), 0)
+	if err != nil || !created || !e.ExpiresAt.IsZero() {
+		t.Fatalf("первая запись: created %v, expires %v, %v", created, e.ExpiresAt, err)
+	}
+	if _, created, err = s.Put(ctx, "user/1", json.RawMessage(`{"name":"bob"}`), 0); err != nil || created {
+		t.Fatalf("замена значения: created %v, %v", created, err)
+	}
+
+	got, err := s.Get(ctx, "user/1")
+	if err != nil || string(got.Value) != `{"name":"bob"}` || got.UpdatedAt.IsZero() {
+		t.Fatalf("Get: %+v, %v", got, err)
+	}
+
+	if err := s.Delete(ctx, "user/1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "user/1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get удаленного ключа: %v, ожидается ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "user/1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("повторный Delete: %v, ожидается ErrNotFound", err)
+	}
+}
+
+func testExpiry(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	e, _, err := s.Put(ctx, "session/a", json.RawMessage(`1`), testTTL)
+	if err != nil || e.ExpiresAt.IsZero() {
+		t.Fatalf("Put со сроком: expires %v, %v", e.ExpiresAt, err)
+	}
+	if _, _, err := s.Put(ctx, "session/b", json.RawMessage(`2`), testTTL); err != nil {
+		t.Fatal(err)
+	}
+	put(t, s, "session/c", `3`)
+
+	if got, err := s.Get(ctx, "session/a"); err != nil || !got.ExpiresAt.Equal(e.ExpiresAt) {
+		t.Fatalf("Get до истечения срока: %+v, %v", got, err)
+	}
+	time.Sleep(2 * testTTL)
+
+	// Ключи с истекшим сроком не видны, хотя еще не удалены
+	if _, err := s.Get(ctx, "session/a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get после истечения срока: %v, ожидается ErrNotFound", err)
+	}
+	if list, err := s.List(ctx, "session/", "", 10); err != nil || !slices.Equal(keys(list), []string{"session/c"}) {
+		t.Fatalf("List после истечения срока: %v, %v", keys(list), err)
+	}
+	err = s.Tx(ctx, func(tx Ops) error {
+		_, err := tx.Get(ctx, "session/b")
+		return err
+	})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get в транзакции после истечения срока: %v, ожидается ErrNotFound", err)
+	}
+
+	// Запись поверх истекшего, но не удаленного значения создает ключ заново
+	e, created, err := s.Put(ctx, "session/a", json.RawMessage(`10`), 0)
+	if err != nil || !created || !e.ExpiresAt.IsZero() {
+		t.Fatalf("Put после истечения срока: created %v, expires %v, %v", created, e.ExpiresAt, err)
+	}
+	if got, err := s.Get(ctx, "session/a"); err != nil || string(got.Value) != `10` {
+		t.Fatalf("Get после перезаписи: %+v, %v", got, err)
+	}
+
+	if n, err := s.DeleteExpired(ctx); err != nil || n != 1 {
+		t.Fatalf("DeleteExpired: %d, %v, ожидается 1", n, err)
+	}
+	if list, err := s.List(ctx, "session/", "", 10); err != nil || !slices.Equal(keys(list), []string{"session/a", "session/c"}) {
+		t.Fatalf("List после DeleteExpired: %v, %v", keys(list), err)
+	}
+}
+
+func testList(t *testing.T, s Store) {
+	ctx := context.Background()
+	all := []string{"B/1", "a", "b/1", "b/2", "b/3", "b/z", "b/é", "c"} // По возрастанию байтов, как строки Go
+	for _, key := range []string{"c", "b/é", "b/2", "a", "b/z", "B/1", "b/3", "b/1"} {
+		put(t, s, key, `0`)
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		after  string
+		limit  int
+		want   []string
+	}{
+		{name: "все ключи", limit: 100, want: all},
+		{name: "первая страница", limit: 3, want: []string{"B/1", "a", "b/1"}},
+		{name: "страница после ключа", after: "b/1", limit: 3, want: []string{"b/2", "b/3", "b/z"}},
+		{name: "after не из хранилища", after: "b/25", limit: 2, want: []string{"b/3", "b/z"}},
+		{name: "последняя страница", after: "b/é", limit: 3, want: []string{"c"}},
+		{name: "после последнего ключа", after: "c", limit: 3, want: nil},
+		{name: "префикс", prefix: "b/", limit: 100, want: []string{"b/1", "b/2", "b/3", "b/z", "b/é"}},
+		{name: "префикс и after", prefix: "b/", after: "b/3", limit: 1, want: []string{"b/z"}},
+		{name: "префикс с учетом регистра", prefix: "B", limit: 100, want: []string{"B/1"}},
+		{name: "префикс с символом LIKE", prefix: "b%", limit: 100, want: nil},
+		{name: "префикс не из ASCII", prefix: "b/é", limit: 100, want: []string{"b/é"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := s.List(ctx, tt.prefix, tt.after, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := keys(list); !slices.Equal(got, tt.want) {
+				t.Fatalf("ключи %v, ожидается %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("обход страницами", func(t *testing.T) {
+		var got []string
+		after := ""
+		for range len(all) {
+			list, err := s.List(ctx, "", after, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) == 0 {
+				break
+			}
+			got = append(got, keys(list)...)
+			after = list[len(list)-1].Key
+		}
+		if !slices.Equal(got, all) {
+			t.Fatalf("ключи %v, ожидается %v", got, all)
+		}
+	})
+}
+
+func testTx(t *testing.T, s Store) {
+	ctx := context.Background()
+	errAbort := errors.New("отмена")
+	put(t, s, "keep", `1`)
+	put(t, s, "gone", `1`)
+
+	// changes - Изменения транзакции с проверкой, что она видит их сама
+	changes := func(t *testing.T, tx Ops) error {
+		if _, created, err := tx.Put(ctx, "new", json.RawMessage(`2`), 0); err != nil || !created {
+			t.Fatalf("Put в транзакции: created %v, %v", created, err)
+		}
+		if _, created, err := tx.Put(ctx, "keep", json.RawMessage(`2`), 0); err != nil || created {
+			t.Fatalf("замена в транзакции: created %v, %v", created, err)
+		}
+		if err := tx.Delete(ctx, "gone"); err != nil {
+			t.Fatalf("Delete в транзакции: %v", err)
+		}
+
+		if got, err := tx.Get(ctx, "keep"); err != nil || string(got.Value) != `2` {
+			t.Fatalf("Get измененного ключа в транзакции: %+v, %v", got, err)
+		}
+		if _, err := tx.Get(ctx, "gone"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get удаленного ключа в транзакции: %v", err)
+		}
+		if list, err := tx.List(ctx, "", "", 10); err != nil || !slices.Equal(keys(list), []string{"keep", "new"}) {
+			t.Fatalf("List в транзакции: %v, %v", keys(list), err)
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, tx Ops) error
+		err  error
+	}{
+		{name: "ошибка fn", fn: func(t *testing.T, tx Ops) error { changes(t, tx); return errAbort }, err: errAbort},
+		{
+			name: "ошибка операции",
+			fn: func(t *testing.T, tx Ops) error {
+				changes(t, tx)
+				return tx.Delete(ctx, "missing")
+			},
+			err: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("откат: "+tt.name, func(t *testing.T) {
+			err := s.Tx(ctx, func(tx Ops) error { return tt.fn(t, tx) })
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Tx: %v, ожидается %v", err, tt.err)
+			}
+
+			// Ни одно изменение не применено
+			if _, err := s.Get(ctx, "new"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("ключ из отмененной транзакции: %v", err)
+			}
+			if got, err := s.Get(ctx, "keep"); err != nil || string(got.Value) != `1` {
+				t.Fatalf("значение после отката: %+v, %v", got, err)
+			}
+			if _, err := s.Get(ctx, "gone"); err != nil {
+				t.Fatalf("удаленный в отмененной транзакции ключ: %v", err)
+			}
+		})
+	}
+
+	t.Run("применение", func(t *testing.T) {
+		if err := s.Tx(ctx, func(tx Ops) error { return changes(t, tx) }); err != nil {
+			t.Fatalf("Tx: %v", err)
+		}
+		if list, err := s.List(ctx, "", "", 10); err != nil || !slices.Equal(keys(list), []string{"keep", "new"}) {
+			t.Fatalf("ключи после транзакции %v, %v", keys(list), err)
+		}
+		if got, err := s.Get(ctx, "keep"); err != nil || string(got.Value) != `2` {
+			t.Fatalf("значение после транзакции: %+v, %v", got, err)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/realip"
+)
+
+// Access - Запись access лога об обработанном запросе. Поля доступны в шаблоне log.access_template,
+// например {{.IP}} {{.Method}} {{.URI}} {{.Status}}
+type Access struct {
+	Time      time.Time     `json:"time"`       // Начало обработки запроса
+	ID        string        `json:"id"`         // Идентификатор запроса
+	Method    string        `json:"method"`     // HTTP метод
+	Host      string        `json:"host"`       // Заголовок Host запроса
+	IP        string        `json:"ip"`         // IP адрес клиента, без порта
+	User      string        `json:"user"`       // Аутентифицированный клиент или "-"
+	Path      string        `json:"path"`       // Путь запроса
+	URI       string        `json:"uri"`        // Путь вместе с параметрами, как в строке запроса
+	Proto     string        `json:"proto"`      // Версия протокола, например HTTP/1.1
+	Status    int           `json:"status"`     // Статус код ответа
+	Size      int64         `json:"size"`       // Размер тела ответа в байтах
+	Duration  time.Duration `json:"-"`          // Время обработки запроса
+	Referer   string        `json:"referer"`    // Заголовок Referer
+	UserAgent string        `json:"user_agent"` // Заголовок User-Agent
+}
+
+// NewAccess - Запись access лога о запросе r, обработка которого началась в момент start.
+// Поля ответа (Status, Size, Duration) и User заполняет вызывающий
+func NewAccess(r *http.Request, start time.Time) Access {
+	return Access{
+		Time:      start,
+		Method:    r.Method,
+		Host:      r.Host,
+		IP:        realip.From(r),
+		User:      "-",
+		Path:      r.URL.Path,
+		URI:       r.URL.RequestURI(),
+		Proto:     r.Proto,
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+	}
+}
+
+// clfTime - Формат времени в Common Log Format
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLog - Запись access лога в формате из настройки log.access_format
+type AccessLog struct {
+	format string
+	tmpl   *template.Template
+
+	mu  sync.Mutex // Строки разных запросов не перемешиваются
+	out io.Writer
+}
+
+// NewAccessLog - Access лог по настройкам cfg. В формате text записи идут в логгер по умолчанию (slog.Default),
+// в остальных - строками в log.output
+func NewAccessLog(cfg config.Log) (*AccessLog, error) {
+	out, err := Writer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &AccessLog{format: cfg.AccessFormat, out: out}
+	if l.format == "template" {
+		if l.tmpl, err = template.New("access").Parse(cfg.AccessTemplate); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Log - Запись e в access лог
+func (l *AccessLog) Log(e Access) {
+	var buf bytes.Buffer
+	switch l.format {
+	case "", "text":
+		slog.Info("access",
+			"id", e.ID,
+			"method", e.Method,
+			"ip", e.IP,
+			"user", e.User,
+			"url", e.Path,
+			"status", e.Status,
+			"size", e.Size,
+			"duration", e.Duration,
+		)
+		return
+	case "common", "combined":
+		writeCLF(&buf, e, l.format == "combined")
+	case "json":
+		json.NewEncoder(&buf).Encode(struct {
+			Access
+			DurationMS float64 `json:"duration_ms"`
+		}{e, float64(e.Duration) / float64(time.Millisecond)})
+	case "template":
+		if err := l.tmpl.Execute(&buf, e); err != nil {
+			slog.Error("access log: template", "error", err)
+			return
+		}
+		buf.WriteByte('\n')
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(buf.Bytes())
+}
+
+// writeCLF - Строка Common Log Format, в формате Combined - с заголовками Referer и User-Agent:
+//
+//	127.0.0.1 - alice [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326 "http://ref/" "curl/8.0"
+func writeCLF(buf *bytes.Buffer, e Access, combined bool) {
+	size := "-"
+	if e.Size > 0 {
+		size = strconv.FormatInt(e.Size, 10)
+	}
+
+	buf.WriteString(clfField(e.IP) + " - " + clfField(e.User) + " [" + e.Time.Format(clfTime) + "] ")
+	buf.WriteString(strconv.Quote(e.Method+" "+e.URI+" "+e.Proto) + " " + strconv.Itoa(e.Status) + " " + size)
+	if combined {
+		buf.WriteString(" " + clfQuote(e.Referer) + " " + clfQuote(e.UserAgent))
+	}
+	buf.WriteByte('\n')
+}
+
+// clfField - Значение поля без кавычек: пустое заменяется на "-", пробелы - на "_", чтобы не сдвигать поля строки
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// clfQuote - Значение поля в кавычках, пустое - "-"
+func clfQuote(s string) string {
+	if s == "" {
+		s = "-"
+	}
+	return strconv.Quote(s)
+}
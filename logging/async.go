@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// flushTimeout - Сколько Flush ждет записи очередей при остановке сервера
+const flushTimeout = 5 * time.Second
+
+// asyncWriter - Запись в out через очередь: Write только копирует запись в очередь, а в out ее пишет
+// отдельная горутина. Записи в обычные назначения (stderr, файл) буферизуются и сбрасываются, как только очередь пуста
+type asyncWriter struct {
+	queue   chan asyncEntry
+	block   atomic.Bool   // Policy: block - ждать места в очереди, иначе отбросить запись
+	dropped atomic.Uint64 // Отброшено записей с момента последнего предупреждения
+
+	out  io.Writer
+	sink Sink // out, если он принимает записи с уровнем (syslog, journald)
+}
+
+// asyncEntry - Запись в очереди или, если задан flushed, запрос на сброс буфера
+type asyncEntry struct {
+	p       []byte
+	level   slog.Level
+	leveled bool
+	flushed chan struct{}
+}
+
+// asyncSink - asyncWriter над Sink, сохраняющий уровень записей
+type asyncSink struct {
+	*asyncWriter
+}
+
+// asyncs - Очереди по назначению. Логгеры, созданные заново при перечитывании конфигурации, пишут в ту же очередь,
+// поэтому порядок записей сохраняется, а горутина записи одна на назначение
+var asyncs = struct {
+	sync.Mutex
+	byOut map[io.Writer]*asyncWriter
+}{byOut: make(map[io.Writer]*asyncWriter)}
+
+// newAsync - Асинхронная запись в out по настройкам cfg. Для уже используемого out возвращается та же очередь
+// с новой политикой заполнения
+func newAsync(out io.Writer, cfg config.AsyncLog) io.Writer {
+	asyncs.Lock()
+	defer asyncs.Unlock()
+
+	a, ok := asyncs.byOut[out]
+	if !ok {
+		a = &asyncWriter{queue: make(chan asyncEntry, cfg.QueueSize), out: out}
+		a.sink, _ = out.(Sink)
+		asyncs.byOut[out] = a
+		go a.run()
+	}
+	a.block.Store(cfg.Policy == "block")
+
+	if a.sink != nil {
+		return asyncSink{a}
+	}
+	return a
+}
+
+// Write - Постановка копии p в очередь. При policy: drop и заполненной очереди запись отбрасывается
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	a.enqueue(asyncEntry{p: append([]byte(nil), p...)})
+	return len(p), nil
+}
+
+// WriteLevel - Постановка в очередь записи с уровнем level
+func (s asyncSink) WriteLevel(level slog.Level, p []byte) error {
+	s.enqueue(asyncEntry{p: append([]byte(nil), p...), level: level, leveled: true})
+	return nil
+}
+
+func (a *asyncWriter) enqueue(e asyncEntry) {
+	if a.block.Load() {
+		a.queue <- e
+		return
+	}
+
+	select {
+	case a.queue <- e:
+	default:
+		a.dropped.Add(1)
+	}
+}
+
+// run - Запись из очереди в out
+func (a *asyncWriter) run() {
+	var bw *bufio.Writer
+	if a.sink == nil {
+		bw = bufio.NewWriterSize(a.out, 64<<10)
+	}
+
+	for e := range a.queue {
+		switch {
+		case e.flushed != nil:
+			if bw != nil {
+				bw.Flush()
+			}
+			close(e.flushed)
+			continue
+		case e.leveled:
+			a.sink.WriteLevel(e.level, e.p)
+		case bw != nil:
+			bw.Write(e.p)
+		default:
+			a.out.Write(e.p)
+		}
+
+		if len(a.queue) > 0 {
+			continue
+		}
+		// Очередь пуста - накопленное отправляется сразу, записи не задерживаются дольше необходимого
+		if bw != nil {
+			bw.Flush()
+		}
+		if n := a.dropped.Swap(0); n > 0 {
+			slog.Warn("logging: queue is full, records dropped", "count", n)
+		}
+	}
+}
+
+// flush - Ожидание записи всего, что уже стоит в очереди, но не дольше, чем до закрытия deadline
+func (a *asyncWriter) flush(deadline <-chan struct{}) {
+	done := make(chan struct{})
+	select {
+	case a.queue <- asyncEntry{flushed: done}:
+	case <-deadline:
+		return
+	}
+
+	select {
+	case <-done:
+	case <-deadline:
+	}
+}
+
+// Flush - Запись всех очередей асинхронного логирования перед завершением процесса, но не дольше flushTimeout
+func Flush() {
+	asyncs.Lock()
+	list := make([]*asyncWriter, 0, len(asyncs.byOut))
+	for _, a := range asyncs.byOut {
+		list = append(list, a)
+	}
+	asyncs.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	for _, a := range list {
+		a.flush(ctx.Done())
+	}
+}
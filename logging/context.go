@@ -0,0 +1,23 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// loggerKey - Ключ контекста для логгера запроса
+type loggerKey struct{}
+
+// With - Контекст с логгером l, который вернет From
+func With(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// From - Логгер из контекста ctx. Для запроса, прошедшего через middleware.RequestLogger, записи уже содержат
+// идентификатор запроса, метод, путь и IP адрес клиента. Без логгера в контексте возвращается slog.Default()
+func From(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,173 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// backupTime - Время ротации в имени архивного файла: server-20261014T170102.000.log
+const backupTime = "20060102T150405.000"
+
+// File - Файл логов с ротацией по размеру и по времени (см. config.LogFile). Безопасен для использования
+// из нескольких горутин. Архивный файл получает имя с временем ротации рядом с текущим
+type File struct {
+	mu     sync.Mutex
+	cfg    config.LogFile
+	f      *os.File
+	size   int64     // Размер текущего файла
+	opened time.Time // Когда открыт текущий файл, от этого момента отсчитывается rotate_every
+}
+
+// files - Открытые файлы логов по пути. Логгеры, созданные заново при перечитывании конфигурации,
+// пишут в тот же File, поэтому файл не открывается повторно и ротация не выполняется дважды
+var files = struct {
+	sync.Mutex
+	byPath map[string]*File
+}{byPath: make(map[string]*File)}
+
+// OpenFile - Файл логов по настройкам cfg. Для уже открытого пути возвращается тот же File с новыми настройками ротации
+func OpenFile(cfg config.LogFile) (*File, error) {
+	path, err := filepath.Abs(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Path = path
+
+	files.Lock()
+	defer files.Unlock()
+
+	if f, ok := files.byPath[path]; ok {
+		f.mu.Lock()
+		f.cfg = cfg
+		f.mu.Unlock()
+		return f, nil
+	}
+
+	f := &File{cfg: cfg}
+	if err = f.open(); err != nil {
+		return nil, err
+	}
+	files.byPath[path] = f
+	return f, nil
+}
+
+// Write - Запись p в текущий файл. Перед записью файл ротируется, если превышен размер или истек интервал
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.due(len(p)) {
+		if err := f.rotate(); err != nil {
+			// Ошибка ротации не теряет записи: они продолжают идти в прежний файл,
+			// а следующая попытка будет через тот же размер или интервал, а не при каждой записи
+			fmt.Fprintf(os.Stderr, "logging: rotate %s: %v\n", f.cfg.Path, err)
+			f.size, f.opened = 0, time.Now()
+		}
+	}
+
+	n, err := f.f.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close - Закрытие текущего файла
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.Close()
+}
+
+// due - Пора ли ротировать файл перед записью n байт. Пустой файл по размеру не ротируется,
+// иначе запись больше max_bytes создавала бы пустые архивы
+func (f *File) due(n int) bool {
+	if f.cfg.MaxBytes > 0 && f.size > 0 && f.size+int64(n) > f.cfg.MaxBytes {
+		return true
+	}
+	return f.cfg.RotateEvery > 0 && time.Since(f.opened) >= f.cfg.RotateEvery.D()
+}
+
+// open - Открытие текущего файла на дозапись
+func (f *File) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.cfg.Path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.cfg.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.f, f.size, f.opened = file, info.Size(), time.Now()
+	return nil
+}
+
+// rotate - Переименование текущего файла в архивный, открытие нового и удаление лишних архивов
+func (f *File) rotate() error {
+	ext := filepath.Ext(f.cfg.Path)
+	backup := strings.TrimSuffix(f.cfg.Path, ext) + "-" + time.Now().Format(backupTime) + ext
+
+	if err := os.Rename(f.cfg.Path, backup); err != nil {
+		return err
+	}
+
+	old := f.f
+	if err := f.open(); err != nil {
+		// Записи продолжают идти в прежний, уже переименованный файл
+		return err
+	}
+	old.Close()
+
+	// Удаление архивов не задерживает запись
+	go prune(f.cfg)
+	return nil
+}
+
+// prune - Удаление архивных файлов сверх max_backups и старше max_age
+func prune(cfg config.LogFile) {
+	if cfg.MaxBackups == 0 && cfg.MaxAge == 0 {
+		return
+	}
+
+	ext := filepath.Ext(cfg.Path)
+	prefix := strings.TrimSuffix(cfg.Path, ext) + "-"
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return
+	}
+
+	// Только архивы этого файла: server-old.log, подходящий под шаблон, не удаляется
+	var backups []string
+	for _, name := range matches {
+		if _, err := time.Parse(backupTime, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)); err == nil {
+			backups = append(backups, name)
+		}
+	}
+
+	// Время в имени упорядочивает архивы: новые в конце
+	slices.Sort(backups)
+	for i, name := range backups {
+		remove := cfg.MaxBackups > 0 && i < len(backups)-cfg.MaxBackups
+		if !remove && cfg.MaxAge > 0 {
+			if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > cfg.MaxAge.D() {
+				remove = true
+			}
+		}
+		if remove {
+			if err := os.Remove(name); err != nil {
+				fmt.Fprintf(os.Stderr, "logging: remove %s: %v\n", name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log/slog"
+	"net"
+	"strconv"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// journalSocket - Сокет systemd-journald для записей в собственном протоколе journald
+const journalSocket = "/run/systemd/journal/socket"
+
+// journald - Отправка записей в журнал systemd. Уровень записи передается в поле PRIORITY,
+// имя программы - в SYSLOG_IDENTIFIER, поэтому записи можно отбирать journalctl -t go-web-server -p warning
+type journald struct {
+	conn *net.UnixConn
+	tag  string
+}
+
+// newJournald - Подключение к journald. Tag из cfg - имя программы в журнале
+func newJournald(cfg config.Syslog) (Sink, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &journald{conn: conn, tag: cfg.Tag}, nil
+}
+
+func (j *journald) Write(p []byte) (int, error) {
+	if err := j.WriteLevel(slog.LevelInfo, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteLevel - Запись с приоритетом syslog по уровню level: 3 - err, 4 - warning, 6 - info, 7 - debug
+func (j *journald) WriteLevel(level slog.Level, p []byte) error {
+	priority := 7
+	switch {
+	case level >= slog.LevelError:
+		priority = 3
+	case level >= slog.LevelWarn:
+		priority = 4
+	case level >= slog.LevelInfo:
+		priority = 6
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("PRIORITY=" + strconv.Itoa(priority) + "\n")
+	buf.WriteString("SYSLOG_IDENTIFIER=" + j.tag + "\n")
+
+	// Сообщение передается с длиной перед значением: так в нем допустимы переводы строк, например в стеке паники
+	msg := trimLine(p)
+	buf.WriteString("MESSAGE\n")
+	binary.Write(&buf, binary.LittleEndian, uint64(len(msg)))
+	buf.Write(msg)
+	buf.WriteByte('\n')
+
+	_, err := j.conn.Write(buf.Bytes())
+	return err
+}
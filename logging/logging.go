@@ -0,0 +1,77 @@
+// Package logging - Структурированные логи сервера на основе log/slog
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// New - Логгер по настройкам cfg: записи ниже уровня log.level отбрасываются. Строки лога в формате key=value,
+// например time=... level=INFO msg=access id=... method=GET, или при log.format: json - объекты JSON:
+// {"time":"...","level":"INFO","msg":"access","id":"...","method":"GET"}.
+// В syslog и journald уровень записи передается как приоритет сообщения
+func New(cfg config.Log) (*slog.Logger, error) {
+	out, err := Writer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	level, err := cfg.SlogLevel()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	newHandler := func(w io.Writer) slog.Handler {
+		if cfg.Format == "json" {
+			return slog.NewJSONHandler(w, opts)
+		}
+		return slog.NewTextHandler(w, opts)
+	}
+
+	sink, ok := out.(Sink)
+	if !ok {
+		return slog.New(newHandler(out)), nil
+	}
+
+	// Время записи добавляют сами syslog и journald
+	opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}
+	return slog.New(newLevelHandler(sink, newHandler)), nil
+}
+
+// Writer - Назначение для логов, указанное в настройках cfg: stderr, stdout, файл с ротацией, syslog или journald.
+// При log.async.enabled запись выполняется через очередь, см. Flush
+func Writer(cfg config.Log) (io.Writer, error) {
+	var (
+		out io.Writer
+		err error
+	)
+	switch cfg.Output {
+	case "", "stderr":
+		out = os.Stderr
+	case "stdout":
+		out = os.Stdout
+	case "file":
+		out, err = OpenFile(cfg.File)
+	case "syslog", "journald":
+		out, err = openSink(cfg)
+	default:
+		err = fmt.Errorf("log.output: неизвестное значение %q: ожидается stderr, stdout, file, syslog или journald", cfg.Output)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Async.Enabled {
+		out = newAsync(out, cfg.Async)
+	}
+	return out, nil
+}
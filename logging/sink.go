@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// Sink - Назначение логов, которое принимает записи вместе с уровнем: syslog или journald.
+// Write отправляет запись с уровнем info, например строку access лога
+type Sink interface {
+	io.Writer
+	WriteLevel(level slog.Level, p []byte) error
+}
+
+// sinks - Открытые соединения с syslog и journald. Логгеры, созданные заново при перечитывании конфигурации
+// с теми же настройками, пишут в то же соединение
+var sinks = struct {
+	sync.Mutex
+	byConfig map[sinkKey]Sink
+}{byConfig: make(map[sinkKey]Sink)}
+
+type sinkKey struct {
+	output string
+	cfg    config.Syslog
+}
+
+// openSink - Соединение с syslog или journald по настройкам cfg
+func openSink(cfg config.Log) (Sink, error) {
+	sinks.Lock()
+	defer sinks.Unlock()
+
+	key := sinkKey{output: cfg.Output, cfg: cfg.Syslog}
+	if s, ok := sinks.byConfig[key]; ok {
+		return s, nil
+	}
+
+	var (
+		s   Sink
+		err error
+	)
+	if cfg.Output == "journald" {
+		s, err = newJournald(cfg.Syslog)
+	} else {
+		s, err = newSyslog(cfg.Syslog)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sinks.byConfig[key] = s
+	return s, nil
+}
+
+// levelHandler - slog.Handler, передающий в Sink уровень каждой записи.
+// Запись форматирует вложенный обработчик, а его вывод приходит в out с уровнем текущей записи
+type levelHandler struct {
+	slog.Handler
+	out *levelWriter
+}
+
+// levelWriter - Вывод вложенного обработчика levelHandler
+type levelWriter struct {
+	mu    sync.Mutex // Уровень относится к записи, которая форматируется сейчас
+	sink  Sink
+	level slog.Level
+}
+
+// newLevelHandler - Обработчик для sink: newHandler создает форматирующий обработчик, который пишет в переданный io.Writer
+func newLevelHandler(sink Sink, newHandler func(io.Writer) slog.Handler) slog.Handler {
+	out := &levelWriter{sink: sink}
+	return &levelHandler{Handler: newHandler(out), out: out}
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+
+	h.out.level = r.Level
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), out: h.out}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), out: h.out}
+}
+
+// Write - Встроенные обработчики slog записывают каждую запись одним вызовом Write
+func (w *levelWriter) Write(p []byte) (int, error) {
+	if err := w.sink.WriteLevel(w.level, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// trimLine - Запись без перевода строки в конце: в syslog и journald каждая запись - отдельное сообщение
+func trimLine(p []byte) []byte {
+	return bytes.TrimSuffix(p, []byte("\n"))
+}
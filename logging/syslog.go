@@ -0,0 +1,55 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"log/slog"
+	"log/syslog"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// facilities - Источники записей syslog по названию из log.syslog.facility
+var facilities = map[string]syslog.Priority{
+	"kern": syslog.LOG_KERN, "user": syslog.LOG_USER, "mail": syslog.LOG_MAIL, "daemon": syslog.LOG_DAEMON,
+	"auth": syslog.LOG_AUTH, "syslog": syslog.LOG_SYSLOG, "lpr": syslog.LOG_LPR, "news": syslog.LOG_NEWS,
+	"uucp": syslog.LOG_UUCP, "cron": syslog.LOG_CRON, "authpriv": syslog.LOG_AUTHPRIV, "ftp": syslog.LOG_FTP,
+	"local0": syslog.LOG_LOCAL0, "local1": syslog.LOG_LOCAL1, "local2": syslog.LOG_LOCAL2, "local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4, "local5": syslog.LOG_LOCAL5, "local6": syslog.LOG_LOCAL6, "local7": syslog.LOG_LOCAL7,
+}
+
+// syslogSink - Отправка записей в syslog. После разрыва соединения syslog.Writer подключается заново
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// newSyslog - Подключение к syslog по настройкам cfg
+func newSyslog(cfg config.Syslog) (Sink, error) {
+	w, err := syslog.Dial(cfg.Network, cfg.Address, facilities[cfg.Facility]|syslog.LOG_INFO, cfg.Tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Write(p []byte) (int, error) {
+	if err := s.WriteLevel(slog.LevelInfo, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteLevel - Запись с приоритетом syslog по уровню level
+func (s *syslogSink) WriteLevel(level slog.Level, p []byte) error {
+	msg := string(trimLine(p))
+	switch {
+	case level >= slog.LevelError:
+		return s.w.Err(msg)
+	case level >= slog.LevelWarn:
+		return s.w.Warning(msg)
+	case level >= slog.LevelInfo:
+		return s.w.Info(msg)
+	default:
+		return s.w.Debug(msg)
+	}
+}
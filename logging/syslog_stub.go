@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package logging
+
+import (
+	"errors"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// newSyslog - Заглушка для платформ без log/syslog: output: syslog приводит к ошибке запуска
+func newSyslog(config.Syslog) (Sink, error) {
+	return nil, errors.New("log.output: syslog не поддерживается на этой платформе")
+}
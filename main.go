@@ -1,122 +1,408 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/derv-dice/go-web-server/audit"
+	"github.com/derv-dice/go-web-server/auth"
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/errreport"
+	"github.com/derv-dice/go-web-server/i18n"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/metrics"
+	"github.com/derv-dice/go-web-server/middleware"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+	"github.com/derv-dice/go-web-server/server"
+	"github.com/derv-dice/go-web-server/tracing"
 )
 
-const helloMsgTmpl = `Hello, from service. Today is %s`
+// serverMetrics - Метрики сервера, отдаются в формате Prometheus по GET /metrics
+var serverMetrics = metrics.NewRegistry()
+
+// maxNameLen - Максимальная длина имени в приветствии, в символах
+const maxNameLen = 64
 
-// helloHandler - Обработчик метода GET /hello
+// helloHandler - Обработчик методов GET /v1/hello и GET /v1/hello/{name}.
+// Имя берется из пути или параметра ?name=, без имени возвращается общее приветствие.
+// Язык приветствия выбирается по параметру ?lang= или заголовку Accept-Language
 func helloHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("hello handler")
-	var err error
-	var status int
-	var data []byte
-
-	// Этот код выполнится в конце функции
-	defer func() {
-		// Если перед завершением функции переменная var содержит ошибку, то клиенту вернется текст ошибки
-		if err != nil {
-			data, err = json.Marshal(response{Error: err.Error()})
-			if err != nil {
-				status = http.StatusInternalServerError
-			}
+	logging.From(r.Context()).Debug("hello handler")
 
-			w.Write(data)
-			return
-		}
+	// Имя из пути имеет приоритет над параметром запроса
+	name := router.Param(r, "name")
+	if name == "" {
+		name = r.URL.Query().Get("name")
+	}
+	name, err := cleanName(name)
+	if err != nil {
+		response.Respond(w, r, http.StatusBadRequest, response.Body{Error: err.Error()})
+		return
+	}
 
-		// Если перед завершением функции переменная var не содержит ошибку, то клиенту вернутся данные из data
-		w.WriteHeader(http.StatusOK)
-		w.Write(data)
-	}()
+	lang := i18n.FromRequest(r)
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
 
-	// Обрабатываем только метод GET
-	switch r.Method {
-	case http.MethodGet:
-		// Вычисляем текущее время и подставляем его в форматированную строку helloMsgTmpl
-		currentTime := time.Now().Format(time.RFC1123Z)
+	// Вычисляем текущее время и подставляем его в приветствие на языке клиента
+	currentTime := time.Now().Format(time.RFC1123Z)
+	msg := i18n.T(lang, "hello", currentTime)
+	if name != "" {
+		msg = i18n.T(lang, "hello.name", name, currentTime)
+	}
 
-		// Сериализация данных из структуры response в массив байт data
-		data, err = json.Marshal(response{Data: fmt.Sprintf(helloMsgTmpl, currentTime)})
-		if err != nil {
-			status = http.StatusInternalServerError
-			return
+	// Ответ в формате, который клиент указал в заголовке Accept (по умолчанию JSON)
+	response.Respond(w, r, http.StatusOK, response.Body{Data: msg})
+}
+
+// cleanName - Имя для приветствия: без пробелов по краям, без управляющих и служебных символов.
+// Допускаются буквы, цифры, пробел, дефис, апостроф и точка. Имя длиннее maxNameLen символов - ошибка
+func cleanName(name string) (string, error) {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), unicode.IsMark(r):
+			return r
+		case r == ' ', r == '-', r == '\'', r == '.':
+			return r
+		case unicode.IsSpace(r):
+			return ' '
 		}
+		return -1
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
 
-	default:
-		err = fmt.Errorf("метод %q не поддерживается", r.Method)
-		status = http.StatusNotImplemented
-		return
+	if n := utf8.RuneCountInString(name); n > maxNameLen {
+		return "", fmt.Errorf("имя длиннее %d символов", maxNameLen)
 	}
+	return name, nil
 }
 
-// recovery - Middleware, предотвращающий остановку приложения в случае критической ошибки
-func recovery(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("panic middleware")
-
-		defer func() {
-			fmt.Println("panic middleware defer")
-			err := recover()
-			if err != nil {
-				// В случае непредвиденной критической ошибки - возвращается ответ с формате JSON заданной структуры
-				var data []byte
-				data, _ = json.Marshal(response{Error: fmt.Sprintf("%v", err)})
-				w.WriteHeader(http.StatusInternalServerError) // Важно сначала передать заголовок с статус кодом
-				w.Write(data)                                 // А уже после заголовков передается тело ответа
-
-				// Логирование факта ошибки
-				log.Printf("panic: {method: %s, ip: %s, url: %s}",
-					r.Method,     // HTTP метод
-					r.RemoteAddr, // IP адрес отправителя запроса
-					r.URL.Path,   // URL метода, на который был отправлен запрос
-				)
-			}
-		}()
+// methodNotAllowed - Ответ на запрос к существующему маршруту с неподдерживаемым методом.
+// Заголовок Allow с допустимыми методами устанавливает маршрутизатор
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	response.Error(w, http.StatusMethodNotAllowed, fmt.Sprintf("метод %q не поддерживается", r.Method))
+}
 
-		next.ServeHTTP(w, r)
-	})
+// notFound - Ответ на запрос, для которого не найден маршрут
+func notFound(w http.ResponseWriter, r *http.Request) {
+	response.Error(w, http.StatusNotFound, "not found")
 }
 
 // accessLog - Middleware, логирующий все входящие запросы
-func accessLog(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("access_log middleware")
-
-		start := time.Now()  // Засекается момент времени, когда непосредственно началась обработка запроса
-		next.ServeHTTP(w, r) // Обработка запроса
-
-		log.Printf("access_log: {method: %s, ip: %s, url: %s, time: %s}",
-			r.Method,          // HTTP метод
-			r.RemoteAddr,      // IP адрес отправителя запроса
-			r.URL.Path,        // URL метода, на который был отправлен запрос
-			time.Since(start), // Записывается время, прошедшее с момента начала обработки
-		)
+//
+// Логирование включается и выключается настройкой log.access, в том числе без перезапуска при перечитывании конфигурации.
+// Формат записей задает log.access_format
+func accessLog(store *config.Store) router.Middleware {
+	// Ошибка здесь невозможна: настройки уже проверены при загрузке конфигурации
+	var access atomic.Pointer[logging.AccessLog]
+	l, _ := logging.NewAccessLog(store.Current().Log)
+	access.Store(l)
+	store.OnReload(func(_, cur *config.Config) {
+		l, _ := logging.NewAccessLog(cur.Log)
+		access.Store(l)
 	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !store.Current().Log.Access {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logging.From(r.Context()).Debug("access_log middleware")
+
+			r, identity := auth.Track(r) // Аутентификация выполняется в middleware маршрута, уже после этого
+
+			sw := middleware.NewStatusWriter(w) // Статус код и размер ответа для записи в лог
+			start := time.Now()                 // Засекается момент времени, когда непосредственно началась обработка запроса
+			next.ServeHTTP(sw, r)               // Обработка запроса
+
+			e := logging.NewAccess(r, start)
+			e.ID = middleware.RequestIDFrom(r.Context()) // Идентификатор запроса
+			if id, ok := identity(); ok {
+				e.User = id.Name // Аутентифицированный клиент
+			}
+			e.Status, e.Size = sw.Status(), sw.Size()
+			e.Duration = time.Since(start) // Записывается время, прошедшее с момента начала обработки
+
+			access.Load().Log(e)
+		})
+	}
+}
+
+// requireFeature - Middleware, пропускающий запрос к маршруту, только пока включен переключатель из секции
+// features конфигурации. Если переключатель выключен, клиент получает 404, как если бы маршрута не было
+func requireFeature(store *config.Store, enabled func(config.Features) bool) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !enabled(store.Current().Features) {
+				notFound(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
-// response - структура, описывающая общий ответ сервера на запросы
-type response struct {
-	Data  string `json:"data,omitempty"`
-	Error string `json:"error,omitempty"`
+// fatal - Запись ошибки err, из-за которой сервер не может работать, и завершение процесса
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	logging.Flush()
+	os.Exit(1)
+}
+
+// reloadOnSIGHUP - Перечитывание конфигурации при получении SIGHUP, пока не отменен контекст ctx
+func reloadOnSIGHUP(ctx context.Context, store *config.Store) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := store.Reload(); err != nil {
+				// Ошибка в новой конфигурации не останавливает сервер: продолжает действовать прежняя
+				slog.Error("config: reload", "error", err)
+				continue
+			}
+			slog.Info("config: reloaded")
+		}
+	}
 }
 
 func main() {
-	// Создание пустой серверной шины
-	mux := http.NewServeMux()
+	// Чтение конфигурации из файла, переменных окружения и флагов командной строки
+	cfg, err := config.FromEnvironment()
+	if err != nil {
+		fatal("config", err)
+	}
 
-	// регистрация обработчика по адресу /hello
-	mux.HandleFunc("/hello", helloHandler)
+	// Конфигурация перечитывается по SIGHUP, обработчики и middleware читают ее текущий снимок из store
+	store := config.NewStore(cfg, config.FromEnvironment)
 
-	// Добавление middleware
-	handler := accessLog(mux)
-	handler = recovery(handler)
+	// Ошибка здесь невозможна: значение уже проверено при загрузке конфигурации.
+	// Логгер по умолчанию используют и вызовы пакета log, в том числе внутри net/http
+	logger, _ := logging.New(cfg.Log)
+	slog.SetDefault(logger)
+	store.OnReload(func(_, cur *config.Config) {
+		logger, _ := logging.New(cur.Log)
+		slog.SetDefault(logger)
+	})
 
-	// запуск сервера по адресу localhost:8080 с собранным обработчиком
-	log.Fatal(http.ListenAndServe(":8080", handler))
-}
\ No newline at end of file
+	// С флагом -migrate сервер только выполняет миграции схемы хранилищ и завершается
+	if cfg.Migrations.Command != "" {
+		if err := runMigrations(cfg); err != nil {
+			fatal("migrate", err)
+		}
+		logging.Flush()
+		return
+	}
+
+	// Отправка трассировок по OTLP. Настройки tracing читаются только при запуске
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("tracing", err)
+	}
+
+	// Отправка паник и внутренних ошибок во внешний сервис. Настройки error_reporting читаются только при запуске
+	reporter, flushReports, err := errreport.Setup(cfg.ErrorReport, currentBuild.Version)
+	if err != nil {
+		fatal("error_reporting", err)
+	}
+	errreport.SetDefault(reporter)
+
+	// Журнал действий клиентов, отдельный от логов сервера
+	var auditLog *audit.Log
+	if cfg.Audit.Enabled {
+		if auditLog, err = audit.Open(cfg.Audit.Path, cfg.Audit.Key); err != nil {
+			fatal("audit", err)
+		}
+		defer auditLog.Close()
+	}
+
+	// Счетчики неудачных попыток входа и использованные X-Nonce общие для маршрутов всех виртуальных хостов
+	logins = newLoginThrottle(store)
+	nonces = newNonces(store)
+
+	// Общее для экземпляров сервера состояние: корзины rate_limit, сессии и кэш ответов
+	shared, err := openRedis(cfg.Redis)
+	if err != nil {
+		fatal("redis", err)
+	}
+	if shared != nil {
+		defer shared.Close()
+	}
+
+	// Очередь фоновых задач для обработчиков всех виртуальных хостов, воркеры запускаются вместе с сервером
+	queue = newQueue(cfg.Jobs)
+
+	// Тяжелые по CPU обработчики, например хэширование паролей, занимают не больше work_pool.size процессоров
+	workPool = newWorkPool(cfg.WorkPool)
+
+	// Хранилище значений kv открывается один раз для маршрутов всех виртуальных хостов
+	values, closeValues, err := openKV(cfg.KV, cfg.Migrations)
+	if err != nil {
+		fatal("kv", err)
+	}
+	defer closeValues()
+	keyValues = values
+
+	// События изменений ключей записываются вместе с изменениями и доставляются после фиксации
+	dispatcher := newDispatcher(cfg, values)
+
+	// Сборка маршрутизаторов по настройкам router, в том числе для виртуальных хостов
+	mux, err := newHandler(cfg.Router, store)
+	if err != nil {
+		fatal("router", err)
+	}
+
+	sessionStore, err := newSessionStore(cfg.Session, shared)
+	if err != nil {
+		fatal("session", err)
+	}
+	sessions := newSessions(cfg.Session, sessionStore)
+
+	// Кэш ответов cache.responses, его записи удаляются и через служебный адрес
+	responseCache := newResponseCache(cfg.Cache.Responses, shared)
+
+	// Добавление middleware в порядке выполнения:
+	//   - RequestID первым назначает запросу идентификатор для логов;
+	//   - RealIP определяет адрес клиента за доверенным прокси для всех следующих;
+	//   - RequestLogger сохраняет в контексте логгер запроса с этим идентификатором;
+	//   - Middleware трассировки создает span и добавляет trace_id в логгер запроса;
+	//   - ResponseOptions задает формат ответов, в том числе об ошибках в следующих middleware;
+	//   - Recovery перехватывает панику в любом из следующих обработчиков;
+	//   - Metrics учитывает все запросы, в том числе отклоненные следующими middleware;
+	//   - accessLog записывает в журнал доступа каждый запрос с его статусом;
+	//   - SlowLog предупреждает о запросах, обработка которых заняла слишком много времени;
+	//   - Audit после обработки видит клиента, которого аутентифицировали следующие middleware;
+	//   - ClientCert сохраняет сертификат клиента mTLS как аутентифицированного клиента;
+	//   - IPFilter отклоняет запросы с запрещенных адресов;
+	//   - LoadShedding отклоняет лишние запросы до обращения к хранилищу лимитов RateLimit;
+	//   - RateLimit ограничивает частоту запросов с одного адреса;
+	//   - RequestTimeout ограничивает время обработки всех следующих;
+	//   - BodyLog записывает тела запросов и ответов;
+	//   - BodyLimit ограничивает размер тела запроса;
+	//   - Compress сжимает готовый ответ;
+	//   - ETag отвечает 304 на условные запросы по хэшу несжатого ответа;
+	//   - CacheControl задает заголовки кэширования по политике пути;
+	//   - CacheResponses внутри Compress и ETag хранит несжатые ответы без ETag, а до сессий не видит их cookie;
+	//   - сессии загружаются ближе всего к маршрутам;
+	//   - CORS отвечает на предварительные запросы браузера
+	handler := router.Chain(
+		middleware.RequestID,
+		middleware.RealIP(store),
+		middleware.RequestLogger,
+		tracing.Middleware,
+		middleware.ResponseOptions(store),
+		middleware.Recovery(store),
+		middleware.Metrics(serverMetrics),
+		accessLog(store),
+		middleware.SlowLog(store),
+		middleware.Audit(store, auditLog),
+		auth.ClientCert,
+		middleware.IPFilter(store),
+		middleware.LoadShedding(store, serverMetrics),
+		middleware.RateLimit(store, newRateLimiter(cfg.RateLimit, shared)),
+		middleware.RequestTimeout(store),
+		middleware.BodyLog(store),
+		middleware.BodyLimit(store),
+		middleware.Compress(store),
+		middleware.ETag(store),
+		middleware.CacheControl(store),
+		middleware.CacheResponses(store, responseCache),
+		sessions,
+		middleware.CORS(store),
+	)(mux)
+
+	// Контекст отменяется при получении SIGINT или SIGTERM, после чего сервер завершает активные запросы и останавливается
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go reloadOnSIGHUP(ctx, store)
+
+	// Сервер готов принимать трафик после сборки всех обработчиков и до начала остановки
+	readiness.ready.Store(true)
+	context.AfterFunc(ctx, func() { readiness.ready.Store(false) })
+
+	// Задачи по расписанию выполняют запросы через тот же обработчик, что и запросы клиентов
+	scheduler, err := newScheduler(cfg.Scheduler, sessionStore, handler)
+	if err == nil && cfg.KV.Enabled {
+		err = scheduler.Add(config.TaskKVCleanup, "@every "+cfg.KV.SweepInterval.D().String(), kvCleanup(values))
+	}
+	if err != nil {
+		fatal("scheduler", err)
+	}
+
+	// запуск сервера по настроенному адресу с собранным обработчиком
+	srv, err := server.New(cfg.Server, handler)
+	if err != nil {
+		fatal("server", err)
+	}
+
+	admin, err := newAdminServer(cfg, srv, auditLog, queue, responseCache)
+	if err != nil {
+		fatal("admin", err)
+	}
+	if admin != nil {
+		// Служебный адрес останавливается вместе с основным по отмене ctx, его ошибки не останавливают основной сервер
+		go func() {
+			if err := admin.Run(ctx); err != nil {
+				slog.Error("admin", "error", err)
+			}
+		}()
+	}
+
+	// Воркеры останавливаются по отмене ctx, начатые задачи дорабатывают после остановки сервера
+	jobsDone := make(chan struct{})
+	go func() {
+		queue.Run(ctx)
+		close(jobsDone)
+	}()
+
+	// Задачи по расписанию останавливаются вместе с сервером, начатые дорабатывают с отмененным ctx
+	scheduleDone := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(scheduleDone)
+	}()
+
+	// Недоставленные к остановке события остаются в базе и доставляются после перезапуска
+	outboxDone := make(chan struct{})
+	go func() {
+		if dispatcher != nil {
+			dispatcher.Run(ctx)
+		}
+		close(outboxDone)
+	}()
+
+	if err = srv.Run(ctx); err != nil {
+		fatal("server", err)
+	}
+	<-jobsDone
+	<-scheduleDone
+	<-outboxDone
+
+	// Отправка накопленных span и ошибок перед завершением процесса
+	tctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = shutdownTracing(tctx); err != nil {
+		slog.Error("tracing: shutdown", "error", err)
+	}
+	flushReports(5 * time.Second)
+	logging.Flush()
+}
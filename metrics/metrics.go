@@ -0,0 +1,328 @@
+// Package metrics - Метрики сервера в текстовом формате Prometheus (счетчики, измерители, гистограммы)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets - Границы корзин гистограммы длительности запросов в секундах
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector - Метрика, которую Registry выводит в ответе /metrics
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry - Набор метрик сервера
+type Registry struct {
+	mu      sync.Mutex
+	metrics []collector
+	names   map[string]bool
+}
+
+// NewRegistry - Создание пустого набора метрик
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// register - Добавление метрики в набор. Повторная регистрация имени - ошибка в коде сервера
+func (reg *Registry) register(name string, c collector) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if reg.names[name] {
+		panic(fmt.Sprintf("metrics: метрика %q уже зарегистрирована", name))
+	}
+	reg.names[name] = true
+	reg.metrics = append(reg.metrics, c)
+}
+
+// Handler - Обработчик, отдающий все метрики набора в текстовом формате Prometheus
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reg.mu.Lock()
+		metrics := slices.Clone(reg.metrics)
+		reg.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		bw := bufio.NewWriter(w)
+		for _, m := range metrics {
+			m.write(bw)
+		}
+		bw.Flush()
+	})
+}
+
+// family - Общая часть метрик с метками: имя, описание и значения по наборам меток
+type family[T any] struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*T // Ключ - значения меток, разделенные нулевым байтом
+	newT   func() *T
+}
+
+// with - Значение метрики для набора значений меток values, создается при первом обращении
+func (f *family[T]) with(values []string) *T {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s: ожидается %d значений меток, передано %d", f.name, len(f.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\x00")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		s = f.newT()
+		f.series[key] = s
+	}
+	return s
+}
+
+// each - Обход значений метрики в порядке значений меток, чтобы вывод был стабильным
+func (f *family[T]) each(w *bufio.Writer, fn func(labels string, s *T)) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	series := make([]*T, 0, len(keys))
+	slices.Sort(keys)
+	for _, k := range keys {
+		series = append(series, f.series[k])
+	}
+	f.mu.Unlock()
+
+	for i, k := range keys {
+		var values []string
+		if len(f.labels) > 0 {
+			values = strings.Split(k, "\x00")
+		}
+		fn(formatLabels(f.labels, values), series[i])
+	}
+}
+
+// value - Число с плавающей точкой, которое можно менять из нескольких горутин
+type value struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (v *value) add(d float64) {
+	v.mu.Lock()
+	v.v += d
+	v.mu.Unlock()
+}
+
+func (v *value) set(x float64) {
+	v.mu.Lock()
+	v.v = x
+	v.mu.Unlock()
+}
+
+func (v *value) get() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.v
+}
+
+// Counter - Счетчик, который может только увеличиваться
+type Counter struct {
+	f *family[value]
+}
+
+// NewCounter - Регистрация счетчика name с метками labels
+func (reg *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{f: newFamily[value](name, help, "counter", labels, func() *value { return &value{} })}
+	reg.register(name, c)
+	return c
+}
+
+// Inc - Увеличение счетчика на 1 для значений меток values
+func (c *Counter) Inc(values ...string) {
+	c.f.with(values).add(1)
+}
+
+// Add - Увеличение счетчика на d для значений меток values. Отрицательные d игнорируются
+func (c *Counter) Add(d float64, values ...string) {
+	if d < 0 {
+		return
+	}
+	c.f.with(values).add(d)
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.f.each(w, func(labels string, v *value) {
+		fmt.Fprintf(w, "%s%s %s\n", c.f.name, labels, formatFloat(v.get()))
+	})
+}
+
+// Gauge - Измеритель, значение которого может как увеличиваться, так и уменьшаться
+type Gauge struct {
+	f *family[value]
+}
+
+// NewGauge - Регистрация измерителя name с метками labels
+func (reg *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{f: newFamily[value](name, help, "gauge", labels, func() *value { return &value{} })}
+	reg.register(name, g)
+	return g
+}
+
+// Inc - Увеличение значения на 1
+func (g *Gauge) Inc(values ...string) { g.f.with(values).add(1) }
+
+// Dec - Уменьшение значения на 1
+func (g *Gauge) Dec(values ...string) { g.f.with(values).add(-1) }
+
+// Set - Установка значения x
+func (g *Gauge) Set(x float64, values ...string) { g.f.with(values).set(x) }
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.f.each(w, func(labels string, v *value) {
+		fmt.Fprintf(w, "%s%s %s\n", g.f.name, labels, formatFloat(v.get()))
+	})
+}
+
+// histogram - Значения одной гистограммы: количество наблюдений по корзинам, их сумма и общее количество
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64 // Количество наблюдений, попавших в корзину, без учета предыдущих корзин
+	sum    float64
+	count  uint64
+}
+
+// Histogram - Гистограмма распределения значений (например длительности запросов) по корзинам
+type Histogram struct {
+	f       *family[histogram]
+	buckets []float64
+}
+
+// NewHistogram - Регистрация гистограммы name с верхними границами корзин buckets и метками labels
+func (reg *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+
+	h := &Histogram{buckets: buckets}
+	h.f = newFamily[histogram](name, help, "histogram", labels, func() *histogram {
+		return &histogram{counts: make([]uint64, len(buckets))}
+	})
+	reg.register(name, h)
+	return h
+}
+
+// Observe - Добавление наблюдения x для значений меток values
+func (h *Histogram) Observe(x float64, values ...string) {
+	s := h.f.with(values)
+	i, _ := slices.BinarySearch(h.buckets, x)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.sum += x
+	s.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.f.each(w, func(labels string, s *histogram) {
+		s.mu.Lock()
+		counts, sum, count := slices.Clone(s.counts), s.sum, s.count
+		s.mu.Unlock()
+
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.f.name, withLabel(labels, "le", formatFloat(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.f.name, withLabel(labels, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.f.name, labels, formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.f.name, labels, count)
+	})
+}
+
+// newFamily - Создание семейства метрик с метками labels
+func newFamily[T any](name, help, kind string, labels []string, newT func() *T) *family[T] {
+	f := &family[T]{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*T),
+		newT:   newT,
+	}
+
+	// Метрика без меток выводится сразу, с нулевым значением
+	if len(labels) == 0 {
+		f.series[""] = newT()
+	}
+	return f
+}
+
+// formatLabels - Метки в формате {name="value",...}. Без меток - пустая строка
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withLabel - Добавление метки name к уже отформатированным меткам labels
+func withLabel(labels, name, value string) string {
+	l := name + `="` + value + `"`
+	if labels == "" {
+		return "{" + l + "}"
+	}
+	return labels[:len(labels)-1] + "," + l + "}"
+}
+
+// escapeLabel - Экранирование значения метки по правилам текстового формата Prometheus
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// escapeHelp - Экранирование описания метрики
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// formatFloat - Число в формате Prometheus
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
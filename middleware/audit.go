@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+
+	"github.com/derv-dice/go-web-server/audit"
+	"github.com/derv-dice/go-web-server/auth"
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// Audit - Middleware, записывающий в журнал log запросы с методами из audit.methods: клиента, действие
+// (метод и шаблон маршрута), путь и результат. Отклоненные аутентификацией и ограничениями запросы
+// тоже записываются, с результатом denied или failure. Если log == nil, ничего не делает
+func Audit(store *config.Store, log *audit.Log) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		if log == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := store.Current().Audit
+			if !cfg.Enabled || !slices.Contains(cfg.Methods, r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r, identity := auth.Track(r)
+			r, pattern := router.TrackPattern(r)
+			sw := NewStatusWriter(w)
+			next.ServeHTTP(sw, r)
+
+			e := audit.Entry{
+				RequestID: RequestIDFrom(r.Context()),
+				Actor:     "-",
+				IP:        clientIP(r),
+				Action:    r.Method + " " + cmp.Or(pattern(), "unmatched"),
+				Resource:  r.URL.Path,
+				Outcome:   audit.Outcome(sw.Status()),
+				Status:    sw.Status(),
+			}
+			if id, ok := identity(); ok {
+				e.Actor, e.AuthMethod = id.Name, id.Method
+			}
+
+			if err := log.Record(e); err != nil {
+				logging.From(r.Context()).Error("audit: record", "error", err)
+			}
+		})
+	}
+}
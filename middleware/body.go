@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// bodyKey - Ключ контекста для исходного тела запроса, до ограничения размера
+type bodyKey struct{}
+
+// BodyLimit - Middleware, ограничивающий размер тела любого запроса настройкой request.max_body_bytes.
+// Нулевое значение отключает ограничение
+func BodyLimit(store *config.Store) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limitBody(w, r, next, store.Current().Request.MaxBodyBytes)
+		})
+	}
+}
+
+// MaxBody - Middleware для отдельного маршрута или группы, заменяющий общий лимит request.max_body_bytes значением n.
+// В отличие от общего лимита, n может быть и больше, например для загрузки файлов
+func MaxBody(n int64) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limitBody(w, r, next, n)
+		})
+	}
+}
+
+// limitBody - Ограничение тела запроса n байтами.
+//
+// Тело оборачивается в http.MaxBytesReader: при превышении лимита чтение возвращает ошибку, а сервер закрывает
+// соединение после ответа. Запрос не отклоняется заранее по Content-Length, так как до выбора маршрута
+// неизвестно, не задан ли для него собственный лимит. Ответ 413 отправляет обработчик, см. BodyError
+func limitBody(w http.ResponseWriter, r *http.Request, next http.Handler, n int64) {
+	// Лимит маршрута применяется к исходному телу, а не к уже ограниченному общим лимитом
+	if orig, ok := r.Context().Value(bodyKey{}).(io.ReadCloser); ok {
+		r.Body = orig
+	} else {
+		r = r.WithContext(context.WithValue(r.Context(), bodyKey{}, r.Body))
+	}
+
+	if n <= 0 {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, n)
+	next.ServeHTTP(w, r)
+}
+
+// BodyError - Отправка клиенту 413, если err - ошибка превышения лимита размера тела запроса.
+// Возвращает false и ничего не отправляет для любых других ошибок:
+//
+//	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+//		if middleware.BodyError(w, err) {
+//			return
+//		}
+//		...
+//	}
+func BodyError(w http.ResponseWriter, err error) bool {
+	var tooBig *http.MaxBytesError
+	if !errors.As(err, &tooBig) {
+		return false
+	}
+
+	tooLarge(w, tooBig.Limit)
+	return true
+}
+
+// tooLarge - Ответ 413 на запрос с телом больше limit байт
+func tooLarge(w http.ResponseWriter, limit int64) {
+	response.Error(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("тело запроса больше допустимых %d байт", limit))
+}
@@ -0,0 +1,283 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// redacted - Значение, которым заменяются скрытые заголовки и поля
+const redacted = "[REDACTED]"
+
+// BodyLog - Middleware, записывающий в лог с уровнем debug заголовки и тела запроса и ответа (настройка log.bodies).
+//
+// В лог попадают первые log.bodies.max_bytes байт тела, которые прочитал обработчик и которые он отправил клиенту.
+// Значения заголовков из redact_headers, полей JSON и форм и параметров query из redact_fields
+// заменяются на [REDACTED].
+// Двоичные тела не записываются, только их размер. Должен стоять перед BodyLimit и Compress,
+// чтобы видеть тело запроса при любом лимите маршрута и ответ до сжатия
+func BodyLog(store *config.Store) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := store.Current().Log.Bodies
+			if !cfg.Enabled || !logging.From(r.Context()).Enabled(r.Context(), slog.LevelDebug) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var reqBody *capture
+			if r.Body != nil && r.Body != http.NoBody {
+				reqBody = &capture{max: cfg.MaxBytes}
+				r.Body = &captureReader{ReadCloser: r.Body, capture: reqBody}
+			}
+			bw := &bodyLogWriter{ResponseWriter: w, capture: capture{max: cfg.MaxBytes}}
+
+			next.ServeHTTP(bw, r)
+
+			red := newRedactor(cfg)
+			attrs := []any{
+				"url", red.url(r.URL),
+				"request_headers", red.headers(r.Header),
+				"response_headers", red.headers(w.Header()),
+			}
+			if reqBody != nil {
+				attrs = append(attrs, "request_body", red.body(reqBody, r.Header.Get("Content-Type")))
+			}
+			attrs = append(attrs, "response_body", red.body(&bw.capture, w.Header().Get("Content-Type")))
+
+			logging.From(r.Context()).Debug("body", attrs...)
+		})
+	}
+}
+
+// capture - Начало тела размером до max байт и полный размер тела
+type capture struct {
+	max  int
+	buf  bytes.Buffer
+	size int64
+}
+
+func (c *capture) write(p []byte) {
+	c.size += int64(len(p))
+	if n := c.max - c.buf.Len(); n > 0 {
+		c.buf.Write(p[:min(n, len(p))])
+	}
+}
+
+// truncated - Тело длиннее сохраненной части
+func (c *capture) truncated() bool {
+	return c.size > int64(c.buf.Len())
+}
+
+// captureReader - Тело запроса, сохраняющее прочитанное обработчиком
+type captureReader struct {
+	io.ReadCloser
+	capture *capture
+}
+
+func (r *captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.capture.write(p[:n])
+	return n, err
+}
+
+// bodyLogWriter - ResponseWriter, сохраняющий отправленное клиенту тело
+type bodyLogWriter struct {
+	http.ResponseWriter
+	capture capture
+}
+
+func (w *bodyLogWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.capture.write(p[:n])
+	return n, err
+}
+
+// Flush - Потоковая отправка ответа, если ее поддерживает исходный ResponseWriter
+func (w *bodyLogWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack - Передача соединения обработчику (например, для WebSocket)
+func (w *bodyLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap - Исходный ResponseWriter для http.ResponseController
+func (w *bodyLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// redactor - Скрытие значений заголовков и полей по настройкам log.bodies
+type redactor struct {
+	hidden  map[string]bool // Канонические имена скрываемых заголовков
+	fields  map[string]bool // Имена скрываемых полей в нижнем регистре
+	pattern *regexp.Regexp  // Поля в JSON, который не удалось разобрать, например обрезанном по max_bytes
+}
+
+func newRedactor(cfg config.BodyLog) *redactor {
+	red := &redactor{hidden: make(map[string]bool), fields: make(map[string]bool)}
+	for _, h := range cfg.RedactHeaders {
+		red.hidden[http.CanonicalHeaderKey(h)] = true
+	}
+
+	quoted := make([]string, 0, len(cfg.RedactFields))
+	for _, f := range cfg.RedactFields {
+		red.fields[strings.ToLower(f)] = true
+		quoted = append(quoted, regexp.QuoteMeta(f))
+	}
+	if len(quoted) > 0 {
+		red.pattern = regexp.MustCompile(`(?i)("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]*)`)
+	}
+	return red
+}
+
+// headers - Заголовки со скрытыми значениями, несколько значений одного заголовка - через запятую
+func (red *redactor) headers(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if red.hidden[k] {
+			out[k] = redacted
+			continue
+		}
+		out[k] = strings.Join(v, ", ")
+	}
+	return out
+}
+
+// body - Тело для записи в лог: JSON и формы со скрытыми полями, текст как есть, для остального - только размер
+func (red *redactor) body(c *capture, contentType string) string {
+	data := c.buf.Bytes()
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	var text string
+	switch {
+	case c.size == 0:
+		return ""
+	case isJSON(contentType):
+		text = red.jsonBody(data)
+	case mediaType == "application/x-www-form-urlencoded":
+		text = red.form(string(data))
+	case (strings.HasPrefix(mediaType, "text/") || mediaType == "" || strings.HasSuffix(mediaType, "xml")) && utf8.Valid(data):
+		text = string(data)
+	default:
+		return "[" + mediaType + ", " + formatSize(c.size) + "]"
+	}
+
+	if c.truncated() {
+		text += "... [" + formatSize(c.size) + "]"
+	}
+	return text
+}
+
+// jsonBody - JSON со скрытыми полями. Если JSON не разбирается целиком, поля ищутся по шаблону
+func (red *redactor) jsonBody(data []byte) string {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		if red.pattern == nil {
+			return string(data)
+		}
+		return red.pattern.ReplaceAllString(string(data), `${1}"`+redacted+`"`)
+	}
+
+	out, err := json.Marshal(red.value(v))
+	if err != nil {
+		return string(data)
+	}
+	return string(out)
+}
+
+// value - Значение JSON со скрытыми полями на любой глубине
+func (red *redactor) value(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if red.fields[strings.ToLower(k)] {
+				v[k] = redacted
+			} else {
+				v[k] = red.value(item)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = red.value(item)
+		}
+	}
+	return v
+}
+
+// form - Форма application/x-www-form-urlencoded со скрытыми полями
+func (red *redactor) form(data string) string {
+	values, err := url.ParseQuery(data)
+	if err != nil {
+		return "[форма не разобрана, " + formatSize(int64(len(data))) + "]"
+	}
+	for k := range values {
+		if red.fields[strings.ToLower(k)] {
+			values[k] = []string{redacted}
+		}
+	}
+	return values.Encode()
+}
+
+// url - Путь и query запроса со скрытыми значениями параметров, см. query
+func (red *redactor) url(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+	path, _, _ := strings.Cut(u.RequestURI(), "?")
+	return path + "?" + red.query(u.RawQuery)
+}
+
+// query - Строка query со скрытыми значениями параметров. Порядок и запись остальных параметров
+// сохраняются, чтобы адрес в логе совпадал с запрошенным
+func (red *redactor) query(raw string) string {
+	pairs := strings.Split(raw, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if red.fields[strings.ToLower(name)] {
+			pairs[i] = key + "=" + redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// RedactRequest - Копия запроса r, в которой значения заголовков из cfg.RedactHeaders и параметров query
+// из cfg.RedactFields заменены на [REDACTED], как в BodyLog. Для ответов, повторяющих запрос клиенту,
+// например /debug/echo: их могут сохранить прокси и логи по пути. Тело общее с r и не изменяется
+func RedactRequest(cfg config.BodyLog, r *http.Request) *http.Request {
+	red := newRedactor(cfg)
+	out := r.Clone(r.Context())
+	for k := range out.Header {
+		if red.hidden[k] {
+			out.Header[k] = []string{redacted}
+		}
+	}
+	if out.URL.RawQuery != "" {
+		out.URL.RawQuery = red.query(out.URL.RawQuery)
+	}
+	return out
+}
+
+// formatSize - Размер тела для лога
+func formatSize(n int64) string {
+	return strconv.FormatInt(n, 10) + " B"
+}
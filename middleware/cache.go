@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// CacheControl - Middleware, устанавливающий заголовки Cache-Control и Expires по политике из секции cache
+// для пути запроса. Обработчик может заменить их своими значениями
+func CacheControl(store *config.Store) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := store.Current().Cache
+			if cfg.Enabled {
+				setCacheHeaders(w.Header(), cfg.Policy(r.URL.Path), time.Now())
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Cache - Middleware маршрута, заменяющий заголовки кэширования политикой p, например для статических файлов.
+// Применяется независимо от cache.enabled
+func Cache(p config.CachePolicy) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			setCacheHeaders(w.Header(), p, time.Now())
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setCacheHeaders - Заголовки Cache-Control и Expires для политики p.
+// Expires нужен только старым клиентам и прокси: при наличии max-age в Cache-Control он не учитывается
+func setCacheHeaders(h http.Header, p config.CachePolicy, now time.Time) {
+	if p.NoStore {
+		h.Set("Cache-Control", "no-store")
+		h.Set("Expires", "0")
+		return
+	}
+
+	directives := []string{"public"}
+	if p.Private {
+		directives[0] = "private"
+	}
+
+	if p.MaxAge == 0 {
+		directives = append(directives, "no-cache")
+	} else {
+		directives = append(directives, "max-age="+strconv.Itoa(int(p.MaxAge.D().Seconds())))
+		if p.Immutable {
+			directives = append(directives, "immutable")
+		}
+	}
+
+	h.Set("Cache-Control", strings.Join(directives, ", "))
+	h.Set("Expires", now.Add(p.MaxAge.D()).UTC().Format(http.TimeFormat))
+}
@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// encoder - Алгоритм сжатия ответа.
+// level - степень сжатия от 1 до 9, 0 - значение по умолчанию алгоритма
+type encoder func(w io.Writer, level int) io.WriteCloser
+
+// encoders - Поддерживаемые значения Content-Encoding. Brotli добавляется при сборке с тегом brotli
+var encoders = map[string]encoder{
+	"gzip": func(w io.Writer, level int) io.WriteCloser {
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		zw, _ := gzip.NewWriterLevel(w, level) // Ошибка возможна только при некорректном level, он проверяется в конфигурации
+		return zw
+	},
+	"deflate": func(w io.Writer, level int) io.WriteCloser {
+		if level == 0 {
+			level = flate.DefaultCompression
+		}
+		zw, _ := flate.NewWriter(w, level)
+		return zw
+	},
+}
+
+// encodingPreference - Порядок выбора алгоритма, если клиент принимает несколько с одинаковым весом
+var encodingPreference = []string{"br", "gzip", "deflate"}
+
+// Compress - Middleware, сжимающий ответ алгоритмом из заголовка Accept-Encoding.
+//
+// Сжимаются только ответы с типом содержимого из compression.types размером не меньше compression.min_size.
+// Обработчики пишут ответ как обычно: до набора min_size байт ответ буферизуется, затем решается, сжимать ли его
+func Compress(store *config.Store) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := store.Current().Compression
+			if !cfg.Enabled || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, cfg: cfg, encoding: encoding, status: http.StatusOK}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding - Выбор поддерживаемого алгоритма по заголовку Accept-Encoding с учетом весов q.
+// Пустая строка - сжимать нельзя
+func negotiateEncoding(header string) string {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		weights[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range encodingPreference {
+		if _, ok := encoders[name]; !ok {
+			continue
+		}
+
+		q, ok := weights[name]
+		if !ok {
+			q, ok = weights["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+// compressWriter - ResponseWriter, который откладывает отправку заголовков, пока не станет ясно, сжимать ли ответ
+type compressWriter struct {
+	http.ResponseWriter
+	cfg      config.Compression
+	encoding string
+
+	status  int
+	started bool           // Заголовки ответа отправлены клиенту
+	buf     []byte         // Начало ответа, пока его размер меньше min_size
+	enc     io.WriteCloser // Алгоритм сжатия, nil - ответ пишется без сжатия
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.started {
+		return
+	}
+
+	// Промежуточные ответы (100 Continue, 103 Early Hints) передаются сразу
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.status = status
+	if !w.eligible() {
+		w.start(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		if w.Header().Get("Content-Type") == "" && len(w.buf) == 0 {
+			// Тип определяется так же, как это сделал бы net/http, иначе его нельзя сравнить со списком
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+
+		if !w.eligible() {
+			w.start(false)
+		} else {
+			w.buf = append(w.buf, p...)
+			if len(w.buf) >= w.cfg.MinSize {
+				w.start(true)
+			}
+			return len(p), nil
+		}
+	}
+
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// eligible - Ответ с текущими заголовками и статусом можно сжать
+func (w *compressWriter) eligible() bool {
+	switch w.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < w.cfg.MinSize {
+		return false
+	}
+
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		// Тип еще неизвестен, он определится по первым байтам ответа
+		return true
+	}
+
+	return typeAllowed(w.cfg.Types, ct)
+}
+
+// start - Отправка заголовков и накопленного начала ответа, со сжатием или без
+func (w *compressWriter) start(compress bool) {
+	w.started = true
+
+	if compress {
+		h := w.Header()
+		h.Del("Content-Length") // Длина изменится после сжатия
+		h.Set("Content-Encoding", w.encoding)
+		w.ResponseWriter.WriteHeader(w.status)
+		w.enc = encoders[w.encoding](w.ResponseWriter, w.cfg.Level)
+		_, _ = w.enc.Write(w.buf)
+	} else {
+		w.ResponseWriter.WriteHeader(w.status)
+		if len(w.buf) > 0 {
+			_, _ = w.ResponseWriter.Write(w.buf)
+		}
+	}
+
+	w.buf = nil
+}
+
+// close - Завершение ответа после обработчика: короткий ответ отправляется без сжатия
+func (w *compressWriter) close() {
+	if !w.started {
+		w.start(false)
+	}
+
+	if w.enc != nil {
+		_ = w.enc.Close()
+	}
+}
+
+// Flush - Потоковая отправка: накопленное начало ответа сжимается сразу, не дожидаясь min_size
+func (w *compressWriter) Flush() {
+	if !w.started {
+		w.start(w.eligible() && len(w.buf) > 0)
+	}
+
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack - Передача соединения обработчику (например, для WebSocket), ответ при этом не сжимается
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("compress: ResponseWriter не поддерживает Hijack")
+	}
+
+	w.started = true
+	return h.Hijack()
+}
+
+// Unwrap - Исходный ResponseWriter для http.ResponseController
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// typeAllowed - Тип содержимого contentType есть в списке types (с учетом шаблонов вида text/*)
+func typeAllowed(types []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range types {
+		t = strings.ToLower(t)
+		if t == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+//go:build brotli
+
+package middleware
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+func init() {
+	encoders["br"] = func(w io.Writer, level int) io.WriteCloser {
+		if level == 0 {
+			level = brotli.DefaultCompression
+		}
+		return brotli.NewWriterLevel(w, level)
+	}
+}
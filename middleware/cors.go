@@ -0,0 +1,118 @@
+// Package middleware - Middleware общего назначения для маршрутизатора
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// CORS - Middleware, разрешающий кросс-доменные запросы из браузера по настройкам секции cors.
+//
+// Запрос с заголовком Origin из списка разрешенных получает заголовки Access-Control-Allow-*.
+// Предварительный запрос (OPTIONS с Access-Control-Request-Method) обрабатывается здесь же
+// и дальше не передается. Настройки читаются из текущего снимка конфигурации на каждый запрос
+func CORS(store *config.Store) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := store.Current().CORS
+			origin := r.Header.Get("Origin")
+			if !cfg.Enabled || origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Ответ зависит от Origin, поэтому кэши должны хранить его отдельно для каждого источника
+			h := w.Header()
+			h.Add("Vary", "Origin")
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if !originAllowed(cfg.AllowedOrigins, origin) {
+				if preflight {
+					// Без заголовков Access-Control-Allow-* браузер сам отклонит основной запрос
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(cfg.AllowedOrigins) == 1 && cfg.AllowedOrigins[0] == "*" && !cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+
+			if cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if len(cfg.ExposedHeaders) > 0 {
+					h.Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			method := r.Header.Get("Access-Control-Request-Method")
+			if !contains(cfg.AllowedMethods, method) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			h.Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				if contains(cfg.AllowedHeaders, "*") {
+					// Разрешены любые заголовки: в ответе повторяются запрошенные
+					h.Set("Access-Control-Allow-Headers", requested)
+				} else if len(cfg.AllowedHeaders) > 0 {
+					h.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+				}
+			}
+
+			if cfg.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.D().Seconds())))
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// originAllowed - Источник origin есть в списке разрешенных.
+// Элемент списка "*" разрешает любой источник, "https://*.example.com" - любой поддомен
+func originAllowed(allowed []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		switch {
+		case a == "*" || a == origin:
+			return true
+		case strings.Contains(a, "://*."):
+			scheme, suffix, _ := strings.Cut(a, "://*")
+			if rest, ok := strings.CutPrefix(origin, scheme+"://"); ok && strings.HasSuffix(rest, suffix) && len(rest) > len(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contains - Значение v есть в списке list без учета регистра
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
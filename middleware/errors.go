@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// ResponseOptions - Middleware, задающий настройки ответов на запрос (см. response.Options):
+//   - формат ответов с ошибкой по настройке response.error_format: обычный {"error": ...} или application/problem+json;
+//   - JSON с отступами, если в запросе есть параметр ?pretty (кроме ?pretty=0 и ?pretty=false) или включен режим debug;
+//   - разделитель и строка заголовков в ответах text/csv по настройкам response.csv.
+//
+// Должен стоять в начале цепочки, чтобы настройки применялись и к ответам других middleware
+func ResponseOptions(store *config.Store) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := store.Current()
+			opts := response.Options{
+				ErrorFormat: cfg.Response.ErrorFormat,
+				Instance:    r.URL.Path,
+				Pretty:      cfg.Debug || pretty(r),
+				CSV:         response.CSV{Comma: cfg.Response.CSV.Comma(), Header: cfg.Response.CSV.Header},
+			}
+			next.ServeHTTP(response.WithOptions(w, opts), r)
+		})
+	}
+}
+
+// Errors - Middleware для отдельного маршрута или группы, заменяющий формат ошибок response.error_format
+// значением format (response.FormatEnvelope или response.FormatProblem)
+func Errors(format string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			opts := response.OptionsOf(w)
+			opts.ErrorFormat, opts.Instance = format, r.URL.Path
+			next.ServeHTTP(response.WithOptions(w, opts), r)
+		})
+	}
+}
+
+// pretty - В запросе есть параметр ?pretty со значением, отличным от 0 и false
+func pretty(r *http.Request) bool {
+	q := r.URL.Query()
+	if !q.Has("pretty") {
+		return false
+	}
+	switch q.Get("pretty") {
+	case "0", "false":
+		return false
+	}
+	return true
+}
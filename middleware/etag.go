@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// ETag - Middleware, добавляющий слабый ETag к JSON ответам на GET и HEAD и отвечающий 304 Not Modified,
+// если клиент прислал в If-None-Match тот же ETag.
+//
+// JSON ответ обработчика буферизуется целиком, чтобы посчитать хэш тела. Обработчик может установить
+// ETag сам, тогда используется его значение. Остальные ответы (файлы, HTML) и потоковые ответы (с вызовом Flush)
+// передаются клиенту без буферизации и без ETag от middleware
+func ETag(store *config.Store) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !store.Current().ETag.Enabled || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ew := &etagWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(ew, r)
+
+			if ew.streaming {
+				return
+			}
+			ew.finish(r)
+		})
+	}
+}
+
+// etagWriter - ResponseWriter, накапливающий ответ до завершения обработчика
+type etagWriter struct {
+	http.ResponseWriter
+	status    int
+	buf       bytes.Buffer
+	decided   bool // Статус ответа известен и выбрано, буферизуется ли ответ
+	streaming bool // Ответ передается клиенту напрямую: он не JSON или обработчик вызвал Flush
+}
+
+// WriteHeader - Ответ буферизуется, только если это успешный JSON ответ. Остальные сразу передаются клиенту
+func (w *etagWriter) WriteHeader(status int) {
+	if w.streaming || w.decided {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.decided = true
+	w.status = status
+	if status != http.StatusOK || !isJSON(w.Header().Get("Content-Type")) {
+		w.streaming = true
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *etagWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.streaming {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+// Flush - Переход к потоковой отправке: накопленное начало ответа отправляется без ETag
+func (w *etagWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap - Исходный ResponseWriter для http.ResponseController
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish - Отправка накопленного ответа клиенту или 304, если у клиента уже есть актуальная версия
+func (w *etagWriter) finish(r *http.Request) {
+	h := w.Header()
+
+	if w.status == http.StatusOK && isJSON(h.Get("Content-Type")) {
+		etag := h.Get("ETag")
+		if etag == "" {
+			etag = weakETag(etagContent(w.buf.Bytes()))
+			h.Set("ETag", etag)
+		}
+
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			// Ответ 304 не содержит тела, заголовки о его содержимом не передаются
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+// weakETag - Слабый ETag по хэшу и длине тела ответа: совпадение означает одинаковое содержимое,
+// но не побайтовую идентичность передаваемого ответа (например, после сжатия)
+func weakETag(body []byte) string {
+	h := fnv.New64a()
+	h.Write(body)
+	return fmt.Sprintf(`W/"%x-%x"`, len(body), h.Sum64())
+}
+
+// etagContent - Часть JSON ответа, по которой считается ETag: без полей request_id и time, которые
+// различаются в каждом ответе. Иначе одинаковые данные никогда не совпали бы с ETag клиента
+func etagContent(body []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+
+	delete(fields, "request_id")
+	delete(fields, "time")
+
+	// Ключи map сериализуются по алфавиту, поэтому результат не зависит от порядка полей в ответе
+	content, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return content
+}
+
+// etagMatch - Заголовок If-None-Match содержит etag. Сравнение слабое: префикс W/ не учитывается
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// isJSON - Тип содержимого application/json или производный от него, например application/problem+json
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// networks - Разобранные списки сетей из секции ip_filter
+type networks struct {
+	allow, deny []netip.Prefix
+}
+
+// IPFilter - Middleware, ограничивающий доступ к серверу по IP адресу клиента списками сетей из секции ip_filter.
+//
+// Клиент из списка deny или не из списка allow (если тот не пуст) получает 403. Запросы через Unix сокет
+// не фильтруются: адрес клиента у них неизвестен, а доступ к сокету ограничивается правами на файл.
+// Списки разбираются один раз и заново после каждого перечитывания конфигурации
+func IPFilter(store *config.Store) router.Middleware {
+	var current atomic.Pointer[networks]
+
+	update := func(cfg *config.Config) {
+		// Ошибка здесь невозможна: списки уже проверены при загрузке конфигурации
+		allow, deny, _ := cfg.IPFilter.Prefixes()
+		current.Store(&networks{allow: allow, deny: deny})
+	}
+	update(store.Current())
+	store.OnReload(func(_, cur *config.Config) { update(cur) })
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr, err := netip.ParseAddr(clientIP(r))
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !current.Load().allowed(addr.Unmap()) {
+				response.Error(w, http.StatusForbidden, "доступ с этого адреса запрещен")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allowed - Адресу addr разрешен доступ
+func (n *networks) allowed(addr netip.Addr) bool {
+	for _, p := range n.deny {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+
+	if len(n.allow) == 0 {
+		return true
+	}
+
+	for _, p := range n.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
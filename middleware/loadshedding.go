@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/metrics"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// LoadShedding - Middleware, ограничивающий количество одновременно обрабатываемых запросов по настройкам
+// секции load_shedding. Запрос сверх лимита сразу получает 503 с заголовком Retry-After: очередь ожидающих
+// запросов под нагрузкой только росла бы, а клиенты все равно не дождались бы ответа.
+//
+// Лимит берется из текущей конфигурации, поэтому его изменение применяется сразу: при уменьшении уже
+// начатые запросы завершаются, новые отклоняются, пока их количество не опустится ниже лимита.
+// В reg - количество запросов, учитываемых в лимите, и количество отклоненных
+func LoadShedding(store *config.Store, reg *metrics.Registry) router.Middleware {
+	concurrent := reg.NewGauge("http_requests_concurrent", "Количество HTTP запросов в обработке, учитываемых в лимите load_shedding")
+	shed := reg.NewCounter("http_requests_shed_total", "Количество HTTP запросов, отклоненных сверх лимита load_shedding")
+
+	var inFlight atomic.Int64
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := store.Current().LoadShedding
+			if !cfg.Enabled || cfg.Excluded(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if inFlight.Add(1) > int64(cfg.MaxInFlight) {
+				inFlight.Add(-1)
+				shed.Inc()
+				// Как в RateLimit: целые секунды с округлением вверх
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(cfg.RetryAfter.D().Seconds()))))
+				response.Error(w, http.StatusServiceUnavailable, "сервер перегружен, повторите позже")
+				return
+			}
+
+			concurrent.Inc()
+			defer func() {
+				concurrent.Dec()
+				inFlight.Add(-1)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/realip"
+)
+
+// RequestLogger - Middleware, сохраняющий в контексте запроса логгер с полями id, method, path и ip.
+// Обработчики и следующие middleware пишут в лог через logging.From(r.Context()), и каждая запись
+// связана с запросом. Должен стоять после RequestID и RealIP. Логгер создается из slog.Default() на каждый запрос,
+// поэтому настройки логирования применяются без перезапуска при перечитывании конфигурации
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := slog.Default().With(
+			"id", RequestIDFrom(r.Context()),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"ip", realip.From(r),
+		)
+		next.ServeHTTP(w, r.WithContext(logging.With(r.Context(), l)))
+	})
+}
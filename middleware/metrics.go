@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"bufio"
+	"cmp"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/derv-dice/go-web-server/metrics"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// Metrics - Middleware, собирающий метрики запросов в reg: количество запросов по методу, маршруту и статусу,
+// гистограммы длительности и размера ответа по маршруту, количество запросов в обработке и количество паник.
+//
+// Маршрут в метках - шаблон совпавшего маршрута (/files/{id}), а не путь запроса: так количество рядов метрик
+// не зависит от того, какие пути запрашивают клиенты. Запросы без маршрута (404, перенаправления) получают метку unmatched.
+//
+// Должен стоять в цепочке после Recovery, чтобы паника сначала была учтена здесь, а затем обработана
+func Metrics(reg *metrics.Registry) router.Middleware {
+	requests := reg.NewCounter("http_requests_total", "Количество обработанных HTTP запросов", "method", "route", "code")
+	duration := reg.NewHistogram("http_request_duration_seconds", "Длительность обработки HTTP запросов",
+		metrics.DefaultBuckets, "method", "route")
+	size := reg.NewHistogram("http_response_size_bytes", "Размер тела ответов на HTTP запросы",
+		SizeBuckets, "method", "route")
+	inFlight := reg.NewGauge("http_requests_in_flight", "Количество HTTP запросов в обработке")
+	panics := reg.NewCounter("http_panics_total", "Количество паник в обработчиках HTTP запросов")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inFlight.Inc()
+			start := time.Now()
+			sw := NewStatusWriter(w)
+			r, pattern := router.TrackPattern(r)
+
+			defer func() {
+				inFlight.Dec()
+
+				// Паника будет обработана в Recovery, клиент получит 500
+				err := recover()
+				if err != nil {
+					value := err
+					if p, ok := err.(*handlerPanic); ok {
+						value = p.value
+					}
+					if value != http.ErrAbortHandler {
+						panics.Inc()
+					}
+					sw.status = http.StatusInternalServerError
+				}
+
+				method, route := methodLabel(r.Method), cmp.Or(pattern(), "unmatched")
+				requests.Inc(method, route, strconv.Itoa(sw.Status()))
+				duration.Observe(time.Since(start).Seconds(), method, route)
+				size.Observe(float64(sw.Size()), method, route)
+
+				if err != nil {
+					panic(err)
+				}
+			}()
+
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// SizeBuckets - Границы корзин гистограммы размера ответов в байтах: от 100 B до 100 MB
+var SizeBuckets = []float64{100, 1000, 10_000, 100_000, 1_000_000, 10_000_000, 100_000_000}
+
+// methodLabel - Метод запроса для метки метрики. Нестандартные методы объединяются в одно значение,
+// чтобы клиент не мог создать произвольное количество рядов метрики
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// StatusWriter - http.ResponseWriter, запоминающий отправленный статус код и размер тела ответа
+type StatusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// NewStatusWriter - Обертка над w, запоминающая статус код и размер ответа, который отправляет обработчик
+func NewStatusWriter(w http.ResponseWriter) *StatusWriter {
+	return &StatusWriter{ResponseWriter: w}
+}
+
+// WriteHeader - Запоминается первый отправленный статус код
+func (sw *StatusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+// Write - Подсчет размера тела ответа
+func (sw *StatusWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(p)
+	sw.size += int64(n)
+	return n, err
+}
+
+// Status - Отправленный статус код. Если обработчик ничего не отправил, net/http ответит 200
+func (sw *StatusWriter) Status() int {
+	if sw.status == 0 {
+		return http.StatusOK
+	}
+	return sw.status
+}
+
+// Size - Количество отправленных байт тела ответа
+func (sw *StatusWriter) Size() int64 {
+	return sw.size
+}
+
+// Flush - Потоковая отправка ответа, если ее поддерживает исходный ResponseWriter
+func (sw *StatusWriter) Flush() {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	http.NewResponseController(sw.ResponseWriter).Flush()
+}
+
+// Hijack - Передача соединения обработчику (например, для WebSocket)
+func (sw *StatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(sw.ResponseWriter).Hijack()
+}
+
+// Unwrap - Исходный ResponseWriter для http.ResponseController
+func (sw *StatusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
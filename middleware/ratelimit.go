@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/realip"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// RateLimiter - Хранилище корзин токенов для ограничения частоты запросов.
+//
+// Take забирает один токен из корзины клиента key, которая пополняется со скоростью rate токенов в секунду
+// и вмещает не больше burst токенов. Если токенов нет, возвращается время до появления следующего.
+// Ошибка - корзину проверить не удалось, например внешнее хранилище недоступно
+type RateLimiter interface {
+	Take(ctx context.Context, key string, rate float64, burst int) (ok bool, retryAfter time.Duration, err error)
+}
+
+// RateLimit - Middleware, ограничивающий частоту запросов с одного IP адреса по настройкам секции rate_limit.
+// При превышении лимита клиент получает 429 с заголовком Retry-After. Если limiter недоступен, запросы
+// пропускаются без ограничения: отказ хранилища лимитов не должен останавливать весь сервер
+func RateLimit(store *config.Store, limiter RateLimiter) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := store.Current().RateLimit
+			if !cfg.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ok, retryAfter, err := limiter.Take(r.Context(), clientIP(r), cfg.Rate, cfg.Burst)
+			if err != nil {
+				logging.From(r.Context()).Warn("rate limit: limiter unavailable, request is not limited", "error", err)
+				ok = true
+			}
+			if !ok {
+				// Retry-After передается в целых секундах, округление вверх, чтобы повторный запрос не пришел раньше срока
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				response.Error(w, http.StatusTooManyRequests, "слишком много запросов, повторите позже")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP - IP адрес клиента без порта, за доверенным прокси - из его заголовков (см. RealIP)
+func clientIP(r *http.Request) string {
+	return realip.From(r)
+}
+
+// sweepInterval - Как часто MemoryRateLimiter удаляет корзины неактивных клиентов
+const sweepInterval = time.Minute
+
+// MemoryRateLimiter - RateLimiter, хранящий корзины в памяти процесса.
+//
+// Корзина клиента, который не присылал запросов дольше, чем нужно для ее полного пополнения,
+// ничем не отличается от новой и удаляется, поэтому память не растет с числом когда-либо обратившихся адресов
+type MemoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket - Корзина токенов одного клиента
+type bucket struct {
+	tokens float64
+	last   time.Time // Момент последнего пересчета tokens
+	full   time.Time // Момент, когда корзина пополнится полностью
+}
+
+// NewMemoryRateLimiter - Пустое хранилище корзин в памяти
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (l *MemoryRateLimiter) Take(_ context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+
+	// Пополнение корзины за время с прошлого запроса, но не больше ее емкости.
+	// Емкость и скорость берутся из текущей конфигурации, поэтому их изменение применяется сразу
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate * float64(time.Second)), nil
+	}
+
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)))
+	return true, 0, nil
+}
+
+// sweep - Удаление полностью пополнившихся корзин
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/realip"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// RealIP - Middleware, определяющий IP адрес клиента по настройкам real_ip и сохраняющий его в контексте запроса
+// (см. realip.From). Заголовки X-Forwarded-For и X-Real-IP учитываются, только если запрос пришел
+// от доверенного прокси. Должен стоять перед RequestLogger, чтобы адрес попал в логи.
+// Список сетей разбирается один раз и заново после каждого перечитывания конфигурации
+func RealIP(store *config.Store) router.Middleware {
+	var current atomic.Pointer[realip.Resolver]
+
+	update := func(cfg *config.Config) {
+		// Ошибка здесь невозможна: список уже проверен при загрузке конфигурации
+		trusted, _ := cfg.RealIP.Prefixes()
+		current.Store(&realip.Resolver{Trusted: trusted, TrustUnix: cfg.RealIP.TrustUnix, Headers: cfg.RealIP.Headers})
+	}
+	update(store.Current())
+	store.OnReload(func(_, cur *config.Config) { update(cur) })
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := current.Load().Resolve(r)
+			next.ServeHTTP(w, r.WithContext(realip.With(r.Context(), ip)))
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/errreport"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// handlerPanic - Паника, перехваченная в другой горутине вместе со стеком вызовов в момент паники
+type handlerPanic struct {
+	value any
+	stack []byte
+}
+
+// panicError - Значение паники в виде error
+func panicError(v any) error {
+	if err, ok := v.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", v)
+}
+
+// Recovery - Middleware, предотвращающий остановку приложения в случае критической ошибки.
+//
+// Паника в обработчике логируется вместе со стеком вызовов и передается в errreport, а клиент получает 500. Текст паники и стек
+// передаются клиенту только в режиме отладки (настройка debug), иначе ответ содержит общее сообщение,
+// чтобы не раскрывать внутренние детали сервера
+func Recovery(store *config.Store) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				err := recover()
+				if err == nil {
+					return
+				}
+
+				stack := debug.Stack()
+				if p, ok := err.(*handlerPanic); ok {
+					err, stack = p.value, p.stack
+				}
+
+				// Прерывание ответа обработчиком - штатная ситуация, ее обрабатывает сам net/http
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
+				// Логирование факта ошибки
+				// Идентификатор, метод, путь и IP адрес клиента добавляет логгер запроса
+				logging.From(r.Context()).Error("panic",
+					"error", fmt.Sprint(err), // Значение, переданное в panic
+					"stack", string(stack), // Стек вызовов в момент паники
+				)
+
+				// Отправка во внешний сервис ошибок, если он настроен (error_reporting)
+				errreport.Report(r.Context(), errreport.Event{
+					Err:       panicError(err),
+					Panic:     true,
+					Stack:     stack,
+					RequestID: RequestIDFrom(r.Context()),
+					Request:   r,
+				})
+
+				// В случае непредвиденной критической ошибки - возвращается ответ с формате JSON заданной структуры
+				body := response.Body{
+					Error:     "внутренняя ошибка сервера",
+					RequestID: RequestIDFrom(r.Context()),
+				}
+				if store.Current().Debug {
+					body.Error = fmt.Sprintf("%v", err)
+					body.Stack = strings.Split(strings.TrimSpace(string(stack)), "\n")
+				}
+				response.JSON(w, http.StatusInternalServerError, body)
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/derv-dice/go-web-server/response"
+)
+
+// requestIDKey - Ключ контекста для идентификатора запроса
+type requestIDKey struct{}
+
+// maxRequestIDLen - Максимальная длина идентификатора, принимаемого от клиента
+const maxRequestIDLen = 128
+
+// RequestID - Middleware, присваивающий каждому запросу идентификатор.
+//
+// Идентификатор из заголовка X-Request-ID запроса используется, если он есть и корректен (например, его
+// назначил балансировщик), иначе генерируется новый. Идентификатор сохраняется в контексте запроса,
+// передается дальше в заголовке запроса (в том числе на upstream) и возвращается клиенту в заголовке ответа
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(response.RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+			r.Header.Set(response.RequestIDHeader, id)
+		}
+
+		w.Header().Set(response.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFrom - Идентификатор запроса из контекста ctx. Пустая строка, если запрос не прошел через RequestID
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID - Случайный идентификатор из 32 шестнадцатеричных символов
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:]) // crypto/rand.Read не возвращает ошибок
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID - Идентификатор от клиента можно безопасно записать в логи и заголовки:
+// непустой, не длиннее maxRequestIDLen и только из букв, цифр и символов - _ . :
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+
+	for _, c := range []byte(id) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
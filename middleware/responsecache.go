@@ -0,0 +1,341 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/derv-dice/go-web-server/auth"
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// ResponseCache - Хранилище сохраненных ответов. Get возвращает false, если ответа нет или его срок истек.
+// Ключ начинается с пути запроса, см. Purge
+type ResponseCache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Purge - Удаление ответов на запросы к путям с префиксом prefix, пустой - всех. Возвращает число удаленных
+	// записей: кроме ответов это и списки заголовков Vary
+	Purge(ctx context.Context, prefix string) (int, error)
+}
+
+// cachedResponse - Сохраненный ответ: заголовки, которые установил обработчик, и тело
+type cachedResponse struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+// CacheResponses - Middleware кэша ответов на стороне сервера по настройкам cache.responses. Ответ 200 на GET
+// сохраняется на max_age политики кэширования своего пути, если она разрешает общий кэш, и отдается
+// следующим таким же запросам без вызова обработчика с заголовками X-Cache: HIT и Age.
+//
+// Запросы с учетными данными (см. hasCredentials), запросы, клиент которых аутентифицирован в middleware
+// маршрута (auth.Track), и ответы с Set-Cookie, Cache-Control: private, no-store или no-cache не кэшируются:
+// они относятся к конкретному клиенту, и сохраненный ответ достался бы клиентам без доступа. Разные Accept, Accept-Language и Origin, а также
+// заголовки из Vary ответа кэшируются отдельно, ответы с Vary: * не кэшируются. Middleware ставится внутри
+// Compress, чтобы хранить несжатые ответы
+func CacheResponses(store *config.Store, cache ResponseCache) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := store.Current().Cache
+			policy := cfg.Policy(r.URL.Path)
+			if !cfg.Responses.Enabled || r.Method != http.MethodGet || policy.NoStore || policy.Private || policy.MaxAge <= 0 ||
+				hasCredentials(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			log := logging.From(r.Context())
+			base := responseKey(r, nil)
+
+			// Заголовки Vary прошлого ответа на этот адрес, если он их добавил к varyHeaders
+			vary := varyHeaders
+			data, ok, err := cache.Get(r.Context(), varyKey(r, base))
+			if ok && err == nil {
+				err = json.Unmarshal(data, &vary)
+			}
+			if err != nil {
+				log.Warn("cache: get vary", "error", err)
+			}
+
+			key := responseKey(r, vary)
+			data, ok, err = cache.Get(r.Context(), key)
+			if err != nil {
+				log.Warn("cache: get response", "error", err)
+			}
+			var cached cachedResponse
+			if ok && json.Unmarshal(data, &cached) == nil {
+				h := w.Header()
+				for k, v := range cached.Header {
+					h[k] = v
+				}
+				h.Set("X-Cache", "HIT")
+				h.Set("Age", strconv.Itoa(int(time.Since(cached.StoredAt).Seconds())))
+				w.WriteHeader(cached.Status)
+				w.Write(cached.Body)
+				return
+			}
+
+			w.Header().Set("X-Cache", "MISS")
+			cw := &cacheWriter{ResponseWriter: w, before: w.Header().Clone(), limit: cfg.Responses.MaxBodyBytes}
+			r, identity := auth.Track(r)
+			next.ServeHTTP(cw, r)
+
+			if _, ok := identity(); ok || !cw.cacheable() {
+				return
+			}
+			vary, ok = responseVary(cw.header)
+			if !ok {
+				return
+			}
+			ttl := policy.MaxAge.D()
+			if !slices.Equal(vary, varyHeaders) {
+				data, _ = json.Marshal(vary)
+				if err := cache.Set(r.Context(), varyKey(r, base), data, ttl); err != nil {
+					log.Warn("cache: set vary", "error", err)
+					return
+				}
+			}
+			data, err = json.Marshal(cachedResponse{Status: cw.status, Header: cw.header, Body: cw.buf.Bytes(), StoredAt: time.Now()})
+			if err == nil {
+				err = cache.Set(r.Context(), responseKey(r, vary), data, ttl)
+			}
+			if err != nil {
+				log.Warn("cache: set response", "error", err)
+			}
+		})
+	}
+}
+
+// hasCredentials - Запрос несет учетные данные: Authorization, cookie, API ключ, подпись или сертификат клиента mTLS
+func hasCredentials(r *http.Request) bool {
+	return r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" ||
+		r.Header.Get(auth.APIKeyHeader) != "" || r.Header.Get(auth.SignatureHeader) != "" ||
+		r.TLS != nil && len(r.TLS.PeerCertificates) > 0
+}
+
+// varyHeaders - Заголовки запроса, от которых ответ может зависеть и без Vary, по порядку Vary ответа
+var varyHeaders = []string{"Accept", "Accept-Language", "Origin"}
+
+// responseKey - Ключ ответа на запрос r: путь, по которому Purge находит ответы, и хэш адреса
+// и значений заголовков vary. nil vary - ключ адреса без заголовков, см. varyKey
+func responseKey(r *http.Request, vary []string) string {
+	parts := []string{r.Host, r.URL.RequestURI()}
+	for _, name := range vary {
+		v := strings.Join(r.Header.Values(name), ", ")
+		// Запрос без Accept принимает любой формат, как и Accept: */*
+		if name == "Accept" && v == "" {
+			v = "*/*"
+		}
+		parts = append(parts, name+": "+v)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	// EscapedPath не содержит пробелов, поэтому путь отделяется от хэша однозначно
+	return r.URL.EscapedPath() + " " + hex.EncodeToString(sum[:])
+}
+
+// varyKey - Ключ списка заголовков Vary ответа на адрес base, см. responseKey
+func varyKey(r *http.Request, base string) string {
+	return r.URL.EscapedPath() + " vary " + strings.TrimPrefix(base, r.URL.EscapedPath()+" ")
+}
+
+// responseVary - Заголовки, значения которых различают сохраненные ответы: varyHeaders и заголовки
+// из Vary ответа h в каноническом виде. false - Vary: *, ответ нельзя отдавать другим запросам
+func responseVary(h http.Header) ([]string, bool) {
+	vary := slices.Clone(varyHeaders)
+	for _, v := range h.Values("Vary") {
+		for name := range strings.SplitSeq(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			switch {
+			case name == "*":
+				return nil, false
+			case name != "" && !slices.Contains(vary, name):
+				vary = append(vary, name)
+			}
+		}
+	}
+	slices.Sort(vary[len(varyHeaders):])
+	return vary, true
+}
+
+// cacheWriter - ResponseWriter, который передает ответ клиенту и одновременно копирует его для кэша
+type cacheWriter struct {
+	http.ResponseWriter
+	before    http.Header // Заголовки до вызова обработчика: их устанавливают внешние middleware для каждого запроса
+	header    http.Header // Заголовки, которые установил обработчик
+	status    int
+	buf       bytes.Buffer
+	limit     int64
+	skip      bool // Ответ не кэшируется: тело больше limit или обработчик вызвал Flush
+	wroteHead bool
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	if w.wroteHead {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.wroteHead = true
+	w.status = status
+
+	w.header = http.Header{}
+	for k, v := range w.Header() {
+		if k != "X-Cache" && !slices.Equal(w.before[k], v) {
+			w.header[k] = slices.Clone(v)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	if !w.wroteHead {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.skip {
+		if int64(w.buf.Len()+len(p)) > w.limit {
+			w.skip = true
+			w.buf = bytes.Buffer{}
+		} else {
+			w.buf.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush - Потоковый ответ не кэшируется: клиент получает его частями по мере готовности
+func (w *cacheWriter) Flush() {
+	w.skip = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap - Исходный ResponseWriter для http.ResponseController
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// cacheable - Ответ можно сохранить в общем кэше
+func (w *cacheWriter) cacheable() bool {
+	if w.skip || w.status != http.StatusOK || w.header.Get("Set-Cookie") != "" {
+		return false
+	}
+	cc := strings.ToLower(w.Header().Get("Cache-Control"))
+	return !strings.Contains(cc, "private") && !strings.Contains(cc, "no-store") && !strings.Contains(cc, "no-cache")
+}
+
+// MemoryResponseCache - ResponseCache в памяти процесса. Каждый экземпляр сервера кэширует ответы отдельно.
+// Число ответов и занятая ими память ограничены: при превышении вытесняются ответы, которые дольше всех
+// не запрашивались
+type MemoryResponseCache struct {
+	maxEntries int
+	maxBytes   int64
+
+	mu        sync.Mutex
+	entries   map[string]*list.Element // Значения - *memoryResponse
+	lru       *list.List               // Недавно запрошенные в начале
+	bytes     int64
+	lastSweep time.Time
+}
+
+type memoryResponse struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryResponseCache - Пустой кэш ответов в памяти не больше чем на maxEntries ответов и maxBytes байт
+func NewMemoryResponseCache(maxEntries int, maxBytes int64) *MemoryResponseCache {
+	return &MemoryResponseCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (c *MemoryResponseCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*memoryResponse)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		return nil, false, nil
+	}
+	c.lru.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (c *MemoryResponseCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= sweepInterval {
+		for _, el := range c.entries {
+			if now.After(el.Value.(*memoryResponse).expires) {
+				c.remove(el)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	size := memorySize(key, value)
+	if size > c.maxBytes {
+		return nil
+	}
+	// Число разных адресов не ограничено: без предела запросы с разными параметрами заняли бы всю память
+	for c.lru.Len() > 0 && (c.lru.Len() >= c.maxEntries || c.bytes+size > c.maxBytes) {
+		c.remove(c.lru.Back())
+	}
+	c.entries[key] = c.lru.PushFront(&memoryResponse{key: key, value: value, expires: now.Add(ttl)})
+	c.bytes += size
+	return nil
+}
+
+func (c *MemoryResponseCache) Purge(_ context.Context, prefix string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for key, el := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(el)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// remove - Удаление ответа под c.mu
+func (c *MemoryResponseCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*memoryResponse)
+	delete(c.entries, e.key)
+	c.bytes -= memorySize(e.key, e.value)
+}
+
+// memorySize - Память, которую занимает ответ: ключ и значение, без служебных структур
+func memorySize(key string, value []byte) int64 {
+	return int64(len(key) + len(value))
+}
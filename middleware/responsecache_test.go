@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/derv-dice/go-web-server/auth"
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/response"
+)
+
+// testKeys - auth.KeyStore с одним ключом
+type testKeys map[string]string
+
+func (k testKeys) Lookup(key string) (string, bool) {
+	name, ok := k[key]
+	return name, ok
+}
+
+// cachedRoute - Маршрут с политикой max_age за кэшем ответов, доступ к которому проверяет auth
+func cachedRoute(t *testing.T, auth func(http.Handler) http.Handler) http.Handler {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Cache.Enabled = true
+	cfg.Cache.Paths = map[string]config.CachePolicy{"/private/": {MaxAge: config.Duration(time.Minute)}}
+	cfg.Cache.Responses.Enabled = true
+	store := config.NewStore(cfg, nil)
+
+	secret := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.JSON(w, http.StatusOK, response.Body{Data: "secret"})
+	})
+	return CacheResponses(store, NewMemoryResponseCache(100, 1<<20))(auth(secret))
+}
+
+func TestCacheResponsesAuthenticated(t *testing.T) {
+	tests := []struct {
+		name   string
+		auth   func(http.Handler) http.Handler
+		header string // Заголовок с учетными данными
+		value  string
+	}{
+		{
+			name:   "api key",
+			auth:   auth.APIKey(testKeys{"key": "svc"}),
+			header: auth.APIKeyHeader,
+			value:  "key",
+		},
+		{
+			// Учетные данные, о которых кэш не знает: отказ определяется по Identity после обработчика
+			name: "identity",
+			auth: func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Header.Get("X-Token") != "token" {
+						response.Error(w, http.StatusUnauthorized, "требуется авторизация")
+						return
+					}
+					auth.WithIdentity(r.Context(), auth.Identity{Name: "svc", Method: "token"})
+					next.ServeHTTP(w, r)
+				})
+			},
+			header: "X-Token",
+			value:  "token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := cachedRoute(t, tt.auth)
+
+			for range 2 {
+				r := httptest.NewRequest(http.MethodGet, "/private/report", nil)
+				r.Header.Set(tt.header, tt.value)
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				if w.Code != http.StatusOK {
+					t.Fatalf("запрос с учетными данными: статус %d, ожидается 200", w.Code)
+				}
+				if got := w.Header().Get("X-Cache"); got == "HIT" {
+					t.Fatalf("запрос с учетными данными получил ответ из кэша")
+				}
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/private/report", nil))
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("запрос без учетных данных: статус %d, X-Cache %q, ожидается 401 от обработчика",
+					w.Code, w.Header().Get("X-Cache"))
+			}
+		})
+	}
+}
+
+func TestCacheResponsesAnonymous(t *testing.T) {
+	h := cachedRoute(t, func(next http.Handler) http.Handler { return next })
+
+	for i, want := range []string{"MISS", "HIT"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/private/report", nil))
+		if got := w.Header().Get("X-Cache"); w.Code != http.StatusOK || got != want {
+			t.Fatalf("запрос %d: статус %d, X-Cache %q, ожидается 200 и %s", i+1, w.Code, got, want)
+		}
+	}
+}
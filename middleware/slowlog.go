@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"cmp"
+	"net/http"
+	"time"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// SlowLog - Middleware, записывающий в лог с уровнем warn запросы, обработка которых заняла больше
+// log.slow_threshold. Запись отдельна от access лога и делается, даже если он выключен
+func SlowLog(store *config.Store) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			threshold := store.Current().Log.SlowThreshold.D()
+			if threshold <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r, pattern := router.TrackPattern(r)
+			sw := NewStatusWriter(w)
+			start := time.Now()
+			next.ServeHTTP(sw, r)
+
+			duration := time.Since(start)
+			if duration <= threshold {
+				return
+			}
+
+			logging.From(r.Context()).Warn("slow request",
+				"route", cmp.Or(pattern(), "unmatched"),
+				"uri", r.URL.RequestURI(),
+				"status", sw.Status(),
+				"size", sw.Size(),
+				"duration", duration,
+				"threshold", threshold,
+			)
+		})
+	}
+}
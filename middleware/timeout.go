@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// RequestTimeout - Middleware, ограничивающий время обработки любого запроса настройкой request.timeout.
+// Нулевое значение отключает ограничение
+func RequestTimeout(store *config.Store) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d := store.Current().Request.Timeout.D()
+			if d <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			serveWithTimeout(w, r, next, d)
+		})
+	}
+}
+
+// Timeout - Middleware для отдельного маршрута или группы, ограничивающий время обработки запроса значением d.
+// Вместе с request.timeout действует меньшее из двух значений
+func Timeout(d time.Duration) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			serveWithTimeout(w, r, next, d)
+		})
+	}
+}
+
+// timeoutBufferSize - Сколько байт ответа serveWithTimeout накапливает, прежде чем начать передавать его клиенту
+const timeoutBufferSize = 64 << 10
+
+// serveWithTimeout - Выполнение обработчика next с контекстом, который отменяется через d.
+//
+// Начало ответа обработчика накапливается в буфере. Если обработчик не успел завершиться, клиент получает 504,
+// а все дальнейшие записи обработчика возвращают http.ErrHandlerTimeout. Когда ответ вырастает больше
+// timeoutBufferSize или обработчик вызывает Flush, накопленное отправляется клиенту и дальше ответ передается
+// без буфера: так файлы и потоки NDJSON не хранятся в памяти целиком. Если время выйдет уже после этого,
+// отправить 504 нельзя, и соединение прерывается, чтобы клиент не принял неполный ответ за целый.
+// Обработчик должен следить за r.Context(), чтобы прекратить работу после отмены
+func serveWithTimeout(w http.ResponseWriter, r *http.Request, next http.Handler, d time.Duration) {
+	ctx, cancel := context.WithTimeout(r.Context(), d)
+	defer cancel()
+
+	// Обработчик видит уже установленные заголовки ответа, например X-Request-ID
+	tw := &timeoutWriter{header: w.Header().Clone(), orig: w, ctx: ctx}
+	done := make(chan struct{})
+	panicked := make(chan *handlerPanic, 1)
+
+	go func() {
+		defer func() {
+			// Паника в обработчике передается в исходную горутину, чтобы ее перехватил Recovery.
+			// Стек сохраняется здесь: после повторной паники в другой горутине он будет уже другим
+			if p := recover(); p != nil {
+				panicked <- &handlerPanic{value: p, stack: debug.Stack()}
+			}
+		}()
+
+		next.ServeHTTP(tw, r.WithContext(ctx))
+		close(done)
+	}()
+
+	select {
+	case p := <-panicked:
+		panic(p)
+
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+
+		if !tw.streaming {
+			tw.commit()
+		}
+
+	case <-ctx.Done():
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+
+		tw.timedOut = true
+		if tw.streaming {
+			logging.From(r.Context()).Warn("request timeout: response already started, connection aborted", "timeout", d)
+			panic(http.ErrAbortHandler)
+		}
+		response.Error(w, http.StatusGatewayTimeout, "превышено время обработки запроса")
+	}
+}
+
+// timeoutWriter - ResponseWriter, накапливающий начало ответа обработчика, см. serveWithTimeout
+type timeoutWriter struct {
+	orig        http.ResponseWriter // Исходный ResponseWriter, обработчик пишет в него только через timeoutWriter
+	ctx         context.Context     // Контекст обработчика: после его отмены запись не принимается, даже если 504 еще не отправлен
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	streaming   bool // Заголовки и буфер отправлены клиенту, дальше ответ передается без буфера
+	timedOut    bool // Время вышло: клиенту уже отправлен 504 или соединение прервано
+}
+
+// ResponseOptions - Настройки ответов исходного ResponseWriter. Unwrap не реализован: обработчик, продолжающий
+// работу после 504, не должен получить доступ к исходному ResponseWriter. Flush доступен через FlushError
+func (tw *timeoutWriter) ResponseOptions() response.Options {
+	return response.OptionsOf(tw.orig)
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired() || tw.wroteHeader {
+		return
+	}
+	tw.status = status
+	tw.wroteHeader = true
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.streaming {
+		return tw.orig.Write(p)
+	}
+	if !tw.wroteHeader {
+		tw.status = http.StatusOK
+		tw.wroteHeader = true
+	}
+	n, err := tw.buf.Write(p)
+	if tw.buf.Len() > timeoutBufferSize {
+		tw.commit()
+	}
+	return n, err
+}
+
+// FlushError - Отправка клиенту уже записанной части ответа, в том числе через http.ResponseController.
+// После этого ответ передается без буфера
+func (tw *timeoutWriter) FlushError() error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.expired() {
+		return http.ErrHandlerTimeout
+	}
+	if !tw.streaming {
+		tw.commit()
+	}
+	return http.NewResponseController(tw.orig).Flush()
+}
+
+// Flush - http.Flusher, см. FlushError
+func (tw *timeoutWriter) Flush() {
+	_ = tw.FlushError()
+}
+
+// expired - Время обработки вышло. Вызывается под mu
+func (tw *timeoutWriter) expired() bool {
+	return tw.timedOut || tw.ctx.Err() != nil
+}
+
+// commit - Отправка заголовков и накопленного ответа в исходный ResponseWriter. Вызывается под mu
+func (tw *timeoutWriter) commit() {
+	// Заголовки, удаленные обработчиком из копии, удаляются и из исходного ответа
+	dst := tw.orig.Header()
+	for k := range dst {
+		if _, ok := tw.header[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	if !tw.wroteHeader {
+		tw.status = http.StatusOK
+		tw.wroteHeader = true
+	}
+	tw.orig.WriteHeader(tw.status)
+	tw.orig.Write(tw.buf.Bytes())
+	tw.buf = bytes.Buffer{}
+	tw.streaming = true
+}
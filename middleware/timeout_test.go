@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveTimeout - Запуск h через Timeout(d) в отдельной горутине. Канал получает значение паники
+// serveWithTimeout (nil - без паники), когда обработка завершится
+func serveTimeout(w http.ResponseWriter, h http.HandlerFunc, d time.Duration) <-chan any {
+	done := make(chan any, 1)
+	go func() {
+		defer func() { done <- recover() }()
+		Timeout(d)(h).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/report", nil))
+	}()
+	return done
+}
+
+// wait - Ожидание завершения serveTimeout
+func wait(t *testing.T, done <-chan any) any {
+	t.Helper()
+	select {
+	case p := <-done:
+		return p
+	case <-time.After(5 * time.Second):
+		t.Fatalf("обработка запроса не завершилась")
+		return nil
+	}
+}
+
+func TestTimeoutBuffered(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Request-ID", "req-1")
+	w.Header().Set("X-Removed", "1")
+
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Del("X-Removed")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	}
+	if p := wait(t, serveTimeout(w, h, time.Second)); p != nil {
+		t.Fatalf("паника: %v", p)
+	}
+
+	if w.Code != http.StatusCreated || w.Body.String() != "done" {
+		t.Fatalf("статус %d, тело %q, ожидается 201 и done", w.Code, w.Body.String())
+	}
+	if got := w.Header(); got.Get("X-Request-ID") != "req-1" || got.Get("Content-Type") != "text/plain" {
+		t.Fatalf("заголовки ответа %v", got)
+	}
+	if _, ok := w.Header()["X-Removed"]; ok {
+		t.Fatalf("заголовок, удаленный обработчиком, остался в ответе")
+	}
+}
+
+func TestTimeoutExpired(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeErr := make(chan error, 1)
+
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Partial", "1")
+		w.Write([]byte("partial"))
+		<-r.Context().Done()
+		_, err := w.Write([]byte("late"))
+		writeErr <- err
+	}
+	if p := wait(t, serveTimeout(w, h, 20*time.Millisecond)); p != nil {
+		t.Fatalf("паника: %v", p)
+	}
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("статус %d, ожидается 504", w.Code)
+	}
+	if body := w.Body.String(); strings.Contains(body, "partial") {
+		t.Fatalf("в ответ 504 попало начало ответа обработчика: %q", body)
+	}
+	if w.Header().Get("X-Partial") != "" {
+		t.Fatalf("в ответ 504 попали заголовки обработчика")
+	}
+	if err := <-writeErr; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Fatalf("запись после 504: %v, ожидается http.ErrHandlerTimeout", err)
+	}
+}
+
+func TestTimeoutStreamsLargeResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	chunk := bytes.Repeat([]byte("x"), 16<<10)
+	wrote, release := make(chan struct{}), make(chan struct{})
+
+	h := func(w http.ResponseWriter, r *http.Request) {
+		for range timeoutBufferSize/len(chunk) + 1 {
+			w.Write(chunk)
+		}
+		close(wrote)
+		<-release
+		w.Write(chunk)
+	}
+	done := serveTimeout(w, h, time.Second)
+
+	<-wrote
+	// Ответ больше буфера уже передан клиенту, пока обработчик продолжает работу
+	if got := w.Body.Len(); got <= timeoutBufferSize {
+		t.Fatalf("до завершения обработчика передано %d байт, ожидается больше %d", got, timeoutBufferSize)
+	}
+	close(release)
+
+	if p := wait(t, done); p != nil {
+		t.Fatalf("паника: %v", p)
+	}
+	if want := (timeoutBufferSize/len(chunk) + 2) * len(chunk); w.Code != http.StatusOK || w.Body.Len() != want {
+		t.Fatalf("статус %d, %d байт, ожидается 200 и %d байт", w.Code, w.Body.Len(), want)
+	}
+}
+
+func TestTimeoutFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	flushed, release := make(chan error, 1), make(chan struct{})
+
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{}\n"))
+		flushed <- http.NewResponseController(w).Flush()
+		<-release
+		w.Write([]byte("{}\n"))
+	}
+	done := serveTimeout(w, h, time.Second)
+
+	if err := <-flushed; err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if !w.Flushed || w.Body.String() != "{}\n" || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("после Flush: flushed %v, тело %q, заголовки %v", w.Flushed, w.Body.String(), w.Header())
+	}
+	close(release)
+
+	if p := wait(t, done); p != nil {
+		t.Fatalf("паника: %v", p)
+	}
+	if w.Body.String() != "{}\n{}\n" {
+		t.Fatalf("тело %q", w.Body.String())
+	}
+}
+
+func TestTimeoutAfterStreamingAborts(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}\n"))
+		http.NewResponseController(w).Flush()
+		<-r.Context().Done()
+	}
+
+	// Ответ уже начат, 504 отправить нельзя: соединение прерывается
+	if p := wait(t, serveTimeout(w, h, 20*time.Millisecond)); p != http.ErrAbortHandler {
+		t.Fatalf("паника %v, ожидается http.ErrAbortHandler", p)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "{}\n" {
+		t.Fatalf("статус %d, тело %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutHandlerPanic(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}
+
+	p, ok := wait(t, serveTimeout(httptest.NewRecorder(), h, time.Second)).(*handlerPanic)
+	if !ok || p.value != "boom" || len(p.stack) == 0 {
+		t.Fatalf("паника обработчика не передана в вызывающую горутину со стеком: %#v", p)
+	}
+}
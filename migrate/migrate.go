@@ -0,0 +1,284 @@
+// Package migrate - Миграции схемы базы данных SQL: пронумерованные файлы up и down, встроенные в сервер,
+// и таблица примененных версий
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrPending - Не все миграции применены, см. Check
+var ErrPending = errors.New("схема базы устарела")
+
+// Migration - Изменение схемы: SQL применения Up и отката Down
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string // Пустой - миграцию нельзя откатить
+}
+
+// fileName - Имя файла миграции: версия, название и направление, например 0001_create_kv.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load - Миграции из файлов fsys в каталоге dir по возрастанию версии. У каждой версии должен быть файл up,
+// файл down необязателен
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			return nil, fmt.Errorf("migrate: %s: ожидается имя вида 0001_name.up.sql или 0001_name.down.sql", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrate: у версии %d разные названия: %s и %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if strings.TrimSpace(mig.Up) == "" {
+			return nil, fmt.Errorf("migrate: у версии %d нет файла up", mig.Version)
+		}
+		list = append(list, *mig)
+	}
+	slices.SortFunc(list, func(a, b Migration) int { return a.Version - b.Version })
+	return list, nil
+}
+
+// Options - Параметры Migrator
+type Options struct {
+	// Table - Таблица примененных версий. У каждого набора миграций своя, даже в одной базе
+	Table string
+	// Numbered - Параметры запросов нумеруются ($1, $2, ...), как в PostgreSQL, а не обозначаются ?
+	Numbered bool
+	// Lock и Unlock - Запросы блокировки, чтобы несколько экземпляров сервера, запущенных одновременно,
+	// не применяли миграции параллельно, например SELECT pg_advisory_lock(1). Пустые - без блокировки
+	Lock, Unlock string
+	// DryRun - Up и Down только записывают в лог SQL миграций, не выполняя его.
+	// Создается только таблица версий, если ее нет
+	DryRun bool
+}
+
+// Migrator - Применение и откат миграций в базе данных
+type Migrator struct {
+	db   *sql.DB
+	list []Migration
+	opts Options
+}
+
+// New - Migrator миграций list, загруженных Load, в базе db
+func New(db *sql.DB, list []Migration, opts Options) *Migrator {
+	return &Migrator{db: db, list: list, opts: opts}
+}
+
+// Status - Примененные версии по возрастанию и миграции, которые еще не применены
+func (m *Migrator) Status(ctx context.Context) (applied []int, pending []Migration, err error) {
+	err = m.withConn(ctx, func(conn *sql.Conn) error {
+		applied, err = m.applied(ctx, conn)
+		pending = m.pending(applied)
+		return err
+	})
+	return applied, pending, err
+}
+
+// Up - Применение всех миграций, которых еще нет в базе, по возрастанию версии. Каждая миграция выполняется
+// в своей транзакции вместе с записью версии, поэтому при ошибке примененными остаются предыдущие.
+// Возвращает примененные миграции, при DryRun - те, которые были бы применены
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withConn(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.pending(applied) {
+			if m.opts.DryRun {
+				slog.Info("migrate: dry run: up", "table", m.opts.Table, "version", mig.Version, "name", mig.Name, "sql", mig.Up)
+				done = append(done, mig)
+				continue
+			}
+
+			err := m.inTx(ctx, conn, mig.Up, m.query(`INSERT INTO `+m.opts.Table+` (version, name, applied_at) VALUES (?, ?, ?)`),
+				mig.Version, mig.Name, time.Now().Unix())
+			if err != nil {
+				return fmt.Errorf("migrate: %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+			slog.Info("migrate: up", "table", m.opts.Table, "version", mig.Version, "name", mig.Name)
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down - Откат последней примененной миграции. false, если ни одна миграция не применена
+func (m *Migrator) Down(ctx context.Context) (Migration, bool, error) {
+	var (
+		mig Migration
+		ok  bool
+	)
+	err := m.withConn(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil || len(applied) == 0 {
+			return err
+		}
+
+		version := applied[len(applied)-1]
+		i := slices.IndexFunc(m.list, func(mig Migration) bool { return mig.Version == version })
+		if i < 0 {
+			return fmt.Errorf("migrate: версии %d нет среди миграций сервера: база изменена более новой версией", version)
+		}
+		mig, ok = m.list[i], true
+		if strings.TrimSpace(mig.Down) == "" {
+			return fmt.Errorf("migrate: %04d_%s: миграцию нельзя откатить, нет файла down", mig.Version, mig.Name)
+		}
+
+		if m.opts.DryRun {
+			slog.Info("migrate: dry run: down", "table", m.opts.Table, "version", mig.Version, "name", mig.Name, "sql", mig.Down)
+			return nil
+		}
+		if err := m.inTx(ctx, conn, mig.Down, m.query(`DELETE FROM `+m.opts.Table+` WHERE version = ?`), mig.Version); err != nil {
+			return fmt.Errorf("migrate: %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		slog.Info("migrate: down", "table", m.opts.Table, "version", mig.Version, "name", mig.Name)
+		return nil
+	})
+	return mig, ok, err
+}
+
+// withConn - Выполнение fn на одном соединении под блокировкой Options.Lock. Таблица версий создается, если ее нет
+func (m *Migrator) withConn(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.opts.Lock != "" {
+		if _, err := conn.ExecContext(ctx, m.opts.Lock); err != nil {
+			return fmt.Errorf("migrate: блокировка: %w", err)
+		}
+		defer func() {
+			// Блокировка снимается и при закрытии соединения, но оно может вернуться в пул открытым
+			if _, unlockErr := conn.ExecContext(context.WithoutCancel(ctx), m.opts.Unlock); unlockErr != nil && err == nil {
+				err = fmt.Errorf("migrate: снятие блокировки: %w", unlockErr)
+			}
+		}()
+	}
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+m.opts.Table+` (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at BIGINT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("migrate: таблица версий %s: %w", m.opts.Table, err)
+	}
+	return fn(conn)
+}
+
+// applied - Примененные версии по возрастанию
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) ([]int, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM `+m.opts.Table+` ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// pending - Миграции, версий которых нет в applied
+func (m *Migrator) pending(applied []int) []Migration {
+	var list []Migration
+	for _, mig := range m.list {
+		if !slices.Contains(applied, mig.Version) {
+			list = append(list, mig)
+		}
+	}
+	return list
+}
+
+// inTx - Выполнение скрипта миграции script и запроса q к таблице версий в одной транзакции
+func (m *Migrator) inTx(ctx context.Context, conn *sql.Conn, script, q string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // После Commit ничего не делает
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// query - Запрос q с параметрами в записи базы данных
+func (m *Migrator) query(q string) string {
+	if !m.opts.Numbered {
+		return q
+	}
+	for n := 1; strings.Contains(q, "?"); n++ {
+		q = strings.Replace(q, "?", "$"+strconv.Itoa(n), 1)
+	}
+	return q
+}
+
+// Check - Ошибка ErrPending, если есть непримененные миграции: сервер не должен работать со старой схемой.
+// Версии, неизвестные серверу, только записываются в лог: базу уже обновил более новый экземпляр
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, pending, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: не применено миграций: %d, первая - %04d_%s", ErrPending, len(pending), pending[0].Version, pending[0].Name)
+	}
+	if n := len(applied); n > 0 && len(m.list) > 0 && applied[n-1] > m.list[len(m.list)-1].Version {
+		slog.Warn("migrate: database schema is newer than this server", "table", m.opts.Table, "version", applied[n-1])
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/jobs"
+	"github.com/derv-dice/go-web-server/kv"
+	"github.com/derv-dice/go-web-server/outbox"
+)
+
+// newDispatcher - Запись событий изменений ключей store в outbox и их доставка в webhook из jobs.webhooks.
+// nil, если outbox выключен
+func newDispatcher(cfg config.Config, store kv.Store) *outbox.Dispatcher {
+	if !cfg.Outbox.Enabled {
+		return nil
+	}
+	// Проверка конфигурации гарантирует хранилище в базе данных
+	events := store.(*kv.SQL).EnableOutbox()
+
+	hooks := cfg.Jobs.Webhooks
+	client := &http.Client{}
+	deliver := func(ctx context.Context, ev outbox.Event) error {
+		var (
+			body []byte
+			errs []error
+		)
+		for _, w := range hooks {
+			if w.Event != ev.Type {
+				continue
+			}
+			if body == nil {
+				var err error
+				if body, err = json.Marshal(event{Event: ev.Type, Time: ev.CreatedAt, Data: ev.Payload}); err != nil {
+					return err
+				}
+			}
+			p := jobs.WebhookPayload{URL: w.URL, Event: ev.Type, Body: body}
+			errs = append(errs, jobs.SendWebhook(ctx, client, ev.ID, p, w.Secret))
+		}
+		// Событие без получателей считается доставленным и удаляется
+		return errors.Join(errs...)
+	}
+
+	o := cfg.Outbox
+	return outbox.New(events, deliver, outbox.Options{
+		Interval:    o.Interval.D(),
+		MaxAttempts: o.MaxAttempts,
+		BaseBackoff: o.BaseBackoff.D(),
+		MaxBackoff:  o.MaxBackoff.D(),
+		Timeout:     o.Timeout.D(),
+	})
+}
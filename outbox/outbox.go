@@ -0,0 +1,126 @@
+// Package outbox - Надежная доставка событий об изменениях: событие записывается в базу данных в той же
+// транзакции, что и изменение, а Dispatcher доставляет его получателям после фиксации. Если процесс
+// завершится до доставки, событие останется в базе и будет доставлено после перезапуска
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	mrand "math/rand/v2"
+	"time"
+)
+
+// Event - Событие в outbox
+type Event struct {
+	ID        string          `json:"id"` // Одинаковый во всех попытках доставки: получатель может отбрасывать дубли
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	Attempts  int             `json:"attempts"` // Попытки доставки, включая текущую
+}
+
+// Store - Таблица outbox. Несколько экземпляров сервера могут доставлять события из одной таблицы:
+// Claim занимает событие, чтобы его не взял другой экземпляр
+type Store interface {
+	// Claim - Событие, время доставки которого наступило к now, занятое до until. false - таких нет.
+	// Если процесс завершится во время доставки, событие снова станет доступно после until
+	Claim(ctx context.Context, now, until time.Time) (Event, bool, error)
+	// Delivered - Удаление доставленного события
+	Delivered(ctx context.Context, id string) error
+	// Retry - Следующая попытка доставки не раньше at
+	Retry(ctx context.Context, id string, at time.Time, lastErr string) error
+	// Dead - Попытки доставки исчерпаны. Событие остается в таблице для разбора, но больше не доставляется
+	Dead(ctx context.Context, id string, lastErr string) error
+}
+
+// Deliver - Доставка события всем получателям. Ошибка - доставка будет повторена позже целиком,
+// поэтому получатели должны отбрасывать повторы по Event.ID
+type Deliver func(ctx context.Context, ev Event) error
+
+// Options - Параметры Dispatcher
+type Options struct {
+	Interval    time.Duration // Как часто проверяется таблица, когда событий нет
+	MaxAttempts int           // Попыток доставки до перевода события в dead
+	BaseBackoff time.Duration // Пауза перед первым повтором, каждая следующая вдвое дольше
+	MaxBackoff  time.Duration // Наибольшая пауза перед повтором
+	Timeout     time.Duration // Ограничение времени одной доставки
+}
+
+// Dispatcher - Доставка событий из Store. События доставляются по одному в порядке записи,
+// но повтор после ошибки может доставить событие позже следующих
+type Dispatcher struct {
+	store   Store
+	deliver Deliver
+	opts    Options
+}
+
+// New - Dispatcher событий store
+func New(store Store, deliver Deliver, opts Options) *Dispatcher {
+	return &Dispatcher{store: store, deliver: deliver, opts: opts}
+}
+
+// Run - Доставка событий до отмены ctx. Начатая доставка завершается, ее ограничивает только Options.Timeout
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Пока события есть, они доставляются подряд, без ожидания следующей проверки
+		for ctx.Err() == nil && d.next(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// next - Доставка одного события. false - событий нет или таблица недоступна
+func (d *Dispatcher) next(ctx context.Context) bool {
+	now := time.Now()
+	// Занятое событие освобождается вдвое позже ограничения доставки: до этого его еще может доставлять
+	// другой экземпляр
+	ev, ok, err := d.store.Claim(ctx, now, now.Add(2*d.opts.Timeout))
+	if err != nil {
+		slog.Warn("outbox: claim event", "error", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	dctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), d.opts.Timeout)
+	err = d.deliver(dctx, ev)
+	cancel()
+
+	// Результат записывается и после отмены ctx: иначе доставленное событие было бы доставлено повторно
+	sctx := context.WithoutCancel(ctx)
+	switch {
+	case err == nil:
+		err = d.store.Delivered(sctx, ev.ID)
+	case ev.Attempts >= d.opts.MaxAttempts:
+		slog.Error("outbox: event dead", "id", ev.ID, "type", ev.Type, "attempts", ev.Attempts, "error", err)
+		err = d.store.Dead(sctx, ev.ID, err.Error())
+	default:
+		at := time.Now().Add(d.backoff(ev.Attempts))
+		slog.Warn("outbox: delivery will be retried", "id", ev.ID, "type", ev.Type, "attempts", ev.Attempts, "retry_at", at, "error", err)
+		err = d.store.Retry(sctx, ev.ID, at, err.Error())
+	}
+	if err != nil {
+		slog.Warn("outbox: save delivery result", "id", ev.ID, "error", err)
+		return false
+	}
+	return true
+}
+
+// backoff - Пауза перед повтором после attempt неудачных попыток, как у очереди задач jobs:
+// BaseBackoff, удвоенная за каждую попытку, не больше MaxBackoff, со случайной половиной
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	p := float64(d.opts.BaseBackoff) * math.Pow(2, float64(attempt-1))
+	p = math.Min(p, float64(d.opts.MaxBackoff))
+	return time.Duration(p/2 + mrand.Float64()*p/2)
+}
@@ -0,0 +1,88 @@
+// Package proxy - Маршруты, перенаправляющие запросы на другой сервер (обратный прокси)
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// New - Обработчик, проксирующий запросы на upstream из настроек cfg.
+//
+// Если включен strip_prefix, префикс маршрута удаляется из пути: при prefix /backend и upstream
+// http://10.0.0.1:9000/api запрос /backend/users уходит на http://10.0.0.1:9000/api/users.
+// В запрос к upstream добавляются заголовки X-Forwarded-For, X-Forwarded-Host, X-Forwarded-Proto
+// и заголовки из cfg.Headers. Ошибки соединения с upstream передаются в onError
+func New(cfg config.Proxy, onError func(w http.ResponseWriter, r *http.Request, err error)) (http.Handler, error) {
+	target, err := url.Parse(cfg.Upstream)
+	if err != nil {
+		return nil, fmt.Errorf("upstream: %w", err)
+	}
+
+	prefix := strings.TrimSuffix(cfg.Prefix, "/")
+
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if cfg.StripPrefix {
+				pr.Out.URL.Path = stripPrefix(pr.In.URL.Path, prefix)
+				pr.Out.URL.RawPath = stripPrefix(pr.In.URL.RawPath, prefix)
+			}
+
+			// Путь upstream дописывается перед путем запроса, Host запроса меняется на хост upstream
+			pr.SetURL(target)
+			pr.SetXForwarded()
+
+			if cfg.PreserveHost {
+				pr.Out.Host = pr.In.Host
+			}
+
+			for k, v := range cfg.Headers {
+				pr.Out.Header.Set(k, v)
+			}
+		},
+		ErrorHandler: onError,
+	}, nil
+}
+
+// stripPrefix - Путь без префикса prefix. Результат всегда начинается с "/", кроме пустого пути
+func stripPrefix(path, prefix string) string {
+	if path == "" {
+		return ""
+	}
+
+	path = strings.TrimPrefix(path, prefix)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// Reachable - Проверка, что upstream принимает TCP соединения. Запрос к самому upstream не отправляется
+func Reachable(ctx context.Context, upstream string) error {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return err
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,123 @@
+// Package realip - IP адрес клиента за обратным прокси и балансировщиком нагрузки.
+//
+// Middleware определяет адрес по заголовкам X-Forwarded-For и X-Real-IP от доверенных прокси и сохраняет его
+// в контексте запроса, а логи, ограничение частоты запросов и фильтр по IP берут его через From.
+// Адрес, полученный из PROXY protocol, уже содержится в r.RemoteAddr
+package realip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ipKey - Ключ контекста для адреса клиента
+type ipKey struct{}
+
+// With - Контекст с адресом клиента ip
+func With(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ipKey{}, ip)
+}
+
+// From - IP адрес клиента без порта: определенный по заголовкам доверенного прокси или адрес соединения.
+// Для запросов через Unix сокет без заголовков - r.RemoteAddr как есть
+func From(r *http.Request) string {
+	if ip, ok := r.Context().Value(ipKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// remoteIP - Адрес соединения без порта
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// Адрес без порта, например у запросов через Unix сокет
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Resolver - Определение адреса клиента по заголовкам запросов от доверенных прокси
+type Resolver struct {
+	Trusted   []netip.Prefix // Сети доверенных прокси
+	TrustUnix bool           // Считать доверенными запросы через Unix сокет
+	Headers   []string       // Заголовки с адресом клиента в порядке проверки
+}
+
+// Resolve - Адрес клиента запроса r. Если запрос пришел не от доверенного прокси, заголовки не учитываются:
+// иначе клиент мог бы подставить в них любой адрес
+func (res Resolver) Resolve(r *http.Request) string {
+	remote := remoteIP(r)
+	if !res.trusted(remote) {
+		return remote
+	}
+
+	for _, name := range res.Headers {
+		values := r.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		if http.CanonicalHeaderKey(name) == "X-Forwarded-For" {
+			if ip, ok := res.forwardedFor(values); ok {
+				return ip
+			}
+			continue
+		}
+
+		// Заголовок с одним адресом ставит ближайший к серверу прокси, поэтому берется последнее значение
+		if addr, err := netip.ParseAddr(strings.TrimSpace(values[len(values)-1])); err == nil {
+			return addr.Unmap().String()
+		}
+	}
+	return remote
+}
+
+// forwardedFor - Адрес клиента из X-Forwarded-For. Каждый прокси дописывает адрес своего клиента в конец,
+// поэтому список разбирается справа налево: первый адрес не из доверенных сетей - клиент. Левее него
+// значения подставлены самим клиентом и не проверяются. Если доверенные все адреса, клиент - самый левый
+func (res Resolver) forwardedFor(values []string) (string, bool) {
+	var hops []string
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	var client string
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			// Некорректное значение: дальше по цепочке доверять нельзя
+			break
+		}
+		client = addr.Unmap().String()
+		if !res.trustedAddr(addr) {
+			break
+		}
+	}
+	return client, client != ""
+}
+
+// trusted - Адрес соединения принадлежит доверенному прокси
+func (res Resolver) trusted(remote string) bool {
+	addr, err := netip.ParseAddr(remote)
+	if err != nil {
+		// Unix сокет: адрес соединения пуст или "@"
+		return res.TrustUnix
+	}
+	return res.trustedAddr(addr)
+}
+
+func (res Resolver) trustedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range res.Trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
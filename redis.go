@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/health"
+	"github.com/derv-dice/go-web-server/middleware"
+	"github.com/derv-dice/go-web-server/redis"
+)
+
+// openRedis - Подключение к Redis по настройкам redis. nil, если Redis выключен
+func openRedis(cfg config.Redis) (*redis.Client, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	client, err := redis.Open(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	readiness.checks.Register("redis", health.Ping(client))
+	return client, nil
+}
+
+// newRateLimiter - Хранилище корзин rate_limit: общее в Redis или в памяти процесса
+func newRateLimiter(cfg config.RateLimit, shared *redis.Client) middleware.RateLimiter {
+	if shared != nil && cfg.Store == config.StateRedis {
+		return shared.Limiter
+	}
+	return middleware.NewMemoryRateLimiter()
+}
+
+// newResponseCache - Хранилище кэша ответов cache.responses: общее в Redis или в памяти процесса
+func newResponseCache(cfg config.ResponseCache, shared *redis.Client) middleware.ResponseCache {
+	if shared != nil && cfg.Store == config.StateRedis {
+		return shared.Responses
+	}
+	return middleware.NewMemoryResponseCache(cfg.MaxEntries, cfg.MaxMemoryBytes)
+}
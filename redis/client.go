@@ -0,0 +1,237 @@
+//go:build redis
+
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// Open - Подключение к Redis по настройкам cfg. Redis должен быть доступен при запуске, потерянные потом
+// соединения клиент открывает заново
+func Open(ctx context.Context, cfg config.Redis) (*Client, error) {
+	opts := &goredis.Options{
+		Addr:                  cfg.Addr,
+		Username:              cfg.Username,
+		Password:              cfg.Password,
+		DB:                    cfg.DB,
+		PoolSize:              cfg.PoolSize,
+		DialTimeout:           cfg.DialTimeout.D(),
+		ReadTimeout:           cfg.Timeout.D(),
+		WriteTimeout:          cfg.Timeout.D(),
+		ContextTimeoutEnabled: true,
+	}
+	if cfg.TLS {
+		host, _, _ := net.SplitHostPort(cfg.Addr)
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, ServerName: host}
+	}
+
+	rdb := goredis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(ctx, cfg.DialTimeout.D())
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("redis: %s: %w", cfg.Addr, err)
+	}
+
+	return &Client{
+		Limiter:   limiter{rdb: rdb, prefix: cfg.KeyPrefix + "ratelimit:"},
+		Sessions:  sessions{rdb: rdb, prefix: cfg.KeyPrefix + "session:"},
+		Responses: responses{rdb: rdb, prefix: cfg.KeyPrefix + "response:"},
+		ping:      func(ctx context.Context) error { return rdb.Ping(ctx).Err() },
+		close:     rdb.Close,
+	}, nil
+}
+
+// takeScript - Корзина токенов в хэше KEYS[1] с полями tokens и last, как у middleware.MemoryRateLimiter.
+// ARGV - rate и burst. Время берется у Redis, чтобы расхождение часов экземпляров сервера не влияло на лимит.
+// Результат - {1, "0"}, если токен взят, или {0, секунды до появления следующего}
+var takeScript = goredis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(b[1]) or burst
+local last = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - last) * rate)
+
+if tokens < 1 then
+	return {0, tostring((1 - tokens) / rate)}
+end
+
+tokens = tokens - 1
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', tostring(now))
+-- Полностью пополнившаяся корзина не отличается от новой и удаляется
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000))
+return {1, '0'}
+`)
+
+// limiter - middleware.RateLimiter с корзинами в Redis, общими для всех экземпляров сервера
+type limiter struct {
+	rdb    *goredis.Client
+	prefix string
+}
+
+func (l limiter) Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	res, err := takeScript.Run(ctx, l.rdb, []string{l.prefix + key}, rate, burst).Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("redis: %w", err)
+	}
+	if len(res) != 2 {
+		return false, 0, fmt.Errorf("redis: неожиданный ответ скрипта лимита: %v", res)
+	}
+
+	ok, _ := res[0].(int64)
+	s, _ := res[1].(string)
+	wait, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("redis: неожиданный ответ скрипта лимита: %v", res)
+	}
+	return ok == 1, time.Duration(wait * float64(time.Second)), nil
+}
+
+// sessions - session.Store в Redis. В cookie хранится случайный идентификатор, а ключ записи - его хэш,
+// чтобы по содержимому Redis нельзя было подделать cookie
+type sessions struct {
+	rdb    *goredis.Client
+	prefix string
+}
+
+func (s sessions) key(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return s.prefix + hex.EncodeToString(sum[:])
+}
+
+func (s sessions) Load(ctx context.Context, token string) (map[string]any, error) {
+	data, err := s.rdb.Get(ctx, s.key(token)).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, errors.New("session: сессия не найдена")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("redis: сессия: %w", err)
+	}
+	return values, nil
+}
+
+func (s sessions) Save(ctx context.Context, token string, values map[string]any, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("session: %w", err)
+	}
+
+	if token != "" {
+		// Запись обновляется, только если она еще есть: удаленная сессия получает новый идентификатор
+		ok, err := s.rdb.SetXX(ctx, s.key(token), data, ttl).Result()
+		if err != nil {
+			return "", fmt.Errorf("redis: %w", err)
+		}
+		if ok {
+			return token, nil
+		}
+	}
+
+	token = newToken()
+	if err := s.rdb.Set(ctx, s.key(token), data, ttl).Err(); err != nil {
+		return "", fmt.Errorf("redis: %w", err)
+	}
+	return token, nil
+}
+
+func (s sessions) Delete(ctx context.Context, token string) error {
+	if err := s.rdb.Del(ctx, s.key(token)).Err(); err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	return nil
+}
+
+// newToken - Случайный идентификатор сессии из 32 байт
+func newToken() string {
+	var b [32]byte
+	_, _ = rand.Read(b[:])
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+// responses - middleware.ResponseCache в Redis: кэш ответов общий для всех экземпляров сервера
+type responses struct {
+	rdb    *goredis.Client
+	prefix string
+}
+
+func (r responses) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := r.rdb.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("redis: %w", err)
+	}
+	return data, true, nil
+}
+
+func (r responses) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := r.rdb.Set(ctx, r.prefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	return nil
+}
+
+// Purge - Ключи ищутся командой SCAN по шаблону и удаляются частями, не блокируя Redis надолго.
+// Ответы, сохраненные во время удаления, могут остаться
+func (r responses) Purge(ctx context.Context, prefix string) (int, error) {
+	// Символы шаблона SCAN в пути должны совпадать буквально
+	pattern := r.prefix + globEscaper.Replace(prefix) + "*"
+
+	n := 0
+	iter := r.rdb.Scan(ctx, 0, pattern, purgeBatch).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == purgeBatch {
+			deleted, err := r.rdb.Unlink(ctx, keys...).Result()
+			n += int(deleted)
+			if err != nil {
+				return n, fmt.Errorf("redis: %w", err)
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return n, fmt.Errorf("redis: %w", err)
+	}
+	if len(keys) > 0 {
+		deleted, err := r.rdb.Unlink(ctx, keys...).Result()
+		n += int(deleted)
+		if err != nil {
+			return n, fmt.Errorf("redis: %w", err)
+		}
+	}
+	return n, nil
+}
+
+// purgeBatch - Сколько ключей Purge запрашивает у SCAN и удаляет одной командой
+const purgeBatch = 500
+
+// globEscaper - Экранирование символов шаблона SCAN MATCH
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
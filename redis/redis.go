@@ -0,0 +1,30 @@
+// Package redis - Общее для нескольких экземпляров сервера состояние в Redis: корзины ограничения частоты
+// запросов, сессии и кэш ответов
+package redis
+
+import (
+	"context"
+
+	"github.com/derv-dice/go-web-server/middleware"
+	"github.com/derv-dice/go-web-server/session"
+)
+
+// Client - Хранилища в одном Redis, см. Open. Ключи всех хранилищ начинаются с redis.key_prefix
+type Client struct {
+	Limiter   middleware.RateLimiter   // Корзины rate_limit
+	Sessions  session.Store            // Сессии пользователей
+	Responses middleware.ResponseCache // Кэш ответов cache.responses
+
+	ping  func(ctx context.Context) error
+	close func() error
+}
+
+// PingContext - Проверка соединения с Redis, см. health.Ping
+func (c *Client) PingContext(ctx context.Context) error {
+	return c.ping(ctx)
+}
+
+// Close - Закрытие соединений с Redis
+func (c *Client) Close() error {
+	return c.close()
+}
@@ -0,0 +1,15 @@
+//go:build !redis
+
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// Open - Redis доступен только при сборке с тегом redis
+func Open(context.Context, config.Redis) (*Client, error) {
+	return nil, errors.New("redis: сервер собран без поддержки Redis, пересоберите с -tags redis")
+}
@@ -0,0 +1,68 @@
+package response
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/derv-dice/go-web-server/apperr"
+	"github.com/derv-dice/go-web-server/errreport"
+	"github.com/derv-dice/go-web-server/logging"
+)
+
+// HandlerFunc - Обработчик, возвращающий ошибку вместо самостоятельной отправки ответа с ошибкой
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Handle - http.HandlerFunc из обработчика h. Ошибка h отправляется клиенту:
+//   - *apperr.Error - со статусом по коду ошибки, ее текстом и подробностями;
+//   - превышение лимита тела запроса (http.MaxBytesError) - 413 PAYLOAD_TOO_LARGE;
+//   - любая другая ошибка логируется и передается в errreport, а клиент получает 500 INTERNAL без ее текста.
+//
+// Ошибки apperr со статусом 5xx тоже передаются в errreport. Если h уже начал отправку ответа, ответ не меняется
+func Handle(h HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tw := &trackWriter{ResponseWriter: w}
+		err := h(tw, r)
+		if err == nil {
+			return
+		}
+
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			err = apperr.Wrap(err, apperr.PayloadTooLarge, "тело запроса больше допустимого размера")
+		}
+
+		e, ok := apperr.As(err)
+		if !ok || e.Code.Status() >= http.StatusInternalServerError || tw.wrote {
+			logging.From(r.Context()).Error("handler", "error", err)
+		}
+		if !ok || e.Code.Status() >= http.StatusInternalServerError {
+			errreport.Report(r.Context(), errreport.Event{Err: err, RequestID: w.Header().Get(RequestIDHeader), Request: r})
+		}
+		if tw.wrote {
+			return
+		}
+
+		Respond(w, r, e.Code.Status(), Body{Error: e.Message, Code: string(e.Code), Data: e.Data})
+	}
+}
+
+// trackWriter - ResponseWriter, запоминающий, начал ли обработчик отправку ответа
+type trackWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (w *trackWriter) WriteHeader(status int) {
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *trackWriter) Write(p []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap - Исходный ResponseWriter для http.ResponseController
+func (w *trackWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,78 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// Options - Настройки ответов на конкретный запрос. Их устанавливает middleware через WithOptions,
+// а JSON, Error и Respond находят по ResponseWriter, поэтому обработчикам не нужно передавать их явно
+type Options struct {
+	ErrorFormat string // Формат ответов с ошибкой: FormatEnvelope или FormatProblem
+	Instance    string // Путь запроса для поля instance в формате problem
+	Pretty      bool   // JSON с отступами для чтения человеком
+	CSV         CSV    // Настройки ответов в text/csv
+}
+
+// CSV - Настройки ответов в text/csv
+type CSV struct {
+	Comma  rune // Разделитель полей
+	Header bool // Первая строка - имена столбцов
+}
+
+// OptionsCarrier - ResponseWriter, хранящий настройки ответов. Ищется в том числе под другими обертками с методом Unwrap
+type OptionsCarrier interface {
+	ResponseOptions() Options
+}
+
+// defaultCSV - Настройки CSV, если они не заданы для запроса: запятая и строка заголовков
+var defaultCSV = CSV{Comma: ',', Header: true}
+
+// optionsWriter - ResponseWriter с настройками ответов
+type optionsWriter struct {
+	http.ResponseWriter
+	opts Options
+}
+
+// WithOptions - Обертка над w, с которой ответы отправляются с настройками opts
+func WithOptions(w http.ResponseWriter, opts Options) http.ResponseWriter {
+	return &optionsWriter{ResponseWriter: w, opts: opts}
+}
+
+// ResponseOptions - Настройки ответов
+func (w *optionsWriter) ResponseOptions() Options {
+	return w.opts
+}
+
+// Unwrap - Исходный ResponseWriter для http.ResponseController
+func (w *optionsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// OptionsOf - Настройки ответов для w. Ищется ближайшая к обработчику обертка,
+// по умолчанию ошибки отправляются в формате FormatEnvelope, CSV - с настройками defaultCSV
+func OptionsOf(w http.ResponseWriter) Options {
+	for w != nil {
+		if c, ok := w.(OptionsCarrier); ok {
+			return c.ResponseOptions()
+		}
+
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	return Options{ErrorFormat: FormatEnvelope, CSV: defaultCSV}
+}
+
+// indentJSON - JSON с отступами из уже сериализованного data. Если data - не JSON, возвращается как есть
+func indentJSON(data []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, bytes.TrimSpace(data), "", "  "); err != nil {
+		return data
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
@@ -0,0 +1,70 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledBuffer - Буферы больше этого размера не возвращаются в пул: один крупный ответ
+// не должен надолго удерживать память во всех буферах пула
+const maxPooledBuffer = 64 << 10
+
+// encoder - Буфер с привязанным к нему json.Encoder, переиспользуется между запросами
+type encoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var encoders = sync.Pool{New: func() any {
+	e := new(encoder)
+	e.enc = json.NewEncoder(&e.buf)
+	return e
+}}
+
+// getEncoder - Пустой encoder из пула, с отступами при pretty
+func getEncoder(pretty bool) *encoder {
+	e := encoders.Get().(*encoder)
+	if pretty {
+		e.enc.SetIndent("", "  ")
+	} else {
+		e.enc.SetIndent("", "")
+	}
+	return e
+}
+
+// putEncoder - Возврат e в пул. После вызова байты e.buf использовать нельзя
+func putEncoder(e *encoder) {
+	if e.buf.Cap() > maxPooledBuffer {
+		return
+	}
+	e.buf.Reset()
+	encoders.Put(e)
+}
+
+// encode - Сериализация v в JSON, как json.Marshal или json.MarshalIndent при pretty, в буфер e.
+// Результат действителен до putEncoder
+func (e *encoder) encode(v any) ([]byte, error) {
+	e.buf.Reset()
+	if err := e.enc.Encode(v); err != nil {
+		return nil, err
+	}
+	// Encode добавляет перевод строки, которого нет в ответах json.Marshal
+	return bytes.TrimSuffix(e.buf.Bytes(), []byte{'\n'}), nil
+}
+
+var buffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// getBuffer - Пустой буфер из пула
+func getBuffer() *bytes.Buffer {
+	return buffers.Get().(*bytes.Buffer)
+}
+
+// putBuffer - Возврат buf в пул. После вызова байты buf использовать нельзя
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	buffers.Put(buf)
+}
@@ -0,0 +1,57 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Форматы ответов с ошибкой
+const (
+	FormatEnvelope = "envelope" // {"error": "...", "request_id": "..."}
+	FormatProblem  = "problem"  // application/problem+json по RFC 7807
+)
+
+// Problem - Ошибка в формате RFC 7807 (application/problem+json)
+type Problem struct {
+	Type     string `json:"type"`               // URI вида ошибки. about:blank - вид определяется статус кодом
+	Title    string `json:"title"`              // Краткое описание вида ошибки, для about:blank - текст статус кода
+	Status   int    `json:"status"`             // HTTP статус код ответа
+	Detail   string `json:"detail,omitempty"`   // Описание конкретной ошибки
+	Instance string `json:"instance,omitempty"` // Путь запроса, на который получена ошибка
+
+	// Расширения формата: те же поля, что в Body
+	Code      string    `json:"code,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Time      time.Time `json:"time,omitzero"`
+	Data      any       `json:"data,omitempty"`
+	Stack     []string  `json:"stack,omitempty"`
+}
+
+// problem - Отправка ошибки body в формате application/problem+json
+func problem(w http.ResponseWriter, status int, body Body, opts Options) {
+	p := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    body.Error,
+		Instance:  opts.Instance,
+		Code:      body.Code,
+		RequestID: body.RequestID,
+		Time:      body.Time,
+		Data:      body.Data,
+		Stack:     body.Stack,
+	}
+
+	e := getEncoder(opts.Pretty)
+	defer putEncoder(e)
+	data, err := e.encode(p)
+	if err != nil {
+		status = http.StatusInternalServerError
+		data, _ = json.Marshal(Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: err.Error()})
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
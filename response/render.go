@@ -0,0 +1,338 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/derv-dice/go-web-server/logging"
+)
+
+// Renderer - Формат ответа: запись body в w
+type Renderer interface {
+	Render(w io.Writer, body Body) error
+}
+
+// OptionsRenderer - Формат ответа, зависящий от настроек запроса (см. Options). Respond вызывает RenderOptions вместо Render
+type OptionsRenderer interface {
+	Renderer
+	RenderOptions(w io.Writer, body Body, opts Options) error
+}
+
+// RendererFunc - Функция, реализующая Renderer
+type RendererFunc func(w io.Writer, body Body) error
+
+// Render - Вызов f(w, body)
+func (f RendererFunc) Render(w io.Writer, body Body) error {
+	return f(w, body)
+}
+
+// renderers - Форматы ответа по типу содержимого. Первый зарегистрированный (JSON) используется по умолчанию
+var renderers = struct {
+	sync.RWMutex
+	types  []string
+	byType map[string]Renderer
+}{byType: make(map[string]Renderer)}
+
+// Register - Регистрация формата ответа с типом содержимого mediaType, например application/xml.
+// Повторная регистрация заменяет формат
+func Register(mediaType string, r Renderer) {
+	renderers.Lock()
+	defer renderers.Unlock()
+
+	if _, ok := renderers.byType[mediaType]; !ok {
+		renderers.types = append(renderers.types, mediaType)
+	}
+	renderers.byType[mediaType] = r
+}
+
+func init() {
+	Register("application/json", RendererFunc(renderJSON))
+	Register("application/xml", RendererFunc(renderXML))
+	Register("text/xml", RendererFunc(renderXML))
+	Register("text/plain", RendererFunc(renderText))
+	Register("text/csv", csvRenderer{})
+}
+
+// formats - Короткие имена форматов для параметра запроса ?format=, например ?format=csv
+var formats = map[string]string{
+	"json": "application/json",
+	"xml":  "application/xml",
+	"text": "text/plain",
+	"csv":  "text/csv",
+}
+
+// Respond - Отправка клиенту ответа body со статус кодом status в формате, выбранном по заголовку Accept запроса r:
+// JSON, XML, текст, CSV или зарегистрированный через Register. Параметр ?format= с именем формата (json, xml, text, csv)
+// важнее заголовка Accept, например для ссылки на выгрузку в CSV. Другие значения ?format= не учитываются:
+// параметр может принадлежать обработчику, как в /v1/time. Если клиент не принимает ни один из форматов
+// или не прислал Accept, ответ отправляется в JSON. Идентификатор запроса и время заполняются так же, как в JSON,
+// а формат problem+json для ошибок, если он выбран, используется независимо от Accept
+func Respond(w http.ResponseWriter, r *http.Request, status int, body Body) {
+	body = envelope(w, status, body)
+
+	// Формат problem+json для ошибок важнее заголовка Accept: клиент, которому он нужен, ожидает его всегда
+	opts := OptionsOf(w)
+	if body.Error != "" && opts.ErrorFormat == FormatProblem {
+		problem(w, status, body, opts)
+		return
+	}
+
+	mediaType, renderer := negotiate(r.Header.Get("Accept"))
+	if t, rr, ok := lookup(r.URL.Query().Get("format")); ok {
+		mediaType, renderer = t, rr
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	var err error
+	if or, ok := renderer.(OptionsRenderer); ok {
+		err = or.RenderOptions(buf, body, opts)
+	} else {
+		err = renderer.Render(buf, body)
+	}
+	if err != nil {
+		logging.From(r.Context()).Error("response: render failed", "type", mediaType, "error", err)
+		JSON(w, http.StatusInternalServerError, Body{Error: "не удалось сформировать ответ", RequestID: w.Header().Get(RequestIDHeader)})
+		return
+	}
+
+	data := buf.Bytes()
+	if opts.Pretty && isJSON(mediaType) {
+		data = indentJSON(data)
+	}
+
+	h := w.Header()
+	h.Add("Vary", "Accept")
+	if strings.HasPrefix(mediaType, "text/") || mediaType == "application/xml" {
+		mediaType += "; charset=utf-8"
+	}
+	h.Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// renderJSON - JSON с переводом строки в конце, как у json.Encoder
+func renderJSON(w io.Writer, body Body) error {
+	e := getEncoder(false)
+	defer putEncoder(e)
+	if err := e.enc.Encode(body); err != nil {
+		return err
+	}
+	_, err := w.Write(e.buf.Bytes())
+	return err
+}
+
+// isJSON - Тип содержимого application/json или производный от него, например application/problem+json
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// lookup - Формат ответа по короткому имени из параметра ?format=
+func lookup(name string) (string, Renderer, bool) {
+	renderers.RLock()
+	defer renderers.RUnlock()
+
+	mediaType, ok := formats[name]
+	if !ok {
+		return "", nil, false
+	}
+	renderer, ok := renderers.byType[mediaType]
+	return mediaType, renderer, ok
+}
+
+// negotiate - Формат ответа по заголовку Accept. Из подходящих форматов выбирается формат с наибольшим q,
+// при равных q - более конкретный (application/xml важнее application/*), затем зарегистрированный раньше
+func negotiate(accept string) (string, Renderer) {
+	renderers.RLock()
+	defer renderers.RUnlock()
+
+	type candidate struct {
+		mediaType   string
+		q           float64
+		specificity int
+		order       int
+	}
+
+	var best *candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q <= 0 {
+				continue
+			}
+		}
+
+		for i, t := range renderers.types {
+			specificity := matchMediaType(mediaType, t)
+			if specificity < 0 {
+				continue
+			}
+
+			c := candidate{mediaType: t, q: q, specificity: specificity, order: i}
+			if best == nil || c.q > best.q ||
+				(c.q == best.q && (c.specificity > best.specificity || (c.specificity == best.specificity && c.order < best.order))) {
+				best = &c
+			}
+		}
+	}
+
+	if best == nil {
+		t := renderers.types[0]
+		return t, renderers.byType[t]
+	}
+	return best.mediaType, renderers.byType[best.mediaType]
+}
+
+// matchMediaType - Насколько шаблон pattern из Accept подходит типу t: 2 - точное совпадение,
+// 1 - совпадение по type/*, 0 - */*, -1 - не подходит
+func matchMediaType(pattern, t string) int {
+	switch {
+	case pattern == t:
+		return 2
+	case pattern == "*/*":
+		return 0
+	case strings.HasSuffix(pattern, "/*") && strings.HasPrefix(t, strings.TrimSuffix(pattern, "*")):
+		return 1
+	}
+	return -1
+}
+
+// renderText - Ответ в виде текста: строка Data как есть, остальные данные - в JSON с отступами
+func renderText(w io.Writer, body Body) error {
+	if body.Error != "" {
+		io.WriteString(w, "error: "+body.Error+"\n")
+		io.WriteString(w, "code: "+body.Code+"\n")
+		if body.RequestID != "" {
+			io.WriteString(w, "request_id: "+body.RequestID+"\n")
+		}
+		for _, line := range body.Stack {
+			io.WriteString(w, line+"\n")
+		}
+		return nil
+	}
+
+	switch data := body.Data.(type) {
+	case nil:
+		return nil
+	case string:
+		_, err := io.WriteString(w, data+"\n")
+		return err
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	}
+}
+
+// renderXML - Ответ в XML. Структура та же, что в JSON: данные сначала приводятся к виду JSON,
+// поэтому имена полей совпадают с JSON тегами. Элементы массивов записываются в <item>
+func renderXML(w io.Writer, body Body) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err = dec.Decode(&v); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	writeXML(&buf, "response", v)
+	buf.WriteByte('\n')
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// writeXML - Запись значения v, полученного из JSON, в элемент name
+func writeXML(buf *bytes.Buffer, name string, v any) {
+	name = xmlName(name)
+	buf.WriteString("<" + name + ">")
+
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// Поля ответа идут в привычном порядке, данные внутри - по алфавиту
+		sort.Slice(keys, func(i, j int) bool { return fieldOrder(keys[i], keys[j]) })
+		for _, k := range keys {
+			writeXML(buf, k, v[k])
+		}
+	case []any:
+		for _, item := range v {
+			writeXML(buf, "item", item)
+		}
+	case nil:
+	case string:
+		xml.EscapeText(buf, []byte(v))
+	default:
+		xml.EscapeText(buf, []byte(fmtValue(v)))
+	}
+
+	buf.WriteString("</" + name + ">")
+}
+
+// fieldOrder - Порядок полей: сначала поля Body в порядке объявления, остальные по алфавиту
+func fieldOrder(a, b string) bool {
+	order := []string{"data", "error", "code", "meta", "request_id", "time", "stack"}
+	ia, ib := slices.Index(order, a), slices.Index(order, b)
+	switch {
+	case ia >= 0 && ib >= 0:
+		return ia < ib
+	case ia >= 0:
+		return true
+	case ib >= 0:
+		return false
+	}
+	return a < b
+}
+
+// fmtValue - Число или логическое значение из JSON в виде строки
+func fmtValue(v any) string {
+	switch v := v.(type) {
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// xmlName - Имя элемента XML из ключа JSON: недопустимые символы заменяются на "_"
+func xmlName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		valid := r == '_' || r == '-' || r == '.' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') ||
+			('0' <= r && r <= '9') || r > 0x7f
+		if i == 0 && (r == '-' || r == '.' || ('0' <= r && r <= '9')) {
+			valid = false
+		}
+		if !valid {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
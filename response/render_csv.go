@@ -0,0 +1,134 @@
+package response
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+)
+
+// csvRenderer - Ответ в CSV с разделителем и строкой заголовков из настроек запроса (см. Options.CSV)
+type csvRenderer struct{}
+
+// Render - Ответ в CSV с настройками по умолчанию
+func (csvRenderer) Render(w io.Writer, body Body) error {
+	return renderCSV(w, body, defaultCSV)
+}
+
+// RenderOptions - Ответ в CSV с настройками opts.CSV
+func (csvRenderer) RenderOptions(w io.Writer, body Body, opts Options) error {
+	return renderCSV(w, body, opts.CSV)
+}
+
+// renderCSV - Ответ в CSV. Каждый элемент списка Data - строка, поля объекта - столбцы в порядке их появления,
+// вложенные объекты и массивы записываются в ячейку в виде JSON. Значение, которое не является объектом,
+// записывается в столбец value. Ошибка - одна строка со столбцами error, code и request_id
+func renderCSV(w io.Writer, body Body, opts CSV) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = opts.Comma
+
+	var columns []string
+	var rows []map[string]string
+	if body.Error != "" {
+		columns = []string{"error", "code", "request_id"}
+		rows = []map[string]string{{"error": body.Error, "code": body.Code, "request_id": body.RequestID}}
+	} else if body.Data != nil {
+		raw, err := json.Marshal(body.Data)
+		if err != nil {
+			return err
+		}
+		if columns, rows, err = csvTable(raw); err != nil {
+			return err
+		}
+	}
+
+	if opts.Header && len(columns) > 0 {
+		cw.Write(columns)
+	}
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, c := range columns {
+			record[i] = row[c]
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvTable - Столбцы и строки таблицы из JSON значения raw: массива или одного элемента
+func csvTable(raw []byte) ([]string, []map[string]string, error) {
+	var items []json.RawMessage
+	if raw = bytes.TrimSpace(raw); len(raw) > 0 && raw[0] == '[' {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		items = []json.RawMessage{raw}
+	}
+
+	var columns []string
+	seen := make(map[string]bool)
+	rows := make([]map[string]string, 0, len(items))
+	for _, item := range items {
+		keys, values, err := csvObject(item)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		row := make(map[string]string, len(keys))
+		for i, k := range keys {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+			row[k] = csvCell(values[i])
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, nil
+}
+
+// csvObject - Поля JSON объекта raw в порядке записи. Если raw - не объект, это одно поле value
+func csvObject(raw json.RawMessage) ([]string, []json.RawMessage, error) {
+	if raw = bytes.TrimSpace(raw); len(raw) == 0 || raw[0] != '{' {
+		return []string{"value"}, []json.RawMessage{raw}, nil
+	}
+
+	// Порядок полей берется из самого JSON: при разборе в map он был бы потерян
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+
+	var keys []string
+	var values []json.RawMessage
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var v json.RawMessage
+		if err = dec.Decode(&v); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, t.(string))
+		values = append(values, v)
+	}
+	return keys, values, nil
+}
+
+// csvCell - Значение ячейки из JSON значения raw: строка без кавычек, null - пустая ячейка, остальное как есть
+func csvCell(raw json.RawMessage) string {
+	switch {
+	case len(raw) == 0 || string(raw) == "null":
+		return ""
+	case raw[0] == '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s
+		}
+	}
+	return string(raw)
+}
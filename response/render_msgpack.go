@@ -0,0 +1,22 @@
+//go:build msgpack
+
+package response
+
+import (
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Ответы в MessagePack для клиентов, которым компактный бинарный формат удобнее JSON.
+// Имена полей те же, что в JSON
+func init() {
+	render := RendererFunc(func(w io.Writer, body Body) error {
+		enc := msgpack.NewEncoder(w)
+		enc.SetCustomStructTag("json")
+		return enc.Encode(body)
+	})
+	Register("application/msgpack", render)
+	Register("application/x-msgpack", render)
+	formats["msgpack"] = "application/msgpack"
+}
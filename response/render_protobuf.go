@@ -0,0 +1,42 @@
+//go:build protobuf
+
+package response
+
+import (
+	"encoding/json"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Ответы в Protobuf. Схема ответа не зависит от обработчика: тело - сообщение google.protobuf.Struct
+// с теми же полями, что в JSON, поэтому клиенту достаточно стандартного well-known типа
+func init() {
+	render := RendererFunc(func(w io.Writer, body Body) error {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		var fields map[string]any
+		if err = json.Unmarshal(raw, &fields); err != nil {
+			return err
+		}
+
+		msg, err := structpb.NewStruct(fields)
+		if err != nil {
+			return err
+		}
+
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	Register("application/x-protobuf", render)
+	Register("application/protobuf", render)
+	formats["protobuf"] = "application/x-protobuf"
+}
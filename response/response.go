@@ -0,0 +1,94 @@
+// Package response - Общий формат ответов сервера в JSON и других форматах по заголовку Accept
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/derv-dice/go-web-server/apperr"
+)
+
+// RequestIDHeader - Заголовок с идентификатором запроса, по которому ответ можно найти в логах сервера
+const RequestIDHeader = "X-Request-ID"
+
+// Body - структура, описывающая общий ответ сервера на запросы
+type Body struct {
+	Data      any       `json:"data,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Code      string    `json:"code,omitempty"`       // Машиночитаемый код ошибки, например METHOD_NOT_ALLOWED
+	Meta      *Meta     `json:"meta,omitempty"`       // Дополнительные сведения об ответе, задает обработчик
+	RequestID string    `json:"request_id,omitempty"` // Идентификатор запроса, заполняется из заголовка X-Request-ID
+	Time      time.Time `json:"time,omitzero"`        // Время формирования ответа на сервере, заполняется автоматически
+	Stack     []string  `json:"stack,omitempty"`      // Стек вызовов при панике, только в режиме отладки
+}
+
+// Meta - Дополнительные сведения об ответе
+type Meta struct {
+	Pagination *Pagination        `json:"pagination,omitempty"`
+	Timings    map[string]float64 `json:"timings_ms,omitempty"` // Длительность этапов обработки в миллисекундах, например db
+}
+
+// Pagination - Положение страницы в списке
+type Pagination struct {
+	Page    int `json:"page"`     // Номер страницы, начиная с 1
+	PerPage int `json:"per_page"` // Размер страницы
+	Total   int `json:"total"`    // Количество элементов во всем списке
+}
+
+// NewPagination - Pagination для страницы page размером perPage из списка длиной total
+func NewPagination(page, perPage, total int) *Pagination {
+	return &Pagination{Page: page, PerPage: perPage, Total: total}
+}
+
+// Pages - Количество страниц в списке
+func (p Pagination) Pages() int {
+	if p.PerPage <= 0 {
+		return 0
+	}
+	return (p.Total + p.PerPage - 1) / p.PerPage
+}
+
+// envelope - Заполнение служебных полей ответа: идентификатора запроса, времени и кода ошибки по статусу status
+func envelope(w http.ResponseWriter, status int, body Body) Body {
+	if body.Error != "" && body.Code == "" {
+		body.Code = string(apperr.CodeFor(status))
+	}
+	if body.RequestID == "" {
+		body.RequestID = w.Header().Get(RequestIDHeader)
+	}
+	if body.Time.IsZero() {
+		body.Time = time.Now().UTC()
+	}
+	return body
+}
+
+// JSON - Отправка клиенту ответа body в формате JSON со статус кодом status. Идентификатор запроса,
+// время ответа и код ошибки, если он не задан, заполняются автоматически.
+// Ответ с ошибкой отправляется в формате problem+json, если он выбран для w (см. WithOptions)
+func JSON(w http.ResponseWriter, status int, body Body) {
+	body = envelope(w, status, body)
+	opts := OptionsOf(w)
+	if body.Error != "" && opts.ErrorFormat == FormatProblem {
+		problem(w, status, body, opts)
+		return
+	}
+
+	e := getEncoder(opts.Pretty)
+	defer putEncoder(e)
+	data, err := e.encode(body)
+	if err != nil {
+		status = http.StatusInternalServerError
+		data, _ = json.Marshal(Body{Error: err.Error()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// Error - Отправка клиенту ошибки с текстом msg и статус кодом status.
+// Идентификатор запроса берется из заголовка ответа X-Request-ID, если его уже установил middleware
+func Error(w http.ResponseWriter, status int, msg string) {
+	JSON(w, status, Body{Error: msg})
+}
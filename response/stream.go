@@ -0,0 +1,62 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// NDJSONType - Тип содержимого потока JSON значений, по одному в строке
+const NDJSONType = "application/x-ndjson"
+
+// Stream - Потоковая отправка списка в формате NDJSON: каждый элемент - отдельная строка JSON,
+// которая сразу передается клиенту. Список не накапливается в памяти сервера целиком
+//
+//	s := response.NewStream(w, http.StatusOK)
+//	for rows.Next() {
+//		if err := s.Send(row); err != nil {
+//			return err // Клиент отключился
+//		}
+//	}
+//
+// Middleware, накапливающие ответ (ETag, ограничение времени обработки), передают поток клиенту при каждом Flush.
+// request.timeout ограничивает и время всего потока: после него соединение прерывается
+type Stream struct {
+	w   http.ResponseWriter
+	rc  *http.ResponseController
+	enc *json.Encoder
+}
+
+// NewStream - Начало потока NDJSON со статус кодом status: заголовки отправляются сразу.
+// После этого статус и заголовки ответа изменить нельзя
+func NewStream(w http.ResponseWriter, status int) *Stream {
+	h := w.Header()
+	h.Set("Content-Type", NDJSONType)
+	h.Del("Content-Length")
+	// Прокси (например nginx) не должны накапливать поток перед отправкой клиенту
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(status)
+
+	s := &Stream{w: w, rc: http.NewResponseController(w), enc: json.NewEncoder(w)}
+	s.Flush()
+	return s
+}
+
+// Send - Отправка элемента v отдельной строкой. Ошибка означает, что клиент больше не получает ответ
+// или v не сериализуется в JSON, продолжать поток не нужно
+func (s *Stream) Send(v any) error {
+	// Encoder дописывает перевод строки после каждого значения, а в самом JSON без отступов переводов строк нет
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+// Flush - Передача клиенту уже записанных данных. Если ResponseWriter не поддерживает Flush,
+// данные будут отправлены по мере заполнения буфера сервера
+func (s *Stream) Flush() error {
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// constraint - Ограничение на значение параметра пути
+type constraint struct {
+	expr  string // Запись ограничения в шаблоне: имя типа или регулярное выражение
+	match func(value string) bool
+}
+
+// String - Запись ограничения в шаблоне. Для nil - пустая строка
+func (c *constraint) String() string {
+	if c == nil {
+		return ""
+	}
+	return c.expr
+}
+
+// typeConstraints - Ограничения, записываемые именем типа: {id:int}
+var typeConstraints = map[string]func(string) bool{
+	"int": func(v string) bool {
+		_, err := strconv.ParseInt(v, 10, 64)
+		return err == nil
+	},
+	"uint": func(v string) bool {
+		_, err := strconv.ParseUint(v, 10, 64)
+		return err == nil
+	},
+	"uuid":  regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	"alpha": regexp.MustCompile(`^[a-zA-Z]+$`).MatchString,
+	"alnum": regexp.MustCompile(`^[a-zA-Z0-9]+$`).MatchString,
+}
+
+// newConstraint - Разбор ограничения: имя типа из typeConstraints или регулярное выражение
+func newConstraint(expr string) (*constraint, error) {
+	if expr == "" {
+		return nil, fmt.Errorf("пустое ограничение")
+	}
+
+	if match, ok := typeConstraints[expr]; ok {
+		return &constraint{expr: expr, match: match}, nil
+	}
+
+	if strings.Contains(expr, "/") {
+		return nil, fmt.Errorf("регулярное выражение %q не может содержать /", expr)
+	}
+
+	// Выражение должно совпадать с сегментом целиком, а не с его частью
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("регулярное выражение %q: %w", expr, err)
+	}
+
+	return &constraint{expr: expr, match: re.MatchString}, nil
+}
@@ -0,0 +1,119 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Middleware - Обертка над обработчиком, выполняющая код до и/или после него
+type Middleware func(http.Handler) http.Handler
+
+// Chain - Объединение нескольких middleware в один. Порядок выполнения совпадает с порядком перечисления:
+// Chain(a, b, c)(h) эквивалентно a(b(c(h))), то есть a получает запрос первым, а ответ - последним
+func Chain(mw ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		// Первый middleware в списке должен выполняться первым, поэтому оборачивание идет с конца
+		for i := len(mw) - 1; i >= 0; i-- {
+			h = mw[i](h)
+		}
+		return h
+	}
+}
+
+// routes - Регистрация маршрутов с общим префиксом пути и общим набором middleware.
+// Используется и корневым маршрутизатором (пустой префикс, без middleware), и группами
+type routes struct {
+	router     *Router
+	prefix     string
+	middleware []Middleware
+}
+
+// Group - Группа маршрутов с общим префиксом пути и общим набором middleware
+type Group struct {
+	routes
+}
+
+// Group - Создание вложенной группы маршрутов.
+//
+// Шаблоны маршрутов группы дописываются к префиксу: в группе "/api" маршрут "/users" становится "/api/users".
+// Middleware группы оборачивают только ее маршруты (и маршруты вложенных групп) в порядке перечисления:
+// первый в списке выполняется первым. Запросы, для которых маршрут не найден, через middleware группы не проходят
+func (g *routes) Group(prefix string, mw ...Middleware) *Group {
+	if !strings.HasPrefix(prefix, "/") {
+		panic(fmt.Sprintf("router: group %q: префикс должен начинаться с /", prefix))
+	}
+
+	return &Group{routes{
+		router:     g.router,
+		prefix:     g.prefix + strings.TrimSuffix(prefix, "/"),
+		middleware: append(g.middleware[:len(g.middleware):len(g.middleware)], mw...),
+	}}
+}
+
+// With - Группа с тем же префиксом и дополнительными middleware, например для одного маршрута:
+//
+//	r.With(requireAuth).GET("/admin", adminHandler)
+func (g *routes) With(mw ...Middleware) *Group {
+	return &Group{routes{
+		router:     g.router,
+		prefix:     g.prefix,
+		middleware: append(g.middleware[:len(g.middleware):len(g.middleware)], mw...),
+	}}
+}
+
+// Handle - Регистрация обработчика для любого метода по шаблону pattern.
+// Middleware mw применяются только к этому маршруту и выполняются после middleware группы
+func (g *routes) Handle(pattern string, h http.Handler, mw ...Middleware) {
+	g.Method(anyMethod, pattern, h, mw...)
+}
+
+// HandleFunc - Регистрация функции-обработчика для любого метода по шаблону pattern
+func (g *routes) HandleFunc(pattern string, h http.HandlerFunc, mw ...Middleware) {
+	g.Handle(pattern, h, mw...)
+}
+
+// GET - Регистрация обработчика метода GET. Он же обрабатывает HEAD, если для HEAD нет отдельного обработчика
+func (g *routes) GET(pattern string, h http.HandlerFunc, mw ...Middleware) {
+	g.Method(http.MethodGet, pattern, h, mw...)
+}
+
+// POST - Регистрация обработчика метода POST
+func (g *routes) POST(pattern string, h http.HandlerFunc, mw ...Middleware) {
+	g.Method(http.MethodPost, pattern, h, mw...)
+}
+
+// PUT - Регистрация обработчика метода PUT
+func (g *routes) PUT(pattern string, h http.HandlerFunc, mw ...Middleware) {
+	g.Method(http.MethodPut, pattern, h, mw...)
+}
+
+// PATCH - Регистрация обработчика метода PATCH
+func (g *routes) PATCH(pattern string, h http.HandlerFunc, mw ...Middleware) {
+	g.Method(http.MethodPatch, pattern, h, mw...)
+}
+
+// DELETE - Регистрация обработчика метода DELETE
+func (g *routes) DELETE(pattern string, h http.HandlerFunc, mw ...Middleware) {
+	g.Method(http.MethodDelete, pattern, h, mw...)
+}
+
+// Method - Регистрация обработчика метода method по шаблону pattern.
+//
+// Запрос проходит через middleware группы, затем через middleware маршрута mw, каждые в порядке перечисления.
+// Некорректный шаблон или повторная регистрация того же метода и шаблона приводят к панике, как и в http.ServeMux
+func (g *routes) Method(method, pattern string, h http.Handler, mw ...Middleware) {
+	if h == nil {
+		panic("router: nil handler for " + g.prefix + pattern)
+	}
+
+	all := append(g.middleware[:len(g.middleware):len(g.middleware)], mw...)
+	h = Chain(all...)(h)
+
+	g.router.add(method, g.prefix+pattern, h)
+	g.router.registry = append(g.router.registry, RouteInfo{
+		Method:     methodName(method),
+		Pattern:    g.prefix + pattern,
+		Middleware: middlewareNames(all),
+	})
+}
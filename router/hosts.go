@@ -0,0 +1,101 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Hosts - Выбор обработчика по заголовку Host запроса (виртуальные хосты). Реализует http.Handler.
+//
+// Имя хоста задается точно (api.example.com) или шаблоном для поддоменов (*.example.com).
+// Точное имя имеет приоритет над шаблоном, более длинный шаблон - над более коротким.
+// Порт в заголовке Host и регистр букв не учитываются
+type Hosts struct {
+	exact    map[string]http.Handler
+	wildcard []wildcardHost
+
+	// Default - Обработчик запросов к незарегистрированным хостам. По умолчанию - http.NotFound
+	Default http.Handler
+}
+
+// wildcardHost - Обработчик для всех поддоменов suffix
+type wildcardHost struct {
+	suffix  string // Вида ".example.com"
+	handler http.Handler
+}
+
+// NewHosts - Создание пустого набора виртуальных хостов
+func NewHosts() *Hosts {
+	return &Hosts{
+		exact:   map[string]http.Handler{},
+		Default: http.HandlerFunc(http.NotFound),
+	}
+}
+
+// Handle - Регистрация обработчика для хоста host. Повторная регистрация хоста приводит к панике
+func (hs *Hosts) Handle(host string, h http.Handler) {
+	if h == nil {
+		panic("router: nil handler for host " + host)
+	}
+
+	name := normalizeHost(host)
+	if name == "" {
+		panic(fmt.Sprintf("router: host %q: пустое имя хоста", host))
+	}
+
+	if suffix, ok := strings.CutPrefix(name, "*"); ok {
+		if !strings.HasPrefix(suffix, ".") || len(suffix) < 2 {
+			panic(fmt.Sprintf("router: host %q: шаблон должен иметь вид *.example.com", host))
+		}
+		for _, w := range hs.wildcard {
+			if w.suffix == suffix {
+				panic(fmt.Sprintf("router: host %q уже зарегистрирован", host))
+			}
+		}
+
+		// Более длинные суффиксы проверяются раньше: *.api.example.com важнее *.example.com
+		i := 0
+		for i < len(hs.wildcard) && len(hs.wildcard[i].suffix) >= len(suffix) {
+			i++
+		}
+		hs.wildcard = append(hs.wildcard[:i], append([]wildcardHost{{suffix: suffix, handler: h}}, hs.wildcard[i:]...)...)
+		return
+	}
+
+	if _, ok := hs.exact[name]; ok {
+		panic(fmt.Sprintf("router: host %q уже зарегистрирован", host))
+	}
+	hs.exact[name] = h
+}
+
+// ServeHTTP - Вызов обработчика хоста из заголовка Host
+func (hs *Hosts) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hs.handler(r.Host).ServeHTTP(w, r)
+}
+
+// handler - Обработчик для значения заголовка Host
+func (hs *Hosts) handler(host string) http.Handler {
+	name := normalizeHost(host)
+
+	if h, ok := hs.exact[name]; ok {
+		return h
+	}
+
+	for _, w := range hs.wildcard {
+		if strings.HasSuffix(name, w.suffix) {
+			return w.handler
+		}
+	}
+
+	return hs.Default
+}
+
+// normalizeHost - Имя хоста без порта и завершающей точки в нижнем регистре
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,61 @@
+package router
+
+import (
+	"cmp"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// RouteInfo - Описание зарегистрированного маршрута
+type RouteInfo struct {
+	Method     string   `json:"method"`               // HTTP метод, "*" - любой метод
+	Pattern    string   `json:"pattern"`              // Полный шаблон пути, включая префиксы групп
+	Middleware []string `json:"middleware,omitempty"` // Имена middleware маршрута в порядке выполнения
+}
+
+// Routes - Список всех зарегистрированных маршрутов, отсортированный по шаблону и методу
+func (rt *Router) Routes() []RouteInfo {
+	list := slices.Clone(rt.registry)
+	slices.SortFunc(list, func(a, b RouteInfo) int {
+		return cmp.Or(cmp.Compare(a.Pattern, b.Pattern), cmp.Compare(a.Method, b.Method))
+	})
+	return list
+}
+
+// middlewareNames - Имена middleware для описания маршрута
+func middlewareNames(mw []Middleware) []string {
+	names := make([]string, 0, len(mw))
+	for _, m := range mw {
+		names = append(names, middlewareName(m))
+	}
+	return names
+}
+
+// middlewareName - Имя функции middleware без пути пакета, например "main.adminOnly".
+// Для middleware, созданных функцией-конструктором, возвращается имя конструктора: "router.versionHeader"
+func middlewareName(mw Middleware) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(mw).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	// Анонимные функции получают имена вида constructor.func1, constructor.func1.2 или constructor.Constructor.func1
+	parts := strings.Split(name, ".")
+	for len(parts) > 2 && isAnonFuncPart(parts[len(parts)-1]) {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, ".")
+}
+
+// isAnonFuncPart - Часть имени, которую компилятор добавляет анонимным функциям: func1 или 1
+func isAnonFuncPart(s string) bool {
+	s = strings.TrimPrefix(s, "func")
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
@@ -0,0 +1,448 @@
+// Package router - Небольшой маршрутизатор HTTP запросов с параметрами в пути.
+//
+// Шаблон маршрута состоит из сегментов, разделенных "/". Сегмент вида {name} совпадает с любым
+// непустым сегментом пути запроса, его значение доступно обработчику через Param:
+//
+//	r := router.New()
+//	r.HandleFunc("/hello/{name}", func(w http.ResponseWriter, req *http.Request) {
+//		name := router.Param(req, "name")
+//	})
+//
+// Путь, отличающийся от маршрута только завершающим "/", по умолчанию перенаправляется на путь маршрута,
+// см. TrailingSlash.
+//
+// Статические сегменты имеют приоритет над параметрами: при маршрутах /users/me и /users/{id}
+// запрос /users/me попадет в первый из них.
+//
+// Последний сегмент шаблона вида {name...} совпадает с остатком пути, в том числе пустым:
+// маршрут /static/{path...} обрабатывает /static/, /static/css/app.css и т.д.
+//
+// Значение параметра можно ограничить регулярным выражением или типом: {id:[0-9]+}, {id:int}.
+// Если значение не подходит под ограничение, маршрут не совпадает с запросом (404).
+// Доступные типы: int, uint, uuid, alpha, alnum. Регулярное выражение проверяется по сегменту целиком
+// и не может содержать "/".
+//
+// Обработчик регистрируется для конкретного HTTP метода (GET, POST, ...) или для любого метода (Handle).
+// Если путь совпал с маршрутом, а метод - нет, маршрутизатор отвечает 405 Method Not Allowed
+// с заголовком Allow, в котором перечислены поддерживаемые маршрутом методы.
+//
+// Маршруты с общим префиксом и общими middleware объединяются в группы:
+//
+//	api := r.Group("/api", requireAuth)
+//	api.GET("/users/{id}", userHandler) // GET /api/users/{id}, проходит через requireAuth
+//
+// Middleware, через которые проходят все запросы маршрутизатора, добавляются через Use,
+// а несколько middleware объединяются в один через Chain. В обоих случаях первый в списке выполняется первым.
+//
+// Версии API монтируются по префиксу с именем версии и сообщают ее в заголовке ответа API-Version:
+//
+//	v1 := r.Version("v1")
+//	v1.GET("/hello", helloHandler) // GET /v1/hello
+//	r.SetDefaultVersion("v1")      // GET /hello -> 308 /v1/hello
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// anyMethod - Ключ обработчика, зарегистрированного для любого метода
+const anyMethod = ""
+
+// Router - Маршрутизатор запросов. Реализует http.Handler.
+// Методы регистрации маршрутов (GET, Method, Group и т.д.) описаны у routes
+type Router struct {
+	routes
+	tree *node
+
+	use     []Middleware // Middleware всего маршрутизатора, см. Use
+	handler http.Handler // Поиск маршрута, обернутый в middleware из use
+
+	registry []RouteInfo // Все зарегистрированные маршруты, см. Routes
+
+	versions       []string // Зарегистрированные версии API
+	defaultVersion string   // Версия, на которую перенаправляются запросы без версии в пути
+
+	// TrailingSlash - Поведение, когда путь запроса отличается от маршрута только завершающим "/".
+	// По умолчанию - перенаправление на путь маршрута
+	TrailingSlash TrailingSlash
+
+	// NotFound - Обработчик запросов, для которых не найден маршрут. По умолчанию - http.NotFound
+	NotFound http.Handler
+
+	// MethodNotAllowed - Обработчик запросов, путь которых совпал с маршрутом, а метод - нет.
+	// Заголовок Allow к моменту вызова уже установлен. По умолчанию ответ - текст "405 method not allowed"
+	MethodNotAllowed http.Handler
+}
+
+// New - Создание пустого маршрутизатора
+func New() *Router {
+	rt := &Router{
+		tree:     &node{},
+		NotFound: http.HandlerFunc(http.NotFound),
+		MethodNotAllowed: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		}),
+	}
+	rt.routes.router = rt
+	return rt
+}
+
+// add - Добавление обработчика метода method в дерево маршрутов.
+// Некорректный шаблон или повторная регистрация того же метода и шаблона приводят к панике, как и в http.ServeMux
+func (rt *Router) add(method, pattern string, h http.Handler) {
+	if h == nil {
+		panic("router: nil handler for " + pattern)
+	}
+
+	segments, err := parsePattern(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("router: %s: %v", pattern, err))
+	}
+
+	n := rt.tree
+	for _, seg := range segments {
+		if n, err = n.child(seg); err != nil {
+			panic(fmt.Sprintf("router: %s: %v", pattern, err))
+		}
+	}
+
+	if n.handlers == nil {
+		n.handlers = map[string]http.Handler{}
+	}
+	if _, ok := n.handlers[method]; ok {
+		panic(fmt.Sprintf("router: %s %s: маршрут %s уже зарегистрирован", methodName(method), pattern, n.pattern))
+	}
+	n.handlers[method] = h
+	n.pattern = pattern
+}
+
+// Use - Добавление middleware, через которые проходят все запросы к маршрутизатору, в том числе
+// запросы без подходящего маршрута (404) и с неподдерживаемым методом (405).
+// Порядок выполнения совпадает с порядком добавления, как в Chain. Вызывать до начала обработки запросов
+func (rt *Router) Use(mw ...Middleware) {
+	rt.use = append(rt.use, mw...)
+	rt.handler = Chain(rt.use...)(http.HandlerFunc(rt.route))
+}
+
+// ServeHTTP - Обработка запроса: middleware из Use, поиск маршрута и вызов его обработчика
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if rt.handler != nil {
+		rt.handler.ServeHTTP(w, r)
+		return
+	}
+	rt.route(w, r)
+}
+
+// route - Поиск маршрута по пути запроса и вызов его обработчика
+func (rt *Router) route(w http.ResponseWriter, r *http.Request) {
+	n, params := rt.tree.match(splitPath(r.URL.Path), nil)
+	if n == nil {
+		if rt.matchSlash(w, r) {
+			return
+		}
+
+		if target := rt.versionRedirect(r); target != "" {
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+			return
+		}
+
+		rt.NotFound.ServeHTTP(w, r)
+		return
+	}
+
+	rt.serve(w, r, n, params)
+}
+
+// serve - Вызов обработчика метода запроса из найденного узла n или ответ 405
+func (rt *Router) serve(w http.ResponseWriter, r *http.Request, n *node, params []param) {
+	h := n.handler(r.Method)
+	if h == nil {
+		w.Header().Set("Allow", n.allow())
+		rt.MethodNotAllowed.ServeHTTP(w, r)
+		return
+	}
+
+	ctx := r.Context()
+	if len(params) > 0 {
+		ctx = context.WithValue(ctx, paramsKey, params)
+	}
+	if slot, ok := ctx.Value(patternKey).(*atomic.Pointer[string]); ok {
+		slot.Store(&n.pattern)
+	}
+	r = r.WithContext(ctx)
+	r.Pattern = n.pattern
+
+	h.ServeHTTP(w, r)
+}
+
+// methodName - Название метода для сообщений об ошибках
+func methodName(method string) string {
+	if method == anyMethod {
+		return "*"
+	}
+	return method
+}
+
+// ctxKey - Тип ключей контекста пакета, чтобы они не пересекались с ключами других пакетов
+type ctxKey int
+
+const (
+	paramsKey ctxKey = iota
+	patternKey
+)
+
+// TrackPattern - Запрос, после обработки которого можно узнать шаблон совпавшего маршрута, например /files/{id}.
+// Нужен общему middleware, который выполняется раньше поиска маршрута (метрики, трассировка):
+//
+//	r, pattern := router.TrackPattern(r)
+//	next.ServeHTTP(w, r)
+//	route := pattern() // "" - маршрут не найден
+//
+// Внутри обработчика шаблон доступен в r.Pattern. Несколько middleware в одной цепочке получают шаблон из общей ячейки
+func TrackPattern(r *http.Request) (*http.Request, func() string) {
+	// Обработчик может выполняться в другой горутине (см. middleware.Timeout), поэтому запись атомарная
+	slot, ok := r.Context().Value(patternKey).(*atomic.Pointer[string])
+	if !ok {
+		slot = new(atomic.Pointer[string])
+		r = r.WithContext(context.WithValue(r.Context(), patternKey, slot))
+	}
+
+	return r, func() string {
+		if p := slot.Load(); p != nil {
+			return *p
+		}
+		return ""
+	}
+}
+
+// param - Значение параметра пути
+type param struct {
+	name  string
+	value string
+}
+
+// Param - Значение параметра name из шаблона маршрута, совпавшего с запросом.
+// Для параметра, отсутствующего в шаблоне, возвращается пустая строка
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey).([]param)
+	for _, p := range params {
+		if p.name == name {
+			return p.value
+		}
+	}
+	return ""
+}
+
+// segment - Разобранный сегмент шаблона
+type segment struct {
+	value      string      // Статический текст или имя параметра
+	param      bool        // Сегмент вида {name} или {name:constraint}
+	wildcard   bool        // Сегмент вида {name...}, совпадающий с остатком пути
+	constraint *constraint // Ограничение на значение параметра, nil - любое непустое значение
+}
+
+// parsePattern - Разбор шаблона маршрута на сегменты
+func parsePattern(pattern string) ([]segment, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("шаблон должен начинаться с /")
+	}
+
+	names := map[string]bool{}
+	parts := splitPath(pattern)
+	segments := make([]segment, 0, len(parts))
+
+	for i, part := range parts {
+		if !strings.ContainsAny(part, "{}") {
+			segments = append(segments, segment{value: part})
+			continue
+		}
+
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			return nil, fmt.Errorf("параметр %q должен занимать сегмент целиком", part)
+		}
+
+		// Параметр вида {name...} совпадает с остатком пути и может быть только последним сегментом
+		if name, ok := strings.CutSuffix(part[1:len(part)-1], "..."); ok {
+			if name == "" || strings.ContainsAny(name, "{}:") {
+				return nil, fmt.Errorf("некорректный параметр %q", part)
+			}
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("параметр %q должен быть последним сегментом", part)
+			}
+			if names[name] {
+				return nil, fmt.Errorf("повторяющийся параметр %q", name)
+			}
+			segments = append(segments, segment{value: name, param: true, wildcard: true})
+			break
+		}
+
+		// Выражение ограничения может само содержать фигурные скобки, например {code:[0-9]{3}}
+		name, expr, constrained := strings.Cut(part[1:len(part)-1], ":")
+		if name == "" || strings.ContainsAny(name, "{}") {
+			return nil, fmt.Errorf("некорректный параметр %q", part)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("повторяющийся параметр %q", name)
+		}
+		names[name] = true
+
+		seg := segment{value: name, param: true}
+		if constrained {
+			c, err := newConstraint(expr)
+			if err != nil {
+				return nil, fmt.Errorf("параметр %q: %w", name, err)
+			}
+			seg.constraint = c
+		}
+
+		segments = append(segments, seg)
+	}
+
+	return segments, nil
+}
+
+// splitPath - Разбиение пути на сегменты без ведущего "/". Путь "/" соответствует одному пустому сегменту
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+// node - Узел дерева маршрутов, соответствующий одному сегменту пути
+type node struct {
+	static   map[string]*node // Дочерние узлы для статических сегментов
+	params   []*node          // Дочерние узлы для сегментов-параметров: сначала с ограничениями, последним - без
+	wildcard *node            // Дочерний узел для параметра {name...}, совпадающего с остатком пути
+	name     string           // Имя параметра, если узел - параметр
+
+	constraint *constraint // Ограничение на значение параметра, если узел - параметр с ограничением
+
+	handlers map[string]http.Handler // Обработчики маршрута, заканчивающегося в этом узле, по HTTP методам
+	pattern  string                  // Шаблон маршрута, заканчивающегося в этом узле
+}
+
+// handler - Обработчик метода method или nil, если метод маршрутом не поддерживается
+func (n *node) handler(method string) http.Handler {
+	if h, ok := n.handlers[method]; ok {
+		return h
+	}
+	if method == http.MethodHead {
+		if h, ok := n.handlers[http.MethodGet]; ok {
+			return h
+		}
+	}
+	return n.handlers[anyMethod]
+}
+
+// allow - Значение заголовка Allow: методы, поддерживаемые маршрутом
+func (n *node) allow() string {
+	methods := make([]string, 0, len(n.handlers)+1)
+	for m := range n.handlers {
+		methods = append(methods, m)
+	}
+	if _, ok := n.handlers[http.MethodGet]; ok && !slices.Contains(methods, http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+	slices.Sort(methods)
+	return strings.Join(methods, ", ")
+}
+
+// child - Дочерний узел для сегмента seg, создается при отсутствии
+func (n *node) child(seg segment) (*node, error) {
+	if !seg.param {
+		if n.static == nil {
+			n.static = map[string]*node{}
+		}
+		c, ok := n.static[seg.value]
+		if !ok {
+			c = &node{}
+			n.static[seg.value] = c
+		}
+		return c, nil
+	}
+
+	if seg.wildcard {
+		if n.wildcard == nil {
+			n.wildcard = &node{name: seg.value}
+		}
+		if n.wildcard.name != seg.value {
+			return nil, fmt.Errorf("параметр {%s...} конфликтует с параметром {%s...} на той же позиции", seg.value, n.wildcard.name)
+		}
+		return n.wildcard, nil
+	}
+
+	for _, c := range n.params {
+		if c.constraint.String() != seg.constraint.String() {
+			continue
+		}
+		if c.name != seg.value {
+			return nil, fmt.Errorf("параметр {%s} конфликтует с параметром {%s} на той же позиции", seg.value, c.segment())
+		}
+		return c, nil
+	}
+
+	c := &node{name: seg.value, constraint: seg.constraint}
+	if seg.constraint == nil {
+		// Параметр без ограничения совпадает с любым значением, поэтому проверяется последним
+		n.params = append(n.params, c)
+	} else {
+		n.params = slices.Insert(n.params, n.constrainedParams(), c)
+	}
+	return c, nil
+}
+
+// constrainedParams - Количество дочерних параметров с ограничениями
+func (n *node) constrainedParams() int {
+	if len(n.params) > 0 && n.params[len(n.params)-1].constraint == nil {
+		return len(n.params) - 1
+	}
+	return len(n.params)
+}
+
+// segment - Запись параметра в шаблоне для сообщений об ошибках
+func (n *node) segment() string {
+	if n.constraint == nil {
+		return n.name
+	}
+	return n.name + ":" + n.constraint.String()
+}
+
+// match - Поиск узла с обработчиком для оставшихся сегментов пути.
+// Статические сегменты проверяются раньше параметров, при неудаче выполняется возврат к параметру
+func (n *node) match(parts []string, params []param) (*node, []param) {
+	if len(parts) == 0 {
+		if len(n.handlers) == 0 {
+			return nil, nil
+		}
+		return n, params
+	}
+
+	part, rest := parts[0], parts[1:]
+
+	if c, ok := n.static[part]; ok {
+		if found, p := c.match(rest, params); found != nil {
+			return found, p
+		}
+	}
+
+	// Значение, не подходящее под ограничение, не совпадает с параметром: при отсутствии других
+	// подходящих маршрутов клиент получит 404, и обработчику не нужно проверять значение повторно
+	for _, c := range n.params {
+		if part == "" || c.constraint != nil && !c.constraint.match(part) {
+			continue
+		}
+		if found, p := c.match(rest, append(params, param{name: c.name, value: part})); found != nil {
+			return found, p
+		}
+	}
+
+	// Параметр {name...} забирает весь остаток пути, поэтому проверяется последним
+	if c := n.wildcard; c != nil && len(c.handlers) > 0 {
+		return c, append(params, param{name: c.name, value: strings.Join(parts, "/")})
+	}
+
+	return nil, nil
+}
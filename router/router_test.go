@@ -0,0 +1,363 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// routed - Обработчик, отвечающий шаблоном совпавшего маршрута и значениями его параметров: "/users/{id} id=42"
+func routed(w http.ResponseWriter, r *http.Request) {
+	out := []string{r.Pattern}
+	segments, _ := parsePattern(r.Pattern)
+	for _, seg := range segments {
+		if seg.param {
+			out = append(out, seg.value+"="+Param(r, seg.value))
+		}
+	}
+	fmt.Fprint(w, strings.Join(out, " "))
+}
+
+// serve - Ответ h на запрос method path
+func serve(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+// mustPanic - Проверка, что регистрация register завершается паникой с текстом want
+func mustPanic(t *testing.T, want string, register func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		err := recover()
+		if err == nil {
+			t.Fatalf("паники нет, ожидается %q", want)
+		}
+		if !strings.Contains(fmt.Sprint(err), want) {
+			t.Fatalf("паника %q не содержит %q", err, want)
+		}
+	}()
+	register()
+}
+
+func TestRouterMatch(t *testing.T) {
+	r := New()
+	for _, pattern := range []string{
+		"/",
+		"/users/me",
+		"/users/{id}",
+		"/users/{id}/posts/{post}",
+		"/items/{id:int}",
+		"/items/{slug:[a-z-]+}",
+		"/items/{name}",
+		"/codes/{code:[0-9]{3}}",
+		"/uuid/{id:uuid}",
+		"/static/robots.txt",
+		"/static/{path...}",
+		"/files/{id}/raw",
+		"/files/{rest...}",
+	} {
+		r.GET(pattern, routed)
+	}
+	r.POST("/users/{id}", routed)
+	r.HandleFunc("/any", routed)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{name: "корень", path: "/", body: "/"},
+		{name: "статический сегмент важнее параметра", path: "/users/me", body: "/users/me"},
+		{name: "параметр", path: "/users/42", body: "/users/{id} id=42"},
+		{name: "несколько параметров", path: "/users/42/posts/7", body: "/users/{id}/posts/{post} id=42 post=7"},
+		{name: "пустой сегмент не совпадает с параметром", path: "/users//posts/7", status: http.StatusNotFound},
+		{name: "лишний сегмент", path: "/users/42/posts", status: http.StatusNotFound},
+		{name: "ограничение типом", path: "/items/15", body: "/items/{id:int} id=15"},
+		{name: "регулярное выражение после типа", path: "/items/red-car", body: "/items/{slug:[a-z-]+} slug=red-car"},
+		{name: "параметр без ограничения последним", path: "/items/Car_1", body: "/items/{name} name=Car_1"},
+		{name: "выражение с фигурными скобками", path: "/codes/404", body: "/codes/{code:[0-9]{3}} code=404"},
+		{name: "выражение проверяется по сегменту целиком", path: "/codes/4040", status: http.StatusNotFound},
+		{name: "uuid", path: "/uuid/123e4567-e89b-12d3-a456-426614174000", body: "/uuid/{id:uuid} id=123e4567-e89b-12d3-a456-426614174000"},
+		{name: "не uuid", path: "/uuid/123", status: http.StatusNotFound},
+		{name: "остаток пути", path: "/static/css/app.css", body: "/static/{path...} path=css/app.css"},
+		{name: "пустой остаток пути", path: "/static/", body: "/static/{path...} path="},
+		{name: "статический сегмент важнее остатка пути", path: "/static/robots.txt", body: "/static/robots.txt"},
+		{name: "статический сегмент в начале остатка", path: "/static/robots.txt/x", body: "/static/{path...} path=robots.txt/x"},
+		{name: "параметр важнее остатка пути", path: "/files/a/raw", body: "/files/{id}/raw id=a"},
+		{name: "возврат к остатку пути", path: "/files/a/meta", body: "/files/{rest...} rest=a/meta"},
+		{name: "метод маршрута", method: http.MethodPost, path: "/users/42", body: "/users/{id} id=42"},
+		{name: "HEAD обрабатывается GET", method: http.MethodHead, path: "/users/42", body: "/users/{id} id=42"},
+		{name: "любой метод", method: http.MethodDelete, path: "/any", body: "/any"},
+		{name: "неизвестный путь", path: "/unknown", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			status := tt.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			w := serve(r, method, tt.path)
+			if w.Code != status {
+				t.Fatalf("%s %s: статус %d, ожидается %d", method, tt.path, w.Code, status)
+			}
+			if status == http.StatusOK && w.Body.String() != tt.body {
+				t.Fatalf("%s %s: ответ %q, ожидается %q", method, tt.path, w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.GET("/users/{id}", routed)
+	r.POST("/users/{id}", routed)
+	r.DELETE("/users/me", routed)
+
+	w := serve(r, http.MethodPut, "/users/42")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("статус %d, ожидается 405", w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != "GET, HEAD, POST" {
+		t.Fatalf("Allow %q, ожидается %q", got, "GET, HEAD, POST")
+	}
+
+	// Статический маршрут с другим методом не уступает параметру: путь совпал, метод - нет
+	w = serve(r, http.MethodGet, "/users/me")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "DELETE" {
+		t.Fatalf("статус %d, Allow %q, ожидается 405 и DELETE", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestRouterConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		want     string // Часть текста паники
+	}{
+		{name: "повторный маршрут", patterns: []string{"/a/{id}", "/a/{id}"}, want: "уже зарегистрирован"},
+		{name: "разные имена параметра", patterns: []string{"/a/{id}", "/a/{name}"}, want: "конфликтует с параметром {id}"},
+		{name: "разные имена параметра с ограничением", patterns: []string{"/a/{id:int}", "/a/{n:int}"}, want: "конфликтует с параметром {id:int}"},
+		{name: "разные имена остатка пути", patterns: []string{"/a/{path...}", "/a/{rest...}"}, want: "конфликтует с параметром {path...}"},
+		{name: "без ведущего слеша", patterns: []string{"a"}, want: "должен начинаться с /"},
+		{name: "параметр в части сегмента", patterns: []string{"/a/x{id}"}, want: "должен занимать сегмент целиком"},
+		{name: "остаток пути не последним", patterns: []string{"/a/{path...}/b"}, want: "должен быть последним сегментом"},
+		{name: "повторяющийся параметр", patterns: []string{"/a/{id}/{id}"}, want: "повторяющийся параметр"},
+		{name: "пустое имя", patterns: []string{"/a/{}"}, want: "некорректный параметр"},
+		{name: "пустое ограничение", patterns: []string{"/a/{id:}"}, want: "пустое ограничение"},
+		{name: "некорректное выражение", patterns: []string{"/a/{id:[0-9}"}, want: "регулярное выражение"},
+		{name: "слеш в выражении", patterns: []string{"/a/{id:a/b}"}, want: "должен занимать сегмент целиком"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New()
+			mustPanic(t, tt.want, func() {
+				for _, p := range tt.patterns {
+					r.GET(p, routed)
+				}
+			})
+		})
+	}
+
+	// Один и тот же параметр в маршрутах с разными методами и продолжениями - не конфликт
+	r := New()
+	r.GET("/a/{id}", routed)
+	r.POST("/a/{id}", routed)
+	r.GET("/a/{id}/b", routed)
+	r.GET("/a/{id:int}/c", routed)
+	if w := serve(r, http.MethodGet, "/a/1/c"); w.Body.String() != "/a/{id:int}/c id=1" {
+		t.Fatalf("ответ %q", w.Body.String())
+	}
+}
+
+// trace - Middleware, дописывающий name в заголовок ответа X-Trace
+func trace(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRouterGroups(t *testing.T) {
+	r := New()
+	r.Use(trace("root"))
+	api := r.Group("/api/", trace("api"))
+	api.GET("/users/{id}", routed, trace("route"))
+	admin := api.Group("/admin", trace("admin"))
+	admin.GET("/stats", routed)
+	api.With(trace("with")).GET("/ping", routed)
+
+	tests := []struct {
+		path  string
+		body  string
+		trace string
+	}{
+		{path: "/api/users/1", body: "/api/users/{id} id=1", trace: "root,api,route"},
+		{path: "/api/admin/stats", body: "/api/admin/stats", trace: "root,api,admin"},
+		{path: "/api/ping", body: "/api/ping", trace: "root,api,with"},
+		// Запрос без маршрута проходит только через middleware маршрутизатора
+		{path: "/api/unknown", trace: "root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := serve(r, http.MethodGet, tt.path)
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Fatalf("ответ %q, ожидается %q", w.Body.String(), tt.body)
+			}
+			if got := strings.Join(w.Header().Values("X-Trace"), ","); got != tt.trace {
+				t.Fatalf("middleware %q, ожидается %q", got, tt.trace)
+			}
+		})
+	}
+
+	mustPanic(t, "префикс должен начинаться с /", func() { r.Group("api") })
+}
+
+func TestRouterVersions(t *testing.T) {
+	r := New()
+	v1 := r.Version("v1", trace("v1"))
+	v1.GET("/hello", routed)
+	v1.GET("/items/{id}", routed)
+	v2 := r.Version("v2")
+	v2.GET("/hello", routed)
+	r.GET("/healthz", routed)
+	r.SetDefaultVersion("v1")
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		status   int
+		version  string // Ожидаемый заголовок API-Version
+		location string
+	}{
+		{name: "версия в пути", path: "/v1/hello", status: http.StatusOK, version: "v1"},
+		{name: "другая версия", path: "/v2/hello", status: http.StatusOK, version: "v2"},
+		{name: "перенаправление на версию по умолчанию", path: "/hello?x=1", status: http.StatusPermanentRedirect, location: "/v1/hello?x=1"},
+		{name: "перенаправление с параметром", method: http.MethodPost, path: "/items/5", status: http.StatusPermanentRedirect, location: "/v1/items/5"},
+		{name: "маршрут без версии", path: "/healthz", status: http.StatusOK},
+		{name: "нет маршрута в версии по умолчанию", path: "/unknown", status: http.StatusNotFound},
+		// Ответ 405 дает маршрутизатор, middleware версии не выполняются
+		{name: "метод версии", method: http.MethodPost, path: "/v1/hello", status: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			w := serve(r, method, tt.path)
+			if w.Code != tt.status {
+				t.Fatalf("статус %d, ожидается %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get(VersionHeader); got != tt.version {
+				t.Fatalf("%s %q, ожидается %q", VersionHeader, got, tt.version)
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Fatalf("Location %q, ожидается %q", got, tt.location)
+			}
+		})
+	}
+
+	mustPanic(t, "уже зарегистрирована", func() { r.Version("v1") })
+	mustPanic(t, "не может быть пустым", func() { r.Version("v/3") })
+	mustPanic(t, "не зарегистрирована", func() { r.SetDefaultVersion("v9") })
+}
+
+func TestRouterTrailingSlash(t *testing.T) {
+	tests := []struct {
+		mode     TrailingSlash
+		path     string
+		status   int
+		location string
+	}{
+		{mode: TrailingSlashRedirect, path: "/hello/?a=b", status: http.StatusPermanentRedirect, location: "/hello?a=b"},
+		{mode: TrailingSlashRedirect, path: "/dir", status: http.StatusPermanentRedirect, location: "/dir/"},
+		{mode: TrailingSlashMatch, path: "/hello/", status: http.StatusOK},
+		{mode: TrailingSlashMatch, path: "/dir", status: http.StatusOK},
+		{mode: TrailingSlashStrict, path: "/hello/", status: http.StatusNotFound},
+		{mode: TrailingSlashStrict, path: "/dir", status: http.StatusNotFound},
+		{mode: TrailingSlashStrict, path: "/dir/", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", tt.mode, tt.path), func(t *testing.T) {
+			r := New()
+			r.TrailingSlash = tt.mode
+			r.GET("/hello", routed)
+			r.GET("/dir/", routed)
+
+			w := serve(r, http.MethodGet, tt.path)
+			if w.Code != tt.status || w.Header().Get("Location") != tt.location {
+				t.Fatalf("статус %d, Location %q, ожидается %d и %q", w.Code, w.Header().Get("Location"), tt.status, tt.location)
+			}
+		})
+	}
+
+	if _, err := ParseTrailingSlash("always"); err == nil {
+		t.Fatalf("неизвестный режим принят")
+	}
+}
+
+// named - Обработчик, отвечающий именем name
+func named(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, name)
+	})
+}
+
+func TestHosts(t *testing.T) {
+	hs := NewHosts()
+	hs.Handle("api.example.com", named("api"))
+	hs.Handle("*.example.com", named("example"))
+	hs.Handle("*.eu.example.com", named("eu"))
+	hs.Default = named("default")
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "api.example.com", want: "api"},
+		{host: "API.Example.com:8443", want: "api"},
+		{host: "api.example.com.", want: "api"},
+		{host: "www.example.com", want: "example"},
+		{host: "a.b.example.com", want: "example"},
+		{host: "shop.eu.example.com", want: "eu"},
+		{host: "example.com", want: "default"},
+		{host: "other.org", want: "default"},
+		{host: "", want: "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+			hs.ServeHTTP(w, req)
+			if w.Body.String() != tt.want {
+				t.Fatalf("обработчик %q, ожидается %q", w.Body.String(), tt.want)
+			}
+		})
+	}
+
+	mustPanic(t, "уже зарегистрирован", func() { hs.Handle("Api.Example.com", named("x")) })
+	mustPanic(t, "уже зарегистрирован", func() { hs.Handle("*.example.com", named("x")) })
+	mustPanic(t, "шаблон должен иметь вид", func() { hs.Handle("*example.com", named("x")) })
+	mustPanic(t, "пустое имя хоста", func() { hs.Handle("", named("x")) })
+}
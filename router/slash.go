@@ -0,0 +1,75 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TrailingSlash - Поведение маршрутизатора, когда путь запроса отличается от маршрута только завершающим "/"
+type TrailingSlash int
+
+const (
+	// TrailingSlashRedirect - Перенаправление 308 на путь зарегистрированного маршрута: /hello/ -> /hello
+	TrailingSlashRedirect TrailingSlash = iota
+	// TrailingSlashMatch - Запрос обрабатывается маршрутом так же, как если бы путь совпал точно
+	TrailingSlashMatch
+	// TrailingSlashStrict - Пути с завершающим "/" и без него различаются, несовпадение - 404
+	TrailingSlashStrict
+)
+
+// trailingSlashNames - Названия режимов в конфигурации
+var trailingSlashNames = map[string]TrailingSlash{
+	"redirect": TrailingSlashRedirect,
+	"match":    TrailingSlashMatch,
+	"strict":   TrailingSlashStrict,
+}
+
+// ParseTrailingSlash - Режим по названию из конфигурации: redirect, match или strict
+func ParseTrailingSlash(name string) (TrailingSlash, error) {
+	if ts, ok := trailingSlashNames[name]; ok {
+		return ts, nil
+	}
+	return 0, fmt.Errorf("неизвестный режим завершающего слеша %q: ожидается redirect, match или strict", name)
+}
+
+// toggleSlash - Путь с добавленным или удаленным завершающим "/". Для корня "/" - пустая строка
+func toggleSlash(path string) string {
+	switch {
+	case path == "/":
+		return ""
+	case strings.HasSuffix(path, "/"):
+		return strings.TrimSuffix(path, "/")
+	default:
+		return path + "/"
+	}
+}
+
+// matchSlash - Поиск маршрута для пути запроса с измененным завершающим "/" согласно режиму rt.TrailingSlash.
+// Возвращает true, если запрос уже обработан
+func (rt *Router) matchSlash(w http.ResponseWriter, r *http.Request) bool {
+	if rt.TrailingSlash == TrailingSlashStrict {
+		return false
+	}
+
+	alt := toggleSlash(r.URL.Path)
+	if alt == "" {
+		return false
+	}
+
+	n, params := rt.tree.match(splitPath(alt), nil)
+	if n == nil {
+		return false
+	}
+
+	if rt.TrailingSlash == TrailingSlashMatch {
+		rt.serve(w, r, n, params)
+		return true
+	}
+
+	if r.URL.RawQuery != "" {
+		alt += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, alt, http.StatusPermanentRedirect)
+	return true
+}
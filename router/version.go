@@ -0,0 +1,70 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// VersionHeader - Заголовок ответа, в котором сообщается версия API, обработавшая запрос
+const VersionHeader = "API-Version"
+
+// Version - Группа маршрутов версии API name, смонтированная по префиксу "/"+name (например, /v1).
+//
+// Все ответы маршрутов версии содержат заголовок API-Version. Остальные middleware выполняются после его установки
+func (rt *Router) Version(name string, mw ...Middleware) *Group {
+	if name == "" || strings.Contains(name, "/") {
+		panic(fmt.Sprintf("router: version %q: имя версии не может быть пустым или содержать /", name))
+	}
+
+	for _, v := range rt.versions {
+		if v == name {
+			panic(fmt.Sprintf("router: version %q уже зарегистрирована", name))
+		}
+	}
+	rt.versions = append(rt.versions, name)
+
+	return rt.Group("/"+name, append([]Middleware{versionHeader(name)}, mw...)...)
+}
+
+// SetDefaultVersion - Версия API, на которую перенаправляются запросы без версии в пути.
+//
+// Если для пути запроса нет маршрута, но есть маршрут с тем же путем в версии по умолчанию,
+// клиент получает 308 Permanent Redirect на версионный путь: /hello -> /v1/hello.
+// Метод и тело запроса при таком перенаправлении сохраняются
+func (rt *Router) SetDefaultVersion(name string) {
+	for _, v := range rt.versions {
+		if v == name {
+			rt.defaultVersion = name
+			return
+		}
+	}
+	panic(fmt.Sprintf("router: default version %q не зарегистрирована", name))
+}
+
+// versionRedirect - Путь в версии по умолчанию, на который нужно перенаправить запрос, или пустая строка
+func (rt *Router) versionRedirect(r *http.Request) string {
+	if rt.defaultVersion == "" {
+		return ""
+	}
+
+	path := "/" + rt.defaultVersion + r.URL.Path
+	if n, _ := rt.tree.match(splitPath(path), nil); n == nil {
+		return ""
+	}
+
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	return path
+}
+
+// versionHeader - Middleware, добавляющий в ответ заголовок с версией API
+func versionHeader(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(VersionHeader, name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/derv-dice/go-web-server/auth"
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/files"
+	"github.com/derv-dice/go-web-server/health"
+	"github.com/derv-dice/go-web-server/kv"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/middleware"
+	"github.com/derv-dice/go-web-server/proxy"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+	"github.com/derv-dice/go-web-server/static"
+)
+
+// routeSets - Именованные наборы маршрутов. Из них собираются маршрутизаторы виртуальных хостов (router.hosts)
+var routeSets = map[string]func(mux *router.Router, store *config.Store) error{
+	"api":     registerAPI,
+	"auth":    registerAuth,
+	"debug":   registerDebug,
+	"files":   registerFiles,
+	"health":  registerHealth,
+	"kv":      registerKV,
+	"metrics": registerMetrics,
+	"proxy":   registerProxies,
+	"static":  registerStatic,
+	"status":  registerStatus,
+	"version": registerVersion,
+}
+
+// registerAPI - Маршруты публичного API
+func registerAPI(mux *router.Router, store *config.Store) error {
+	apiAuth, err := clientAuth(store)
+	if err != nil {
+		return err
+	}
+
+	signAuth, err := signatureAuth(store)
+	if err != nil {
+		return err
+	}
+
+	// Первая версия API. Запросы без версии в пути (например, /hello) перенаправляются на нее.
+	// Если включена аутентификация по API ключу (auth.api_key), JWT (auth.jwt) или подписи HMAC (auth.signature),
+	// маршруты доступны только с ними. С auth.replay каждый запрос должен содержать уникальный X-Nonce
+	v1 := mux.Version("v1", apiAuth, signAuth, replayGuard(store))
+	mux.SetDefaultVersion("v1")
+
+	// регистрация обработчиков методов GET /v1/hello и GET /v1/hello/{name}
+	hello := requireFeature(store, func(f config.Features) bool { return f.Hello })
+	v1.GET("/hello", helloHandler, hello)
+	v1.GET("/hello/{name}", helloHandler, hello)
+	v1.GET("/time", response.Handle(timeHandler), requireFeature(store, func(f config.Features) bool { return f.Time }))
+	return nil
+}
+
+// registerAuth - Маршруты входа пользователей: регистрация и вход по паролю с токенами обновления (auth.password)
+// и вход через OpenID Connect (auth.oidc). Маршруты каждого способа регистрируются, только если он включен
+func registerAuth(mux *router.Router, store *config.Store) error {
+	cfg := store.Current().Auth
+	a := mux.Group("/auth")
+
+	if cfg.Password.Enabled {
+		password, err := newPasswordAuth(store.Current())
+		if err != nil {
+			return fmt.Errorf("auth.password: %w", err)
+		}
+
+		a.POST("/register", response.Handle(password.Register))
+		a.POST("/login", response.Handle(password.Login))
+		if cfg.Password.Refresh.Enabled {
+			a.POST("/refresh", response.Handle(password.Refresh))
+			a.POST("/revoke", response.Handle(password.Revoke))
+		}
+		if !cfg.OIDC.Enabled {
+			// Вход через OIDC регистрирует свой выход, который удаляет ту же сессию
+			a.POST("/logout", response.Handle(password.Logout))
+		}
+	}
+
+	if !cfg.OIDC.Enabled {
+		return nil
+	}
+
+	oidc, err := auth.NewOIDC(auth.OIDCConfig{
+		Issuer:       cfg.OIDC.Issuer,
+		ClientID:     cfg.OIDC.ClientID,
+		ClientSecret: cfg.OIDC.ClientSecret,
+		RedirectURL:  cfg.OIDC.RedirectURL,
+		Scopes:       cfg.OIDC.Scopes,
+		CookieSecret: []byte(cfg.OIDC.CookieSecret),
+		SessionTTL:   cfg.OIDC.SessionTTL.D(),
+	})
+	if err != nil {
+		return fmt.Errorf("auth.oidc: %w", err)
+	}
+
+	a.GET("/login", oidc.Login)
+	a.GET("/callback", oidc.Callback)
+	a.POST("/logout", oidc.Logout)
+	return nil
+}
+
+// registerHealth - Проверки состояния сервера для Kubernetes: liveness и readiness,
+// в том числе свободного места на дисках из настройки health.disks
+func registerHealth(mux *router.Router, store *config.Store) error {
+	for _, d := range store.Current().Health.Disks {
+		readiness.checks.Register("disk "+d.Path, health.DiskSpace(d.Path, d.MinFreeBytes))
+	}
+
+	mux.GET("/healthz", livenessHandler)
+	mux.GET("/readyz", readiness.readinessHandler(store))
+	return nil
+}
+
+// registerMetrics - Метрики сервера в формате Prometheus
+func registerMetrics(mux *router.Router, store *config.Store) error {
+	mux.Method(http.MethodGet, "/metrics", serverMetrics.Handler())
+	return nil
+}
+
+// registerStatus - Сводное состояние сервера для панелей мониторинга
+func registerStatus(mux *router.Router, store *config.Store) error {
+	mux.GET("/status", statusHandler(store))
+	return nil
+}
+
+// registerVersion - Данные сборки сервера
+func registerVersion(mux *router.Router, store *config.Store) error {
+	mux.GET("/version", versionHandler)
+	return nil
+}
+
+// registerFiles - Загрузка и скачивание файлов. Регистрируется, только если включена секция files.
+// Доступ ограничивается так же, как к API: API ключом или JWT, если они включены, а при auth.jwt.require_scopes -
+// scope files:write и files:read токена
+func registerFiles(mux *router.Router, store *config.Store) error {
+	cfg := store.Current().Files
+	if !cfg.Enabled {
+		return nil
+	}
+
+	storage, err := files.NewDirStorage(cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("files.dir: %w", err)
+	}
+
+	apiAuth, err := clientAuth(store)
+	if err != nil {
+		return err
+	}
+
+	// Уведомления о загрузке из jobs.webhooks отправляются фоновыми задачами, не задерживая ответ
+	hooks := store.Current().Jobs.Webhooks
+	uploaded := func(ctx context.Context, saved []files.Info) {
+		notify(ctx, hooks, config.EventFileUploaded, saved)
+	}
+
+	access := newAccess(store)
+	mux.POST("/upload", files.Upload(storage, cfg.MaxFileBytes, cfg.MaxFiles, uploaded),
+		middleware.MaxBody(cfg.MaxRequestBytes), apiAuth,
+		access.requireScope("files:write"), access.requirePermission("files:upload"))
+	mux.GET("/files/{id}", files.Download(storage), apiAuth,
+		access.requireScope("files:read"), access.requirePermission("files:download"))
+	return nil
+}
+
+// registerKV - REST API хранилища ключ - значение. Регистрируется, только если включена секция kv.
+// POST /kv - несколько изменений в одной транзакции, см. kv.Batch. Доступ ограничивается так же, как к файлам:
+// API ключом или JWT, разрешениями kv:read и kv:write, а при auth.jwt.require_scopes - одноименными scope токена
+func registerKV(mux *router.Router, store *config.Store) error {
+	cfg := store.Current().KV
+	if !cfg.Enabled {
+		return nil
+	}
+
+	apiAuth, err := clientAuth(store)
+	if err != nil {
+		return err
+	}
+
+	access := newAccess(store)
+	read := []router.Middleware{apiAuth, access.requireScope("kv:read"), access.requirePermission("kv:read")}
+	write := []router.Middleware{apiAuth, access.requireScope("kv:write"), access.requirePermission("kv:write")}
+
+	g := mux.Group("/kv")
+	g.GET("", kv.List(keyValues), read...)
+	g.POST("", kv.Batch(keyValues, cfg.MaxValueBytes), write...)
+	g.GET("/{key...}", kv.Get(keyValues), read...)
+	g.PUT("/{key...}", kv.Put(keyValues, cfg.MaxValueBytes), write...)
+	g.DELETE("/{key...}", kv.Delete(keyValues), write...)
+	return nil
+}
+
+// registerDebug - Отладочные маршруты. Доступны только с локального адреса и, если включена
+// Basic аутентификация (auth.basic), только пользователям из ее списка. При включенном auth.rbac
+// пользователю нужны разрешения маршрута
+func registerDebug(mux *router.Router, store *config.Store) error {
+	basic, err := basicAuth(store)
+	if err != nil {
+		return err
+	}
+
+	access := newAccess(store)
+	debug := mux.Group("/debug", adminOnly, basic)
+	debug.GET("/routes", routesHandler(mux),
+		requireFeature(store, func(f config.Features) bool { return f.DebugRoutes }),
+		access.requirePermission("debug:routes"),
+	)
+	debug.HandleFunc("/echo", response.Handle(echoHandler(store)),
+		requireFeature(store, func(f config.Features) bool { return f.DebugEcho }),
+		access.requirePermission("debug:echo"),
+	)
+	return nil
+}
+
+// registerProxies - Маршруты обратного прокси из настроек router.proxies
+func registerProxies(mux *router.Router, store *config.Store) error {
+	for i, p := range store.Current().Router.Proxies {
+		h, err := proxy.New(p, proxyError)
+		if err != nil {
+			return fmt.Errorf("router.proxies[%d]: %w", i, err)
+		}
+
+		// Сервер не готов принимать трафик, пока upstream недоступен
+		readiness.checks.Register("proxy "+p.Upstream, health.CheckerFunc(func(ctx context.Context) error {
+			return proxy.Reachable(ctx, p.Upstream)
+		}))
+
+		// Сам префикс и все пути под ним, для любого метода
+		prefix := strings.TrimSuffix(p.Prefix, "/")
+		if prefix != "" {
+			mux.Handle(prefix, h)
+		}
+		mux.Handle(prefix+"/{path...}", h)
+	}
+	return nil
+}
+
+// registerStatic - Маршруты статических файлов из настроек router.static
+func registerStatic(mux *router.Router, store *config.Store) error {
+	for i, s := range store.Current().Router.Static {
+		h, err := static.New(s, http.HandlerFunc(notFound))
+		if err != nil {
+			return fmt.Errorf("router.static[%d]: %w", i, err)
+		}
+
+		var mw []router.Middleware
+		if s.Cache != nil {
+			mw = append(mw, middleware.Cache(*s.Cache))
+		}
+
+		// Файлы отдаются только на GET и HEAD, остальные методы получают 405
+		mux.GET(strings.TrimSuffix(s.Prefix, "/")+"/{path...}", h.ServeHTTP, mw...)
+	}
+	return nil
+}
+
+// newRouter - Маршрутизатор с наборами маршрутов sets
+func newRouter(cfg config.Router, store *config.Store, sets []string) (*router.Router, error) {
+	mux := router.New()
+	mux.NotFound = http.HandlerFunc(notFound)
+	mux.MethodNotAllowed = http.HandlerFunc(methodNotAllowed)
+
+	ts, err := router.ParseTrailingSlash(cfg.TrailingSlash)
+	if err != nil {
+		return nil, err
+	}
+	mux.TrailingSlash = ts
+
+	for _, name := range sets {
+		register, ok := routeSets[name]
+		if !ok {
+			return nil, fmt.Errorf("неизвестный набор маршрутов %q", name)
+		}
+		if err = register(mux, store); err != nil {
+			return nil, err
+		}
+	}
+
+	return mux, nil
+}
+
+// newHandler - Обработчик всех запросов с учетом виртуальных хостов из настроек router.hosts.
+// Без виртуальных хостов все запросы обслуживает один маршрутизатор с наборами router.default_routes
+func newHandler(cfg config.Router, store *config.Store) (http.Handler, error) {
+	defaultSets := cfg.DefaultRoutes
+	if defaultSets == nil {
+		// Наборы в отсортированном порядке, чтобы маршрутизатор собирался одинаково при каждом запуске
+		for name := range routeSets {
+			defaultSets = append(defaultSets, name)
+		}
+		slices.Sort(defaultSets)
+	}
+
+	defaultMux, err := newRouter(cfg, store, defaultSets)
+	if err != nil {
+		return nil, fmt.Errorf("router.default_routes: %w", err)
+	}
+
+	if len(cfg.Hosts) == 0 {
+		return defaultMux, nil
+	}
+
+	hosts := router.NewHosts()
+	hosts.Default = defaultMux
+
+	for i, vh := range cfg.Hosts {
+		mux, err := newRouter(cfg, store, vh.Routes)
+		if err != nil {
+			return nil, fmt.Errorf("router.hosts[%d].routes: %w", i, err)
+		}
+
+		for _, name := range vh.Names {
+			hosts.Handle(name, mux)
+		}
+	}
+
+	return hosts, nil
+}
+
+// proxyError - Ответ клиенту, когда upstream обратного прокси недоступен
+func proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.From(r.Context()).Error("proxy", "error", err)
+	response.Error(w, http.StatusBadGateway, "upstream недоступен")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/schedule"
+	"github.com/derv-dice/go-web-server/session"
+)
+
+// newScheduler - Задачи по расписанию из scheduler.tasks, если scheduler включен. sessions - хранилище сессий
+// для session_cleanup, handler - обработчик сервера со всеми middleware для cache_warmup.
+// Задачи, которые нужны серверу независимо от scheduler, добавляются в результат отдельно, см. kvCleanup
+func newScheduler(cfg config.Scheduler, sessions session.Store, handler http.Handler) (*schedule.Scheduler, error) {
+	s := schedule.New(cfg.Location())
+	if !cfg.Enabled {
+		return s, nil
+	}
+
+	funcs := map[string]schedule.Func{
+		config.TaskSessionCleanup: func(context.Context) error {
+			// Проверка конфигурации гарантирует хранилище в памяти
+			if m, ok := sessions.(*session.MemoryStore); ok {
+				if n := m.DeleteExpired(); n > 0 {
+					slog.Info("session: expired sessions deleted", "count", n)
+				}
+			}
+			return nil
+		},
+		config.TaskCacheWarmup: func(ctx context.Context) error {
+			return warmup(ctx, handler, cfg.Warmup)
+		},
+	}
+
+	for name, spec := range cfg.Tasks {
+		if err := s.Add(name, spec, funcs[name]); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// warmup - GET путей cfg.Paths через handler: ответы попадают в кэш cache.responses так же,
+// как ответы на запросы клиентов. Запросы идут без cookie и авторизации, поэтому прогреваются
+// только общие для всех клиентов ответы
+func warmup(ctx context.Context, handler http.Handler, cfg config.Warmup) error {
+	host := cfg.Host
+	if host == "" {
+		host = "localhost"
+	}
+
+	var errs []error
+	for _, path := range cfg.Paths {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+path, nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.RemoteAddr = "127.0.0.1:0"
+		r.RequestURI = path
+
+		w := &discardWriter{header: http.Header{}}
+		handler.ServeHTTP(w, r)
+		if w.status != http.StatusOK {
+			errs = append(errs, fmt.Errorf("cache_warmup: GET %s: ответ %d", path, w.status))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// discardWriter - ResponseWriter, который запоминает только статус ответа
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardWriter) Header() http.Header { return w.header }
+
+func (w *discardWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *discardWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(p), nil
+}
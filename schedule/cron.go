@@ -0,0 +1,218 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule - Расписание задачи. Next - ближайший момент выполнения после t, нулевое время - больше не выполняется
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// macros - Сокращения расписаний cron
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field - Допустимые значения поля выражения cron и имена значений
+type field struct {
+	name     string
+	min, max int
+	names    []string // Имена значений начиная с min, например jan для месяца 1
+}
+
+var (
+	minuteField = field{name: "минуты", min: 0, max: 59}
+	hourField   = field{name: "часы", min: 0, max: 23}
+	domField    = field{name: "день месяца", min: 1, max: 31}
+	monthField  = field{name: "месяц", min: 1, max: 12,
+		names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	// 7 - тоже воскресенье, как в большинстве реализаций cron
+	dowField = field{name: "день недели", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// Parse - Расписание из выражения cron из пяти полей: минуты, часы, день месяца, месяц, день недели.
+// Поле - *, число, диапазон a-b, шаг */n или a-b/n, или список таких значений через запятую. Месяцы и дни
+// недели можно указывать именами: jan, mon. Если ограничены и день месяца, и день недели, задача выполняется
+// в дни, подходящие под любое из них. Также допускаются @yearly, @monthly, @weekly, @daily, @hourly
+// и @every <длительность>, например @every 10m, - через равные промежутки от предыдущего выполнения
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || interval < time.Second {
+			return nil, fmt.Errorf("расписание %q: ожидается @every с длительностью не меньше 1s, например @every 10m", spec)
+		}
+		return every(interval), nil
+	}
+	if m, ok := macros[spec]; ok {
+		spec = m
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("расписание %q: ожидается 5 полей (минуты, часы, день месяца, месяц, день недели), получено %d", spec, len(parts))
+	}
+
+	var (
+		c    cron
+		errs []error
+	)
+	for i, f := range []struct {
+		field
+		set *uint64
+	}{{minuteField, &c.minute}, {hourField, &c.hour}, {domField, &c.dom}, {monthField, &c.month}, {dowField, &c.dow}} {
+		set, err := f.parse(strings.ToLower(parts[i]))
+		if err != nil {
+			errs = append(errs, err)
+		}
+		*f.set = set
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("расписание %q: %w", spec, err)
+	}
+
+	// Воскресенье - 0 для time.Weekday
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	c.anyDom = parts[2] == "*"
+	c.anyDow = parts[4] == "*"
+
+	// Например 30 февраля: без проверки задача молча никогда бы не выполнилась
+	if c.Next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return nil, fmt.Errorf("расписание %q: такой даты не бывает", spec)
+	}
+	return &c, nil
+}
+
+// parse - Множество значений поля: бит i - значение i
+func (f field) parse(s string) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(s, ",") {
+		lo, hi, step := f.min, f.max, 1
+
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%s: некорректный шаг %q", f.name, item)
+			}
+			step = n
+		}
+
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(b); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max // a/n - от a до конца диапазона
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s: начало диапазона %q больше конца", f.name, item)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// value - Число или имя значения поля
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if s == name {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s: ожидается значение от %d до %d, получено %q", f.name, f.min, f.max, s)
+	}
+	return v, nil
+}
+
+// cron - Расписание выражения cron: множества подходящих значений полей
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	anyDom, anyDow                bool // Поле * - не ограничивает день
+}
+
+// maxSearch - Насколько далеко Next ищет подходящий момент: 29 февраля бывает раз в четыре года
+const maxSearch = 5
+
+// Next - Время считается в часовом поясе t. Момент выполнения в час, пропущенный при переходе на летнее
+// время, пропускается, а в час, повторенный при переходе обратно, выполняется один раз
+func (c *cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(maxSearch, 0, 0)
+
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !has(c.month, int(m)):
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !c.day(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case !has(c.hour, t.Hour()):
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case !has(c.minute, t.Minute()):
+			// Сразу к следующей подходящей минуте этого часа или к следующему часу
+			next := bits.TrailingZeros64(c.minute >> (t.Minute() + 1))
+			if t.Minute()+1+next > minuteField.max {
+				t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+			} else {
+				t = t.Add(time.Duration(next+1) * time.Minute)
+			}
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// day - День t подходит под день месяца и день недели
+func (c *cron) day(t time.Time) bool {
+	dom, dow := has(c.dom, t.Day()), has(c.dow, int(t.Weekday()))
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	}
+	return dom || dow
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
+
+// every - Расписание @every: через равные промежутки
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Truncate(time.Second).Add(time.Duration(e))
+}
@@ -0,0 +1,96 @@
+// Package schedule - Выполнение задач сервера по расписанию cron: прогрев кэша, удаление истекших данных
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Func - Задача по расписанию. ctx отменяется при остановке сервера
+type Func func(ctx context.Context) error
+
+// task - Зарегистрированная задача
+type task struct {
+	name     string
+	spec     string
+	schedule Schedule
+	fn       Func
+}
+
+// Scheduler - Задачи по расписанию. Одна задача не выполняется параллельно сама с собой: если выполнение
+// длится дольше промежутка до следующего, пропущенные запуски не наверстываются
+type Scheduler struct {
+	loc   *time.Location
+	tasks []task
+}
+
+// New - Пустой набор задач с расписаниями в часовом поясе loc
+func New(loc *time.Location) *Scheduler {
+	return &Scheduler{loc: loc}
+}
+
+// Add - Задача name с расписанием spec, см. Parse. Добавляется до Run
+func (s *Scheduler) Add(name, spec string, fn Func) error {
+	sched, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	s.tasks = append(s.tasks, task{name: name, spec: spec, schedule: sched, fn: fn})
+	return nil
+}
+
+// Run - Выполнение задач по расписанию до отмены ctx. После отмены Run ждет завершения начатых задач
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, t := range s.tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, t)
+		}()
+	}
+	wg.Wait()
+}
+
+// loop - Ожидание и выполнение одной задачи
+func (s *Scheduler) loop(ctx context.Context, t task) {
+	slog.Info("schedule: task registered", "task", t.name, "schedule", t.spec)
+
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	for {
+		next := t.schedule.Next(time.Now().In(s.loc))
+		if next.IsZero() {
+			return
+		}
+
+		timer.Reset(time.Until(next))
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		start := time.Now()
+		if err := safeRun(ctx, t.fn); err != nil {
+			slog.Error("schedule: task failed", "task", t.name, "duration", time.Since(start), "error", err)
+			continue
+		}
+		slog.Debug("schedule: task done", "task", t.name, "duration", time.Since(start))
+	}
+}
+
+// safeRun - Вызов fn с превращением паники в ошибку: паника задачи не должна останавливать сервер
+func safeRun(ctx context.Context, fn Func) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return fn(ctx)
+}
@@ -0,0 +1,104 @@
+// Package secrets - Загрузка секретов из внешних источников: переменных окружения, файлов и HashiCorp Vault.
+//
+// Значение конфигурации ссылается на секрет в виде ${источник:ссылка}, например ${env:JWT_SECRET},
+// ${file:/run/secrets/jwt} или ${vault:secret/data/app#jwt_secret}. Resolver заменяет такие ссылки значениями
+// секретов, поэтому сами секреты не хранятся в файле конфигурации
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Provider - Источник секретов
+type Provider interface {
+	// Secret - Значение секрета по ссылке ref. Формат ссылки зависит от источника
+	Secret(ctx context.Context, ref string) (string, error)
+}
+
+// ProviderFunc - Функция как Provider
+type ProviderFunc func(ctx context.Context, ref string) (string, error)
+
+func (f ProviderFunc) Secret(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+// Env - Секреты из переменных окружения: ref - имя переменной. Пустая переменная считается незаданной
+func Env(getenv func(string) string) Provider {
+	return ProviderFunc(func(_ context.Context, name string) (string, error) {
+		v := getenv(name)
+		if v == "" {
+			return "", fmt.Errorf("переменная окружения %s не задана", name)
+		}
+		return v, nil
+	})
+}
+
+// File - Секреты из файлов: ref - путь к файлу, например секрету Docker или Kubernetes в /run/secrets.
+// Завершающий перевод строки отбрасывается
+func File() Provider {
+	return ProviderFunc(func(_ context.Context, path string) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	})
+}
+
+// reference - Ссылка на секрет: ${источник:ссылка}
+var reference = regexp.MustCompile(`\$\{([a-z]+):([^}]+)\}`)
+
+// Resolver - Подстановка значений секретов вместо ссылок ${источник:ссылка}
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver - Resolver без источников, см. Register
+func NewResolver() *Resolver {
+	return &Resolver{providers: map[string]Provider{}}
+}
+
+// Register - Источник p для ссылок вида ${name:...}
+func (r *Resolver) Register(name string, p Provider) {
+	r.providers[name] = p
+}
+
+// Contains - В строке s есть ссылка на секрет
+func Contains(s string) bool {
+	return reference.MatchString(s)
+}
+
+// Expand - Строка s, в которой все ссылки на секреты заменены их значениями.
+// Ссылка может быть всем значением или его частью, например postgres://app:${env:DB_PASSWORD}@db/app
+func (r *Resolver) Expand(ctx context.Context, s string) (string, error) {
+	if !Contains(s) {
+		return s, nil
+	}
+
+	var errs []error
+	out := reference.ReplaceAllStringFunc(s, func(m string) string {
+		sub := reference.FindStringSubmatch(m)
+		p, ok := r.providers[sub[1]]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: неизвестный источник секретов %q", m, sub[1]))
+			return m
+		}
+
+		v, err := p.Secret(ctx, sub[2])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m, err))
+			return m
+		}
+		return v
+	})
+
+	if err := errors.Join(errs...); err != nil {
+		return "", err
+	}
+	return out, nil
+}
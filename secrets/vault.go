@@ -0,0 +1,121 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultOptions - Подключение к HashiCorp Vault
+type VaultOptions struct {
+	Address   string        // Адрес сервера, например https://vault.example.com:8200
+	Token     string        // Токен доступа
+	Namespace string        // Пространство имен Vault Enterprise, пустая строка - корневое
+	Timeout   time.Duration // Ограничение времени одного запроса к Vault
+}
+
+// Vault - Секреты из движка KV (версии 1 или 2) HashiCorp Vault.
+//
+// Ссылка имеет вид путь#поле, например secret/data/app#jwt_secret для KV версии 2 или secret/app#jwt_secret
+// для версии 1. Каждый путь читается один раз, поэтому несколько полей одного секрета не требуют лишних запросов
+type Vault struct {
+	opts   VaultOptions
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]map[string]any // Прочитанные секреты по пути
+}
+
+// NewVault - Источник секретов из Vault с настройками opts
+func NewVault(opts VaultOptions) *Vault {
+	return &Vault{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		cache:  map[string]map[string]any{},
+	}
+}
+
+func (v *Vault) Secret(ctx context.Context, ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", errors.New("vault: ожидается ссылка вида путь#поле")
+	}
+
+	data, err := v.read(ctx, strings.Trim(path, "/"))
+	if err != nil {
+		return "", err
+	}
+
+	switch value := data[field].(type) {
+	case string:
+		return value, nil
+	case nil:
+		return "", fmt.Errorf("vault: %s: нет поля %q", path, field)
+	default:
+		// Числа и другие значения JSON передаются в том же виде, в котором их вернул Vault
+		raw, err := json.Marshal(value)
+		return string(raw), err
+	}
+}
+
+// read - Поля секрета по пути path
+func (v *Vault) read(ctx context.Context, path string) (map[string]any, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if data, ok := v.cache[path]; ok {
+		return data, nil
+	}
+
+	if v.opts.Address == "" {
+		return nil, errors.New("vault: не задан адрес сервера")
+	}
+	if v.opts.Token == "" {
+		return nil, errors.New("vault: не задан токен доступа")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(v.opts.Address, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.opts.Token)
+	if v.opts.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.opts.Namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Data   map[string]any `json:"data"`
+		Errors []string       `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("vault: %s: некорректный ответ: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(body.Errors) > 0 {
+			return nil, fmt.Errorf("vault: %s: %s: %s", path, resp.Status, strings.Join(body.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault: %s: %s", path, resp.Status)
+	}
+
+	// В KV версии 2 поля секрета вложены в data.data, рядом с data.metadata
+	data := body.Data
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+
+	v.cache[path] = data
+	return data, nil
+}
@@ -0,0 +1,39 @@
+//go:build acme
+
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// newACME - Получение и продление сертификатов по ACME для доменов из cfg.
+//
+// Возвращает настройки TLS, выбирающие сертификат по имени хоста, и обертку для обработчиков обычного HTTP,
+// отвечающую на проверки http-01. Сертификаты выпускаются при первом обращении к домену и продлеваются
+// заранее, до истечения срока
+func newACME(cfg config.ACME) (*tls.Config, func(http.Handler) http.Handler, error) {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cfg.CacheDir),
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Email:      cfg.Email,
+	}
+	if cfg.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.DirectoryURL}
+	}
+
+	// Проверка tls-alpn-01 проходит на порту HTTPS: TLSConfig уже содержит протокол acme-tls/1
+	challenge := func(next http.Handler) http.Handler { return next }
+	if cfg.Challenge == "http-01" {
+		// autocert использует http-01, только если обработчик проверок подключен к обычному HTTP
+		challenge = m.HTTPHandler
+	}
+
+	return m.TLSConfig(), challenge, nil
+}
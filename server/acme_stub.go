@@ -0,0 +1,16 @@
+//go:build !acme
+
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// newACME - Заглушка для сборки без ACME: включение server.tls.acme приводит к ошибке запуска
+func newACME(config.ACME) (*tls.Config, func(http.Handler) http.Handler, error) {
+	return nil, nil, errors.New("acme: сервер собран без поддержки ACME, пересоберите с -tags acme")
+}
@@ -0,0 +1,27 @@
+//go:build http3
+
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3Listener - HTTP/3 (QUIC) поверх UDP на адресе addr с тем же обработчиком, что и у HTTPS
+func newHTTP3Listener(name, addr string, handler http.Handler, tlsConfig *tls.Config) (*listener, error) {
+	srv := &http3.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(tlsConfig.Clone()), // Для QUIC нужен ALPN h3, исходная конфигурация не меняется
+	}
+
+	return &listener{
+		name:     name,
+		addr:     addr,
+		serve:    srv.ListenAndServe,
+		shutdown: srv.Shutdown,
+		close:    srv.Close,
+	}, nil
+}
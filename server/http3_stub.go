@@ -0,0 +1,14 @@
+//go:build !http3
+
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+)
+
+// newHTTP3Listener - Заглушка для сборки без HTTP/3: включение server.tls.http3 приводит к ошибке запуска
+func newHTTP3Listener(string, string, http.Handler, *tls.Config) (*listener, error) {
+	return nil, errors.New("http3: сервер собран без поддержки HTTP/3, пересоберите с -tags http3")
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyHeaderTimeout - Сколько ждать заголовок PROXY protocol после установки соединения
+const proxyHeaderTimeout = 5 * time.Second
+
+// proxySignature - Начало заголовка PROXY protocol v2
+var proxySignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// errNoProxyHeader - Соединение не начинается с заголовка PROXY protocol
+var errNoProxyHeader = errors.New("proxy protocol: нет заголовка PROXY")
+
+// proxyListener - net.Listener, соединения которого начинаются с заголовка PROXY protocol v1 или v2.
+// Адреса клиента и сервера из заголовка возвращают RemoteAddr и LocalAddr соединения
+type proxyListener struct {
+	net.Listener
+}
+
+// Accept - Соединение с отложенным чтением заголовка: заголовок читается в горутине соединения,
+// чтобы медленный клиент не задерживал прием остальных
+func (l proxyListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{Conn: c}, nil
+}
+
+// proxyConn - Соединение с заголовком PROXY protocol
+type proxyConn struct {
+	net.Conn
+
+	once          sync.Once
+	r             *bufio.Reader
+	remote, local net.Addr // Адреса из заголовка, nil - адреса соединения (команда LOCAL, UNKNOWN)
+	err           error
+}
+
+// init - Чтение заголовка при первом обращении. Соединение с некорректным заголовком или без него закрывается
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		c.r = bufio.NewReader(c.Conn)
+		c.remote, c.local, c.err = readProxyHeader(c.r)
+		c.Conn.SetReadDeadline(time.Time{})
+
+		if c.err != nil {
+			c.Conn.Close()
+		}
+	})
+}
+
+func (c *proxyConn) Read(p []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(p)
+}
+
+// RemoteAddr - Адрес клиента из заголовка
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr - Адрес, к которому подключался клиент, из заголовка
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.init()
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readProxyHeader - Разбор заголовка PROXY protocol v1 (текст) или v2 (двоичный)
+func readProxyHeader(r *bufio.Reader) (remote, local net.Addr, err error) {
+	sig, err := r.Peek(len(proxySignature))
+	switch {
+	case err == nil && bytes.Equal(sig, proxySignature):
+		return readProxyV2(r)
+	case len(sig) >= 6 && string(sig[:6]) == "PROXY ":
+		return readProxyV1(r)
+	case err != nil && len(sig) == 0:
+		return nil, nil, err
+	}
+	return nil, nil, errNoProxyHeader
+}
+
+// readProxyV1 - Заголовок v1: PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n, не длиннее 107 байт
+func readProxyV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errors.New("proxy protocol v1: заголовок не завершен")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, fmt.Errorf("proxy protocol v1: некорректный заголовок %q", line)
+	}
+
+	remote, err := parseProxyAddr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	local, err := parseProxyAddr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+	return remote, local, nil
+}
+
+func parseProxyAddr(ip, port string) (net.Addr, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, fmt.Errorf("proxy protocol v1: некорректный адрес %q", ip)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("proxy protocol v1: некорректный порт %q", port)
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(p))), nil
+}
+
+// readProxyV2 - Двоичный заголовок v2: сигнатура, версия и команда, семейство адресов, длина и адреса.
+// Дополнительные поля (TLV) пропускаются
+func readProxyV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, nil, err
+	}
+	if head[12]>>4 != 2 {
+		return nil, nil, fmt.Errorf("proxy protocol v2: неизвестная версия %d", head[12]>>4)
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(head[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, nil, err
+	}
+
+	// Команда LOCAL - соединение от самого балансировщика, например проверка доступности
+	if head[12]&0x0f == 0 {
+		return nil, nil, nil
+	}
+
+	var size int
+	switch head[13] {
+	case 0x11: // TCP over IPv4
+		size = 4
+	case 0x21: // TCP over IPv6
+		size = 16
+	default:
+		// UDP, Unix сокеты и неизвестные семейства: адреса соединения не заменяются
+		return nil, nil, nil
+	}
+	if len(body) < 2*size+4 {
+		return nil, nil, errors.New("proxy protocol v2: заголовок короче адресов")
+	}
+
+	src, _ := netip.AddrFromSlice(body[:size])
+	dst, _ := netip.AddrFromSlice(body[size : 2*size])
+	srcPort := binary.BigEndian.Uint16(body[2*size:])
+	dstPort := binary.BigEndian.Uint16(body[2*size+2:])
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(src, srcPort)),
+		net.TCPAddrFromAddrPort(netip.AddrPortFrom(dst, dstPort)), nil
+}
@@ -0,0 +1,304 @@
+// Package server - Сборка и запуск HTTP сервера по конфигурации
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// Server - HTTP сервер, собранный по конфигурации. Слушает один или несколько адресов одновременно
+type Server struct {
+	cfg       config.Server
+	listeners []*listener
+	conns     atomic.Int64 // Открытые соединения HTTP/1.x и HTTP/2 на всех адресах
+}
+
+// listener - Отдельный адрес, на котором сервер принимает запросы
+type listener struct {
+	name string // Имя для логов из конфигурации
+	addr string
+
+	serve    func() error                    // Прием соединений. После shutdown возвращает http.ErrServerClosed
+	shutdown func(ctx context.Context) error // Штатная остановка с ожиданием активных запросов
+	close    func() error                    // Принудительное закрытие всех соединений
+}
+
+// httpListener - listener поверх http.Server. Если у сервера задан TLSConfig, запросы принимаются по HTTPS.
+// proxyProtocol - соединения начинаются с заголовка PROXY protocol
+func httpListener(name string, srv *http.Server, proxyProtocol bool) *listener {
+	return &listener{
+		name: name,
+		addr: srv.Addr,
+		serve: func() error {
+			ln, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				return err
+			}
+			if proxyProtocol {
+				ln = proxyListener{ln}
+			}
+
+			if srv.TLSConfig != nil {
+				// Сертификат уже загружен в TLSConfig, поэтому пути к файлам не передаются
+				return srv.ServeTLS(ln, "", "")
+			}
+			return srv.Serve(ln)
+		},
+		shutdown: srv.Shutdown,
+		close:    srv.Close,
+	}
+}
+
+// New - Создание сервера с заданными настройками и обработчиком запросов.
+//
+// Сервер запускается на всех адресах из cfg.EffectiveListeners(). Все адреса, кроме перенаправляющих
+// на HTTPS, обслуживаются одним и тем же обработчиком handler
+func New(cfg config.Server, handler http.Handler) (*Server, error) {
+	s := &Server{cfg: cfg}
+	list := cfg.EffectiveListeners()
+
+	var (
+		tlsConfig  *tls.Config
+		httpsPort  string // Порт первого адреса с HTTPS, на него перенаправляют адреса с redirect_https
+		http3Port  string // Порт первого адреса с HTTP/3, он сообщается клиентам в заголовке Alt-Svc
+		tcpHandler = handler
+
+		// Обертка обработчиков обычного HTTP, отвечающая на проверки ACME http-01
+		challenge = func(next http.Handler) http.Handler { return next }
+	)
+
+	for _, l := range list {
+		if l.TLS && httpsPort == "" {
+			httpsPort = l.Port()
+		}
+		if l.HTTP3 && http3Port == "" {
+			http3Port = l.Port()
+		}
+	}
+
+	switch {
+	case httpsPort == "":
+		// Адресов с HTTPS нет, сертификат не нужен
+	case cfg.TLS.ACME.Enabled:
+		var err error
+		if tlsConfig, challenge, err = newACME(cfg.TLS.ACME); err != nil {
+			return nil, err
+		}
+		tlsConfig.MinVersion = cfg.TLS.MinTLSVersion()
+	case cfg.TLS.Enabled():
+		cert, err := cfg.TLS.Certificate()
+		if err != nil {
+			return nil, fmt.Errorf("tls: загрузка сертификата: %w", err)
+		}
+
+		tlsConfig = &tls.Config{
+			MinVersion:   cfg.TLS.MinTLSVersion(),
+			Certificates: []tls.Certificate{cert},
+		}
+	}
+
+	if tlsConfig != nil && cfg.TLS.ClientCAFile != "" {
+		pool, err := loadCertPool(cfg.TLS.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: client_ca_file: %w", err)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	if http3Port != "" {
+		// Ответы по TCP сообщают клиенту, что тот же сервер доступен по HTTP/3
+		tcpHandler = altSvc(handler, http3Port)
+	}
+
+	for _, l := range list {
+		switch {
+		case l.Socket != "":
+			s.listeners = append(s.listeners, unixListener(l.Name, l.Socket, l.SocketFileMode(), s.newHTTPServer(l.Socket, handler), l.ProxyProtocol))
+			continue
+		case l.RedirectHTTPS:
+			s.listeners = append(s.listeners, httpListener(l.Name, s.newHTTPServer(l.Addr, challenge(redirectToHTTPS(httpsPort))), l.ProxyProtocol))
+			continue
+		}
+
+		h := tcpHandler
+		if !l.TLS {
+			h = challenge(h)
+		}
+
+		srv := s.newHTTPServer(l.Addr, h)
+		if l.TLS {
+			srv.TLSConfig = listenerTLSConfig(tlsConfig, l)
+		}
+		s.listeners = append(s.listeners, httpListener(l.Name, srv, l.ProxyProtocol))
+
+		if l.HTTP3 {
+			// HTTP/3 слушает UDP на том же адресе, что и HTTPS
+			h3, err := newHTTP3Listener(l.Name+"/h3", l.Addr, handler, srv.TLSConfig)
+			if err != nil {
+				return nil, err
+			}
+			s.listeners = append(s.listeners, h3)
+		}
+	}
+
+	return s, nil
+}
+
+// listenerTLSConfig - Настройки TLS адреса l: общий сертификат и собственная проверка сертификатов клиентов
+func listenerTLSConfig(base *tls.Config, l config.Listener) *tls.Config {
+	mode := l.TLSClientAuth()
+	if mode == tls.NoClientCert {
+		return base
+	}
+
+	c := base.Clone()
+	c.ClientAuth = mode
+	return c
+}
+
+// loadCertPool - Набор сертификатов удостоверяющих центров из PEM файла
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: нет сертификатов в формате PEM", path)
+	}
+	return pool, nil
+}
+
+// newHTTPServer - http.Server с общими для всех адресов настройками
+func (s *Server) newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       s.cfg.ReadTimeout.D(),
+		ReadHeaderTimeout: s.cfg.ReadHeaderTimeout.D(),
+		WriteTimeout:      s.cfg.WriteTimeout.D(),
+		IdleTimeout:       s.cfg.IdleTimeout.D(),
+		MaxHeaderBytes:    s.cfg.MaxHeaderBytes,
+		ConnState:         s.trackConn,
+	}
+}
+
+// trackConn - Подсчет открытых соединений. Соединение, переданное обработчику (WebSocket), перестает учитываться
+func (s *Server) trackConn(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		s.conns.Add(1)
+	case http.StateHijacked, http.StateClosed:
+		s.conns.Add(-1)
+	}
+}
+
+// Connections - Количество открытых соединений на всех адресах, кроме HTTP/3
+func (s *Server) Connections() int64 {
+	return s.conns.Load()
+}
+
+// Run - Запуск сервера до отмены контекста ctx.
+//
+// После отмены контекста сервер перестает принимать новые соединения и ждет завершения активных запросов,
+// но не дольше, чем ShutdownTimeout из конфигурации. Если хотя бы один адрес не удалось запустить,
+// останавливаются все. Возвращает nil, если остановка прошла штатно
+func (s *Server) Run(ctx context.Context) error {
+	errCh := make(chan error, len(s.listeners))
+	for _, l := range s.listeners {
+		go func() {
+			slog.Info("server: listening", "listener", l.name, "addr", l.addr)
+			err := l.serve()
+			if !errors.Is(err, http.ErrServerClosed) {
+				err = fmt.Errorf("%s %s: %w", l.name, l.addr, err)
+			}
+			errCh <- err
+		}()
+	}
+
+	var runErr error
+	pending := len(s.listeners) // Количество адресов, которые еще не вернули результат в errCh
+	select {
+	case runErr = <-errCh:
+		// Один из адресов не запустился или остановился сам - остальные тоже останавливаются
+		pending--
+	case <-ctx.Done():
+	}
+
+	slog.Info("server: shutting down, waiting for active requests", "timeout", s.cfg.ShutdownTimeout.D())
+
+	// Контекст остановки не наследуется от ctx: тот уже отменен
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout.D())
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = []error{runErr}
+	)
+	for _, l := range s.listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.shutdown(shutdownCtx); err != nil {
+				// Не все запросы успели завершиться - оставшиеся соединения закрываются принудительно
+				l.close()
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s shutdown: %w", l.name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// После Shutdown каждый запущенный адрес возвращает http.ErrServerClosed
+	for ; pending > 0; pending-- {
+		if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	slog.Info("server: stopped")
+	return nil
+}
+
+// altSvc - Добавляет в ответы заголовок Alt-Svc, по которому клиент узнает о доступности HTTP/3 на порту port
+func altSvc(next http.Handler, port string) http.Handler {
+	value := fmt.Sprintf(`h3=":%s"; ma=86400`, port)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", value)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// redirectToHTTPS - Обработчик, перенаправляющий запрос на тот же адрес по HTTPS
+func redirectToHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
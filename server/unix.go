@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// unixListener - listener поверх http.Server, принимающий соединения на Unix сокете path.
+// proxyProtocol - соединения начинаются с заголовка PROXY protocol
+func unixListener(name, path string, mode os.FileMode, srv *http.Server, proxyProtocol bool) *listener {
+	return &listener{
+		name: name,
+		addr: path,
+		serve: func() error {
+			ln, err := listenUnix(path, mode)
+			if err != nil {
+				return err
+			}
+			if proxyProtocol {
+				ln = proxyListener{ln}
+			}
+			return srv.Serve(ln)
+		},
+		shutdown: srv.Shutdown,
+		close:    srv.Close,
+	}
+}
+
+// listenUnix - Создание Unix сокета с правами mode.
+//
+// Файл сокета, оставшийся от аварийно завершенного процесса, удаляется. Если сокет занят
+// работающим процессом, возвращается ошибка. Файл сокета удаляется при закрытии listener
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Закрытие listener при остановке сервера удаляет файл сокета
+	ln.(*net.UnixListener).SetUnlinkOnClose(true)
+
+	if err = os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("права на сокет: %w", err)
+	}
+
+	return ln, nil
+}
+
+// removeStaleSocket - Удаление файла сокета, к которому никто не подключен
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Обычный файл или каталог по этому пути удалять нельзя
+	if info.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("%s существует и не является сокетом", path)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var d net.Dialer
+	if conn, err := d.DialContext(ctx, "unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("сокет %s уже используется другим процессом", path)
+	}
+
+	return os.Remove(path)
+}
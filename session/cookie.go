@@ -0,0 +1,96 @@
+package session
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxCookieSize - Браузеры не принимают cookie больше 4 КБ вместе с именем и атрибутами
+const maxCookieSize = 3800
+
+// CookieStore - Хранилище, в котором сессия целиком хранится в cookie клиента.
+//
+// Данные шифруются AES-256-GCM: клиент не может ни прочитать, ни изменить их. Серверу не нужно
+// общее хранилище, но размер сессии ограничен размером cookie, а удалить сессию до истечения срока
+// можно только у самого клиента
+type CookieStore struct {
+	aead cipher.AEAD
+}
+
+// cookiePayload - Содержимое cookie до шифрования
+type cookiePayload struct {
+	Values  map[string]any `json:"v"`
+	Expires int64          `json:"e"` // Unix время истечения сессии
+}
+
+// NewCookieStore - Хранилище с ключом шифрования, полученным из secret. Секрет должен быть не короче 32 байт
+func NewCookieStore(secret []byte) (*CookieStore, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("session: секрет должен быть не короче 32 байт")
+	}
+
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieStore{aead: aead}, nil
+}
+
+func (c *CookieStore) Load(_ context.Context, token string) (map[string]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return nil, errors.New("session: некорректная cookie")
+	}
+
+	nonce, sealed := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errors.New("session: cookie подделана или зашифрована другим ключом")
+	}
+
+	var p cookiePayload
+	if err := json.Unmarshal(plain, &p); err != nil {
+		return nil, fmt.Errorf("session: %w", err)
+	}
+	if time.Now().Unix() > p.Expires {
+		return nil, errors.New("session: сессия истекла")
+	}
+	if p.Values == nil {
+		p.Values = make(map[string]any)
+	}
+	return p.Values, nil
+}
+
+func (c *CookieStore) Save(_ context.Context, _ string, values map[string]any, ttl time.Duration) (string, error) {
+	plain, err := json.Marshal(cookiePayload{Values: values, Expires: time.Now().Add(ttl).Unix()})
+	if err != nil {
+		return "", fmt.Errorf("session: %w", err)
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	_, _ = rand.Read(nonce)
+
+	token := base64.RawURLEncoding.EncodeToString(c.aead.Seal(nonce, nonce, plain, nil))
+	if len(token) > maxCookieSize {
+		return "", fmt.Errorf("session: сессия слишком большая для cookie (%d байт)", len(token))
+	}
+	return token, nil
+}
+
+// Delete - У CookieStore нет серверного состояния: сессия удаляется вместе с cookie
+func (c *CookieStore) Delete(context.Context, string) error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+)
+
+// sweepInterval - Как часто MemoryStore удаляет истекшие сессии
+const sweepInterval = time.Minute
+
+// MemoryStore - Серверное хранилище сессий в памяти процесса. В cookie хранится только случайный идентификатор.
+// Сессии теряются при перезапуске и не разделяются между несколькими экземплярами сервера
+type MemoryStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	values  map[string]any
+	expires time.Time
+}
+
+// NewMemoryStore - Пустое хранилище сессий в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memoryEntry)}
+}
+
+func (m *MemoryStore) Load(_ context.Context, token string) (map[string]any, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.sessions[token]
+	if !ok || time.Now().After(e.expires) {
+		return nil, errors.New("session: сессия не найдена")
+	}
+	return maps.Clone(e.values), nil
+}
+
+func (m *MemoryStore) Save(_ context.Context, token string, values map[string]any, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.deleteExpired(now)
+	}
+
+	if _, ok := m.sessions[token]; !ok {
+		// Новый идентификатор, даже если клиент прислал существующий, но уже удаленный
+		token = newToken()
+	}
+	m.sessions[token] = memoryEntry{values: values, expires: now.Add(ttl)}
+	return token, nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, token)
+	return nil
+}
+
+// DeleteExpired - Удаление истекших сессий, возвращает их число. Save удаляет их и сам, но только
+// при сохранении сессий: без новых запросов память не освобождается
+func (m *MemoryStore) DeleteExpired() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteExpired(time.Now())
+}
+
+// deleteExpired - Удаление сессий, истекших к now, под m.mu
+func (m *MemoryStore) deleteExpired(now time.Time) int {
+	n := len(m.sessions)
+	maps.DeleteFunc(m.sessions, func(_ string, e memoryEntry) bool { return now.After(e.expires) })
+	m.lastSweep = now
+	return n - len(m.sessions)
+}
+
+// newToken - Случайный идентификатор сессии из 32 байт
+func newToken() string {
+	var b [32]byte
+	_, _ = rand.Read(b[:])
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
@@ -0,0 +1,220 @@
+// Package session - Сессии пользователей: значения, которые сохраняются между запросами одного клиента
+package session
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/derv-dice/go-web-server/cookies"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// Store - Хранилище сессий.
+//
+// token - значение cookie сессии: у CookieStore это сами данные сессии в зашифрованном виде,
+// у серверных хранилищ (MemoryStore) - идентификатор записи
+type Store interface {
+	// Load - Значения сессии token. Ошибка, если сессия не найдена, истекла или cookie подделана
+	Load(ctx context.Context, token string) (map[string]any, error)
+	// Save - Сохранение значений сессии на время ttl. Возвращает новое значение cookie.
+	// Пустой token означает новую сессию
+	Save(ctx context.Context, token string, values map[string]any, ttl time.Duration) (string, error)
+	// Delete - Удаление сессии token
+	Delete(ctx context.Context, token string) error
+}
+
+// Options - Параметры cookie сессии
+type Options struct {
+	CookieName string        // Имя cookie, по умолчанию session
+	TTL        time.Duration // Время жизни сессии с момента последнего изменения
+	Secure     bool          // Cookie передается только по HTTPS
+}
+
+// Session - Сессия текущего запроса. Значения сохраняются в JSON, поэтому после загрузки числа
+// становятся float64, а структуры - map[string]any
+type Session struct {
+	mu        sync.Mutex
+	token     string // Значение cookie, с которым пришел запрос
+	values    map[string]any
+	changed   bool
+	renew     bool // Выдать сессии новый идентификатор, см. Renew
+	destroyed bool
+}
+
+// sessionKey - Ключ контекста для *Session
+type sessionKey struct{}
+
+// From - Сессия запроса. nil, если запрос не прошел через Middleware
+func From(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
+
+// Get - Значение key или nil, если его нет
+func (s *Session) Get(key string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+// String - Строковое значение key или пустая строка, если его нет или оно не строка
+func (s *Session) String(key string) string {
+	v, _ := s.Get(key).(string)
+	return v
+}
+
+// Strings - Значение key как список строк. nil, если его нет или оно другого типа
+func (s *Session) Strings(key string) []string {
+	switch v := s.Get(key).(type) {
+	case []string:
+		return v
+	case []any:
+		// Список после загрузки из JSON
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				list = append(list, str)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// Set - Установка значения key. Значение должно сериализоваться в JSON
+func (s *Session) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	s.changed = true
+}
+
+// Delete - Удаление значения key
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.changed = true
+	}
+}
+
+// Renew - Выдача сессии нового идентификатора с сохранением значений. Вызывается при входе пользователя,
+// чтобы идентификатор, известный до входа (например, подброшенный злоумышленником), перестал действовать
+func (s *Session) Renew() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renew = true
+	s.changed = true
+}
+
+// Destroy - Удаление сессии вместе с cookie, например при выходе пользователя
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = make(map[string]any)
+	s.destroyed = true
+}
+
+// Middleware - Middleware, загружающий сессию из cookie и сохраняющий ее изменения перед отправкой ответа.
+// Обработчик получает сессию через From(r.Context())
+func Middleware(store Store, opts Options) router.Middleware {
+	if opts.CookieName == "" {
+		opts.CookieName = "session"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s := &Session{values: make(map[string]any)}
+			if c, err := r.Cookie(opts.CookieName); err == nil {
+				// Недействительная или истекшая сессия заменяется новой пустой
+				if values, err := store.Load(r.Context(), c.Value); err == nil {
+					s.token, s.values = c.Value, values
+				}
+			}
+
+			sw := &sessionWriter{ResponseWriter: w}
+			sw.save = func() { s.save(w, r, store, opts) }
+			defer sw.commit()
+
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), sessionKey{}, s)))
+		})
+	}
+}
+
+// save - Сохранение изменений сессии и установка cookie. Вызывается до отправки заголовков ответа
+func (s *Session) save(w http.ResponseWriter, r *http.Request, store Store, opts Options) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attrs := cookies.Options{MaxAge: opts.TTL, Insecure: !opts.Secure}
+
+	switch {
+	case s.destroyed:
+		if s.token == "" {
+			return
+		}
+		if err := store.Delete(r.Context(), s.token); err != nil {
+			logging.From(r.Context()).Error("session: delete", "error", err)
+		}
+		cookies.Delete(w, opts.CookieName, attrs)
+
+	case s.changed:
+		token := s.token
+		if s.renew && token != "" {
+			if err := store.Delete(r.Context(), token); err != nil {
+				logging.From(r.Context()).Error("session: delete", "error", err)
+			}
+			token = ""
+		}
+
+		newToken, err := store.Save(r.Context(), token, maps.Clone(s.values), opts.TTL)
+		if err != nil {
+			logging.From(r.Context()).Error("session: save", "error", err)
+			return
+		}
+		if err := cookies.Set(w, opts.CookieName, newToken, attrs); err != nil {
+			logging.From(r.Context()).Error("session: save", "error", err)
+		}
+	}
+}
+
+// sessionWriter - ResponseWriter, сохраняющий сессию перед отправкой заголовков, пока еще можно установить cookie
+type sessionWriter struct {
+	http.ResponseWriter
+	save  func()
+	saved bool
+}
+
+func (w *sessionWriter) commit() {
+	if !w.saved {
+		w.saved = true
+		w.save()
+	}
+}
+
+func (w *sessionWriter) WriteHeader(status int) {
+	w.commit()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *sessionWriter) Write(p []byte) (int, error) {
+	w.commit()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *sessionWriter) Flush() {
+	w.commit()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap - Исходный ResponseWriter для http.ResponseController
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,205 @@
+// Package static - Маршруты, отдающие статические файлы из каталога
+package static
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// indexFile - Файл, который отдается по пути каталога
+const indexFile = "index.html"
+
+// handler - Обработчик файлов одного каталога
+type handler struct {
+	root     http.Dir
+	listing  bool
+	spa      bool
+	exclude  []string // Префиксы путей без подстановки index.html в режиме spa
+	notFound http.Handler
+}
+
+// New - Обработчик, отдающий файлы из каталога cfg.Dir. Путь файла берется из параметра маршрута {path...}.
+//
+// Тип содержимого определяется по расширению файла, а если его нет - по началу содержимого. Запросы с
+// If-Modified-Since и Range обрабатываются http.ServeContent. Скрытые файлы и каталоги (начинающиеся с ".")
+// не отдаются. Если файл не найден, запрос передается в notFound.
+//
+// В режиме cfg.SPA вместо 404 отдается index.html из корня каталога, кроме путей с префиксами из
+// cfg.SPAExclude: например, несуществующие маршруты /api/* по-прежнему получают 404 в формате JSON
+func New(cfg config.Static, notFound http.Handler) (http.Handler, error) {
+	fi, err := os.Stat(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("dir: %w", err)
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("dir: %q не является каталогом", cfg.Dir)
+	}
+
+	if cfg.SPA {
+		if _, err := os.Stat(filepath.Join(cfg.Dir, indexFile)); err != nil {
+			return nil, fmt.Errorf("spa: %w", err)
+		}
+	}
+
+	return &handler{
+		root:     http.Dir(cfg.Dir),
+		listing:  cfg.Listing,
+		spa:      cfg.SPA,
+		exclude:  cfg.SPAExclude,
+		notFound: notFound,
+	}, nil
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + router.Param(r, "path"))
+	if hidden(name) {
+		h.notFound.ServeHTTP(w, r)
+		return
+	}
+
+	f, err := h.root.Open(name)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	if fi.IsDir() {
+		// Относительные ссылки в index.html и в списке файлов работают, только если путь каталога заканчивается на "/"
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, path.Base(r.URL.Path)+"/", http.StatusMovedPermanently)
+			return
+		}
+
+		index, err := h.root.Open(path.Join(name, indexFile))
+		if err == nil {
+			defer index.Close()
+			if ifi, err := index.Stat(); err == nil && !ifi.IsDir() {
+				serveFile(w, r, index, ifi)
+				return
+			}
+		}
+
+		if !h.listing {
+			h.missing(w, r)
+			return
+		}
+		h.list(w, r, f)
+		return
+	}
+
+	serveFile(w, r, f, fi)
+}
+
+// serveFile - Отправка содержимого файла
+func serveFile(w http.ResponseWriter, r *http.Request, f http.File, fi fs.FileInfo) {
+	// Браузер не должен угадывать тип содержимого: загруженный пользователем .txt не выполнится как HTML
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+}
+
+// list - Список файлов каталога dir в виде HTML страницы
+func (h *handler) list(w http.ResponseWriter, r *http.Request, dir http.File) {
+	entries, err := dir.Readdir(-1)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	entries = slices.DeleteFunc(entries, func(fi fs.FileInfo) bool { return strings.HasPrefix(fi.Name(), ".") })
+	slices.SortFunc(entries, func(a, b fs.FileInfo) int { return strings.Compare(a.Name(), b.Name()) })
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	title := html.EscapeString(r.URL.Path)
+	fmt.Fprintf(w, "<!doctype html>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<h1>%s</h1>\n<ul>\n", title, title)
+	for _, fi := range entries {
+		name := fi.Name()
+		if fi.IsDir() {
+			name += "/"
+		}
+		link := url.URL{Path: "./" + name} // "./" - чтобы имя вида a:b не разбиралось как схема URL
+		fmt.Fprintf(w, "<li><a href=\"%s\">%s</a></li>\n", link.String(), html.EscapeString(name))
+	}
+	fmt.Fprint(w, "</ul>\n")
+}
+
+// missing - Ответ на путь, для которого нет файла: index.html в режиме spa или 404
+func (h *handler) missing(w http.ResponseWriter, r *http.Request) {
+	if !h.spa || h.excluded(r.URL.Path) {
+		h.notFound.ServeHTTP(w, r)
+		return
+	}
+
+	// Ошибки здесь не передаются в error, чтобы удаленный index.html не приводил к повторному вызову missing
+	f, err := h.root.Open("/" + indexFile)
+	if err != nil {
+		h.notFound.ServeHTTP(w, r)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		h.notFound.ServeHTTP(w, r)
+		return
+	}
+
+	// index.html ссылается на текущие версии остальных файлов, поэтому браузер проверяет его при каждом запросе
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Del("Expires")
+	serveFile(w, r, f, fi)
+}
+
+// excluded - Путь относится к одному из префиксов spa_exclude
+func (h *handler) excluded(p string) bool {
+	for _, prefix := range h.exclude {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// error - Ответ на ошибку открытия файла: отсутствующий файл и файл без прав на чтение неотличимы для клиента
+func (h *handler) error(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		h.missing(w, r)
+		return
+	}
+	response.Error(w, http.StatusInternalServerError, "ошибка чтения файла")
+}
+
+// hidden - Путь содержит скрытый файл или каталог, например /.git/config
+func hidden(name string) bool {
+	for _, seg := range strings.Split(name, "/") {
+		if strings.HasPrefix(seg, ".") {
+			return true
+		}
+	}
+	return false
+}
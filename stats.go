@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/derv-dice/go-web-server/response"
+	"github.com/derv-dice/go-web-server/server"
+	"github.com/derv-dice/go-web-server/workpool"
+)
+
+// startedAt - Время запуска процесса, от него отсчитывается uptime
+var startedAt = time.Now()
+
+// gcPauses - Сколько последних пауз сборщика мусора выводится в /debug/stats
+const gcPauses = 10
+
+// runtimeStats - Состояние процесса для быстрой диагностики без pprof
+type runtimeStats struct {
+	Uptime      string         `json:"uptime"`
+	StartedAt   time.Time      `json:"started_at"`
+	Goroutines  int            `json:"goroutines"`
+	Connections int64          `json:"connections"` // Открытые соединения основного сервера, кроме HTTP/3
+	CPUs        int            `json:"cpus"`
+	Memory      memStats       `json:"memory"`
+	GC          gcStats        `json:"gc"`
+	WorkPool    workpool.Stats `json:"work_pool"` // Исполнители ресурсоемких обработчиков, см. work_pool
+}
+
+// memStats - Память процесса в байтах (см. runtime.MemStats)
+type memStats struct {
+	Alloc        uint64 `json:"alloc"`       // Занято объектами в куче
+	TotalAlloc   uint64 `json:"total_alloc"` // Выделено в куче за все время работы
+	Sys          uint64 `json:"sys"`         // Получено от операционной системы
+	HeapAlloc    uint64 `json:"heap_alloc"`
+	HeapInuse    uint64 `json:"heap_inuse"`
+	HeapIdle     uint64 `json:"heap_idle"`
+	HeapReleased uint64 `json:"heap_released"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	StackInuse   uint64 `json:"stack_inuse"`
+	Mallocs      uint64 `json:"mallocs"`
+	Frees        uint64 `json:"frees"`
+}
+
+// gcStats - Работа сборщика мусора
+type gcStats struct {
+	NumGC       uint32     `json:"num_gc"`
+	NextGC      uint64     `json:"next_gc"`           // Размер кучи, при котором начнется следующая сборка
+	LastGC      *time.Time `json:"last_gc,omitempty"` // Пусто, если сборок еще не было
+	PauseTotal  string     `json:"pause_total"`       // Суммарное время пауз
+	RecentPause []string   `json:"recent_pauses"`     // Последние паузы, новые первыми
+	CPUFraction float64    `json:"cpu_fraction"`      // Доля процессорного времени на сборку мусора
+}
+
+// statsHandler - Обработчик GET /debug/stats служебного адреса: горутины, память, сборщик мусора,
+// время работы, число открытых соединений сервера srv и загрузка пула исполнителей
+func statsHandler(srv *server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+
+		stats := runtimeStats{
+			Uptime:      time.Since(startedAt).Round(time.Second).String(),
+			StartedAt:   startedAt,
+			Goroutines:  runtime.NumGoroutine(),
+			Connections: srv.Connections(),
+			CPUs:        runtime.NumCPU(),
+			Memory: memStats{
+				Alloc:        ms.Alloc,
+				TotalAlloc:   ms.TotalAlloc,
+				Sys:          ms.Sys,
+				HeapAlloc:    ms.HeapAlloc,
+				HeapInuse:    ms.HeapInuse,
+				HeapIdle:     ms.HeapIdle,
+				HeapReleased: ms.HeapReleased,
+				HeapObjects:  ms.HeapObjects,
+				StackInuse:   ms.StackInuse,
+				Mallocs:      ms.Mallocs,
+				Frees:        ms.Frees,
+			},
+			WorkPool: workPool.Stats(),
+			GC: gcStats{
+				NumGC:       ms.NumGC,
+				NextGC:      ms.NextGC,
+				PauseTotal:  time.Duration(ms.PauseTotalNs).String(),
+				RecentPause: []string{},
+				CPUFraction: ms.GCCPUFraction,
+			},
+		}
+
+		if ms.NumGC > 0 {
+			last := time.Unix(0, int64(ms.LastGC))
+			stats.GC.LastGC = &last
+		}
+		// PauseNs - кольцевой буфер, пауза последней сборки - в PauseNs[(NumGC+255)%256]
+		for i := uint32(0); i < min(ms.NumGC, gcPauses); i++ {
+			pause := ms.PauseNs[(ms.NumGC-1-i)%uint32(len(ms.PauseNs))]
+			stats.GC.RecentPause = append(stats.GC.RecentPause, time.Duration(pause).String())
+		}
+
+		response.JSON(w, http.StatusOK, response.Body{Data: stats})
+	}
+}
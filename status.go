@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/health"
+	"github.com/derv-dice/go-web-server/response"
+)
+
+// serverStatus - Сводное состояние сервера для панелей мониторинга
+type serverStatus struct {
+	Status    string         `json:"status"` // ok, degraded (не прошла проверка зависимости) или not ready
+	Uptime    string         `json:"uptime"`
+	StartedAt time.Time      `json:"started_at"`
+	Build     buildInfo      `json:"build"`
+	Config    configStatus   `json:"config"`
+	Listeners []listenerInfo `json:"listeners"`
+	Health    health.Report  `json:"health"`
+}
+
+// configStatus - Текущий снимок конфигурации: контрольная сумма и время загрузки
+type configStatus struct {
+	Checksum string    `json:"checksum"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// listenerInfo - Адрес, на котором сервер принимает запросы
+type listenerInfo struct {
+	Name          string `json:"name"`
+	Addr          string `json:"addr,omitempty"`
+	Socket        string `json:"socket,omitempty"`
+	TLS           bool   `json:"tls,omitempty"`
+	HTTP3         bool   `json:"http3,omitempty"`
+	RedirectHTTPS bool   `json:"redirect_https,omitempty"`
+	ProxyProtocol bool   `json:"proxy_protocol,omitempty"`
+}
+
+// statusHandler - Обработчик GET /status: время работы, версия сборки, контрольная сумма конфигурации,
+// адреса сервера и результаты проверок зависимостей. Ответ всегда 200, состояние - в поле status:
+// для проверок Kubernetes предназначен /readyz
+func statusHandler(store *config.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := store.Current()
+
+		st := serverStatus{
+			Status:    "ok",
+			Uptime:    time.Since(startedAt).Round(time.Second).String(),
+			StartedAt: startedAt,
+			Build:     currentBuild,
+			Config:    configStatus{Checksum: cfg.Checksum(), LoadedAt: store.LoadedAt()},
+			Health:    readiness.checks.Check(r.Context(), cfg.Health.Timeout.D()),
+		}
+		for _, l := range cfg.Server.EffectiveListeners() {
+			st.Listeners = append(st.Listeners, listenerInfo{
+				Name:          l.Name,
+				Addr:          l.Addr,
+				Socket:        l.Socket,
+				TLS:           l.TLS,
+				HTTP3:         l.HTTP3,
+				RedirectHTTPS: l.RedirectHTTPS,
+				ProxyProtocol: l.ProxyProtocol,
+			})
+		}
+
+		switch {
+		case !readiness.ready.Load():
+			st.Status = "not ready"
+		case !st.Health.OK():
+			st.Status = "degraded"
+		}
+
+		response.Respond(w, r, http.StatusOK, response.Body{Data: st})
+	}
+}
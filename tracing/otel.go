@@ -0,0 +1,140 @@
+//go:build otel
+
+package tracing
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/logging"
+	"github.com/derv-dice/go-web-server/middleware"
+	"github.com/derv-dice/go-web-server/realip"
+	"github.com/derv-dice/go-web-server/router"
+)
+
+// instrumentation - Имя библиотеки инструментирования в span
+const instrumentation = "github.com/derv-dice/go-web-server/tracing"
+
+// enabled - Setup выполнен успешно и трассировка включена
+var enabled atomic.Bool
+
+// propagator - Формат передачи контекста трассировки в заголовках: W3C Trace Context и Baggage
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Setup - Настройка отправки трассировок по OTLP. Возвращает функцию, которая отправляет накопленные span
+// и останавливает отправку, ее нужно вызвать при остановке сервера
+func Setup(ctx context.Context, cfg config.Tracing) (func(context.Context) error, error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	if cfg.Protocol == "grpc" {
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint), otlptracegrpc.WithHeaders(cfg.Headers)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	} else {
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint), otlptracehttp.WithHeaders(cfg.Headers)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Атрибуты из OTEL_RESOURCE_ATTRIBUTES дополняют имя сервиса из конфигурации
+	res, err := resource.New(ctx, resource.WithFromEnv(), resource.WithTelemetrySDK(), resource.WithHost(),
+		resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Решение вызывающего о записи трассировки сохраняется, доля sample_ratio применяется к новым трассировкам
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	enabled.Store(true)
+
+	return provider.Shutdown, nil
+}
+
+// wrap - Span на каждый запрос. Имя span - метод и шаблон маршрута (GET /files/{id}), ответ 5xx отмечается как ошибка
+func wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !enabled.Load() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(instrumentation).Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.URLScheme(scheme(r)),
+				semconv.ServerAddress(r.Host),
+				semconv.UserAgentOriginal(r.UserAgent()),
+				semconv.NetworkPeerAddress(r.RemoteAddr),
+				semconv.ClientAddress(realip.From(r)),
+				attribute.String("http.request_id", middleware.RequestIDFrom(r.Context())),
+			),
+		)
+		defer span.End()
+
+		// Дальше, в том числе на upstream, передается контекст этого span
+		propagator.Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+		sc := span.SpanContext()
+		ctx = logging.With(ctx, logging.From(ctx).With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String()))
+
+		sw := middleware.NewStatusWriter(w)
+		r, pattern := router.TrackPattern(r.WithContext(ctx))
+		next.ServeHTTP(sw, r)
+
+		route := pattern()
+		span.SetName(r.Method + " " + cmp.Or(route, "unmatched"))
+		if route != "" {
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+
+		status := sw.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status), semconv.HTTPResponseBodySize(int(sw.Size())))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// scheme - Схема запроса: https для TLS соединений
+func scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
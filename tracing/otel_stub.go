@@ -0,0 +1,23 @@
+//go:build !otel
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/derv-dice/go-web-server/config"
+)
+
+// Setup - Заглушка для сборки без OpenTelemetry: включение tracing приводит к ошибке запуска
+func Setup(_ context.Context, cfg config.Tracing) (func(context.Context) error, error) {
+	if cfg.Enabled {
+		return nil, errors.New("tracing: сервер собран без поддержки OpenTelemetry, пересоберите с -tags otel")
+	}
+	return func(context.Context) error { return nil }, nil
+}
+
+func wrap(next http.Handler) http.Handler {
+	return next
+}
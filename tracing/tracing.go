@@ -0,0 +1,15 @@
+// Package tracing - Трассировка запросов OpenTelemetry.
+//
+// Для каждого запроса создается span, связанный с трассировкой вызывающего по заголовкам W3C Trace Context
+// (traceparent, tracestate). Контекст трассировки передается дальше в заголовках запроса, в том числе
+// на upstream обратного прокси. Поддержка OpenTelemetry собирается только с -tags otel, без него
+// Middleware ничего не делает, а включенная настройка tracing приводит к ошибке запуска
+package tracing
+
+import "net/http"
+
+// Middleware - Middleware, создающий span для каждого запроса после успешного Setup, иначе ничего не делающий.
+// Должен стоять перед Recovery, чтобы ответ 500 на панику попал в span
+func Middleware(next http.Handler) http.Handler {
+	return wrap(next)
+}
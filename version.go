@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/derv-dice/go-web-server/response"
+)
+
+// Данные сборки. Задаются при сборке через -ldflags, например:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+//
+// Незаданные значения берутся из debug.ReadBuildInfo (версия модуля и данные VCS)
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
+// buildInfo - Версия сервера и окружение сборки
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // Сборка из рабочей копии с незафиксированными изменениями
+	GoVersion string `json:"go_version"`
+}
+
+// currentBuild - Данные сборки: значения из -ldflags, дополненные debug.ReadBuildInfo
+var currentBuild = readBuildInfo()
+
+// readBuildInfo - Сборка buildInfo из -ldflags и debug.ReadBuildInfo
+func readBuildInfo() buildInfo {
+	info := buildInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// versionHandler - Обработчик метода GET /version: версия сервера, коммит, дата сборки и версия Go
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	response.Respond(w, r, http.StatusOK, response.Body{Data: currentBuild})
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"github.com/derv-dice/go-web-server/config"
+	"github.com/derv-dice/go-web-server/workpool"
+)
+
+// workPool - Исполнители ресурсоемких по CPU обработчиков, общие для маршрутов всех виртуальных хостов, см. newWorkPool
+var workPool *workpool.Pool
+
+// newWorkPool - Пул исполнителей по настройкам work_pool
+func newWorkPool(cfg config.WorkPool) *workpool.Pool {
+	return workpool.New(workpool.Options{Size: cfg.Size, QueueSize: cfg.QueueSize, MaxWait: cfg.MaxWait.D()})
+}
@@ -0,0 +1,116 @@
+// Package workpool - Ограниченный пул исполнителей для ресурсоемких по CPU обработчиков (хэширование паролей,
+// обработка изображений, построение отчетов): всплеск тяжелых запросов занимает не больше Size процессоров,
+// а остальные запросы сервера продолжают обрабатываться
+package workpool
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBusy - Все исполнители заняты, а очередь ожидающих заполнена или ожидание превысило Options.MaxWait
+var ErrBusy = errors.New("workpool: нет свободных исполнителей")
+
+// Options - Параметры Pool
+type Options struct {
+	Size      int           // Сколько задач выполняется одновременно, 0 - по числу процессоров (GOMAXPROCS)
+	QueueSize int           // Сколько задач может ждать свободного исполнителя, новые сверх этого сразу получают ErrBusy
+	MaxWait   time.Duration // Сколько задача ждет исполнителя до ErrBusy
+}
+
+// Stats - Состояние пула
+type Stats struct {
+	Size      int   `json:"size"`
+	QueueSize int   `json:"queue_size"`
+	Busy      int   `json:"busy"`      // Выполняются сейчас
+	Waiting   int64 `json:"waiting"`   // Ждут исполнителя
+	Completed int64 `json:"completed"` // Выполнено с запуска
+	Rejected  int64 `json:"rejected"`  // Отклонено с ErrBusy с запуска
+}
+
+// Pool - Пул исполнителей. Задача выполняется в горутине вызывающего, поэтому паника в ней доходит
+// до Recovery обработчика как обычно. nil Pool выполняет задачи сразу, без ограничения
+type Pool struct {
+	slots     chan struct{} // Занятые исполнители
+	queueSize int64
+	maxWait   time.Duration
+
+	waiting   atomic.Int64
+	completed atomic.Int64
+	rejected  atomic.Int64
+}
+
+// New - Пул с параметрами opts
+func New(opts Options) *Pool {
+	size := opts.Size
+	if size <= 0 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	return &Pool{
+		slots:     make(chan struct{}, size),
+		queueSize: int64(opts.QueueSize),
+		maxWait:   opts.MaxWait,
+	}
+}
+
+// Do - Выполнение fn, когда освободится исполнитель. ErrBusy - fn не выполнялась, клиенту стоит
+// повторить запрос позже. Если ctx отменен во время ожидания, fn не выполняется и возвращается ctx.Err()
+func (p *Pool) Do(ctx context.Context, fn func()) error {
+	if p == nil {
+		fn()
+		return nil
+	}
+	if err := p.acquire(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		<-p.slots
+		p.completed.Add(1)
+	}()
+	fn()
+	return nil
+}
+
+// acquire - Ожидание свободного исполнителя
+func (p *Pool) acquire(ctx context.Context) error {
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if p.waiting.Add(1) > p.queueSize {
+		p.waiting.Add(-1)
+		p.rejected.Add(1)
+		return ErrBusy
+	}
+	defer p.waiting.Add(-1)
+
+	timer := time.NewTimer(p.maxWait)
+	defer timer.Stop()
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		p.rejected.Add(1)
+		return ErrBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats - Текущее состояние пула
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Size:      cap(p.slots),
+		QueueSize: int(p.queueSize),
+		Busy:      len(p.slots),
+		Waiting:   p.waiting.Load(),
+		Completed: p.completed.Load(),
+		Rejected:  p.rejected.Load(),
+	}
+}